@@ -0,0 +1,132 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sync"
+)
+
+// Block is a processed block, the consumers save it after the derived state of the block is stored
+type Block struct {
+	Number uint64 `json:"number"`
+	Hash   string `json:"hash"`
+}
+
+// Store keeps the recent processed blocks, the blocks are saved in ascending order but not necessarily contiguous
+type Store interface {
+	Latest() (*Block, error)                 // nil if no block is saved
+	List() ([]Block, error)                  // ascending
+	Save(block Block) error                  // block.Number must be greater than the latest one
+	Rollback(number uint64) ([]Block, error) // removes and returns the blocks after number, descending
+}
+
+const DefaultKeep = 1000
+
+var (
+	ErrNotAfterLatest = errors.New("block is not after the latest block")
+	ErrForkTooDeep    = errors.New("no saved block is on the chain")
+)
+
+// HeaderReader is the subset of core.ChainReader to check the saved blocks
+type HeaderReader interface {
+	GetHeaderByNumber(ctx context.Context, number uint64) (*types.Header, error)
+}
+
+// FindCommonAncestor returns the latest saved block which is still on the chain and the saved blocks after it (descending),
+// the ancestor is nil if nothing is saved, ErrForkTooDeep if none of the saved blocks is on the chain.
+// A saved block is forked only if the chain has another hash or no block (nil header or core.ErrChainDataNotExist) at its number,
+// the other errors are returned, so a failed rpc call is not taken as a fork
+func FindCommonAncestor(ctx context.Context, store Store, chain HeaderReader) (*Block, []Block, error) {
+	list, err := store.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("List err: %s", err.Error())
+	}
+	var forked []Block
+	for i := len(list) - 1; i >= 0; i-- {
+		header, err := chain.GetHeaderByNumber(ctx, list[i].Number)
+		if err != nil && !errors.Is(err, core.ErrChainDataNotExist) {
+			return nil, nil, fmt.Errorf("GetHeaderByNumber err: %s", err.Error())
+		}
+		if err == nil && header != nil && header.Hash.Hex() == list[i].Hash {
+			return &list[i], forked, nil
+		}
+		forked = append(forked, list[i])
+	}
+	if len(list) == 0 {
+		return nil, nil, nil
+	}
+	return nil, forked, ErrForkTooDeep
+}
+
+// RollbackFunc reverts the derived state of the removed blocks (descending), ancestor is the block kept
+type RollbackFunc func(ancestor Block, removed []Block) error
+
+// Tracker records the processed blocks of a consumer and rolls its state back on forks,
+// like DasCore.RollbackDasConfig for the config cells. The outpoints of dascache.DasCache are the inputs
+// of the pending txs rather than the state of blocks, they are released by ttl or ClearOutPoint and not tracked here
+type Tracker struct {
+	lock  sync.Mutex
+	store Store
+	chain HeaderReader
+	hooks []RollbackFunc
+}
+
+func NewTracker(store Store, chain HeaderReader) *Tracker {
+	return &Tracker{store: store, chain: chain}
+}
+
+// OnRollback adds a hook called before the blocks are removed from the store,
+// the rollback is aborted if a hook returns an error
+func (t *Tracker) OnRollback(fn RollbackFunc) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.hooks = append(t.hooks, fn)
+}
+
+func (t *Tracker) Latest() (*Block, error) {
+	return t.store.Latest()
+}
+
+// Advance saves the block after its derived state is stored,
+// it returns false without saving if the parent of header is not the latest block, call Reconcile then
+func (t *Tracker) Advance(header *types.Header) (bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	latest, err := t.store.Latest()
+	if err != nil {
+		return false, fmt.Errorf("Latest err: %s", err.Error())
+	}
+	if latest != nil && latest.Number+1 == header.Number && latest.Hash != header.ParentHash.Hex() {
+		return false, nil
+	}
+	if err := t.store.Save(Block{Number: header.Number, Hash: header.Hash.Hex()}); err != nil {
+		return false, fmt.Errorf("Save err: %s", err.Error())
+	}
+	return true, nil
+}
+
+// Reconcile checks the saved blocks against the chain, like after restart or when Advance detects a fork,
+// and rolls back to the common ancestor. It returns the ancestor, nil if nothing is saved
+func (t *Tracker) Reconcile(ctx context.Context) (*Block, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ancestor, forked, err := FindCommonAncestor(ctx, t.store, t.chain)
+	if err != nil {
+		return nil, err
+	}
+	if ancestor == nil || len(forked) == 0 {
+		return ancestor, nil
+	}
+	for _, fn := range t.hooks {
+		if err := fn(*ancestor, forked); err != nil {
+			return nil, fmt.Errorf("rollback to %d err: %s", ancestor.Number, err.Error())
+		}
+	}
+	if _, err := t.store.Rollback(ancestor.Number); err != nil {
+		return nil, fmt.Errorf("Rollback err: %s", err.Error())
+	}
+	return ancestor, nil
+}
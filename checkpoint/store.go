@@ -0,0 +1,168 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemStore keeps the latest keep blocks in memory
+type MemStore struct {
+	lock   sync.RWMutex
+	keep   int
+	blocks []Block
+}
+
+func NewMemStore(keep int) *MemStore {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	return &MemStore{keep: keep}
+}
+
+func (m *MemStore) Latest() (*Block, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if len(m.blocks) == 0 {
+		return nil, nil
+	}
+	block := m.blocks[len(m.blocks)-1]
+	return &block, nil
+}
+
+func (m *MemStore) List() ([]Block, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]Block{}, m.blocks...), nil
+}
+
+func (m *MemStore) Save(block Block) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	blocks, err := appendBlock(m.blocks, block, m.keep)
+	if err != nil {
+		return err
+	}
+	m.blocks = blocks
+	return nil
+}
+
+func (m *MemStore) Rollback(number uint64) ([]Block, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var removed []Block
+	m.blocks, removed = rollbackBlocks(m.blocks, number)
+	return removed, nil
+}
+
+func appendBlock(blocks []Block, block Block, keep int) ([]Block, error) {
+	if l := len(blocks); l > 0 && block.Number <= blocks[l-1].Number {
+		return nil, fmt.Errorf("%w: %d <= %d", ErrNotAfterLatest, block.Number, blocks[l-1].Number)
+	}
+	blocks = append(blocks, block)
+	if len(blocks) > keep {
+		blocks = append([]Block{}, blocks[len(blocks)-keep:]...)
+	}
+	return blocks, nil
+}
+
+func rollbackBlocks(blocks []Block, number uint64) ([]Block, []Block) {
+	var removed []Block
+	for len(blocks) > 0 && blocks[len(blocks)-1].Number > number {
+		removed = append(removed, blocks[len(blocks)-1])
+		blocks = blocks[:len(blocks)-1]
+	}
+	return blocks, removed
+}
+
+// FileStore is a MemStore written to a json file on every change,
+// the file is replaced by rename so a crash leaves either the old or the new blocks
+type FileStore struct {
+	MemStore
+	path string
+}
+
+func NewFileStore(path string, keep int) (*FileStore, error) {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	f := FileStore{MemStore: MemStore{keep: keep}, path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &f, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("ReadFile err: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &f.blocks); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal err: %s", err.Error())
+	}
+	for i := 1; i < len(f.blocks); i++ {
+		if f.blocks[i].Number <= f.blocks[i-1].Number {
+			return nil, fmt.Errorf("checkpoint file %s is not in ascending order", path)
+		}
+	}
+	if len(f.blocks) > f.keep {
+		f.blocks = f.blocks[len(f.blocks)-f.keep:]
+	}
+	return &f, nil
+}
+
+func (f *FileStore) Save(block Block) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	blocks, err := appendBlock(f.blocks, block, f.keep)
+	if err != nil {
+		return err
+	}
+	if err := f.write(blocks); err != nil {
+		return err
+	}
+	f.blocks = blocks
+	return nil
+}
+
+func (f *FileStore) Rollback(number uint64) ([]Block, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	blocks, removed := rollbackBlocks(append([]Block{}, f.blocks...), number)
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	if err := f.write(blocks); err != nil {
+		return nil, err
+	}
+	f.blocks = blocks
+	return removed, nil
+}
+
+func (f *FileStore) write(blocks []Block) error {
+	if blocks == nil {
+		blocks = []Block{}
+	}
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		return fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("CreateTemp err: %s", err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Write err: %s", err.Error())
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Sync err: %s", err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Close err: %s", err.Error())
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("Rename err: %s", err.Error())
+	}
+	return nil
+}
@@ -68,7 +68,7 @@ func FormatDasChainTypeToCoinType(chainType ChainType) CoinType {
 }
 
 func FormatChainIdToDasChainType(netType DasNetType, chainId ChainId) ChainType {
-	if netType == DasNetTypeMainNet {
+	if IsMainNet(netType) {
 		switch chainId {
 		case ChainIdEthMainNet, ChainIdBscMainNet, ChainIdPolygonMainNet:
 			return ChainTypeEth
@@ -0,0 +1,208 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var ErrNetworkNotExist = errors.New("network not exist")
+
+// NetworkProfile describes one das network, the built-in networks are registered by the core package,
+// others (devnets, private testnets) can be registered or loaded from json/yaml files at runtime
+type NetworkProfile struct {
+	Name    string     `json:"name" yaml:"name"`
+	NetType DasNetType `json:"net_type" yaml:"net_type"`
+	// Chain is the chain name of ckb get_blockchain_info, empty if the network can not be recognized by it
+	Chain string `json:"chain" yaml:"chain"`
+	// MainNet decides the address mode and the evm chain ids of the network
+	MainNet bool `json:"main_net" yaml:"main_net"`
+	// ContractArgsMultiSig means the das contracts and the das lock are owned by the multisig lock of ContractArgs
+	ContractArgsMultiSig bool                       `json:"contract_args_multi_sig" yaml:"contract_args_multi_sig"`
+	THQCodeHash          string                     `json:"thq_code_hash" yaml:"thq_code_hash"`
+	ContractArgs         string                     `json:"contract_args" yaml:"contract_args"`
+	ContractCodeHash     string                     `json:"contract_code_hash" yaml:"contract_code_hash"`
+	Contracts            map[DasContractName]string `json:"contracts" yaml:"contracts"`                 // contract name => type args
+	SoScripts            map[SoScriptType]string    `json:"so_scripts" yaml:"so_scripts"`               // so script type => type args
+	ContractVersions     map[DasContractName]string `json:"contract_versions" yaml:"contract_versions"` // default versions for CheckContractVersionV2
+	// SecpDep is the dep group of secp256k1_blake160_sighash_all and secp256k1_blake160_multisig_all
+	SecpDep *NetworkCellDep `json:"secp_dep" yaml:"secp_dep"`
+	// AnyLockDeps is the any lock name (omni-lock, joyid, nostr) => cell dep
+	AnyLockDeps       map[string]*NetworkCellDep `json:"any_lock_deps" yaml:"any_lock_deps"`
+	DidCellRecycleDep *NetworkCellDep            `json:"did_cell_recycle_dep" yaml:"did_cell_recycle_dep"`
+	ClusterId         string                     `json:"cluster_id" yaml:"cluster_id"` // spore cluster of the did cells
+}
+
+// NetworkCellDep is a cell dep of the network, the cell of the type id TypeArgs is searched if TxHash is empty
+type NetworkCellDep struct {
+	TxHash   string `json:"tx_hash" yaml:"tx_hash"`
+	Index    uint   `json:"index" yaml:"index"`
+	DepType  string `json:"dep_type" yaml:"dep_type"` // code or dep_group
+	TypeArgs string `json:"type_args" yaml:"type_args"`
+}
+
+// CellDep returns nil if TxHash is empty
+func (n *NetworkCellDep) CellDep() *types.CellDep {
+	if n == nil || n.TxHash == "" {
+		return nil
+	}
+	depType := types.DepTypeCode
+	if n.DepType == string(types.DepTypeDepGroup) {
+		depType = types.DepTypeDepGroup
+	}
+	return &types.CellDep{
+		OutPoint: &types.OutPoint{TxHash: types.HexToHash(n.TxHash), Index: n.Index},
+		DepType:  depType,
+	}
+}
+
+func (n *NetworkProfile) check() error {
+	if n.Name == "" {
+		return fmt.Errorf("network name is empty")
+	}
+	if n.NetType <= 0 {
+		return fmt.Errorf("network[%s] net type invalid: %d", n.Name, n.NetType)
+	}
+	return nil
+}
+
+var (
+	networkLock sync.RWMutex
+	networkList []*NetworkProfile // in the order of registration
+)
+
+// RegisterNetwork adds the profile, a profile with the same net type is replaced in place
+func RegisterNetwork(profile *NetworkProfile) error {
+	if profile == nil {
+		return fmt.Errorf("profile is nil")
+	}
+	if err := profile.check(); err != nil {
+		return err
+	}
+	networkLock.Lock()
+	defer networkLock.Unlock()
+	for i, v := range networkList {
+		if v.NetType == profile.NetType {
+			networkList[i] = profile
+			return nil
+		}
+	}
+	networkList = append(networkList, profile)
+	return nil
+}
+
+// UnregisterNetwork removes the profile of the net type
+func UnregisterNetwork(net DasNetType) {
+	networkLock.Lock()
+	defer networkLock.Unlock()
+	for i, v := range networkList {
+		if v.NetType == net {
+			networkList = append(networkList[:i:i], networkList[i+1:]...)
+			return
+		}
+	}
+}
+
+// Networks returns the registered profiles in the order of registration
+func Networks() []*NetworkProfile {
+	networkLock.RLock()
+	defer networkLock.RUnlock()
+	return append([]*NetworkProfile{}, networkList...)
+}
+
+func GetNetwork(net DasNetType) (*NetworkProfile, error) {
+	networkLock.RLock()
+	defer networkLock.RUnlock()
+	for _, v := range networkList {
+		if v.NetType == net {
+			return v, nil
+		}
+	}
+	return nil, ErrNetworkNotExist
+}
+
+// GetNetworkByChain returns the first registered network of the chain name of ckb get_blockchain_info
+func GetNetworkByChain(chain string) (*NetworkProfile, error) {
+	networkLock.RLock()
+	defer networkLock.RUnlock()
+	if chain != "" {
+		for _, v := range networkList {
+			if v.Chain == chain {
+				return v, nil
+			}
+		}
+	}
+	return nil, ErrNetworkNotExist
+}
+
+// IsMainNet falls back to DasNetTypeMainNet for the networks not registered
+func IsMainNet(net DasNetType) bool {
+	if profile, err := GetNetwork(net); err == nil {
+		return profile.MainNet
+	}
+	return net == DasNetTypeMainNet
+}
+
+// ParseNetworkProfiles parses one profile or a list of profiles, format is json or yaml
+func ParseNetworkProfiles(data []byte, format string) ([]*NetworkProfile, error) {
+	var list []*NetworkProfile
+	switch strings.ToLower(format) {
+	case "json":
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 && data[0] == '[' {
+			if err := json.Unmarshal(data, &list); err != nil {
+				return nil, fmt.Errorf("json.Unmarshal err: %s", err.Error())
+			}
+		} else {
+			var profile NetworkProfile
+			if err := json.Unmarshal(data, &profile); err != nil {
+				return nil, fmt.Errorf("json.Unmarshal err: %s", err.Error())
+			}
+			list = append(list, &profile)
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &list); err != nil {
+			var profile NetworkProfile
+			if err = yaml.Unmarshal(data, &profile); err != nil {
+				return nil, fmt.Errorf("yaml.Unmarshal err: %s", err.Error())
+			}
+			list = []*NetworkProfile{&profile}
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	for _, v := range list {
+		if v == nil {
+			return nil, fmt.Errorf("profile is nil")
+		}
+		if err := v.check(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// LoadNetworkProfiles reads the profiles from a .json, .yaml or .yml file and registers them
+func LoadNetworkProfiles(path string) ([]*NetworkProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile err: %s", err.Error())
+	}
+	list, err := ParseNetworkProfiles(data, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return nil, fmt.Errorf("ParseNetworkProfiles err: %s", err.Error())
+	}
+	for _, v := range list {
+		if err := RegisterNetwork(v); err != nil {
+			return nil, fmt.Errorf("RegisterNetwork err: %s", err.Error())
+		}
+	}
+	return list, nil
+}
@@ -39,6 +39,7 @@ const (
 	DataTypeNew          DataType = 0
 	DataTypeOld          DataType = 1
 	DataTypeDep          DataType = 2
+	DataTypeNone         DataType = -1 // the witness is not wrapped by molecule.Data
 	GoDataEntityVersion1 uint32   = 1
 	GoDataEntityVersion2 uint32   = 2
 	GoDataEntityVersion3 uint32   = 3
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
-	"github.com/nervosnetwork/ckb-sdk-go/collector"
 	"github.com/nervosnetwork/ckb-sdk-go/indexer"
 )
 
@@ -36,27 +35,26 @@ func (d *DasCore) GetAccountCellOnChainByAlgorithmId(oID, mID common.DasAlgorith
 			OutputDataLenRange: &[2]uint64{accountCellDataLenMin, accountCellDataLenMax},
 		},
 	}
-	co := collector.NewLiveCellCollector(d.client, searchKey, indexer.SearchOrderAsc, indexer.SearchLimit, "")
-	co.TypeScript = searchKey.Script
-	iterator, err := co.Iterator()
-	if err != nil {
-		return nil, fmt.Errorf("get cell err: %s", err.Error())
-	}
-	for iterator.HasNext() {
-		liveCell, err := iterator.CurrentItem()
+	lastCursor := ""
+	for {
+		liveCells, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderAsc, indexer.SearchLimit, lastCursor)
 		if err != nil {
-			return nil, fmt.Errorf("CurrentItem err:%s", err.Error())
+			return nil, fmt.Errorf("get cell err: %s", err.Error())
 		}
-		searchAccountId, err := common.OutputDataToAccountId(liveCell.OutputData)
-		if err != nil {
-			continue
+		if len(liveCells.Objects) == 0 || lastCursor == liveCells.LastCursor {
+			break
 		}
-		if bytes.Compare(searchAccountId, accountId) == 0 {
-			log.Info("get account:", account, liveCell.OutPoint.TxHash, liveCell.OutPoint.Index)
-			return liveCell, nil
-		}
-		if err = iterator.Next(); err != nil {
-			return nil, fmt.Errorf("next err:%s", err.Error())
+		lastCursor = liveCells.LastCursor
+
+		for _, liveCell := range liveCells.Objects {
+			searchAccountId, err := common.OutputDataToAccountId(liveCell.OutputData)
+			if err != nil {
+				continue
+			}
+			if bytes.Compare(searchAccountId, accountId) == 0 {
+				log.Info("get account:", account, liveCell.OutPoint.TxHash, liveCell.OutPoint.Index)
+				return liveCell, nil
+			}
 		}
 	}
 	return nil, fmt.Errorf("not exist acc: %s", account)
@@ -86,11 +86,11 @@ func (d *DasAddressFormat) NormalToHex(p DasAddressNormal) (r DasAddressHex, e e
 		if parseAddr, err := address.Parse(p.AddressNormal); err != nil {
 			e = fmt.Errorf("address.Parse err: %s", err.Error())
 		} else {
-			if d.DasNetType == common.DasNetTypeMainNet && parseAddr.Mode != address.Mainnet {
+			if common.IsMainNet(d.DasNetType) && parseAddr.Mode != address.Mainnet {
 				e = fmt.Errorf("not support testnet address[%s]", p.AddressNormal)
 				return
 			}
-			if d.DasNetType != common.DasNetTypeMainNet && parseAddr.Mode == address.Mainnet {
+			if !common.IsMainNet(d.DasNetType) && parseAddr.Mode == address.Mainnet {
 				e = fmt.Errorf("not support mainnet address[%s]", p.AddressNormal)
 				return
 			}
@@ -98,15 +98,8 @@ func (d *DasAddressFormat) NormalToHex(p DasAddressNormal) (r DasAddressHex, e e
 			r.AddressHex = common.Bytes2Hex(parseAddr.Script.Args)
 			r.AddressPayload = common.Hex2Bytes(r.AddressHex)
 
-			var envNet Env
-			switch d.DasNetType {
-			case common.DasNetTypeMainNet:
-				envNet = EnvMainNet
-			case common.DasNetTypeTestnet2:
-				envNet = EnvTestnet2
-			case common.DasNetTypeTestnet3:
-				envNet = EnvTestnet3
-			default:
+			envNet, err := EnvFromNetwork(d.DasNetType)
+			if err != nil {
 				e = fmt.Errorf("not support DasNetType[%d]", d.DasNetType)
 				return
 			}
@@ -273,7 +266,7 @@ func (d *DasAddressFormat) NormalToHex(p DasAddressNormal) (r DasAddressHex, e e
 			return
 		}
 		netParams := bitcoin.GetBTCMainNetParams()
-		if d.DasNetType != common.DasNetTypeMainNet {
+		if !common.IsMainNet(d.DasNetType) {
 			netParams = bitcoin.GetBTCTestNetParams()
 		}
 		addr, err := btcutil.DecodeAddress(p.AddressNormal, &netParams)
@@ -311,7 +304,7 @@ func (d *DasAddressFormat) HexToNormal(p DasAddressHex) (r DasAddressNormal, e e
 		}
 
 		mode := address.Mainnet
-		if d.DasNetType != common.DasNetTypeMainNet {
+		if !common.IsMainNet(d.DasNetType) {
 			mode = address.Testnet
 		}
 
@@ -357,7 +350,7 @@ func (d *DasAddressFormat) HexToNormal(p DasAddressHex) (r DasAddressNormal, e e
 	case common.DasAlgorithmIdBitcoin:
 		r.ChainType = common.ChainTypeBitcoin
 		netParams := bitcoin.GetBTCMainNetParams()
-		if d.DasNetType != common.DasNetTypeMainNet {
+		if !common.IsMainNet(d.DasNetType) {
 			netParams = bitcoin.GetBTCTestNetParams()
 		}
 		switch p.DasSubAlgorithmId {
@@ -400,7 +393,7 @@ func (d *DasAddressFormat) HexToNormal(p DasAddressHex) (r DasAddressNormal, e e
 			return
 		}
 		mode := address.Mainnet
-		if d.DasNetType != common.DasNetTypeMainNet {
+		if !common.IsMainNet(d.DasNetType) {
 			mode = address.Testnet
 		}
 		if addr, err := common.ConvertScriptToAddress(mode, lock); err != nil {
@@ -548,7 +541,7 @@ func (d *DasAddressFormat) argsToHalfArgs(args []byte) (owner, manager []byte, e
 		splitLen = common.DasLockArgsLenBitcoin / 2
 	case common.DasAlgorithmIdWebauthn:
 		splitLen = common.DasLockArgsLenWebAuthn / 2
-		if d.DasNetType != common.DasNetTypeMainNet && len(args) == 48 {
+		if !common.IsMainNet(d.DasNetType) && len(args) == 48 {
 			splitLen = 24
 			owner = args[2:splitLen]
 			manager = args[splitLen+2:]
@@ -630,7 +623,7 @@ func (d *DasAddressFormat) ScriptToHex(s *types.Script) (ownerHex, managerHex Da
 		ownerHex.AddressHex = common.Bytes2Hex(s.Args)
 
 		mode := address.Mainnet
-		if d.DasNetType != common.DasNetTypeMainNet {
+		if !common.IsMainNet(d.DasNetType) {
 			mode = address.Testnet
 		}
 		addr, err := address.ConvertScriptToAddress(mode, s)
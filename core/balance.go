@@ -6,9 +6,7 @@ import (
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/dascache"
-	"github.com/nervosnetwork/ckb-sdk-go/collector"
 	"github.com/nervosnetwork/ckb-sdk-go/indexer"
-	"github.com/nervosnetwork/ckb-sdk-go/rpc"
 	"github.com/nervosnetwork/ckb-sdk-go/types"
 	"sync"
 )
@@ -19,42 +17,101 @@ var (
 	ErrNotEnoughChange   = errors.New("NotEnoughChange")
 )
 
-func GetSatisfiedLimitLiveCell(client rpc.Client, dasCache *dascache.DasCache, searchKey *indexer.SearchKey, needLimit uint64, order indexer.SearchOrder) ([]*indexer.LiveCell, error) {
-	co := collector.NewLiveCellCollector(client, searchKey, order, indexer.SearchLimit, "")
-	co.TypeScript = searchKey.Filter.Script
+// GetSatisfiedLimitLiveCell skips the cells pending in dasCache, the cells are not locked
+func GetSatisfiedLimitLiveCell(reader ChainReader, dasCache *dascache.DasCache, searchKey *indexer.SearchKey, needLimit uint64, order indexer.SearchOrder) ([]*indexer.LiveCell, error) {
+	if reader == nil {
+		return nil, fmt.Errorf("chain reader is nil")
+	}
+	var typeScript *types.Script
+	if searchKey.Filter != nil {
+		typeScript = searchKey.Filter.Script
+	}
 	if searchKey.ScriptType == indexer.ScriptTypeType {
-		co.TypeScript = searchKey.Script
+		typeScript = searchKey.Script
 	}
 
-	iterator, err := co.Iterator()
-	if err != nil {
-		return nil, fmt.Errorf("iterator err:%s", err.Error())
-	}
 	var cells []*indexer.LiveCell
 	foundLimit := uint64(0)
-	for iterator.HasNext() {
-		liveCell, err := iterator.CurrentItem()
-		if err != nil {
-			return nil, fmt.Errorf("CurrentItem err:%s", err.Error())
-		}
-		if dasCache != nil && dasCache.ExistOutPoint(common.OutPointStruct2String(liveCell.OutPoint)) {
-		} else {
+	err := eachLiveCell(reader, searchKey, order, typeScript, func(liveCell *indexer.LiveCell) bool {
+		if pickCell(dasCache, liveCell, false) {
 			cells = append(cells, liveCell)
 			foundLimit = foundLimit + 1
-			if foundLimit >= needLimit {
-				break
-			}
-		}
-		if err = iterator.Next(); err != nil {
-			return nil, fmt.Errorf("next err:%s", err.Error())
 		}
+		return foundLimit >= needLimit
+	})
+	if err != nil {
+		return nil, err
 	}
 	return cells, nil
 }
 
-func GetSatisfiedCapacityLiveCellWithOrder(client rpc.Client, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, capacityNeed, capacityForChange uint64, order indexer.SearchOrder) ([]*indexer.LiveCell, uint64, error) {
-	if client == nil {
-		return nil, 0, fmt.Errorf("client is nil")
+// eachLiveCell pages the cells of searchKey by GetCells until fn returns true,
+// the cells are filtered by typeScript the same as the live cell collector, no type if typeScript is nil
+func eachLiveCell(reader ChainReader, searchKey *indexer.SearchKey, order indexer.SearchOrder, typeScript *types.Script, fn func(liveCell *indexer.LiveCell) bool) error {
+	lastCursor := ""
+	for {
+		liveCells, err := reader.GetCells(context.Background(), searchKey, order, indexer.SearchLimit, lastCursor)
+		if err != nil {
+			return fmt.Errorf("GetCells err: %s", err.Error())
+		}
+		if len(liveCells.Objects) == 0 || lastCursor == liveCells.LastCursor {
+			return nil
+		}
+		lastCursor = liveCells.LastCursor
+
+		for _, liveCell := range liveCells.Objects {
+			if typeScript == nil {
+				if liveCell.Output.Type != nil {
+					continue
+				}
+			} else if !typeScript.Equals(liveCell.Output.Type) {
+				continue
+			}
+			if fn(liveCell) {
+				return nil
+			}
+		}
+	}
+}
+
+// pickCell is false if the cell is pending in dasCache, like selected by another process,
+// the cell is locked by TryAddOutPoint if claim is set
+func pickCell(dasCache *dascache.DasCache, cell *indexer.LiveCell, claim bool) bool {
+	if dasCache == nil {
+		return true
+	}
+	outPoint := common.OutPointStruct2String(cell.OutPoint)
+	if claim {
+		return dasCache.TryAddOutPoint([]string{outPoint})
+	}
+	return !dasCache.ExistOutPoint(outPoint)
+}
+
+// releaseCells clears the cells claimed by a failed selection
+func releaseCells(dasCache *dascache.DasCache, cells []*indexer.LiveCell) {
+	if dasCache == nil || len(cells) == 0 {
+		return
+	}
+	outPoints := make([]string, 0, len(cells))
+	for _, v := range cells {
+		outPoints = append(outPoints, common.OutPointStruct2String(v.OutPoint))
+	}
+	dasCache.ClearOutPoint(outPoints)
+}
+
+// GetSatisfiedCapacityLiveCellWithOrder skips the cells pending in dasCache, the selected cells are not locked
+func GetSatisfiedCapacityLiveCellWithOrder(reader ChainReader, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, capacityNeed, capacityForChange uint64, order indexer.SearchOrder) ([]*indexer.LiveCell, uint64, error) {
+	return getSatisfiedCapacityLiveCell(reader, dasCache, dasLockScript, dasTypeScript, capacityNeed, capacityForChange, order, false)
+}
+
+// ClaimSatisfiedCapacityLiveCellWithOrder locks the selected cells in dasCache by TryAddOutPoint, the cells are released if it fails
+func ClaimSatisfiedCapacityLiveCellWithOrder(reader ChainReader, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, capacityNeed, capacityForChange uint64, order indexer.SearchOrder) ([]*indexer.LiveCell, uint64, error) {
+	return getSatisfiedCapacityLiveCell(reader, dasCache, dasLockScript, dasTypeScript, capacityNeed, capacityForChange, order, true)
+}
+
+func getSatisfiedCapacityLiveCell(reader ChainReader, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, capacityNeed, capacityForChange uint64, order indexer.SearchOrder, claim bool) ([]*indexer.LiveCell, uint64, error) {
+	if reader == nil {
+		return nil, 0, fmt.Errorf("chain reader is nil")
 	}
 	searchKey := &indexer.SearchKey{
 		Script:     dasLockScript,
@@ -64,41 +121,36 @@ func GetSatisfiedCapacityLiveCellWithOrder(client rpc.Client, dasCache *dascache
 			OutputDataLenRange: &[2]uint64{0, 1},
 		},
 	}
-	co := collector.NewLiveCellCollector(client, searchKey, order, indexer.SearchLimit, "")
-	co.TypeScript = searchKey.Filter.Script
-	iterator, err := co.Iterator()
-	if err != nil {
-		return nil, 0, fmt.Errorf("iterator err:%s", err.Error())
-	}
 	var cells []*indexer.LiveCell
 	total := uint64(0)
 	hasCache := false
-	for iterator.HasNext() {
-		liveCell, err := iterator.CurrentItem()
-		if err != nil {
-			return nil, 0, fmt.Errorf("CurrentItem err:%s", err.Error())
-		}
-		if capacityNeed > 0 && dasCache != nil && dasCache.ExistOutPoint(common.OutPointStruct2String(liveCell.OutPoint)) {
+	claim = claim && capacityNeed > 0
+	err := eachLiveCell(reader, searchKey, order, dasTypeScript, func(liveCell *indexer.LiveCell) bool {
+		if capacityNeed > 0 && !pickCell(dasCache, liveCell, claim) {
 			hasCache = true
-		} else {
-			cells = append(cells, liveCell)
-			total += liveCell.Output.Capacity
-			if capacityNeed > 0 && (total == capacityNeed || total >= capacityNeed+capacityForChange) { // limit 为转账金额+手续费
-				break
-			}
+			return false
 		}
-		if err = iterator.Next(); err != nil {
-			return nil, 0, fmt.Errorf("next err:%s", err.Error())
+		cells = append(cells, liveCell)
+		total += liveCell.Output.Capacity
+		return capacityNeed > 0 && (total == capacityNeed || total >= capacityNeed+capacityForChange) // limit 为转账金额+手续费
+	})
+	if err != nil {
+		if claim {
+			releaseCells(dasCache, cells)
 		}
+		return nil, 0, err
 	}
-	if capacityNeed > 0 && total != capacityNeed {
+	if capacityNeed > 0 && total != capacityNeed && total < capacityNeed+capacityForChange {
+		if claim {
+			releaseCells(dasCache, cells)
+		}
 		if total < capacityNeed {
 			if hasCache {
 				return cells, total, ErrRejectedOutPoint
 			} else {
 				return cells, total, ErrInsufficientFunds
 			}
-		} else if total < capacityNeed+capacityForChange {
+		} else {
 			if hasCache {
 				return cells, total, ErrRejectedOutPoint
 			} else {
@@ -109,8 +161,23 @@ func GetSatisfiedCapacityLiveCellWithOrder(client rpc.Client, dasCache *dascache
 	return cells, total, nil
 }
 
-func GetSatisfiedCapacityLiveCell(client rpc.Client, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, capacityNeed, capacityForChange uint64) ([]*indexer.LiveCell, uint64, error) {
-	return GetSatisfiedCapacityLiveCellWithOrder(client, dasCache, dasLockScript, dasTypeScript, capacityNeed, capacityForChange, indexer.SearchOrderDesc)
+func GetSatisfiedCapacityLiveCell(reader ChainReader, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, capacityNeed, capacityForChange uint64) ([]*indexer.LiveCell, uint64, error) {
+	return GetSatisfiedCapacityLiveCellWithOrder(reader, dasCache, dasLockScript, dasTypeScript, capacityNeed, capacityForChange, indexer.SearchOrderDesc)
+}
+
+// ClaimSatisfiedCapacityLiveCell is GetSatisfiedCapacityLiveCell with the selected cells locked, the same as ClaimSatisfiedCapacityLiveCellWithOrder
+func ClaimSatisfiedCapacityLiveCell(reader ChainReader, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, capacityNeed, capacityForChange uint64) ([]*indexer.LiveCell, uint64, error) {
+	return ClaimSatisfiedCapacityLiveCellWithOrder(reader, dasCache, dasLockScript, dasTypeScript, capacityNeed, capacityForChange, indexer.SearchOrderDesc)
+}
+
+// GetSatisfiedCapacityLiveCellBySelector searches all the cells of dasLockScript and dasTypeScript, and selects the inputs by selector,
+// the selected cells are locked by SelectCells
+func GetSatisfiedCapacityLiveCellBySelector(reader ChainReader, dasCache *dascache.DasCache, dasLockScript, dasTypeScript *types.Script, selector CoinSelector, p CoinSelectParam) ([]*indexer.LiveCell, uint64, error) {
+	cells, _, err := GetSatisfiedCapacityLiveCellWithOrder(reader, nil, dasLockScript, dasTypeScript, 0, 0, indexer.SearchOrderDesc)
+	if err != nil {
+		return nil, 0, err
+	}
+	return SelectCells(selector, dasCache, cells, p)
 }
 
 func SplitOutputCell(total, base, limit uint64, lockScript, typeScript *types.Script) ([]*types.CellOutput, error) {
@@ -169,8 +236,13 @@ type ParamGetBalanceCells struct {
 	CurrentBlockNumber uint64
 	SearchOrder        indexer.SearchOrder
 	OutputDataLenRange *[2]uint64
+	CoinSelector       CoinSelector      // if set, all the balance cells are searched and the inputs are selected by it
+	MaxInputs          int               // for CoinSelector
+	ExcludeOutPoints   []*types.OutPoint // the cells already in the tx, never selected
+	ClaimCells         bool              // lock the selected cells in DasCache by TryAddOutPoint if CapacityNeed is set, they are released if it fails
 }
 
+// GetBalanceCells skips the cells pending in DasCache, the selected cells are locked only if ClaimCells is set
 func (d *DasCore) GetBalanceCells(p *ParamGetBalanceCells) ([]*indexer.LiveCell, uint64, error) {
 	if p.OutputDataLenRange == nil {
 		p.OutputDataLenRange = &[2]uint64{0, 1}
@@ -179,8 +251,8 @@ func (d *DasCore) GetBalanceCells(p *ParamGetBalanceCells) ([]*indexer.LiveCell,
 }
 
 func (d *DasCore) GetBalanceCellsFilter(p *ParamGetBalanceCells) ([]*indexer.LiveCell, uint64, error) {
-	if d.client == nil {
-		return nil, 0, fmt.Errorf("client is nil")
+	if d.chain == nil {
+		return nil, 0, fmt.Errorf("chain reader is nil")
 	}
 	if p == nil {
 		return nil, 0, fmt.Errorf("param is nil")
@@ -212,10 +284,20 @@ func (d *DasCore) GetBalanceCellsFilter(p *ParamGetBalanceCells) ([]*indexer.Liv
 	hasCache := false
 	lastCursor := ""
 
+	exclude := make(map[string]struct{}, len(p.ExcludeOutPoints))
+	for _, v := range p.ExcludeOutPoints {
+		exclude[common.OutPointStruct2String(v)] = struct{}{}
+	}
+
 	ok := false
+	useSelector := p.CoinSelector != nil && p.CapacityNeed > 0
+	claim := p.ClaimCells && p.CapacityNeed > 0
 	for {
-		liveCells, err := d.client.GetCells(context.Background(), searchKey, p.SearchOrder, indexer.SearchLimit, lastCursor)
+		liveCells, err := d.chain.GetCells(context.Background(), searchKey, p.SearchOrder, indexer.SearchLimit, lastCursor)
 		if err != nil {
+			if !useSelector && claim {
+				releaseCells(p.DasCache, cells)
+			}
 			return nil, 0, err
 		}
 		log.Info("liveCells:", liveCells.LastCursor, len(liveCells.Objects))
@@ -228,7 +310,14 @@ func (d *DasCore) GetBalanceCellsFilter(p *ParamGetBalanceCells) ([]*indexer.Liv
 			if liveCell.Output.Type != nil && !balanceContract.IsSameTypeId(liveCell.Output.Type.CodeHash) {
 				continue
 			}
-			if p.CapacityNeed > 0 && p.DasCache != nil && p.DasCache.ExistOutPoint(common.OutPointStruct2String(liveCell.OutPoint)) {
+			if _, excluded := exclude[common.OutPointStruct2String(liveCell.OutPoint)]; excluded {
+				continue
+			}
+			if useSelector {
+				cells = append(cells, liveCell)
+				continue
+			}
+			if p.CapacityNeed > 0 && !pickCell(p.DasCache, liveCell, claim) {
 				hasCache = true
 				continue
 			}
@@ -246,14 +335,24 @@ func (d *DasCore) GetBalanceCellsFilter(p *ParamGetBalanceCells) ([]*indexer.Liv
 		}
 	}
 
-	if p.CapacityNeed > 0 {
+	if useSelector {
+		return selectCells(p.CoinSelector, p.DasCache, cells, CoinSelectParam{
+			CapacityNeed:      p.CapacityNeed,
+			CapacityForChange: p.CapacityForChange,
+			MaxInputs:         p.MaxInputs,
+		}, claim)
+	}
+	if p.CapacityNeed > 0 && total < p.CapacityNeed+p.CapacityForChange {
+		if claim {
+			releaseCells(p.DasCache, cells)
+		}
 		if total < p.CapacityNeed {
 			if hasCache {
 				return cells, total, ErrRejectedOutPoint
 			} else {
 				return cells, total, ErrInsufficientFunds
 			}
-		} else if total < p.CapacityNeed+p.CapacityForChange {
+		} else {
 			if hasCache {
 				return cells, total, ErrRejectedOutPoint
 			} else {
@@ -314,6 +413,8 @@ func SplitOutputCell2(total, base, limit uint64, lockScript, typeScript *types.S
 
 var balanceLock sync.Mutex
 
+const balanceLockRetry = 3
+
 func (d *DasCore) GetBalanceCellWithLock(p *ParamGetBalanceCells) (uint64, []*indexer.LiveCell, error) {
 	log.Info("GetBalanceCellWithLock:", p.CapacityNeed, p.CapacityForChange)
 	if p.CapacityNeed == 0 {
@@ -327,16 +428,18 @@ func (d *DasCore) GetBalanceCellWithLock(p *ParamGetBalanceCells) (uint64, []*in
 	if p.CapacityForChange == 0 {
 		p.CapacityForChange = common.DasLockWithBalanceTypeMinCkbCapacity
 	}
-	liveCells, total, err := d.GetBalanceCells(p)
-	if err != nil {
-		return 0, nil, fmt.Errorf("GetBalanceCells err: %s", err.Error())
-	}
-
-	var outpoints []string
-	for _, v := range liveCells {
-		outpoints = append(outpoints, common.OutPointStruct2String(v.OutPoint))
+	p.ClaimCells = true
+	// the cells selected by CoinSelector may be taken by another process sharing the cache backend
+	// between the selection and TryAddOutPoint
+	for i := 0; ; i++ {
+		liveCells, total, err := d.GetBalanceCells(p)
+		if errors.Is(err, errSelectedCellsTaken) && i+1 < balanceLockRetry {
+			log.Warn("GetBalanceCellWithLock cells are taken, retry:", i)
+			continue
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("GetBalanceCells err: %w", err)
+		}
+		return total - p.CapacityNeed, liveCells, nil
 	}
-	p.DasCache.AddOutPoint(outpoints)
-
-	return total - p.CapacityNeed, liveCells, nil
 }
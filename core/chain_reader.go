@@ -0,0 +1,347 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ChainReader is the subset of rpc.Client that DasCore needs to read chain data.
+// rpc.Client satisfies it, so WithClient keeps working unchanged.
+type ChainReader interface {
+	GetTipBlockNumber(ctx context.Context) (uint64, error)
+	GetTipHeader(ctx context.Context) (*types.Header, error)
+	GetHeader(ctx context.Context, hash types.Hash) (*types.Header, error)
+	GetHeaderByNumber(ctx context.Context, number uint64) (*types.Header, error)
+	GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error)
+	GetLiveCell(ctx context.Context, outPoint *types.OutPoint, withData bool) (*types.CellWithStatus, error)
+	GetTransaction(ctx context.Context, hash types.Hash) (*types.TransactionWithStatus, error)
+	GetBlockchainInfo(ctx context.Context) (*types.BlockchainInfo, error)
+	GetCells(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.LiveCells, error)
+}
+
+var ErrChainDataNotExist = errors.New("chain data not exist")
+
+// MemChainReader is an in-memory ChainReader that can be seeded with cells, headers and transactions
+type MemChainReader struct {
+	rw           sync.RWMutex
+	chain        string
+	cells        map[string]*indexer.LiveCell // map[outpoint]live cell
+	transactions map[string]*types.TransactionWithStatus
+	headers      map[string]*types.Header
+	blocks       map[uint64]*types.Block
+	tip          *types.Header
+}
+
+func NewMemChainReader(chain string) *MemChainReader {
+	return &MemChainReader{
+		chain:        chain,
+		cells:        make(map[string]*indexer.LiveCell),
+		transactions: make(map[string]*types.TransactionWithStatus),
+		headers:      make(map[string]*types.Header),
+		blocks:       make(map[uint64]*types.Block),
+	}
+}
+
+func (m *MemChainReader) AddLiveCell(cells ...*indexer.LiveCell) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	for _, v := range cells {
+		if v == nil || v.OutPoint == nil {
+			continue
+		}
+		m.cells[common.OutPointStruct2String(v.OutPoint)] = v
+	}
+}
+
+func (m *MemChainReader) RemoveLiveCell(outPoints ...*types.OutPoint) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	for _, v := range outPoints {
+		delete(m.cells, common.OutPointStruct2String(v))
+	}
+}
+
+func (m *MemChainReader) AddTransaction(tx *types.Transaction, blockHash *types.Hash) error {
+	if tx == nil {
+		return fmt.Errorf("tx is nil")
+	}
+	txHash, err := tx.ComputeHash()
+	if err != nil {
+		return fmt.Errorf("ComputeHash err: %s", err.Error())
+	}
+	status := types.TransactionStatusCommitted
+	if blockHash == nil {
+		status = types.TransactionStatusPending
+	}
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	m.transactions[txHash.Hex()] = &types.TransactionWithStatus{
+		Transaction: tx,
+		TxStatus: &types.TxStatus{
+			BlockHash: blockHash,
+			Status:    status,
+		},
+	}
+	return nil
+}
+
+// ApplyTransaction adds tx and moves its inputs and outputs in the live cell set, like a committed block would
+func (m *MemChainReader) ApplyTransaction(tx *types.Transaction, blockNumber uint64, txIndex uint) error {
+	var blockHash *types.Hash
+	m.rw.RLock()
+	if block, ok := m.blocks[blockNumber]; ok && block.Header != nil {
+		hash := block.Header.Hash
+		blockHash = &hash
+	}
+	m.rw.RUnlock()
+	if blockHash == nil {
+		blockHash = &types.Hash{}
+	}
+	if err := m.AddTransaction(tx, blockHash); err != nil {
+		return err
+	}
+	txHash, _ := tx.ComputeHash()
+
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	for _, v := range tx.Inputs {
+		delete(m.cells, common.OutPointStruct2String(v.PreviousOutput))
+	}
+	for i, v := range tx.Outputs {
+		var data []byte
+		if i < len(tx.OutputsData) {
+			data = tx.OutputsData[i]
+		}
+		outPoint := &types.OutPoint{TxHash: txHash, Index: uint(i)}
+		m.cells[common.OutPointStruct2String(outPoint)] = &indexer.LiveCell{
+			BlockNumber: blockNumber,
+			OutPoint:    outPoint,
+			Output:      v,
+			OutputData:  data,
+			TxIndex:     txIndex,
+		}
+	}
+	return nil
+}
+
+func (m *MemChainReader) AddHeader(headers ...*types.Header) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	for _, v := range headers {
+		if v == nil {
+			continue
+		}
+		m.headers[v.Hash.Hex()] = v
+		if m.tip == nil || v.Number >= m.tip.Number {
+			m.tip = v
+		}
+	}
+}
+
+func (m *MemChainReader) AddBlock(blocks ...*types.Block) {
+	for _, v := range blocks {
+		if v == nil || v.Header == nil {
+			continue
+		}
+		m.AddHeader(v.Header)
+		m.rw.Lock()
+		m.blocks[v.Header.Number] = v
+		m.rw.Unlock()
+	}
+}
+
+func (m *MemChainReader) GetTipBlockNumber(ctx context.Context) (uint64, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	if m.tip == nil {
+		return 0, ErrChainDataNotExist
+	}
+	return m.tip.Number, nil
+}
+
+func (m *MemChainReader) GetTipHeader(ctx context.Context) (*types.Header, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	if m.tip == nil {
+		return nil, ErrChainDataNotExist
+	}
+	return m.tip, nil
+}
+
+func (m *MemChainReader) GetHeader(ctx context.Context, hash types.Hash) (*types.Header, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	if item, ok := m.headers[hash.Hex()]; ok {
+		return item, nil
+	}
+	return nil, ErrChainDataNotExist
+}
+
+func (m *MemChainReader) GetHeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	if block, ok := m.blocks[number]; ok {
+		return block.Header, nil
+	}
+	for _, v := range m.headers {
+		if v.Number == number {
+			return v, nil
+		}
+	}
+	return nil, ErrChainDataNotExist
+}
+
+func (m *MemChainReader) GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	if block, ok := m.blocks[number]; ok {
+		return block, nil
+	}
+	return nil, ErrChainDataNotExist
+}
+
+func (m *MemChainReader) GetLiveCell(ctx context.Context, outPoint *types.OutPoint, withData bool) (*types.CellWithStatus, error) {
+	if outPoint == nil {
+		return nil, fmt.Errorf("outPoint is nil")
+	}
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	item, ok := m.cells[common.OutPointStruct2String(outPoint)]
+	if !ok {
+		return &types.CellWithStatus{Cell: &types.CellInfo{Output: &types.CellOutput{}}, Status: "unknown"}, nil
+	}
+	res := types.CellWithStatus{
+		Cell:   &types.CellInfo{Output: item.Output},
+		Status: "live",
+	}
+	if withData {
+		res.Cell.Data = &types.CellData{Content: item.OutputData}
+	}
+	return &res, nil
+}
+
+func (m *MemChainReader) GetTransaction(ctx context.Context, hash types.Hash) (*types.TransactionWithStatus, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	if item, ok := m.transactions[hash.Hex()]; ok {
+		return item, nil
+	}
+	return nil, ErrChainDataNotExist
+}
+
+func (m *MemChainReader) GetBlockchainInfo(ctx context.Context) (*types.BlockchainInfo, error) {
+	return &types.BlockchainInfo{Chain: m.chain}, nil
+}
+
+// GetCells follows the ckb-indexer semantics: args of the search script and filter script are prefix matched,
+// ranges are [start, end), the cursor is the offset of the next cell
+func (m *MemChainReader) GetCells(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.LiveCells, error) {
+	if searchKey == nil || searchKey.Script == nil {
+		return nil, fmt.Errorf("searchKey is nil")
+	}
+	offset := 0
+	if afterCursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(afterCursor); err != nil {
+			return nil, fmt.Errorf("invalid cursor: %s", afterCursor)
+		}
+	}
+
+	m.rw.RLock()
+	var list []*indexer.LiveCell
+	for _, v := range m.cells {
+		if matchSearchKey(searchKey, v) {
+			list = append(list, v)
+		}
+	}
+	m.rw.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
+		}
+		if a.TxIndex != b.TxIndex {
+			return a.TxIndex < b.TxIndex
+		}
+		if a.OutPoint.TxHash != b.OutPoint.TxHash {
+			return bytes.Compare(a.OutPoint.TxHash.Bytes(), b.OutPoint.TxHash.Bytes()) < 0
+		}
+		return a.OutPoint.Index < b.OutPoint.Index
+	})
+	if order == indexer.SearchOrderDesc {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+
+	var res indexer.LiveCells
+	if offset < len(list) {
+		end := len(list)
+		if limit > 0 && offset+int(limit) < end {
+			end = offset + int(limit)
+		}
+		res.Objects = list[offset:end]
+		offset = end
+	}
+	res.LastCursor = strconv.Itoa(offset)
+	return &res, nil
+}
+
+func matchSearchKey(searchKey *indexer.SearchKey, cell *indexer.LiveCell) bool {
+	if cell.Output == nil {
+		return false
+	}
+	var primary, secondary *types.Script
+	if searchKey.ScriptType == indexer.ScriptTypeType {
+		primary, secondary = cell.Output.Type, cell.Output.Lock
+	} else {
+		primary, secondary = cell.Output.Lock, cell.Output.Type
+	}
+	if !matchScriptPrefix(searchKey.Script, primary) {
+		return false
+	}
+	if searchKey.ArgsLen > 0 && uint(len(primary.Args)) != searchKey.ArgsLen {
+		return false
+	}
+	filter := searchKey.Filter
+	if filter == nil {
+		return true
+	}
+	if filter.Script != nil && !matchScriptPrefix(filter.Script, secondary) {
+		return false
+	}
+	if r := filter.OutputDataLenRange; r != nil {
+		if l := uint64(len(cell.OutputData)); l < r[0] || l >= r[1] {
+			return false
+		}
+	}
+	if r := filter.OutputCapacityRange; r != nil {
+		if c := cell.Output.Capacity; c < r[0] || c >= r[1] {
+			return false
+		}
+	}
+	if r := filter.BlockRange; r != nil {
+		if cell.BlockNumber < r[0] || cell.BlockNumber >= r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func matchScriptPrefix(search, script *types.Script) bool {
+	if script == nil {
+		return false
+	}
+	return search.CodeHash == script.CodeHash &&
+		search.HashType == script.HashType &&
+		bytes.HasPrefix(script.Args, search.Args)
+}
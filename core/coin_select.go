@@ -0,0 +1,247 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"sort"
+)
+
+var (
+	ErrTooManyInputs      = errors.New("TooManyInputs")
+	errSelectedCellsTaken = fmt.Errorf("%w: selected cells are taken", ErrRejectedOutPoint)
+)
+
+// CoinSelectParam is satisfied when the total capacity equals CapacityNeed,
+// or is not less than CapacityNeed+CapacityForChange, the same as GetSatisfiedCapacityLiveCell
+type CoinSelectParam struct {
+	CapacityNeed      uint64
+	CapacityForChange uint64
+	MaxInputs         int // 0 means no limit
+}
+
+func (p *CoinSelectParam) satisfied(total uint64) bool {
+	return total == p.CapacityNeed || total >= p.CapacityNeed+p.CapacityForChange
+}
+
+func (p *CoinSelectParam) exceed(count int) bool {
+	return p.MaxInputs > 0 && count > p.MaxInputs
+}
+
+// CoinSelector selects the inputs from the candidate cells, the candidates are not pending in DasCache.
+// It returns the selected cells and the total capacity, or ErrInsufficientFunds, ErrNotEnoughChange, ErrTooManyInputs
+type CoinSelector interface {
+	Select(cells []*indexer.LiveCell, p CoinSelectParam) ([]*indexer.LiveCell, uint64, error)
+}
+
+// SelectCells filters the cells pending in dasCache, selects the inputs by selector and locks them by TryAddOutPoint,
+// ErrInsufficientFunds and ErrNotEnoughChange become ErrRejectedOutPoint if some cells are pending,
+// and it is also ErrRejectedOutPoint if the selected cells are taken by another process before they are locked
+func SelectCells(selector CoinSelector, dasCache *dascache.DasCache, cells []*indexer.LiveCell, p CoinSelectParam) ([]*indexer.LiveCell, uint64, error) {
+	return selectCells(selector, dasCache, cells, p, true)
+}
+
+func selectCells(selector CoinSelector, dasCache *dascache.DasCache, cells []*indexer.LiveCell, p CoinSelectParam, claim bool) ([]*indexer.LiveCell, uint64, error) {
+	if selector == nil {
+		selector = &LargestFirstSelector{}
+	}
+	hasCache := false
+	var candidates []*indexer.LiveCell
+	for _, v := range cells {
+		if dasCache != nil && dasCache.ExistOutPoint(common.OutPointStruct2String(v.OutPoint)) {
+			hasCache = true
+			continue
+		}
+		candidates = append(candidates, v)
+	}
+	selected, total, err := selector.Select(candidates, p)
+	if hasCache && (errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrNotEnoughChange)) {
+		return selected, total, ErrRejectedOutPoint
+	}
+	if err != nil || dasCache == nil || !claim {
+		return selected, total, err
+	}
+	outPoints := make([]string, 0, len(selected))
+	for _, v := range selected {
+		outPoints = append(outPoints, common.OutPointStruct2String(v.OutPoint))
+	}
+	if !dasCache.TryAddOutPoint(outPoints) {
+		return nil, 0, errSelectedCellsTaken
+	}
+	return selected, total, nil
+}
+
+// SortCellsByCapacity returns a copy of the cells sorted by capacity, the order of the equal ones is kept
+func SortCellsByCapacity(cells []*indexer.LiveCell, desc bool) []*indexer.LiveCell {
+	list := append([]*indexer.LiveCell{}, cells...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if desc {
+			return list[i].Output.Capacity > list[j].Output.Capacity
+		}
+		return list[i].Output.Capacity < list[j].Output.Capacity
+	})
+	return list
+}
+
+func sumCapacity(cells []*indexer.LiveCell) uint64 {
+	total := uint64(0)
+	for _, v := range cells {
+		total += v.Output.Capacity
+	}
+	return total
+}
+
+// shortageErr is the error of the largest cells not satisfying p
+func shortageErr(cells []*indexer.LiveCell, p CoinSelectParam) error {
+	list := SortCellsByCapacity(cells, true)
+	if p.MaxInputs > 0 && len(list) > p.MaxInputs {
+		if p.satisfied(sumCapacity(list)) {
+			return fmt.Errorf("%w: more than %d", ErrTooManyInputs, p.MaxInputs)
+		}
+		list = list[:p.MaxInputs]
+	}
+	if sumCapacity(list) < p.CapacityNeed {
+		return ErrInsufficientFunds
+	}
+	return ErrNotEnoughChange
+}
+
+// LargestFirstSelector takes the largest cells until the capacity is satisfied, it needs the fewest inputs
+type LargestFirstSelector struct{}
+
+func (s *LargestFirstSelector) Select(cells []*indexer.LiveCell, p CoinSelectParam) ([]*indexer.LiveCell, uint64, error) {
+	var selected []*indexer.LiveCell
+	total := uint64(0)
+	for _, v := range SortCellsByCapacity(cells, true) {
+		if p.exceed(len(selected) + 1) {
+			break
+		}
+		selected = append(selected, v)
+		total += v.Output.Capacity
+		if p.satisfied(total) {
+			return selected, total, nil
+		}
+	}
+	return selected, total, shortageErr(cells, p)
+}
+
+// MinInputCountSelector takes as few cells as LargestFirstSelector, but the last one is the smallest cell
+// which still satisfies the capacity, so the change is smaller
+type MinInputCountSelector struct{}
+
+func (s *MinInputCountSelector) Select(cells []*indexer.LiveCell, p CoinSelectParam) ([]*indexer.LiveCell, uint64, error) {
+	selected, total, err := (&LargestFirstSelector{}).Select(cells, p)
+	if err != nil {
+		return selected, total, err
+	}
+	last := len(selected) - 1
+	base := total - selected[last].Output.Capacity
+	rest := SortCellsByCapacity(cells, false)
+	for _, v := range rest {
+		if v.Output.Capacity > selected[last].Output.Capacity {
+			break
+		}
+		if p.satisfied(base + v.Output.Capacity) {
+			if !containsCell(selected[:last], v) {
+				selected[last] = v
+				return selected, base + v.Output.Capacity, nil
+			}
+		}
+	}
+	return selected, total, nil
+}
+
+func containsCell(cells []*indexer.LiveCell, cell *indexer.LiveCell) bool {
+	for _, v := range cells {
+		if v == cell {
+			return true
+		}
+	}
+	return false
+}
+
+// BranchAndBoundSelector searches the cells whose total equals CapacityNeed, the change is 0 then
+// and the tx needs no change output. It falls back to LargestFirstSelector if no such cells are found in MaxTries steps
+type BranchAndBoundSelector struct {
+	MaxTries int // 100000 by default
+}
+
+func (s *BranchAndBoundSelector) Select(cells []*indexer.LiveCell, p CoinSelectParam) ([]*indexer.LiveCell, uint64, error) {
+	maxTries := s.MaxTries
+	if maxTries <= 0 {
+		maxTries = 100000
+	}
+	list := SortCellsByCapacity(cells, true)
+	// remain[i] is the total of list[i:]
+	remain := make([]uint64, len(list)+1)
+	for i := len(list) - 1; i >= 0; i-- {
+		remain[i] = remain[i+1] + list[i].Output.Capacity
+	}
+
+	tries := 0
+	var picked []int
+	var search func(i int, total uint64) bool
+	search = func(i int, total uint64) bool {
+		tries++
+		if total == p.CapacityNeed {
+			return true
+		}
+		if i >= len(list) || total > p.CapacityNeed || total+remain[i] < p.CapacityNeed || tries > maxTries || p.exceed(len(picked)+1) {
+			return false
+		}
+		picked = append(picked, i)
+		if search(i+1, total+list[i].Output.Capacity) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+		// the cells of the same capacity are the same for the search
+		j := i + 1
+		for j < len(list) && list[j].Output.Capacity == list[i].Output.Capacity {
+			j++
+		}
+		return search(j, total)
+	}
+	if p.CapacityNeed > 0 && search(0, 0) {
+		var selected []*indexer.LiveCell
+		total := uint64(0)
+		for _, i := range picked {
+			selected = append(selected, list[i])
+			total += list[i].Output.Capacity
+		}
+		return selected, total, nil
+	}
+	return (&LargestFirstSelector{}).Select(cells, p)
+}
+
+// ConsolidateDustSelector selects the cells by LargestFirstSelector and adds the cells less than DustThreshold,
+// the smallest first, up to MaxInputs and MaxDust (0 means no limit), the dust is merged into the change
+type ConsolidateDustSelector struct {
+	DustThreshold uint64
+	MaxDust       int
+}
+
+func (s *ConsolidateDustSelector) Select(cells []*indexer.LiveCell, p CoinSelectParam) ([]*indexer.LiveCell, uint64, error) {
+	selected, total, err := (&LargestFirstSelector{}).Select(cells, p)
+	if err != nil {
+		return selected, total, err
+	}
+	dust := 0
+	for _, v := range SortCellsByCapacity(cells, false) {
+		if v.Output.Capacity >= s.DustThreshold || p.exceed(len(selected)+1) || (s.MaxDust > 0 && dust >= s.MaxDust) {
+			break
+		}
+		if containsCell(selected, v) {
+			continue
+		}
+		// an exact match needs no change, the dust would make a change less than CapacityForChange
+		if !p.satisfied(total + v.Output.Capacity) {
+			break
+		}
+		selected = append(selected, v)
+		total += v.Output.Capacity
+		dust++
+	}
+	return selected, total, nil
+}
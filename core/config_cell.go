@@ -166,7 +166,7 @@ func (d *DasCore) AsyncDasConfigCell() error {
 			Script: configCellContract.ToScript(nil),
 		},
 	}
-	res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 200, "")
+	res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 200, "")
 	if err != nil {
 		return fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -192,6 +192,22 @@ func (d *DasCore) AsyncDasConfigCell() error {
 	return nil
 }
 
+// RollbackDasConfig reloads the config cells and contracts after the blocks after ancestor are rolled back,
+// AsyncDasConfigCell only takes the config cells of higher blocks, so the ones of the removed blocks are reset first
+func (d *DasCore) RollbackDasConfig(ancestor uint64) error {
+	DasConfigCellMap.Range(func(key, value interface{}) bool {
+		if item, ok := value.(*DasConfigCellInfo); ok && item.BlockNumber > ancestor {
+			item.BlockNumber = 0
+		}
+		return true
+	})
+	if err := d.AsyncDasConfigCell(); err != nil {
+		return fmt.Errorf("AsyncDasConfigCell err: %s", err.Error())
+	}
+	d.asyncDasContract()
+	return nil
+}
+
 func (d *DasConfigCellInfo) ToCellDep() *types.CellDep {
 	return &types.CellDep{
 		OutPoint: &d.OutPoint,
@@ -213,7 +229,7 @@ func (d *DasCore) ConfigCellDataBuilderByTypeArgs(configCellTypeArgs common.Conf
 	if err != nil {
 		return nil, fmt.Errorf("GetDasConfigCellInfo err: %s", err.Error())
 	}
-	if res, err := d.client.GetTransaction(d.ctx, configCell.OutPoint.TxHash); err != nil {
+	if res, err := d.chain.GetTransaction(d.ctx, configCell.OutPoint.TxHash); err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	} else {
 		return witness.GetConfigCellDataBuilderByTx(res.Transaction, configCell.OutPoint.Index)
@@ -228,7 +244,7 @@ func (d *DasCore) ConfigCellDataBuilderByTypeArgsList(list ...common.ConfigCellT
 		if err != nil {
 			return nil, fmt.Errorf("GetDasConfigCellInfo err: %s", err.Error())
 		}
-		res, err := d.client.GetTransaction(d.ctx, configCell.OutPoint.TxHash)
+		res, err := d.chain.GetTransaction(d.ctx, configCell.OutPoint.TxHash)
 		if err != nil {
 			return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 		}
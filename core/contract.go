@@ -16,10 +16,8 @@ type DasContractInfo struct {
 }
 
 func (d *DasCore) InitDasContract(mapDasContractTypeArgs map[common.DasContractName]string) {
-	var outPutLock *types.Script
-	if d.net == common.DasNetTypeMainNet || d.net == common.DasNetTypeTestnet2 {
-		outPutLock = common.GetNormalLockScriptByMultiSig(d.dasContractArgs)
-	} else {
+	outPutLock := networkLock(d.net, d.dasContractArgs)
+	if outPutLock == nil {
 		outPutLock = common.GetNormalLockScript(d.dasContractArgs)
 	}
 
@@ -86,7 +84,7 @@ func (d *DasCore) asyncDasContract() {
 			},
 		}
 		//now := time.Now()
-		res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 1, "")
+		res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 1, "")
 		if err != nil {
 			log.Error("GetCells err:", key, err.Error())
 			return true
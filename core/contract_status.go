@@ -55,23 +55,17 @@ func (d *DasCore) CheckContractVersionV2(systemStatus *witness.ConfigCellDataBui
 		err = fmt.Errorf("systemStatus config cell is nil")
 		return
 	}
-	var defaultContractStatus common.ContractStatus
-	var ok bool
-
-	switch d.net {
-	case common.DasNetTypeMainNet, common.DasNetTypeTestnet3:
-		defaultContractStatus, ok = ContractStatusMapMainNet[contractName]
-	case common.DasNetTypeTestnet2:
-		defaultContractStatus, ok = ContractStatusMapTestNet[contractName]
-	default:
+	profile, err := common.GetNetwork(d.net)
+	if err != nil {
 		err = fmt.Errorf("unknow net[%d]", d.net)
 		return
 	}
+	defaultV, ok := profile.ContractVersions[contractName]
 	if !ok {
 		err = fmt.Errorf("unkonw contract name[%s]", contractName)
 		return
 	}
-	defaultV = defaultContractStatus.Version
+	defaultContractStatus := common.ContractStatus{Version: defaultV}
 
 	// get chain status
 	contractStatus, err := systemStatus.GetContractStatus(contractName)
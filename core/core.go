@@ -23,6 +23,7 @@ var (
 
 type DasCore struct {
 	client              rpc.Client
+	chain               ChainReader
 	ctx                 context.Context
 	wg                  *sync.WaitGroup
 	dasContractCodeHash string // contract code hash
@@ -47,6 +48,10 @@ func (d *DasCore) Client() rpc.Client {
 	return d.client
 }
 
+func (d *DasCore) ChainReader() ChainReader {
+	return d.chain
+}
+
 func (d *DasCore) NetType() common.DasNetType {
 	return d.net
 }
@@ -55,17 +60,17 @@ func (d *DasCore) Daf() *DasAddressFormat {
 	return d.daf
 }
 
+// Redis is the client set by WithDasRedis, like for dascache.NewRedisBackend
+func (d *DasCore) Redis() *redis.Client {
+	return d.red
+}
+
 func (d *DasCore) GetDasLock() *types.Script {
-	switch d.net {
-	case common.DasNetTypeMainNet:
-		return common.GetNormalLockScriptByMultiSig(EnvMainNet.ContractArgs)
-	case common.DasNetTypeTestnet2:
-		return common.GetNormalLockScriptByMultiSig(EnvTestnet2.ContractArgs)
-	case common.DasNetTypeTestnet3:
-		return common.GetNormalLockScript(EnvTestnet3.ContractArgs)
-	default:
+	profile, err := common.GetNetwork(d.net)
+	if err != nil {
 		return nil
 	}
+	return networkLock(d.net, profile.ContractArgs)
 }
 
 func SetLogLevel(level int) {
@@ -41,7 +41,7 @@ func (d *DidCellInfo) GetDataInfo() (*witness.SporeData, *witness.DidCellDataLV,
 
 func (d *DidCellInfo) GetLockAddress(netType common.DasNetType) (string, error) {
 	mode := address.Mainnet
-	if netType != common.DasNetTypeMainNet {
+	if !common.IsMainNet(netType) {
 		mode = address.Testnet
 	}
 	addr, err := address.ConvertScriptToAddress(mode, d.Lock)
@@ -85,9 +85,7 @@ func (d *DasCore) TxToDidCellEntityAndAction(tx *types.Transaction) (common.DidC
 	isDidCellTx := false
 	if len(res.Outputs) == 0 {
 		for _, v := range tx.CellDeps {
-			switch v.OutPoint.TxHash.String() {
-			case witness.DidCellCellDepsFalgTestnet,
-				witness.DidCellCellDepsFalgMainnet:
+			if witness.IsDidCellRecycleCellDep(v.OutPoint) {
 				isDidCellTx = true
 				break
 			}
@@ -98,7 +96,7 @@ func (d *DasCore) TxToDidCellEntityAndAction(tx *types.Transaction) (common.DidC
 
 	if isDidCellTx {
 		for i, v := range tx.Inputs {
-			txRes, err := d.client.GetTransaction(context.Background(), v.PreviousOutput.TxHash)
+			txRes, err := d.chain.GetTransaction(context.Background(), v.PreviousOutput.TxHash)
 			if err != nil {
 				return "", res, fmt.Errorf("GetTransaction err: %s", err.Error())
 			}
@@ -186,7 +184,7 @@ func (d *DasCore) TxToDidCellEntityAndAction(tx *types.Transaction) (common.DidC
 //	previousOutputHash := tx.Inputs[inputsIndex].PreviousOutput.TxHash
 //	previousOutputIndex := tx.Inputs[inputsIndex].PreviousOutput.Index
 //
-//	previousTx, err := d.client.GetTransaction(d.ctx, previousOutputHash)
+//	previousTx, err := d.chain.GetTransaction(d.ctx, previousOutputHash)
 //	if err != nil {
 //		return "", fmt.Errorf("GetTransaction err: %s", err.Error())
 //	}
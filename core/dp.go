@@ -17,11 +17,12 @@ type ParamGetDpCells struct {
 	AmountNeed         uint64
 	CurrentBlockNumber uint64
 	SearchOrder        indexer.SearchOrder
+	ClaimCells         bool // lock the selected cells in DasCache by TryAddOutPoint if AmountNeed is set, they are released if it fails
 }
 
 func (d *DasCore) GetDpCells(p *ParamGetDpCells) ([]*indexer.LiveCell, uint64, uint64, error) {
-	if d.client == nil {
-		return nil, 0, 0, fmt.Errorf("client is nil")
+	if d.chain == nil {
+		return nil, 0, 0, fmt.Errorf("chain reader is nil")
 	}
 	if p == nil {
 		return nil, 0, 0, fmt.Errorf("param is nil")
@@ -50,9 +51,13 @@ func (d *DasCore) GetDpCells(p *ParamGetDpCells) ([]*indexer.LiveCell, uint64, u
 	lastCursor := ""
 
 	ok := false
+	claim := p.ClaimCells && p.AmountNeed > 0
 	for {
-		liveCells, err := d.client.GetCells(context.Background(), searchKey, p.SearchOrder, indexer.SearchLimit, lastCursor)
+		liveCells, err := d.chain.GetCells(context.Background(), searchKey, p.SearchOrder, indexer.SearchLimit, lastCursor)
 		if err != nil {
+			if claim {
+				releaseCells(p.DasCache, cells)
+			}
 			return nil, 0, 0, err
 		}
 		//log.Info("liveCells:", liveCells.LastCursor, len(liveCells.Objects))
@@ -65,7 +70,7 @@ func (d *DasCore) GetDpCells(p *ParamGetDpCells) ([]*indexer.LiveCell, uint64, u
 			if liveCell.Output.Type != nil && !dpContract.IsSameTypeId(liveCell.Output.Type.CodeHash) {
 				continue
 			}
-			if p.AmountNeed > 0 && p.DasCache != nil && p.DasCache.ExistOutPoint(common.OutPointStruct2String(liveCell.OutPoint)) {
+			if p.AmountNeed > 0 && !pickCell(p.DasCache, liveCell, claim) {
 				hasCache = true
 				continue
 			}
@@ -74,6 +79,9 @@ func (d *DasCore) GetDpCells(p *ParamGetDpCells) ([]*indexer.LiveCell, uint64, u
 
 			dpData, err := witness.ConvertBysToDPData(liveCell.OutputData)
 			if err != nil {
+				if claim {
+					releaseCells(p.DasCache, cells)
+				}
 				return nil, 0, 0, err
 			}
 
@@ -91,6 +99,9 @@ func (d *DasCore) GetDpCells(p *ParamGetDpCells) ([]*indexer.LiveCell, uint64, u
 	}
 	log.Info("GetDpCells:", p.AmountNeed, totalAmount)
 	if p.AmountNeed > 0 && totalAmount < p.AmountNeed {
+		if claim {
+			releaseCells(p.DasCache, cells)
+		}
 		if hasCache {
 			return cells, totalAmount, totalCapacity, ErrRejectedOutPoint
 		}
@@ -323,7 +334,7 @@ func (d *DasCore) GetInputsDPInfo(tx *types.Transaction) (map[string]TxDPInfo, e
 	for _, v := range tx.Inputs {
 		tmpTx, ok := mapTx[v.PreviousOutput.TxHash.Hex()]
 		if !ok {
-			txStatus, err := d.client.GetTransaction(d.ctx, v.PreviousOutput.TxHash)
+			txStatus, err := d.chain.GetTransaction(d.ctx, v.PreviousOutput.TxHash)
 			if err != nil {
 				return res, fmt.Errorf("GetTransaction err: %s", err.Error())
 			}
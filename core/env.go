@@ -1,6 +1,10 @@
 package core
 
-import "github.com/dotbitHQ/das-lib/common"
+import (
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+)
 
 type Env struct {
 	THQCodeHash      string
@@ -117,30 +121,111 @@ var EnvTestnet3 = Env{
 	},
 }
 
+// the deps of the ckb mainnet and testnet, shared by the networks on the same chain
+var (
+	chainDepsMainNet = chainDeps{
+		secpDep:           &common.NetworkCellDep{TxHash: "0x71a7ba8fc96349fea0ed3a5c47992e3b4084b031a42264a018e0072e8172e46c", DepType: string(types.DepTypeDepGroup)},
+		didCellRecycleDep: &common.NetworkCellDep{TxHash: witness.DidCellCellDepsFalgMainnet, DepType: string(types.DepTypeCode)},
+		clusterId:         witness.ClusterIdMainnet,
+		anyLockDeps: map[string]*common.NetworkCellDep{
+			string(AnyLockNameOmniLock): {TypeArgs: "0x855508fe0f0ca25b935b070452ecaee48f6c9f1d66cd15f046616b99e948236a"},
+			string(AnyLockNameNoStr):    {TypeArgs: "0xfad8cb75eb0bb01718e2336002064568bc05887af107f74ed5dd501829e192f8"},
+			string(AnyLockNameJoyID):    {TxHash: "0xf05188e5f3a6767fc4687faf45ba5f1a6e25d3ada6129dae8722cb282f262493", DepType: string(types.DepTypeDepGroup)},
+		},
+	}
+	chainDepsTestNet = chainDeps{
+		secpDep:           &common.NetworkCellDep{TxHash: "0xf8de3bb47d055cdf460d93a2a6e1b05f7432f9777c8c474abf4eec1d4aee5d37", DepType: string(types.DepTypeDepGroup)},
+		didCellRecycleDep: &common.NetworkCellDep{TxHash: witness.DidCellCellDepsFalgTestnet, DepType: string(types.DepTypeCode)},
+		clusterId:         witness.ClusterIdTestnet,
+		anyLockDeps: map[string]*common.NetworkCellDep{
+			string(AnyLockNameOmniLock): {TypeArgs: "0x761f51fc9cd6a504c32c6ae64b3746594d1af27629b427c5ccf6c9a725a89144"},
+			string(AnyLockNameNoStr):    {TypeArgs: "0x8dc56c6f35f0c535e23ded1629b1f20535477a1b43e59f14617d11e32c50e0aa"},
+			string(AnyLockNameJoyID):    {TxHash: "0x4dcf3f3b09efac8995d6cbee87c5345e812d310094651e0c3d9a730f32dc9263", DepType: string(types.DepTypeDepGroup)},
+		},
+	}
+)
+
+type chainDeps struct {
+	secpDep           *common.NetworkCellDep
+	didCellRecycleDep *common.NetworkCellDep
+	clusterId         string
+	anyLockDeps       map[string]*common.NetworkCellDep
+}
+
+func init() {
+	for _, v := range []*common.NetworkProfile{
+		newNetworkProfile("mainnet", common.DasNetTypeMainNet, "ckb", true, true, EnvMainNet, ContractStatusMapMainNet, chainDepsMainNet),
+		newNetworkProfile("testnet2", common.DasNetTypeTestnet2, "ckb_testnet", false, true, EnvTestnet2, ContractStatusMapTestNet, chainDepsTestNet),
+		newNetworkProfile("testnet3", common.DasNetTypeTestnet3, "", false, false, EnvTestnet3, ContractStatusMapMainNet, chainDepsTestNet),
+	} {
+		if err := common.RegisterNetwork(v); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func newNetworkProfile(name string, net common.DasNetType, chain string, mainNet, multiSig bool, env Env, mapStatus map[common.DasContractName]common.ContractStatus, deps chainDeps) *common.NetworkProfile {
+	profile := common.NetworkProfile{
+		Name:                 name,
+		NetType:              net,
+		Chain:                chain,
+		MainNet:              mainNet,
+		ContractArgsMultiSig: multiSig,
+		THQCodeHash:          env.THQCodeHash,
+		ContractArgs:         env.ContractArgs,
+		ContractCodeHash:     env.ContractCodeHash,
+		Contracts:            env.MapContract,
+		SoScripts:            env.MapSoScript,
+		ContractVersions:     make(map[common.DasContractName]string),
+		SecpDep:              deps.secpDep,
+		AnyLockDeps:          deps.anyLockDeps,
+		DidCellRecycleDep:    deps.didCellRecycleDep,
+		ClusterId:            deps.clusterId,
+	}
+	for k, v := range mapStatus {
+		profile.ContractVersions[k] = v.Version
+	}
+	return &profile
+}
+
+// EnvFromNetwork returns the Env of the registered network
+func EnvFromNetwork(net common.DasNetType) (Env, error) {
+	profile, err := common.GetNetwork(net)
+	if err != nil {
+		return Env{}, err
+	}
+	return Env{
+		THQCodeHash:      profile.THQCodeHash,
+		ContractArgs:     profile.ContractArgs,
+		ContractCodeHash: profile.ContractCodeHash,
+		MapContract:      profile.Contracts,
+		MapSoScript:      profile.SoScripts,
+	}, nil
+}
+
+// InitEnv returns EnvMainNet if the network is not registered
 func InitEnv(net common.DasNetType) Env {
-	switch net {
-	case common.DasNetTypeMainNet:
-		return EnvMainNet
-	case common.DasNetTypeTestnet2:
-		return EnvTestnet2
-	case common.DasNetTypeTestnet3:
-		return EnvTestnet3
-	default:
+	env, err := EnvFromNetwork(net)
+	if err != nil {
 		return EnvMainNet
 	}
+	return env
 }
 
 func InitEnvOpt(net common.DasNetType, names ...common.DasContractName) Env {
-	switch net {
-	case common.DasNetTypeMainNet:
-		return initEnvOpt(EnvMainNet, names...)
-	case common.DasNetTypeTestnet2:
-		return initEnvOpt(EnvTestnet2, names...)
-	case common.DasNetTypeTestnet3:
-		return initEnvOpt(EnvTestnet3, names...)
-	default:
-		return initEnvOpt(EnvMainNet, names...)
+	return initEnvOpt(InitEnv(net), names...)
+}
+
+// networkLock returns the lock of the das contracts and the das lock of the network
+func networkLock(net common.DasNetType, args string) *types.Script {
+	profile, err := common.GetNetwork(net)
+	if err != nil {
+		return nil
+	}
+	if profile.ContractArgsMultiSig {
+		return common.GetNormalLockScriptByMultiSig(args)
 	}
+	return common.GetNormalLockScript(args)
 }
 
 func initEnvOpt(envNet Env, names ...common.DasContractName) Env {
@@ -0,0 +1,557 @@
+package core
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sort"
+	"strings"
+)
+
+type TxExplain struct {
+	TxHash       string                  `json:"tx_hash"`
+	Action       common.DasAction        `json:"action"`
+	ActionParams string                  `json:"action_params"`
+	Accounts     []TxExplainAccount      `json:"accounts"`
+	Sale         *TxExplainSale          `json:"sale,omitempty"`
+	Offers       []TxExplainOffer        `json:"offers,omitempty"`
+	Incomes      []TxExplainIncome       `json:"incomes,omitempty"`
+	Capacity     []TxExplainCapacityFlow `json:"capacity"`
+	DP           []TxExplainDPFlow       `json:"dp,omitempty"`
+	Fee          uint64                  `json:"fee"`
+	SignGroups   []TxExplainSignGroup    `json:"sign_groups"`
+}
+
+type TxExplainAccount struct {
+	Account        string                `json:"account"`
+	AccountId      string                `json:"account_id"`
+	OldOwner       string                `json:"old_owner,omitempty"`
+	NewOwner       string                `json:"new_owner,omitempty"`
+	OldManager     string                `json:"old_manager,omitempty"`
+	NewManager     string                `json:"new_manager,omitempty"`
+	OwnerChanged   bool                  `json:"owner_changed"`
+	ManagerChanged bool                  `json:"manager_changed"`
+	OldStatus      uint8                 `json:"old_status"`
+	NewStatus      uint8                 `json:"new_status"`
+	OldExpiredAt   uint64                `json:"old_expired_at,omitempty"`
+	NewExpiredAt   uint64                `json:"new_expired_at,omitempty"`
+	RecordDiffs    []TxExplainRecordDiff `json:"record_diffs,omitempty"`
+}
+
+type TxExplainRecordOp = string
+
+const (
+	TxExplainRecordOpAdd    TxExplainRecordOp = "add"
+	TxExplainRecordOpRemove TxExplainRecordOp = "remove"
+	TxExplainRecordOpUpdate TxExplainRecordOp = "update"
+)
+
+type TxExplainRecordDiff struct {
+	Op       TxExplainRecordOp `json:"op"`
+	Key      string            `json:"key"`
+	Type     string            `json:"type"`
+	Label    string            `json:"label"`
+	OldValue string            `json:"old_value,omitempty"`
+	NewValue string            `json:"new_value,omitempty"`
+	OldTTL   uint32            `json:"old_ttl,omitempty"`
+	NewTTL   uint32            `json:"new_ttl,omitempty"`
+}
+
+type TxExplainSale struct {
+	Account  string `json:"account"`
+	OldPrice uint64 `json:"old_price,omitempty"`
+	NewPrice uint64 `json:"new_price,omitempty"`
+}
+
+type TxExplainOffer struct {
+	Account string `json:"account"`
+	Price   uint64 `json:"price"`
+	Message string `json:"message"`
+	New     bool   `json:"new"` // false for the offers consumed by the tx
+}
+
+type TxExplainIncome struct {
+	Address  string `json:"address"`
+	Capacity uint64 `json:"capacity"`
+}
+
+type TxExplainCapacityFlow struct {
+	Address string `json:"address"`
+	In      uint64 `json:"in"`
+	Out     uint64 `json:"out"`
+}
+
+func (t TxExplainCapacityFlow) Net() int64 {
+	return int64(t.Out) - int64(t.In)
+}
+
+type TxExplainDPFlow struct {
+	Address string `json:"address"`
+	In      uint64 `json:"in"`
+	Out     uint64 `json:"out"`
+}
+
+type TxExplainSignGroup struct {
+	LockHash string `json:"lock_hash"`
+	Address  string `json:"address"`
+	Role     string `json:"role,omitempty"` // owner or manager for das-lock
+	Inputs   []int  `json:"inputs"`
+}
+
+// ExplainTransaction summarizes tx for humans, the inputs are resolved by their previous txs,
+// so committed txs can be explained as well
+func (d *DasCore) ExplainTransaction(tx *types.Transaction) (*TxExplain, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("tx is nil")
+	}
+	var res TxExplain
+	if txHash, err := tx.ComputeHash(); err == nil {
+		res.TxHash = txHash.Hex()
+	}
+
+	builder, err := witness.ActionDataBuilderFromTx(tx)
+	if err != nil && err != witness.ErrNotExistActionData {
+		return nil, fmt.Errorf("ActionDataBuilderFromTx err: %s", err.Error())
+	} else if builder != nil {
+		res.Action = builder.Action
+		res.ActionParams = builder.ParamsStr
+	}
+
+	inputs, inputsData, err := d.explainInputs(tx)
+	if err != nil {
+		return nil, fmt.Errorf("explainInputs err: %s", err.Error())
+	}
+
+	if err = d.explainAccounts(tx, inputs, &res); err != nil {
+		return nil, fmt.Errorf("explainAccounts err: %s", err.Error())
+	}
+	for i := range res.Accounts {
+		acc := &res.Accounts[i]
+		for j, v := range inputs {
+			if expiredAt, ok := d.explainAccountCellExpiredAt(v, inputsData[j], acc.AccountId); ok {
+				acc.OldExpiredAt = expiredAt
+			}
+		}
+	}
+	d.explainSaleAndOffers(tx, &res)
+	d.explainIncomes(tx, &res)
+
+	// capacity
+	var mapFlow = make(map[string]*TxExplainCapacityFlow)
+	var sortList []string
+	getFlow := func(lock *types.Script) *TxExplainCapacityFlow {
+		addr := d.explainAddress(lock)
+		item, ok := mapFlow[addr]
+		if !ok {
+			item = &TxExplainCapacityFlow{Address: addr}
+			mapFlow[addr] = item
+			sortList = append(sortList, addr)
+		}
+		return item
+	}
+	totalIn := uint64(0)
+	for _, v := range inputs {
+		getFlow(v.Lock).In += v.Capacity
+		totalIn += v.Capacity
+	}
+	for _, v := range tx.Outputs {
+		getFlow(v.Lock).Out += v.Capacity
+	}
+	for _, v := range sortList {
+		res.Capacity = append(res.Capacity, *mapFlow[v])
+	}
+	if totalOut := tx.OutputsCapacity(); totalIn > totalOut {
+		res.Fee = totalIn - totalOut
+	}
+
+	// dp
+	if err = d.explainDP(tx, &res); err != nil {
+		return nil, fmt.Errorf("explainDP err: %s", err.Error())
+	}
+
+	// sign groups, same order as the digest of txbuilder
+	var mapGroup = make(map[string]*TxExplainSignGroup)
+	var groupList []string
+	for i, v := range inputs {
+		lockHash, err := v.Lock.Hash()
+		if err != nil {
+			return nil, fmt.Errorf("lock hash err: %s", err.Error())
+		}
+		item, ok := mapGroup[lockHash.Hex()]
+		if !ok {
+			item = &TxExplainSignGroup{LockHash: lockHash.Hex(), Address: d.explainAddress(v.Lock)}
+			if dispatch, err := GetDasContractInfo(common.DasContractNameDispatchCellType); err == nil &&
+				dispatch.IsSameTypeId(v.Lock.CodeHash) && builder != nil {
+				item.Role = explainSignRole(builder)
+			}
+			mapGroup[lockHash.Hex()] = item
+			groupList = append(groupList, lockHash.Hex())
+		}
+		item.Inputs = append(item.Inputs, i)
+	}
+	sort.Strings(groupList)
+	for _, v := range groupList {
+		res.SignGroups = append(res.SignGroups, *mapGroup[v])
+	}
+	return &res, nil
+}
+
+// explainSignRole is the signer of das-lock, the same as the sign type of the digest in txbuilder
+func explainSignRole(builder *witness.ActionDataBuilder) string {
+	if builder.Action == common.DasActionEditRecords {
+		return "manager"
+	}
+	if len(builder.Params) > 0 {
+		if p := builder.Params[len(builder.Params)-1]; len(p) > 0 && common.Bytes2Hex(p) == common.ParamManager {
+			return "manager"
+		}
+	}
+	return "owner"
+}
+
+func (d *DasCore) explainInputs(tx *types.Transaction) ([]*types.CellOutput, [][]byte, error) {
+	var mapTx = make(map[string]*types.Transaction)
+	var outputs = make([]*types.CellOutput, 0, len(tx.Inputs))
+	var outputsData = make([][]byte, 0, len(tx.Inputs))
+	for _, v := range tx.Inputs {
+		preTx, ok := mapTx[v.PreviousOutput.TxHash.Hex()]
+		if !ok {
+			txStatus, err := d.chain.GetTransaction(d.ctx, v.PreviousOutput.TxHash)
+			if err != nil {
+				return nil, nil, fmt.Errorf("GetTransaction err: %s", err.Error())
+			}
+			preTx = txStatus.Transaction
+			mapTx[v.PreviousOutput.TxHash.Hex()] = preTx
+		}
+		if int(v.PreviousOutput.Index) >= len(preTx.Outputs) {
+			return nil, nil, fmt.Errorf("invalid input: %s", common.OutPointStruct2String(v.PreviousOutput))
+		}
+		var data []byte
+		if int(v.PreviousOutput.Index) < len(preTx.OutputsData) {
+			data = preTx.OutputsData[v.PreviousOutput.Index]
+		}
+		outputs = append(outputs, preTx.Outputs[v.PreviousOutput.Index])
+		outputsData = append(outputsData, data)
+	}
+	return outputs, outputsData, nil
+}
+
+func (d *DasCore) explainAddress(lock *types.Script) string {
+	if lock == nil {
+		return ""
+	}
+	ownerHex, _, err := d.daf.ScriptToHex(lock)
+	if err != nil {
+		mode := address.Mainnet
+		if !common.IsMainNet(d.net) {
+			mode = address.Testnet
+		}
+		if addr, err := common.ConvertScriptToAddress(mode, lock); err == nil {
+			return addr
+		}
+		return common.Bytes2Hex(lock.Args)
+	}
+	if ownerHex.DasAlgorithmId == common.DasAlgorithmIdAnyLock {
+		return ownerHex.AddressHex
+	}
+	if ownerNormal, err := d.daf.HexToNormal(ownerHex); err == nil {
+		return ownerNormal.AddressNormal
+	}
+	return ownerHex.AddressHex
+}
+
+func (d *DasCore) explainOwnerManager(lock *types.Script) (owner, manager string) {
+	if lock == nil {
+		return
+	}
+	dispatch, err := GetDasContractInfo(common.DasContractNameDispatchCellType)
+	if err != nil || !dispatch.IsSameTypeId(lock.CodeHash) {
+		addr := d.explainAddress(lock)
+		return addr, addr
+	}
+	ownerNormal, managerNormal, err := d.daf.ArgsToNormal(lock.Args)
+	if err != nil {
+		return common.Bytes2Hex(lock.Args), common.Bytes2Hex(lock.Args)
+	}
+	return ownerNormal.AddressNormal, managerNormal.AddressNormal
+}
+
+func (d *DasCore) explainAccountCellExpiredAt(output *types.CellOutput, data []byte, accountId string) (uint64, bool) {
+	if output.Type == nil {
+		return 0, false
+	}
+	if accContract, err := GetDasContractInfo(common.DasContractNameAccountCellType); err != nil || !accContract.IsSameTypeId(output.Type.CodeHash) {
+		return 0, false
+	}
+	id, err := common.OutputDataToAccountId(data)
+	if err != nil || common.Bytes2Hex(id) != accountId {
+		return 0, false
+	}
+	expiredAt, err := common.GetAccountCellExpiredAtFromOutputData(data)
+	if err != nil {
+		return 0, false
+	}
+	return expiredAt, true
+}
+
+func (d *DasCore) explainAccounts(tx *types.Transaction, inputs []*types.CellOutput, res *TxExplain) error {
+	mapOld, err := witness.AccountCellDataBuilderMapFromTx(tx, common.DataTypeOld)
+	if err != nil {
+		mapOld = make(map[string]*witness.AccountCellDataBuilder)
+	}
+	mapNew, err := witness.AccountCellDataBuilderMapFromTx(tx, common.DataTypeNew)
+	if err != nil {
+		mapNew = make(map[string]*witness.AccountCellDataBuilder)
+	}
+	var accList []string
+	for k := range mapOld {
+		accList = append(accList, k)
+	}
+	for k := range mapNew {
+		if _, ok := mapOld[k]; !ok {
+			accList = append(accList, k)
+		}
+	}
+	sort.Strings(accList)
+
+	for _, acc := range accList {
+		item := TxExplainAccount{Account: acc}
+		oldBuilder, okOld := mapOld[acc]
+		newBuilder, okNew := mapNew[acc]
+		if okOld {
+			item.AccountId = oldBuilder.AccountId
+			item.OldStatus = oldBuilder.Status
+			if int(oldBuilder.Index) < len(inputs) {
+				item.OldOwner, item.OldManager = d.explainOwnerManager(inputs[oldBuilder.Index].Lock)
+			}
+		}
+		if okNew {
+			item.AccountId = newBuilder.AccountId
+			item.NewStatus = newBuilder.Status
+			item.NewExpiredAt = newBuilder.ExpiredAt
+			if int(newBuilder.Index) < len(tx.Outputs) {
+				item.NewOwner, item.NewManager = d.explainOwnerManager(tx.Outputs[newBuilder.Index].Lock)
+			}
+		}
+		if okOld && okNew {
+			item.OwnerChanged = item.OldOwner != item.NewOwner
+			item.ManagerChanged = item.OldManager != item.NewManager
+			item.RecordDiffs = diffRecords(oldBuilder.Records, newBuilder.Records)
+		}
+		res.Accounts = append(res.Accounts, item)
+	}
+	return nil
+}
+
+func diffRecords(oldRecords, newRecords []witness.Record) []TxExplainRecordDiff {
+	recordKey := func(r witness.Record) string {
+		return r.Type + "." + r.Key + "." + r.Label
+	}
+	var mapOld = make(map[string]witness.Record)
+	var mapNew = make(map[string]witness.Record)
+	for _, v := range oldRecords {
+		mapOld[recordKey(v)] = v
+	}
+	for _, v := range newRecords {
+		mapNew[recordKey(v)] = v
+	}
+	var list []TxExplainRecordDiff
+	for _, v := range oldRecords {
+		n, ok := mapNew[recordKey(v)]
+		if !ok {
+			list = append(list, TxExplainRecordDiff{Op: TxExplainRecordOpRemove, Key: v.Key, Type: v.Type, Label: v.Label, OldValue: v.Value, OldTTL: v.TTL})
+		} else if n.Value != v.Value || n.TTL != v.TTL {
+			list = append(list, TxExplainRecordDiff{Op: TxExplainRecordOpUpdate, Key: v.Key, Type: v.Type, Label: v.Label, OldValue: v.Value, NewValue: n.Value, OldTTL: v.TTL, NewTTL: n.TTL})
+		}
+	}
+	for _, v := range newRecords {
+		if _, ok := mapOld[recordKey(v)]; !ok {
+			list = append(list, TxExplainRecordDiff{Op: TxExplainRecordOpAdd, Key: v.Key, Type: v.Type, Label: v.Label, NewValue: v.Value, NewTTL: v.TTL})
+		}
+	}
+	return list
+}
+
+func (d *DasCore) explainSaleAndOffers(tx *types.Transaction, res *TxExplain) {
+	oldSale, errOld := witness.AccountSaleCellDataBuilderFromTx(tx, common.DataTypeOld)
+	newSale, errNew := witness.AccountSaleCellDataBuilderFromTx(tx, common.DataTypeNew)
+	if errOld == nil || errNew == nil {
+		res.Sale = &TxExplainSale{}
+		if errOld == nil {
+			res.Sale.Account = oldSale.Account
+			res.Sale.OldPrice = oldSale.Price
+		}
+		if errNew == nil {
+			res.Sale.Account = newSale.Account
+			res.Sale.NewPrice = newSale.Price
+		}
+	}
+
+	for _, dataType := range []common.DataType{common.DataTypeOld, common.DataTypeNew} {
+		mapOffer, err := witness.OfferCellDataBuilderMapFromTx(tx, dataType)
+		if err != nil {
+			continue
+		}
+		for _, v := range mapOffer {
+			res.Offers = append(res.Offers, TxExplainOffer{
+				Account: v.Account,
+				Price:   v.Price,
+				Message: v.Message,
+				New:     dataType == common.DataTypeNew,
+			})
+		}
+	}
+	sort.Slice(res.Offers, func(i, j int) bool {
+		if res.Offers[i].New != res.Offers[j].New {
+			return !res.Offers[i].New
+		}
+		return res.Offers[i].Account < res.Offers[j].Account
+	})
+}
+
+func (d *DasCore) explainIncomes(tx *types.Transaction, res *TxExplain) {
+	list, err := witness.IncomeCellDataBuilderListFromTx(tx, common.DataTypeNew)
+	if err != nil {
+		return
+	}
+	for _, v := range list {
+		for _, r := range v.Records() {
+			res.Incomes = append(res.Incomes, TxExplainIncome{
+				Address:  d.explainAddress(molecule.MoleculeScript2CkbScript(r.BelongTo)),
+				Capacity: r.Capacity,
+			})
+		}
+	}
+}
+
+func (d *DasCore) explainDP(tx *types.Transaction, res *TxExplain) error {
+	if _, err := GetDasContractInfo(common.DasContractNameDpCellType); err != nil {
+		return nil
+	}
+	inputsDP, err := d.GetInputsDPInfo(tx)
+	if err != nil {
+		return fmt.Errorf("GetInputsDPInfo err: %s", err.Error())
+	}
+	outputsDP, err := d.GetOutputsDPInfo(tx)
+	if err != nil {
+		return fmt.Errorf("GetOutputsDPInfo err: %s", err.Error())
+	}
+	var mapFlow = make(map[string]*TxExplainDPFlow)
+	var sortList []string
+	getFlow := func(info TxDPInfo) *TxExplainDPFlow {
+		item, ok := mapFlow[info.Payload]
+		if !ok {
+			addr := info.Payload
+			if ownerNormal, _, err := d.daf.ArgsToNormal(info.Args); err == nil {
+				addr = ownerNormal.AddressNormal
+			}
+			item = &TxExplainDPFlow{Address: addr}
+			mapFlow[info.Payload] = item
+			sortList = append(sortList, info.Payload)
+		}
+		return item
+	}
+	for _, v := range inputsDP {
+		getFlow(v).In += v.AmountDP
+	}
+	for _, v := range outputsDP {
+		getFlow(v).Out += v.AmountDP
+	}
+	sort.Strings(sortList)
+	for _, v := range sortList {
+		res.DP = append(res.DP, *mapFlow[v])
+	}
+	return nil
+}
+
+// String renders the summary as plain text
+func (t *TxExplain) String() string {
+	var b strings.Builder
+	if t.TxHash != "" {
+		fmt.Fprintf(&b, "tx: %s\n", t.TxHash)
+	}
+	action := t.Action
+	if action == "" {
+		action = "(none)"
+	}
+	fmt.Fprintf(&b, "action: %s", action)
+	if t.ActionParams != "" {
+		fmt.Fprintf(&b, " [%s]", t.ActionParams)
+	}
+	b.WriteString("\n")
+
+	for _, v := range t.Accounts {
+		fmt.Fprintf(&b, "account: %s (%s)\n", v.Account, v.AccountId)
+		if v.OwnerChanged {
+			fmt.Fprintf(&b, "  owner: %s -> %s\n", v.OldOwner, v.NewOwner)
+		} else if v.NewOwner != "" || v.OldOwner != "" {
+			fmt.Fprintf(&b, "  owner: %s\n", firstNotEmpty(v.NewOwner, v.OldOwner))
+		}
+		if v.ManagerChanged {
+			fmt.Fprintf(&b, "  manager: %s -> %s\n", v.OldManager, v.NewManager)
+		} else if v.NewManager != "" || v.OldManager != "" {
+			fmt.Fprintf(&b, "  manager: %s\n", firstNotEmpty(v.NewManager, v.OldManager))
+		}
+		if v.OldStatus != v.NewStatus {
+			fmt.Fprintf(&b, "  status: %d -> %d\n", v.OldStatus, v.NewStatus)
+		}
+		if v.OldExpiredAt != 0 && v.NewExpiredAt != 0 && v.OldExpiredAt != v.NewExpiredAt {
+			fmt.Fprintf(&b, "  expired at: %d -> %d\n", v.OldExpiredAt, v.NewExpiredAt)
+		}
+		for _, r := range v.RecordDiffs {
+			switch r.Op {
+			case TxExplainRecordOpAdd:
+				fmt.Fprintf(&b, "  + record %s.%s [%s]: %s\n", r.Type, r.Key, r.Label, r.NewValue)
+			case TxExplainRecordOpRemove:
+				fmt.Fprintf(&b, "  - record %s.%s [%s]: %s\n", r.Type, r.Key, r.Label, r.OldValue)
+			default:
+				fmt.Fprintf(&b, "  ~ record %s.%s [%s]: %s -> %s\n", r.Type, r.Key, r.Label, r.OldValue, r.NewValue)
+			}
+		}
+	}
+	if t.Sale != nil {
+		fmt.Fprintf(&b, "sale: %s price %s -> %s\n", t.Sale.Account, witness.ConvertCapacity(t.Sale.OldPrice), witness.ConvertCapacity(t.Sale.NewPrice))
+	}
+	for _, v := range t.Offers {
+		state := "consumed"
+		if v.New {
+			state = "created"
+		}
+		fmt.Fprintf(&b, "offer %s: %s price %s\n", state, v.Account, witness.ConvertCapacity(v.Price))
+	}
+	for _, v := range t.Incomes {
+		fmt.Fprintf(&b, "income: %s %s\n", v.Address, witness.ConvertCapacity(v.Capacity))
+	}
+	b.WriteString("capacity:\n")
+	for _, v := range t.Capacity {
+		fmt.Fprintf(&b, "  %s in: %s out: %s\n", v.Address, witness.ConvertCapacity(v.In), witness.ConvertCapacity(v.Out))
+	}
+	if len(t.DP) > 0 {
+		b.WriteString("dp:\n")
+		for _, v := range t.DP {
+			fmt.Fprintf(&b, "  %s in: %d out: %d\n", v.Address, v.In, v.Out)
+		}
+	}
+	fmt.Fprintf(&b, "fee: %s\n", witness.ConvertCapacity(t.Fee))
+	b.WriteString("sign groups:\n")
+	for _, v := range t.SignGroups {
+		fmt.Fprintf(&b, "  %v %s", v.Inputs, v.Address)
+		if v.Role != "" {
+			fmt.Fprintf(&b, " (%s)", v.Role)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func firstNotEmpty(list ...string) string {
+	for _, v := range list {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -18,8 +18,7 @@ type KeyInfo struct {
 }
 
 func (c *ChainTypeAddress) GetChainId(net common.DasNetType) (chainId int64) {
-	switch net {
-	case common.DasNetTypeMainNet:
+	if common.IsMainNet(net) {
 		switch c.KeyInfo.CoinType {
 		case common.CoinTypeEth:
 			chainId = 1
@@ -28,7 +27,7 @@ func (c *ChainTypeAddress) GetChainId(net common.DasNetType) (chainId int64) {
 		case common.CoinTypeMatic:
 			chainId = 137
 		}
-	default:
+	} else {
 		switch c.KeyInfo.CoinType {
 		case common.CoinTypeEth:
 			chainId = 17000
@@ -80,7 +79,7 @@ func FormatChainTypeAddress(net common.DasNetType, chainType common.ChainType, k
 	}
 
 	var chainId common.ChainId
-	if net == common.DasNetTypeMainNet {
+	if common.IsMainNet(net) {
 		switch chainType {
 		case common.ChainTypeEth:
 			chainId = common.ChainIdEthMainNet
@@ -11,6 +11,15 @@ type DasCoreOption func(*DasCore)
 func WithClient(client rpc.Client) DasCoreOption {
 	return func(dc *DasCore) {
 		dc.client = client
+		if client != nil {
+			dc.chain = client
+		}
+	}
+}
+
+func WithChainReader(chain ChainReader) DasCoreOption {
+	return func(dc *DasCore) {
+		dc.chain = chain
 	}
 }
 
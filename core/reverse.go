@@ -18,7 +18,7 @@ func (d *DasCore) GetReverseRecordSmtCell() (*indexer.LiveCell, error) {
 		ArgsLen:    0,
 		Filter:     nil,
 	}
-	reverseRecordLiveCells, err := d.client.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
+	reverseRecordLiveCells, err := d.chain.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetReverseRecordSmtCell GetCells err: %s", err.Error())
 	}
@@ -15,12 +15,7 @@ type SoScript struct {
 }
 
 func (d *DasCore) InitDasSoScript() error {
-	mapSoScript := EnvMainNet.MapSoScript
-	if d.net == common.DasNetTypeTestnet2 {
-		mapSoScript = EnvTestnet2.MapSoScript
-	} else if d.net == common.DasNetTypeTestnet3 {
-		mapSoScript = EnvTestnet3.MapSoScript
-	}
+	mapSoScript := InitEnv(d.net).MapSoScript
 	//log.Info("mapSoScript:", mapSoScript)
 	for k, v := range mapSoScript {
 		//log.Info("InitDasSoScript:", k)
@@ -68,7 +63,7 @@ func (d *DasCore) asyncDasSoScript() error {
 			ScriptType: indexer.ScriptTypeType,
 		}
 		//now := time.Now()
-		res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 1, "")
+		res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 1, "")
 		if err != nil {
 			log.Error("GetCells err:", key, err.Error())
 			return true
@@ -23,7 +23,7 @@ func (d *DasCore) GetSubAccountCell(parentAccountId string) (*indexer.LiveCell,
 		ArgsLen:    0,
 		Filter:     nil,
 	}
-	subAccLiveCells, err := d.client.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
+	subAccLiveCells, err := d.chain.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -51,7 +51,7 @@ func (d *DasCore) GetCustomScriptLiveCell(data []byte) (*indexer.LiveCell, error
 		Script:     customScript,
 		ScriptType: indexer.ScriptTypeType,
 	}
-	customScriptCell, err := d.client.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
+	customScriptCell, err := d.chain.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
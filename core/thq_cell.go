@@ -30,7 +30,7 @@ func (d *DasCore) GetQuoteCell() (*QuoteCell, error) {
 		Script:     common.GetScript(d.thqCodeHash, common.ArgsQuoteCell),
 		ScriptType: indexer.ScriptTypeType,
 	}
-	res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
+	res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -47,7 +47,7 @@ func (d *DasCore) GetQuoteCellList() ([]*QuoteCell, error) {
 		Script:     common.GetScript(d.thqCodeHash, common.ArgsQuoteCell),
 		ScriptType: indexer.ScriptTypeType,
 	}
-	res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
+	res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -84,7 +84,7 @@ func (d *DasCore) GetTimeCell() (*TimeCell, error) {
 		Script:     common.GetScript(d.thqCodeHash, common.ArgsTimeCell),
 		ScriptType: indexer.ScriptTypeType,
 	}
-	res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
+	res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -101,7 +101,7 @@ func (d *DasCore) GetTimeCellList() ([]*TimeCell, error) {
 		Script:     common.GetScript(d.thqCodeHash, common.ArgsTimeCell),
 		ScriptType: indexer.ScriptTypeType,
 	}
-	res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
+	res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -137,7 +137,7 @@ func (d *DasCore) GetHeightCell() (*HeightCell, error) {
 		Script:     common.GetScript(d.thqCodeHash, common.ArgsHeightCell),
 		ScriptType: indexer.ScriptTypeType,
 	}
-	res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
+	res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -154,7 +154,7 @@ func (d *DasCore) GetHeightCellList() ([]*HeightCell, error) {
 		Script:     common.GetScript(d.thqCodeHash, common.ArgsHeightCell),
 		ScriptType: indexer.ScriptTypeType,
 	}
-	res, err := d.client.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
+	res, err := d.chain.GetCells(d.ctx, searchKey, indexer.SearchOrderDesc, 20, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -175,7 +175,7 @@ func (d *DasCore) GetTxQuote(tx *types.Transaction) (uint64, error) {
 	}
 	var quote uint64
 	for _, v := range tx.CellDeps {
-		cellDepTx, err := d.client.GetTransaction(d.ctx, v.OutPoint.TxHash)
+		cellDepTx, err := d.chain.GetTransaction(d.ctx, v.OutPoint.TxHash)
 		if err != nil {
 			return 0, fmt.Errorf("GetTransaction CellDeps err: %s", err.Error())
 		}
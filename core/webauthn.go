@@ -28,7 +28,7 @@ func (d *DasCore) GetKeyListCell(args []byte) (*indexer.LiveCell, error) {
 		},
 	}
 
-	keyListCells, err := d.client.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
+	keyListCells, err := d.chain.GetCells(d.ctx, &searchKey, indexer.SearchOrderDesc, 1, "")
 	if err != nil {
 		return nil, fmt.Errorf("GetCells err: %s", err.Error())
 	}
@@ -41,7 +41,7 @@ func (d *DasCore) GetKeyListCell(args []byte) (*indexer.LiveCell, error) {
 }
 
 func (d *DasCore) GetIdxOfKeylistByOutPoint(LoginkeyListOp *types.OutPoint, signAddr DasAddressHex) (int, error) {
-	keyListConfigTx, err := d.Client().GetTransaction(d.ctx, LoginkeyListOp.TxHash)
+	keyListConfigTx, err := d.chain.GetTransaction(d.ctx, LoginkeyListOp.TxHash)
 	if err != nil {
 		return 0, fmt.Errorf("GetTransaction err: " + err.Error())
 	}
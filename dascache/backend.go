@@ -0,0 +1,211 @@
+package dascache
+
+import (
+	"fmt"
+	"github.com/go-redis/redis"
+	"sync"
+	"time"
+)
+
+// Backend stores the pending outpoints with the owner (a DasCache) locking them, ttl 0 keeps the outpoint until it is cleared
+type Backend interface {
+	// TryAdd adds all the outpoints if none of them exists, it returns false without adding anything otherwise
+	TryAdd(owner string, outPoints []string, ttl time.Duration) (bool, error)
+	// Add adds the outpoints and renews the ones of owner, it returns false without adding anything
+	// if one of them is locked by another owner
+	Add(owner string, outPoints []string, ttl time.Duration) (bool, error)
+	Exist(outPoint string) (bool, error)
+	Clear(outPoints []string) error
+}
+
+type memOutPoint struct {
+	owner     string
+	expiredAt int64 // 0 means never
+}
+
+// MemBackend is the process-local backend, the expired outpoints are removed by ClearExpired
+type MemBackend struct {
+	rw          sync.RWMutex
+	mapOutPoint map[string]memOutPoint
+}
+
+func NewMemBackend() *MemBackend {
+	return &MemBackend{mapOutPoint: make(map[string]memOutPoint)}
+}
+
+func (m *MemBackend) TryAdd(owner string, outPoints []string, ttl time.Duration) (bool, error) {
+	return m.add(owner, outPoints, ttl, false), nil
+}
+
+func (m *MemBackend) Add(owner string, outPoints []string, ttl time.Duration) (bool, error) {
+	return m.add(owner, outPoints, ttl, true), nil
+}
+
+func (m *MemBackend) add(owner string, outPoints []string, ttl time.Duration, renew bool) bool {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	now := time.Now().Unix()
+	for _, v := range outPoints {
+		item, ok := m.mapOutPoint[v]
+		if !ok || item.expired(now) || (renew && item.owner == owner) {
+			continue
+		}
+		return false
+	}
+	item := memOutPoint{owner: owner, expiredAt: memExpiredAt(ttl)}
+	for _, v := range outPoints {
+		m.mapOutPoint[v] = item
+	}
+	return true
+}
+
+func (m memOutPoint) expired(now int64) bool {
+	return m.expiredAt != 0 && m.expiredAt < now
+}
+
+func (m *MemBackend) Exist(outPoint string) (bool, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	item, ok := m.mapOutPoint[outPoint]
+	return ok && !item.expired(time.Now().Unix()), nil
+}
+
+func (m *MemBackend) Clear(outPoints []string) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	for _, v := range outPoints {
+		delete(m.mapOutPoint, v)
+	}
+	return nil
+}
+
+func (m *MemBackend) ClearExpired() {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	now := time.Now().Unix()
+	log.Info("clearExpiredOutPoint before:", len(m.mapOutPoint))
+	for k, v := range m.mapOutPoint {
+		if v.expired(now) {
+			delete(m.mapOutPoint, k)
+		}
+	}
+	log.Info("clearExpiredOutPoint after:", len(m.mapOutPoint))
+}
+
+func memExpiredAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).Unix()
+}
+
+// RedisBackend shares the pending outpoints between the processes,
+// an outpoint is a key of prefix+outpoint expired by redis
+type RedisBackend struct {
+	red    *redis.Client
+	prefix string
+}
+
+const DefaultRedisPrefix = "das:outpoint:"
+
+func NewRedisBackend(red *redis.Client, prefix string) *RedisBackend {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return &RedisBackend{red: red, prefix: prefix}
+}
+
+// TryAdd sets the keys to owner by SET NX one by one, the keys already set are deleted if one of them exists
+func (r *RedisBackend) TryAdd(owner string, outPoints []string, ttl time.Duration) (bool, error) {
+	var added []string
+	for _, v := range outPoints {
+		ok, err := r.red.SetNX(r.prefix+v, owner, redisTTL(ttl)).Result()
+		if err == nil && ok {
+			added = append(added, v)
+			continue
+		}
+		r.clearAdded(added)
+		if err != nil {
+			return false, fmt.Errorf("SetNX err: %s", err.Error())
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// redisAddScript sets the key by SET NX, or renews it if the value is the owner.
+// It returns 1 if the key is set, 2 if renewed and 0 if the key is locked by another owner
+var redisAddScript = redis.NewScript(`
+local set
+if ARGV[2] == '0' then
+	set = redis.call('SET', KEYS[1], ARGV[1], 'NX')
+else
+	set = redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2])
+end
+if set then
+	return 1
+end
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '0' then
+	redis.call('PERSIST', KEYS[1])
+else
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 2`)
+
+// Add runs redisAddScript key by key, the keys set by it are deleted if one of them is locked by another owner
+func (r *RedisBackend) Add(owner string, outPoints []string, ttl time.Duration) (bool, error) {
+	var added []string
+	for _, v := range outPoints {
+		res, err := redisAddScript.Run(r.red, []string{r.prefix + v}, owner, redisTTL(ttl).Milliseconds()).Int()
+		if err == nil && res != 0 {
+			if res == 1 {
+				added = append(added, v)
+			}
+			continue
+		}
+		r.clearAdded(added)
+		if err != nil {
+			return false, fmt.Errorf("redisAddScript err: %s", err.Error())
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *RedisBackend) clearAdded(added []string) {
+	if err := r.Clear(added); err != nil {
+		log.Error("Clear err:", err.Error())
+	}
+}
+
+func (r *RedisBackend) Exist(outPoint string) (bool, error) {
+	n, err := r.red.Exists(r.prefix + outPoint).Result()
+	if err != nil {
+		return false, fmt.Errorf("Exists err: %s", err.Error())
+	}
+	return n > 0, nil
+}
+
+func (r *RedisBackend) Clear(outPoints []string) error {
+	if len(outPoints) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(outPoints))
+	for _, v := range outPoints {
+		keys = append(keys, r.prefix+v)
+	}
+	if err := r.red.Del(keys...).Err(); err != nil {
+		return fmt.Errorf("Del err: %s", err.Error())
+	}
+	return nil
+}
+
+func redisTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return 0
+	}
+	return ttl
+}
@@ -2,6 +2,8 @@ package dascache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/http_api/logger"
 	"github.com/nervosnetwork/ckb-sdk-go/types"
@@ -12,98 +14,143 @@ import (
 
 var log = logger.NewLogger("cache", mylog.LevelDebug)
 
+// DefaultOutPointTTL is how long AddOutPoint keeps the outpoints if neither WithOutPointTTL nor RunClearExpiredOutPoint sets it
+const DefaultOutPointTTL = time.Minute * 3
+
 type DasCache struct {
-	ctx         context.Context
-	wg          *sync.WaitGroup
-	rw          sync.RWMutex
-	mapOutPoint map[string]int64
+	ctx     context.Context
+	wg      *sync.WaitGroup
+	rw      sync.RWMutex
+	backend Backend
+	ttl     time.Duration
+	owner   string // locks the outpoints in the backend
 }
 
-func NewDasCache(ctx context.Context, wg *sync.WaitGroup) *DasCache {
-	return &DasCache{
-		ctx:         ctx,
-		wg:          wg,
-		rw:          sync.RWMutex{},
-		mapOutPoint: make(map[string]int64),
+type DasCacheOption func(*DasCache)
+
+// WithBackend replaces the process-local backend, like NewRedisBackend to share the outpoints between the replicas
+func WithBackend(backend Backend) DasCacheOption {
+	return func(d *DasCache) {
+		d.backend = backend
 	}
 }
 
-func (d *DasCache) AddOutPoint(outPoint []string) {
-	if len(outPoint) == 0 {
-		return
-	}
-	d.rw.Lock()
-	defer d.rw.Unlock()
-	for _, v := range outPoint {
-		d.mapOutPoint[v] = time.Now().Unix()
+func WithOutPointTTL(ttl time.Duration) DasCacheOption {
+	return func(d *DasCache) {
+		d.ttl = ttl
 	}
 }
 
-func (d *DasCache) AddOutPointWithDuration(outPoint []string, duration time.Duration) {
-	if len(outPoint) == 0 {
-		return
+func NewDasCache(ctx context.Context, wg *sync.WaitGroup, opts ...DasCacheOption) *DasCache {
+	d := DasCache{
+		ctx: ctx,
+		wg:  wg,
+		rw:  sync.RWMutex{},
 	}
-	d.rw.Lock()
-	defer d.rw.Unlock()
-	for _, v := range outPoint {
-		d.mapOutPoint[v] = time.Now().Add(duration).Unix()
+	for _, opt := range opts {
+		opt(&d)
 	}
+	if d.backend == nil {
+		d.backend = NewMemBackend()
+	}
+	owner := make([]byte, 16)
+	_, _ = rand.Read(owner)
+	d.owner = hex.EncodeToString(owner)
+	return &d
 }
 
-func (d *DasCache) AddBlockOutPoint(outPoint []string) {
+func (d *DasCache) outPointTTL() time.Duration {
+	d.rw.RLock()
+	defer d.rw.RUnlock()
+	if d.ttl > 0 {
+		return d.ttl
+	}
+	return DefaultOutPointTTL
+}
+
+func (d *DasCache) add(outPoint []string, ttl time.Duration) bool {
 	if len(outPoint) == 0 {
-		return
+		return true
 	}
-	d.rw.Lock()
-	defer d.rw.Unlock()
-	for _, v := range outPoint {
-		d.mapOutPoint[v] = 0
+	ok, err := d.backend.Add(d.owner, outPoint, ttl)
+	if err != nil {
+		log.Error("backend.Add err:", err.Error())
+		return false
+	}
+	if !ok {
+		log.Warn("outpoints are pending by another process:", outPoint)
 	}
+	return ok
 }
 
-func (d *DasCache) clearExpiredOutPoint(t time.Duration) {
-	d.rw.Lock()
-	defer d.rw.Unlock()
-	timestamp := time.Now().Add(-t).Unix()
-	log.Info("clearExpiredOutPoint before:", len(d.mapOutPoint))
-	for k, v := range d.mapOutPoint {
-		if v == 0 {
-			continue
-		}
-		if v < timestamp {
-			delete(d.mapOutPoint, k)
-			//log.Info("clearExpiredOutPoint:", k, time.Now().String())
-		}
+// AddOutPoint locks the outpoints, like the inputs of a sent tx, the ones locked by TryAddOutPoint of d are renewed.
+// It returns false without adding anything if one of them is pending by another process sharing the backend
+func (d *DasCache) AddOutPoint(outPoint []string) bool {
+	return d.add(outPoint, d.outPointTTL())
+}
+
+// AddOutPointWithDuration keeps the outpoints duration longer than AddOutPoint
+func (d *DasCache) AddOutPointWithDuration(outPoint []string, duration time.Duration) bool {
+	return d.add(outPoint, d.outPointTTL()+duration)
+}
+
+// AddBlockOutPoint keeps the outpoints until ClearOutPoint
+func (d *DasCache) AddBlockOutPoint(outPoint []string) bool {
+	return d.add(outPoint, 0)
+}
+
+// TryAddOutPoint adds the outpoints only if none of them is pending, even by d, so only one of the processes
+// sharing the backend selects the cells
+func (d *DasCache) TryAddOutPoint(outPoint []string) bool {
+	if len(outPoint) == 0 {
+		return true
 	}
-	log.Info("clearExpiredOutPoint after:", len(d.mapOutPoint))
+	ok, err := d.backend.TryAdd(d.owner, outPoint, d.outPointTTL())
+	if err != nil {
+		log.Error("backend.TryAdd err:", err.Error())
+		return false
+	}
+	return ok
 }
 
 func (d *DasCache) ClearOutPoint(outPoint []string) {
-	d.rw.Lock()
-	defer d.rw.Unlock()
-
-	for _, v := range outPoint {
-		delete(d.mapOutPoint, v)
+	if len(outPoint) == 0 {
+		return
+	}
+	if err := d.backend.Clear(outPoint); err != nil {
+		log.Error("backend.Clear err:", err.Error())
 	}
 }
 
+// ExistOutPoint treats the outpoint as pending if the backend fails, to not spend it twice
 func (d *DasCache) ExistOutPoint(outPoint string) bool {
-	d.rw.RLock()
-	defer d.rw.RUnlock()
-	if _, ok := d.mapOutPoint[outPoint]; ok {
+	ok, err := d.backend.Exist(outPoint)
+	if err != nil {
+		log.Error("backend.Exist err:", err.Error())
 		return true
 	}
-	return false
+	return ok
 }
 
+// RunClearExpiredOutPoint removes the expired outpoints of the process-local backend every t,
+// t is also the ttl of AddOutPoint if WithOutPointTTL is not set
 func (d *DasCache) RunClearExpiredOutPoint(t time.Duration) {
+	d.rw.Lock()
+	if d.ttl <= 0 {
+		d.ttl = t
+	}
+	d.rw.Unlock()
+	mem, ok := d.backend.(*MemBackend)
+	if !ok {
+		return
+	}
 	ticker := time.NewTicker(t)
 	d.wg.Add(1)
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
-				d.clearExpiredOutPoint(t)
+				mem.ClearExpired()
 			case <-d.ctx.Done():
 				d.wg.Done()
 				return
@@ -112,7 +159,8 @@ func (d *DasCache) RunClearExpiredOutPoint(t time.Duration) {
 	}()
 }
 
-func (d *DasCache) AddCellInputByAction(action common.DasAction, inputs []*types.CellInput) {
+// AddCellInputByAction locks the inputs of the tx by AddOutPoint
+func (d *DasCache) AddCellInputByAction(action common.DasAction, inputs []*types.CellInput) bool {
 	var outPoints []string
 	switch action {
 	case common.DasActionStartAccountSale:
@@ -132,5 +180,5 @@ func (d *DasCache) AddCellInputByAction(action common.DasAction, inputs []*types
 			outPoints = append(outPoints, common.OutPointStruct2String(inputs[i].PreviousOutput))
 		}
 	}
-	d.AddOutPoint(outPoints)
+	return d.AddOutPoint(outPoints)
 }
@@ -194,7 +194,7 @@ func TestGetSatisfiedCapacityLiveCellWithOrder(t *testing.T) {
 	//	HashType: "type",
 	//	Args:     nil,
 	//}
-	res, _, err := core.GetSatisfiedCapacityLiveCellWithOrder(dc.Client(), nil, &dasLockScript, nil, 1000, 100, indexer.SearchOrderDesc)
+	res, _, err := core.GetSatisfiedCapacityLiveCellWithOrder(dc.ChainReader(), nil, &dasLockScript, nil, 1000, 100, indexer.SearchOrderDesc)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/dotbitHQ/das-lib/core"
 	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/go-redis/redis"
 	"sync"
 	"testing"
 	"time"
@@ -24,6 +25,67 @@ func TestDasCache(t *testing.T) {
 	wg.Wait()
 }
 
+func testDasCacheBackend(t *testing.T, backend dascache.Backend) {
+	var wg sync.WaitGroup
+	// two replicas sharing the backend
+	replicaA := dascache.NewDasCache(context.Background(), &wg, dascache.WithBackend(backend), dascache.WithOutPointTTL(time.Minute))
+	replicaB := dascache.NewDasCache(context.Background(), &wg, dascache.WithBackend(backend), dascache.WithOutPointTTL(time.Minute))
+	prefix := fmt.Sprintf("0x%d-", time.Now().UnixNano())
+	outPoints := []string{prefix + "0", prefix + "1"}
+	defer replicaA.ClearOutPoint(append(outPoints, prefix+"2"))
+
+	if !replicaA.TryAddOutPoint(outPoints) {
+		t.Fatal("TryAddOutPoint failed")
+	}
+	if !replicaB.ExistOutPoint(prefix+"1") || replicaB.ExistOutPoint(prefix+"2") {
+		t.Fatal("unexpected ExistOutPoint")
+	}
+	// none of the outpoints is added if one of them is pending
+	if replicaB.TryAddOutPoint([]string{prefix + "2", prefix + "1"}) || replicaA.ExistOutPoint(prefix+"2") {
+		t.Fatal("TryAddOutPoint of pending outpoint")
+	}
+	replicaB.ClearOutPoint(outPoints[:1])
+	if replicaA.ExistOutPoint(prefix+"0") || !replicaB.TryAddOutPoint([]string{prefix + "0", prefix + "2"}) {
+		t.Fatal("TryAddOutPoint after ClearOutPoint failed")
+	}
+	// AddOutPoint renews the outpoints of the replica, but not the ones of another replica
+	if replicaB.TryAddOutPoint([]string{prefix + "0"}) || !replicaB.AddOutPoint([]string{prefix + "0", prefix + "3"}) {
+		t.Fatal("AddOutPoint of own outpoints failed")
+	}
+	defer replicaB.ClearOutPoint([]string{prefix + "3"})
+	if replicaA.AddOutPoint([]string{prefix + "4", prefix + "2"}) || replicaA.ExistOutPoint(prefix+"4") {
+		t.Fatal("AddOutPoint of pending outpoint")
+	}
+	if !replicaA.AddBlockOutPoint([]string{prefix + "1"}) {
+		t.Fatal("AddBlockOutPoint of own outpoint failed")
+	}
+}
+
+func TestDasCacheMemBackend(t *testing.T) {
+	testDasCacheBackend(t, dascache.NewMemBackend())
+
+	backend := dascache.NewMemBackend()
+	_, _ = backend.TryAdd("", []string{"0x-0"}, time.Millisecond)
+	_, _ = backend.TryAdd("", []string{"0x-1"}, 0)
+	time.Sleep(time.Second * 2)
+	backend.ClearExpired()
+	if ok, _ := backend.Exist("0x-0"); ok {
+		t.Fatal("expired outpoint exists")
+	}
+	if ok, _ := backend.Exist("0x-1"); !ok {
+		t.Fatal("outpoint without ttl is cleared")
+	}
+}
+
+func TestDasCacheRedisBackend(t *testing.T) {
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	if err := red.Ping().Err(); err != nil {
+		t.Skip("redis is not available:", err.Error())
+	}
+	defer red.Close()
+	testDasCacheBackend(t, dascache.NewRedisBackend(red, ""))
+}
+
 func TestCache(t *testing.T) {
 	dc, err := getNewDasCoreMainNet()
 	if err != nil {
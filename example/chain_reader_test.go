@@ -0,0 +1,594 @@
+package example
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/txbuilder"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemChainReader(t *testing.T) {
+	env := core.InitEnv(common.DasNetTypeTestnet2)
+	reader := core.NewMemChainReader("ckb_testnet")
+
+	quote := make([]byte, 10)
+	binary.BigEndian.PutUint64(quote[2:], 5000)
+	for i := uint64(1); i <= 3; i++ {
+		reader.AddLiveCell(&indexer.LiveCell{
+			BlockNumber: i,
+			OutPoint:    &types.OutPoint{TxHash: types.HexToHash(fmt.Sprintf("0x%064x", i)), Index: 0},
+			Output: &types.CellOutput{
+				Capacity: 200 * common.OneCkb,
+				Lock:     common.GetNormalLockScript("0x01"),
+				Type:     common.GetScript(env.THQCodeHash, common.ArgsQuoteCell),
+			},
+			OutputData: quote,
+		})
+	}
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg,
+		core.WithChainReader(reader),
+		core.WithDasNetType(common.DasNetTypeTestnet2),
+		core.WithTHQCodeHash(env.THQCodeHash),
+	)
+	qc, err := dc.GetQuoteCell()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qc.LiveCell.BlockNumber != 3 || qc.Quote() != 5000 {
+		t.Fatal("unexpected quote cell", qc.LiveCell.BlockNumber, qc.Quote())
+	}
+
+	res, err := reader.GetCells(context.Background(), &indexer.SearchKey{
+		Script:     common.GetScript(env.THQCodeHash, ""),
+		ScriptType: indexer.ScriptTypeType,
+	}, indexer.SearchOrderAsc, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Objects) != 2 || res.Objects[0].BlockNumber != 1 {
+		t.Fatal("unexpected page", len(res.Objects))
+	}
+	res, err = reader.GetCells(context.Background(), &indexer.SearchKey{
+		Script:     common.GetScript(env.THQCodeHash, ""),
+		ScriptType: indexer.ScriptTypeType,
+	}, indexer.SearchOrderAsc, 2, res.LastCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Objects) != 1 || res.Objects[0].BlockNumber != 3 {
+		t.Fatal("unexpected page", len(res.Objects))
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	keepDasGlobals(t)
+	reader := core.NewMemChainReader("ckb_testnet")
+	input := &types.OutPoint{TxHash: types.HexToHash("0x01"), Index: 0}
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 1,
+		OutPoint:    input,
+		Output: &types.CellOutput{
+			Capacity: 1000 * common.OneCkb,
+			Lock:     common.GetNormalLockScript("0x01"),
+		},
+	})
+	profile, err := common.GetNetworkByChain("ckb_testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	balanceType := &core.DasContractInfo{
+		ContractName:   common.DasContractNameBalanceCellType,
+		OutPoint:       &types.OutPoint{TxHash: types.HexToHash("0xbb"), Index: 0},
+		ContractTypeId: types.HexToHash("0x000000000000000000000000000000000000000000000000000000000000ff21"),
+	}
+	core.DasContractMap.Store(common.DasContractNameBalanceCellType, balanceType)
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+
+	wa := types.WitnessArgs{Lock: make([]byte, 65)}
+	signWitness, _ := wa.Serialize()
+	wa.Lock = nil
+	emptyLockWitness, _ := wa.Serialize()
+	newTx := func() *types.Transaction {
+		return &types.Transaction{
+			CellDeps: []*types.CellDep{profile.SecpDep.CellDep()},
+			Inputs:   []*types.CellInput{{PreviousOutput: input}},
+			Outputs: []*types.CellOutput{
+				{Capacity: 900 * common.OneCkb, Lock: common.GetNormalLockScript("0x01")},
+				{Capacity: 100*common.OneCkb - 1000, Lock: common.GetNormalLockScript("0x02")},
+			},
+			OutputsData: [][]byte{{}, {}},
+			Witnesses:   [][]byte{signWitness},
+		}
+	}
+	for i, c := range []struct {
+		edit       func(tx *types.Transaction)
+		violations map[string]int // code => index
+	}{
+		{
+			edit:       func(tx *types.Transaction) {},
+			violations: map[string]int{},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.Outputs[1].Capacity = common.OneCkb
+			},
+			violations: map[string]int{txbuilder.SimulateCodeOccupiedCapacity: 1, txbuilder.SimulateCodeTxFee: -1},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.Inputs = append(tx.Inputs, &types.CellInput{PreviousOutput: &types.OutPoint{TxHash: types.HexToHash("0x02"), Index: 0}})
+			},
+			violations: map[string]int{txbuilder.SimulateCodeInputNotLive: 1},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.CellDeps = nil
+			},
+			violations: map[string]int{txbuilder.SimulateCodeCellDep: 0},
+		},
+		{
+			// the type of the output 1 is the balance cell type, the dep of which is missing
+			edit: func(tx *types.Transaction) {
+				tx.Outputs[1].Type = balanceType.ToScript(nil)
+			},
+			violations: map[string]int{txbuilder.SimulateCodeCellDep: 1},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.Outputs[1].Type = balanceType.ToScript(nil)
+				tx.CellDeps = append(tx.CellDeps, balanceType.ToCellDep())
+			},
+			violations: map[string]int{},
+		},
+		{
+			// the output locks are not run, paying to a contract lock needs no dep
+			edit: func(tx *types.Transaction) {
+				tx.Outputs[1].Lock = balanceType.ToScript(nil)
+			},
+			violations: map[string]int{},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.Witnesses = nil
+			},
+			violations: map[string]int{txbuilder.SimulateCodeWitnessLock: 0},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.Witnesses = [][]byte{emptyLockWitness}
+			},
+			violations: map[string]int{txbuilder.SimulateCodeWitnessLock: 0},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.Witnesses = [][]byte{signWitness[:20]}
+			},
+			violations: map[string]int{txbuilder.SimulateCodeWitnessLock: 0},
+		},
+		{
+			edit: func(tx *types.Transaction) {
+				tx.Witnesses = append(tx.Witnesses, witness.GenDasDataWitnessWithByte(common.ActionDataTypeActionData, []byte{0x01, 0x02, 0x03}))
+			},
+			violations: map[string]int{txbuilder.SimulateCodeActionData: -1},
+		},
+	} {
+		tx := newTx()
+		c.edit(tx)
+		res, err := txBuilder.Simulate(tx)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		codes := make(map[string]int)
+		for _, v := range res.Violations {
+			codes[v.Code] = v.Index
+		}
+		if len(codes) != len(c.violations) || len(res.Violations) != len(c.violations) {
+			t.Fatal(i, "unexpected violations", res.Violations)
+		}
+		for k, v := range c.violations {
+			if index, ok := codes[k]; !ok || index != v {
+				t.Fatal(i, "unexpected violations", res.Violations)
+			}
+		}
+	}
+}
+
+func TestSimulateAccountCell(t *testing.T) {
+	seedSignerContracts(t)
+	dispatch, _ := core.GetDasContractInfo(common.DasContractNameDispatchCellType)
+	core.DasContractMap.Store(common.DasContractNameAccountCellType, &core.DasContractInfo{
+		ContractName:   common.DasContractNameAccountCellType,
+		OutPoint:       &types.OutPoint{},
+		ContractTypeId: types.HexToHash("0x000000000000000000000000000000000000000000000000000000000000ff10"),
+	})
+	accContract, _ := core.GetDasContractInfo(common.DasContractNameAccountCellType)
+
+	reader := core.NewMemChainReader("ckb_testnet")
+	configCellAccount := molecule.NewConfigCellAccountBuilder().
+		ExpirationGracePeriod(molecule.GoU32ToMoleculeU32(86400)).
+		RecordMinTtl(molecule.GoU32ToMoleculeU32(300)).
+		TransferAccountThrottle(molecule.GoU32ToMoleculeU32(3600)).
+		EditManagerThrottle(molecule.GoU32ToMoleculeU32(3600)).
+		EditRecordsThrottle(molecule.GoU32ToMoleculeU32(3600)).
+		Build()
+	configTx := &types.Transaction{
+		Outputs: []*types.CellOutput{{
+			Capacity: 100 * common.OneCkb,
+			Lock:     common.GetNormalLockScript("0x01"),
+			Type:     &types.Script{CodeHash: types.HexToHash("0xcc"), HashType: types.HashTypeType, Args: common.Hex2Bytes(common.ConfigCellTypeArgsAccount)},
+		}},
+		OutputsData: [][]byte{append([]byte{0x00}, configCellAccount.AsSlice()...)},
+		Witnesses:   [][]byte{},
+	}
+	if err := reader.ApplyTransaction(configTx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	configTxHash, _ := configTx.ComputeHash()
+	core.DasConfigCellMap.Store(common.ConfigCellTypeArgsAccount, &core.DasConfigCellInfo{
+		Name:     "ConfigCellAccount",
+		OutPoint: types.OutPoint{TxHash: configTxHash, Index: 0},
+	})
+
+	daf := core.DasAddressFormat{DasNetType: common.DasNetTypeTestnet2}
+	owner := core.DasAddressHex{DasAlgorithmId: common.DasAlgorithmIdEth, AddressHex: "0x000000000000000000000000000000000000000a", ChainType: common.ChainTypeEth}
+	args, err := daf.HexToArgs(owner, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountCell := &types.CellOutput{
+		Capacity: 300 * common.OneCkb,
+		Lock:     &types.Script{CodeHash: dispatch.ContractTypeId, HashType: types.HashTypeType, Args: args},
+		Type:     &types.Script{CodeHash: accContract.ContractTypeId, HashType: types.HashTypeType},
+	}
+	account := "simulate.bit"
+	accountId := common.GetAccountIdByAccount(account)
+	moleculeAccountId, _ := molecule.AccountIdFromSlice(accountId, true)
+	var charSet []common.AccountCharSet
+	for _, v := range "simulate" {
+		charSet = append(charSet, common.AccountCharSet{CharSetName: common.AccountCharTypeEn, Char: string(v)})
+	}
+	outputData := func(expiredAt uint64) []byte {
+		var data []byte
+		data = append(data, make([]byte, 32)...)
+		data = append(data, accountId...)
+		data = append(data, make([]byte, 20)...)
+		data = append(data, molecule.GoU64ToBytes(expiredAt)...)
+		return append(data, account...)
+	}
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+
+	// without a time cell the simulator takes the local time
+	now := uint64(time.Now().Unix())
+	wa := types.WitnessArgs{Lock: make([]byte, 65)}
+	signWitness, _ := wa.Serialize()
+	for i, c := range []struct {
+		param         witness.AccountCellParam
+		lastEditAt    uint64
+		oldExpiredAt  uint64
+		newExpiredAt  uint64
+		violationList []string
+	}{
+		{
+			param:         witness.AccountCellParam{Action: common.DasActionEditRecords, Records: []witness.Record{{Key: "60", Type: "address", Value: "0x01", TTL: 300}}},
+			oldExpiredAt:  now + uint64(common.OneYearSec),
+			violationList: nil,
+		},
+		{
+			param:         witness.AccountCellParam{Action: common.DasActionEditRecords, Records: []witness.Record{{Key: "60", Type: "address", Value: "0x01", TTL: 60}}},
+			lastEditAt:    now - 60,
+			oldExpiredAt:  now + uint64(common.OneYearSec),
+			violationList: []string{txbuilder.SimulateCodeEditRecordsThrottle, txbuilder.SimulateCodeRecordTtl},
+		},
+		{
+			param:         witness.AccountCellParam{Action: common.DasActionTransferAccount},
+			oldExpiredAt:  now - 60,
+			violationList: []string{txbuilder.SimulateCodeAccountInGrace},
+		},
+		{
+			param:         witness.AccountCellParam{Action: common.DasActionEditManager},
+			oldExpiredAt:  now - 2*86400,
+			violationList: []string{txbuilder.SimulateCodeAccountExpired},
+		},
+		{
+			param:         witness.AccountCellParam{Action: common.DasActionRenewAccount},
+			oldExpiredAt:  now - 60,
+			newExpiredAt:  now - 60 + uint64(common.OneYearSec),
+			violationList: nil,
+		},
+		{
+			param:         witness.AccountCellParam{Action: common.DasActionRenewAccount},
+			oldExpiredAt:  now - 2*86400,
+			newExpiredAt:  now - 2*86400,
+			violationList: []string{txbuilder.SimulateCodeAccountExpired, txbuilder.SimulateCodeExpiredAt},
+		},
+	} {
+		accountCellData := molecule.NewAccountCellDataBuilder().
+			Id(*moleculeAccountId).
+			Account(*common.ConvertToAccountChars(charSet)).
+			LastEditRecordsAt(molecule.GoU64ToMoleculeU64(c.lastEditAt)).
+			Build()
+		accountBuilder := witness.AccountCellDataBuilder{Version: common.GoDataEntityVersion4, AccountCellData: &accountCellData}
+		preTx := &types.Transaction{
+			Outputs:     []*types.CellOutput{accountCell},
+			OutputsData: [][]byte{outputData(c.oldExpiredAt)},
+			Witnesses:   [][]byte{},
+		}
+		if err := reader.ApplyTransaction(preTx, uint64(i+2), 0); err != nil {
+			t.Fatal(err)
+		}
+		preTxHash, _ := preTx.ComputeHash()
+
+		c.param.LastEditRecordsAt = int64(now)
+		actionWitness, _ := witness.GenActionDataWitness(c.param.Action, nil)
+		accountWitness, _, err := accountBuilder.GenWitness(&c.param)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		newExpiredAt := c.newExpiredAt
+		if newExpiredAt == 0 {
+			newExpiredAt = c.oldExpiredAt
+		}
+		tx := &types.Transaction{
+			CellDeps:    []*types.CellDep{dispatch.ToCellDep()},
+			Inputs:      []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: preTxHash, Index: 0}}},
+			Outputs:     []*types.CellOutput{{Capacity: accountCell.Capacity - 1000, Lock: accountCell.Lock, Type: accountCell.Type}},
+			OutputsData: [][]byte{outputData(newExpiredAt)},
+			Witnesses:   [][]byte{signWitness, actionWitness, accountWitness},
+		}
+		res, err := txBuilder.Simulate(tx)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if res.Action != c.param.Action || res.TxFee != 1000 || len(res.Violations) != len(c.violationList) {
+			t.Fatal(i, "unexpected violations", res.Action, res.TxFee, res.Violations)
+		}
+		for j, v := range res.Violations {
+			if v.Code != c.violationList[j] || v.Account != account {
+				t.Fatal(i, "unexpected violations", res.Violations)
+			}
+		}
+	}
+}
+
+func TestBuildTransactionWithFee(t *testing.T) {
+	seedSignerContracts(t)
+	reader := core.NewMemChainReader("ckb_testnet")
+	ownerLock := common.GetNormalLockScript("0x0000000000000000000000000000000000000001")
+	input := &types.OutPoint{TxHash: types.HexToHash("0x01"), Index: 0}
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 1,
+		OutPoint:    input,
+		Output:      &types.CellOutput{Capacity: 150 * common.OneCkb, Lock: ownerLock},
+	}, &indexer.LiveCell{
+		BlockNumber: 2,
+		OutPoint:    &types.OutPoint{TxHash: types.HexToHash("0x02"), Index: 0},
+		Output:      &types.CellOutput{Capacity: 200 * common.OneCkb, Lock: ownerLock},
+	})
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	fee, err := txBuilder.BuildTransactionWithFee(&txbuilder.BuildTransactionParams{
+		Inputs:      []*types.CellInput{{PreviousOutput: input}},
+		Outputs:     []*types.CellOutput{{Capacity: 100 * common.OneCkb, Lock: common.GetNormalLockScript("0x0000000000000000000000000000000000000002")}},
+		OutputsData: [][]byte{{}},
+	}, &txbuilder.ParamTxFee{
+		FeeRate:    2000,
+		ChangeLock: ownerLock,
+		DasCache:   dascache.NewDasCache(context.Background(), &wg),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := txBuilder.Transaction
+	if len(tx.Inputs) != 2 || len(tx.Outputs) != 2 {
+		t.Fatal("unexpected inputs or outputs", len(tx.Inputs), len(tx.Outputs))
+	}
+	if tx.OutputsCapacity()+fee != 350*common.OneCkb {
+		t.Fatal("unexpected change", tx.Outputs[1].Capacity, fee)
+	}
+	testnet, _ := common.GetNetworkByChain("ckb_testnet")
+	hasSecpDep := false
+	for _, v := range tx.CellDeps {
+		hasSecpDep = hasSecpDep || v.OutPoint.TxHash == testnet.SecpDep.CellDep().OutPoint.TxHash
+	}
+	if !hasSecpDep {
+		t.Fatal("secp dep not exist")
+	}
+
+	// the fee is the same after signed
+	sig := common.Bytes2Hex(make([]byte, 65))
+	if err = txBuilder.AddSignatureForTx([]txbuilder.SignData{{SignType: common.DasAlgorithmIdCkb, SignMsg: sig}}); err != nil {
+		t.Fatal(err)
+	}
+	signedFee, err := transaction.CalculateTransactionFee(tx, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedFee != fee {
+		t.Fatal("fee mismatch after signed", fee, signedFee)
+	}
+}
+
+func TestBuildTransactionWithFeeSameLock(t *testing.T) {
+	seedSignerContracts(t)
+	reader := core.NewMemChainReader("ckb_testnet")
+	ownerLock := common.GetNormalLockScript("0x0000000000000000000000000000000000000001")
+	// the input is the first balance cell of the lock in the search order
+	input := &types.OutPoint{TxHash: types.HexToHash("0x01"), Index: 0}
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 2,
+		OutPoint:    input,
+		Output:      &types.CellOutput{Capacity: 150 * common.OneCkb, Lock: ownerLock},
+	}, &indexer.LiveCell{
+		BlockNumber: 1,
+		OutPoint:    &types.OutPoint{TxHash: types.HexToHash("0x02"), Index: 0},
+		Output:      &types.CellOutput{Capacity: 200 * common.OneCkb, Lock: ownerLock},
+	})
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	fee, err := txBuilder.BuildTransactionWithFee(&txbuilder.BuildTransactionParams{
+		Inputs:      []*types.CellInput{{PreviousOutput: input}},
+		Outputs:     []*types.CellOutput{{Capacity: 100 * common.OneCkb, Lock: common.GetNormalLockScript("0x0000000000000000000000000000000000000002")}},
+		OutputsData: [][]byte{{}},
+	}, &txbuilder.ParamTxFee{
+		ChangeLock: ownerLock,
+		DasCache:   dascache.NewDasCache(context.Background(), &wg),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := txBuilder.Transaction
+	inputs := make(map[string]bool)
+	for _, v := range tx.Inputs {
+		key := common.OutPointStruct2String(v.PreviousOutput)
+		if inputs[key] {
+			t.Fatal("duplicate input", key)
+		}
+		inputs[key] = true
+	}
+	if len(tx.Inputs) != 2 || tx.OutputsCapacity()+fee != 350*common.OneCkb {
+		t.Fatal("unexpected inputs", len(tx.Inputs), fee)
+	}
+}
+
+func TestBuildTransactionWithFeeOutputsKept(t *testing.T) {
+	seedSignerContracts(t)
+	reader := core.NewMemChainReader("ckb_testnet")
+	ownerLock := common.GetNormalLockScript("0x0000000000000000000000000000000000000001")
+	balance := &types.OutPoint{TxHash: types.HexToHash("0x01"), Index: 0}
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 1,
+		OutPoint:    balance,
+		Output:      &types.CellOutput{Capacity: 500 * common.OneCkb, Lock: ownerLock},
+	})
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader))
+	dasCache := dascache.NewDasCache(context.Background(), &wg)
+	newParams := func() *txbuilder.BuildTransactionParams {
+		// the output of ChangeLock is a payment, not the change
+		return &txbuilder.BuildTransactionParams{
+			Outputs:     []*types.CellOutput{{Capacity: 100 * common.OneCkb, Lock: ownerLock}},
+			OutputsData: [][]byte{{}},
+		}
+	}
+
+	// the balance cells are released if it fails
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	if _, err := txBuilder.BuildTransactionWithFee(newParams(), &txbuilder.ParamTxFee{
+		ChangeLock: ownerLock,
+		DasCache:   dasCache,
+		MaxFee:     1,
+	}); err == nil {
+		t.Fatal("expected exceeds max fee")
+	}
+	if dasCache.ExistOutPoint(common.OutPointStruct2String(balance)) {
+		t.Fatal("the balance cell is not released")
+	}
+
+	txBuilder = txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	fee, err := txBuilder.BuildTransactionWithFee(newParams(), &txbuilder.ParamTxFee{
+		ChangeLock: ownerLock,
+		DasCache:   dasCache,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := txBuilder.Transaction
+	if len(tx.Outputs) != 2 || tx.Outputs[0].Capacity != 100*common.OneCkb {
+		t.Fatal("the output of ChangeLock is changed", len(tx.Outputs), tx.Outputs[0].Capacity)
+	}
+	if tx.Outputs[1].Capacity+fee != 400*common.OneCkb || !dasCache.ExistOutPoint(common.OutPointStruct2String(balance)) {
+		t.Fatal("unexpected change", tx.Outputs[1].Capacity, fee)
+	}
+}
+
+func TestGetSatisfiedCapacityLiveCellByChainReader(t *testing.T) {
+	reader := core.NewMemChainReader("ckb_testnet")
+	ownerLock := common.GetNormalLockScript("0x0000000000000000000000000000000000000001")
+	typeScript := common.GetScript("0x0000000000000000000000000000000000000000000000000000000000000abc", "")
+	// the first page is full of the typed cells, the cells without type are on the second page
+	for i := uint64(0); i < indexer.SearchLimit+3; i++ {
+		cell := &indexer.LiveCell{
+			BlockNumber: i,
+			OutPoint:    &types.OutPoint{TxHash: types.HexToHash(fmt.Sprintf("0x%064x", i+1)), Index: 0},
+			Output:      &types.CellOutput{Capacity: 100 * common.OneCkb, Lock: ownerLock},
+		}
+		if i < indexer.SearchLimit {
+			cell.Output.Type = typeScript
+		}
+		reader.AddLiveCell(cell)
+	}
+
+	cells, total, err := core.GetSatisfiedCapacityLiveCellWithOrder(reader, nil, ownerLock, nil, 150*common.OneCkb, 50*common.OneCkb, indexer.SearchOrderAsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 2 || total != 200*common.OneCkb || cells[0].Output.Type != nil {
+		t.Fatal("unexpected cells", len(cells), total)
+	}
+	if _, total, err = core.GetSatisfiedCapacityLiveCellWithOrder(reader, nil, ownerLock, nil, 0, 0, indexer.SearchOrderAsc); err != nil || total != 300*common.OneCkb {
+		t.Fatal("unexpected total", total, err)
+	}
+	if _, _, err = core.GetSatisfiedCapacityLiveCellWithOrder(reader, nil, ownerLock, nil, 400*common.OneCkb, 0, indexer.SearchOrderAsc); err != core.ErrInsufficientFunds {
+		t.Fatal("expected ErrInsufficientFunds:", err)
+	}
+
+	var wg sync.WaitGroup
+	dasCache := dascache.NewDasCache(context.Background(), &wg)
+	limitCells, err := core.GetSatisfiedLimitLiveCell(reader, dasCache, &indexer.SearchKey{
+		Script:     typeScript,
+		ScriptType: indexer.ScriptTypeType,
+	}, 3, indexer.SearchOrderDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limitCells) != 3 || limitCells[0].BlockNumber != indexer.SearchLimit-1 {
+		t.Fatal("unexpected limit cells", len(limitCells))
+	}
+
+	// only the Claim calls lock the cells, the pending cells are skipped by both
+	for i := 0; i < 2; i++ {
+		if cells, _, err = core.GetSatisfiedCapacityLiveCellWithOrder(reader, dasCache, ownerLock, nil, 150*common.OneCkb, 50*common.OneCkb, indexer.SearchOrderAsc); err != nil || dasCache.ExistOutPoint(common.OutPointStruct2String(cells[0].OutPoint)) {
+			t.Fatal("the cells are locked without claim", err)
+		}
+	}
+	if cells, _, err = core.ClaimSatisfiedCapacityLiveCellWithOrder(reader, dasCache, ownerLock, nil, 150*common.OneCkb, 50*common.OneCkb, indexer.SearchOrderAsc); err != nil || !dasCache.ExistOutPoint(common.OutPointStruct2String(cells[1].OutPoint)) {
+		t.Fatal("the claimed cells are not locked", err)
+	}
+	if _, _, err = core.GetSatisfiedCapacityLiveCellWithOrder(reader, dasCache, ownerLock, nil, 150*common.OneCkb, 0, indexer.SearchOrderAsc); err != core.ErrRejectedOutPoint {
+		t.Fatal("expected ErrRejectedOutPoint:", err)
+	}
+	if _, _, err = core.ClaimSatisfiedCapacityLiveCellWithOrder(reader, dasCache, ownerLock, nil, 150*common.OneCkb, 0, indexer.SearchOrderAsc); err != core.ErrRejectedOutPoint {
+		t.Fatal("expected ErrRejectedOutPoint:", err)
+	}
+	// the cell claimed by the failed call is released
+	if _, total, err = core.ClaimSatisfiedCapacityLiveCellWithOrder(reader, dasCache, ownerLock, nil, 100*common.OneCkb, 0, indexer.SearchOrderAsc); err != nil || total != 100*common.OneCkb {
+		t.Fatal("the failed claim is not released", total, err)
+	}
+	if _, _, err = core.GetSatisfiedCapacityLiveCell(nil, nil, ownerLock, nil, 1, 0); err == nil {
+		t.Fatal("expected chain reader is nil")
+	}
+}
@@ -0,0 +1,160 @@
+package example
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/checkpoint"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCheckpointFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	store, err := checkpoint.NewFileStore(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(1); i <= 5; i++ {
+		if err := store.Save(checkpoint.Block{Number: i, Hash: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Save(checkpoint.Block{Number: 5}); !errors.Is(err, checkpoint.ErrNotAfterLatest) {
+		t.Fatal("save the latest again", err)
+	}
+	removed, err := store.Rollback(3)
+	if err != nil || fmt.Sprint(removed) != "[{5 5} {4 4}]" {
+		t.Fatal("unexpected rollback", removed, err)
+	}
+
+	// reopen
+	store, err = checkpoint.NewFileStore(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := store.List()
+	if fmt.Sprint(list) != "[{3 3}]" {
+		t.Fatal("unexpected blocks", list)
+	}
+}
+
+func TestCheckpointTracker(t *testing.T) {
+	reader := core.NewMemChainReader("ckb_testnet")
+	header := func(number, fork uint64) *types.Header {
+		parentFork := fork
+		if number == 3 {
+			parentFork = 0
+		}
+		return &types.Header{
+			Number:     number,
+			Hash:       types.HexToHash(fmt.Sprintf("0x%064x", number+fork<<8)),
+			ParentHash: types.HexToHash(fmt.Sprintf("0x%064x", number-1+parentFork<<8)),
+		}
+	}
+	blocks := checkpoint.NewMemStore(0)
+	tracker := checkpoint.NewTracker(blocks, reader)
+	var rollbacks []string
+	tracker.OnRollback(func(ancestor checkpoint.Block, removed []checkpoint.Block) error {
+		for _, v := range removed {
+			rollbacks = append(rollbacks, fmt.Sprintf("%d>%d", v.Number, ancestor.Number))
+		}
+		return nil
+	})
+	for i := uint64(1); i <= 4; i++ {
+		reader.AddBlock(&types.Block{Header: header(i, 0)})
+		if ok, err := tracker.Advance(header(i, 0)); err != nil || !ok {
+			t.Fatal("advance", i, err)
+		}
+	}
+
+	// the chain is forked from block 3
+	reader.AddBlock(&types.Block{Header: header(3, 1)}, &types.Block{Header: header(4, 1)})
+	if ok, err := tracker.Advance(header(5, 1)); err != nil || ok {
+		t.Fatal("advance on the fork", ok, err)
+	}
+	ancestor, err := tracker.Reconcile(context.Background())
+	if err != nil || ancestor.Number != 2 || fmt.Sprint(rollbacks) != "[4>2 3>2]" {
+		t.Fatal("unexpected reconcile", ancestor, rollbacks, err)
+	}
+	if ok, err := tracker.Advance(header(3, 1)); err != nil || !ok {
+		t.Fatal("advance after reconcile", err)
+	}
+
+	// nothing to roll back
+	rollbacks = nil
+	if ancestor, err = tracker.Reconcile(context.Background()); err != nil || ancestor.Number != 3 || len(rollbacks) != 0 {
+		t.Fatal("unexpected reconcile", ancestor, rollbacks, err)
+	}
+
+	// a fork deeper than the saved blocks
+	store := checkpoint.NewMemStore(0)
+	_ = store.Save(checkpoint.Block{Number: 4, Hash: "0x04"})
+	if _, _, err := checkpoint.FindCommonAncestor(context.Background(), store, reader); !errors.Is(err, checkpoint.ErrForkTooDeep) {
+		t.Fatal("fork too deep", err)
+	}
+
+	// a failed rpc call is not a fork
+	tracker = checkpoint.NewTracker(blocks, failedHeaderReader{})
+	if _, err = tracker.Reconcile(context.Background()); err == nil || errors.Is(err, checkpoint.ErrForkTooDeep) {
+		t.Fatal("reconcile on rpc error", err)
+	}
+	if latest, _ := tracker.Latest(); latest == nil || latest.Number != 3 {
+		t.Fatal("rolled back on rpc error", latest)
+	}
+}
+
+type failedHeaderReader struct{}
+
+func (failedHeaderReader) GetHeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func TestRollbackDasConfig(t *testing.T) {
+	keepDasGlobals(t)
+	contractLock := common.GetNormalLockScript("0x0000000000000000000000000000000000000001")
+	core.DasContractMap.Store(common.DasContractNameConfigCellType, &core.DasContractInfo{
+		ContractName:   common.DasContractNameConfigCellType,
+		OutPoint:       &types.OutPoint{},
+		OutPut:         &types.CellOutput{Lock: contractLock, Type: &types.Script{HashType: types.HashTypeType}},
+		ContractTypeId: types.HexToHash("0xff01"),
+	})
+	contract, _ := core.GetDasContractInfo(common.DasContractNameConfigCellType)
+	item := &core.DasConfigCellInfo{Name: "ConfigCellAccount"}
+	core.DasConfigCellMap.Store(common.ConfigCellTypeArgsAccount, item)
+	configCell := func(blockNumber uint64, txHash string) *indexer.LiveCell {
+		return &indexer.LiveCell{
+			BlockNumber: blockNumber,
+			OutPoint:    &types.OutPoint{TxHash: types.HexToHash(txHash)},
+			Output: &types.CellOutput{
+				Lock: contract.OutPut.Lock,
+				Type: contract.ToScript(common.Hex2Bytes(string(common.ConfigCellTypeArgsAccount))),
+			},
+		}
+	}
+	reader := core.NewMemChainReader("ckb_testnet")
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	reader.AddLiveCell(configCell(5, "0xa1"))
+	if err := dc.AsyncDasConfigCell(); err != nil || item.BlockNumber != 5 {
+		t.Fatal("unexpected config cell", item, err)
+	}
+
+	// block 5 is forked, the config cell is updated at block 4 of the new chain
+	reader.RemoveLiveCell(configCell(5, "0xa1").OutPoint)
+	reader.AddLiveCell(configCell(4, "0xb1"))
+	if err := dc.AsyncDasConfigCell(); err != nil || item.BlockNumber != 5 {
+		t.Fatal("config cell of a lower block", item, err)
+	}
+	if err := dc.RollbackDasConfig(3); err != nil {
+		t.Fatal(err)
+	}
+	if item.BlockNumber != 4 || item.OutPoint.TxHash != types.HexToHash("0xb1") {
+		t.Fatal("config cell not reloaded", item)
+	}
+}
@@ -0,0 +1,280 @@
+package example
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/txbuilder"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sync"
+	"testing"
+)
+
+func coinSelectCells(capacities ...uint64) []*indexer.LiveCell {
+	lock := common.GetNormalLockScript("0x0000000000000000000000000000000000000001")
+	var cells []*indexer.LiveCell
+	for i, v := range capacities {
+		cells = append(cells, &indexer.LiveCell{
+			BlockNumber: uint64(i + 1),
+			OutPoint:    &types.OutPoint{TxHash: types.HexToHash(fmt.Sprintf("0x%x", i+1)), Index: 0},
+			Output:      &types.CellOutput{Capacity: v * common.OneCkb, Lock: lock},
+		})
+	}
+	return cells
+}
+
+func coinSelectCapacities(cells []*indexer.LiveCell) string {
+	var list []uint64
+	for _, v := range cells {
+		list = append(list, v.Output.Capacity/common.OneCkb)
+	}
+	return fmt.Sprint(list)
+}
+
+func TestCoinSelector(t *testing.T) {
+	cells := coinSelectCells(100, 500, 61, 62, 300, 63, 1000, 250)
+	p := core.CoinSelectParam{CapacityNeed: 550 * common.OneCkb, CapacityForChange: 100 * common.OneCkb}
+
+	list := []struct {
+		selector core.CoinSelector
+		p        core.CoinSelectParam
+		res      string
+		err      error
+	}{
+		{&core.LargestFirstSelector{}, p, "[1000]", nil},
+		{&core.MinInputCountSelector{}, p, "[1000]", nil},
+		{&core.MinInputCountSelector{}, core.CoinSelectParam{CapacityNeed: 1100 * common.OneCkb, CapacityForChange: 100 * common.OneCkb}, "[1000 100]", nil},
+		{&core.BranchAndBoundSelector{}, p, "[500 *]", nil},
+		{&core.BranchAndBoundSelector{}, core.CoinSelectParam{CapacityNeed: 424 * common.OneCkb}, "[300 63 61]", nil},
+		{&core.BranchAndBoundSelector{}, core.CoinSelectParam{CapacityNeed: 424 * common.OneCkb, MaxInputs: 2}, "[1000]", nil},
+		// no cells sum up to 364, it falls back to the largest first with the change
+		{&core.BranchAndBoundSelector{}, core.CoinSelectParam{CapacityNeed: 364 * common.OneCkb, CapacityForChange: 61 * common.OneCkb}, "[1000]", nil},
+		{&core.ConsolidateDustSelector{DustThreshold: 70 * common.OneCkb, MaxDust: 2}, p, "[1000 61 62]", nil},
+		{&core.ConsolidateDustSelector{DustThreshold: 70 * common.OneCkb}, core.CoinSelectParam{CapacityNeed: 550 * common.OneCkb, CapacityForChange: 100 * common.OneCkb, MaxInputs: 3}, "[1000 61 62]", nil},
+		{&core.LargestFirstSelector{}, core.CoinSelectParam{CapacityNeed: 2000 * common.OneCkb, CapacityForChange: 100 * common.OneCkb, MaxInputs: 3}, "", core.ErrTooManyInputs},
+		{&core.LargestFirstSelector{}, core.CoinSelectParam{CapacityNeed: 3000 * common.OneCkb}, "", core.ErrInsufficientFunds},
+		{&core.LargestFirstSelector{}, core.CoinSelectParam{CapacityNeed: 2300 * common.OneCkb, CapacityForChange: 100 * common.OneCkb}, "", core.ErrNotEnoughChange},
+	}
+	for i, v := range list {
+		selected, total, err := v.selector.Select(cells, v.p)
+		if v.err != nil {
+			if !errors.Is(err, v.err) {
+				t.Fatal(i, "unexpected err", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		res := coinSelectCapacities(selected)
+		if v.res == "[500 *]" {
+			// any exact match is fine
+			if total != v.p.CapacityNeed {
+				t.Fatal(i, "not exact match", res)
+			}
+			continue
+		}
+		if res != v.res {
+			t.Fatal(i, "unexpected selection", res)
+		}
+	}
+}
+
+func TestGetBalanceCellsByCoinSelector(t *testing.T) {
+	keepDasGlobals(t)
+	for i, name := range []common.DasContractName{common.DasContractNameDispatchCellType, common.DasContractNameBalanceCellType} {
+		if _, err := core.GetDasContractInfo(name); err != nil {
+			core.DasContractMap.Store(name, &core.DasContractInfo{
+				ContractName:   name,
+				OutPoint:       &types.OutPoint{},
+				ContractTypeId: types.HexToHash(fmt.Sprintf("0x%064x", 0xff00+i)),
+			})
+		}
+	}
+	reader := core.NewMemChainReader("ckb_testnet")
+	cells := coinSelectCells(100, 500, 61, 1000, 250)
+	reader.AddLiveCell(cells...)
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	dasCache := dascache.NewDasCache(context.Background(), &wg)
+	dasCache.AddOutPoint([]string{common.OutPointStruct2String(cells[3].OutPoint)})
+
+	p := &core.ParamGetBalanceCells{
+		DasCache:          dasCache,
+		LockScript:        cells[0].Output.Lock,
+		CapacityNeed:      600 * common.OneCkb,
+		CapacityForChange: 61 * common.OneCkb,
+		SearchOrder:       indexer.SearchOrderAsc,
+		CoinSelector:      &core.LargestFirstSelector{},
+	}
+	// the cells are not locked without ClaimCells
+	for i := 0; i < 2; i++ {
+		if selected, _, err := dc.GetBalanceCells(p); err != nil || coinSelectCapacities(selected) != "[500 250]" {
+			t.Fatal("unexpected selection", coinSelectCapacities(selected), err)
+		}
+	}
+	p.ClaimCells = true
+	selected, total, err := dc.GetBalanceCells(p)
+	if err != nil || coinSelectCapacities(selected) != "[500 250]" || total != 750*common.OneCkb {
+		t.Fatal("unexpected selection", coinSelectCapacities(selected), err)
+	}
+	// the selected cells are locked
+	if _, _, err = dc.GetBalanceCells(p); !errors.Is(err, core.ErrRejectedOutPoint) {
+		t.Fatal("the selected cells are not locked", err)
+	}
+	dasCache.ClearOutPoint([]string{common.OutPointStruct2String(selected[0].OutPoint), common.OutPointStruct2String(selected[1].OutPoint)})
+
+	p.MaxInputs = 1
+	if _, _, err = dc.GetBalanceCells(p); !errors.Is(err, core.ErrTooManyInputs) {
+		t.Fatal("unexpected max inputs", err)
+	}
+	p.MaxInputs, p.CapacityNeed = 0, 900*common.OneCkb
+	if _, _, err = dc.GetBalanceCells(p); !errors.Is(err, core.ErrRejectedOutPoint) {
+		t.Fatal("the pending cell is not excluded", err)
+	}
+}
+
+func TestGetBalanceCellsConcurrently(t *testing.T) {
+	keepDasGlobals(t)
+	core.DasContractMap.Store(common.DasContractNameBalanceCellType, &core.DasContractInfo{
+		ContractName:   common.DasContractNameBalanceCellType,
+		OutPoint:       &types.OutPoint{},
+		ContractTypeId: types.HexToHash("0xff01"),
+	})
+	reader := core.NewMemChainReader("ckb_testnet")
+	var capacities []uint64
+	for i := 0; i < 20; i++ {
+		capacities = append(capacities, 100)
+	}
+	cells := coinSelectCells(capacities...)
+	reader.AddLiveCell(cells...)
+
+	// the replicas sharing the backend select the cells at the same time
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	backend := dascache.NewMemBackend()
+	var lock sync.Mutex
+	selected := make(map[string]int)
+	var workers sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		workers.Add(1)
+		go func(i int) {
+			defer workers.Done()
+			p := &core.ParamGetBalanceCells{
+				DasCache:          dascache.NewDasCache(context.Background(), &wg, dascache.WithBackend(backend)),
+				LockScript:        cells[0].Output.Lock,
+				CapacityNeed:      150 * common.OneCkb,
+				CapacityForChange: 61 * common.OneCkb,
+				SearchOrder:       indexer.SearchOrderAsc,
+				ClaimCells:        true,
+			}
+			if i%2 == 1 {
+				p.CoinSelector = &core.LargestFirstSelector{}
+			}
+			list, _, err := dc.GetBalanceCells(p)
+			if err != nil {
+				if !errors.Is(err, core.ErrRejectedOutPoint) {
+					t.Error(err)
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			for _, v := range list {
+				selected[common.OutPointStruct2String(v.OutPoint)]++
+			}
+		}(i)
+	}
+	workers.Wait()
+	if len(selected) == 0 {
+		t.Fatal("nothing selected")
+	}
+	for k, v := range selected {
+		if v > 1 {
+			t.Fatal("cell selected twice:", k)
+		}
+	}
+	// the cells of the failed selections are released
+	for _, v := range cells {
+		ok, _ := backend.Exist(common.OutPointStruct2String(v.OutPoint))
+		if _, sel := selected[common.OutPointStruct2String(v.OutPoint)]; ok != sel {
+			t.Fatal("unexpected pending cell", common.OutPointStruct2String(v.OutPoint), ok)
+		}
+	}
+}
+
+func TestBranchAndBoundSelectorTx(t *testing.T) {
+	keepDasGlobals(t)
+	core.DasContractMap.Store(common.DasContractNameBalanceCellType, &core.DasContractInfo{
+		ContractName:   common.DasContractNameBalanceCellType,
+		OutPoint:       &types.OutPoint{},
+		ContractTypeId: types.HexToHash("0xff01"),
+	})
+	fee := uint64(100000)
+	cells := coinSelectCells(150, 50, 120, 500)
+	cells[1].Output.Capacity += fee
+	reader := core.NewMemChainReader("ckb_testnet")
+	reader.AddLiveCell(cells...)
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	dasCache := dascache.NewDasCache(context.Background(), &wg)
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	ownerLock := cells[0].Output.Lock
+	profile, err := common.GetNetworkByChain("ckb_testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signWitness, _ := (&types.WitnessArgs{Lock: make([]byte, 65)}).Serialize()
+	transfer := func(capacity uint64) *types.Transaction {
+		change, selected, err := dc.GetBalanceCellWithLock(&core.ParamGetBalanceCells{
+			DasCache:          dasCache,
+			LockScript:        ownerLock,
+			CapacityNeed:      capacity + fee,
+			CapacityForChange: 61 * common.OneCkb,
+			CoinSelector:      &core.BranchAndBoundSelector{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := &types.Transaction{
+			CellDeps:    []*types.CellDep{profile.SecpDep.CellDep()},
+			Outputs:     []*types.CellOutput{{Capacity: capacity, Lock: common.GetNormalLockScript("0x02")}},
+			OutputsData: [][]byte{{}},
+		}
+		for i, v := range selected {
+			tx.Inputs = append(tx.Inputs, &types.CellInput{PreviousOutput: v.OutPoint})
+			if i == 0 {
+				tx.Witnesses = append(tx.Witnesses, signWitness)
+			} else {
+				tx.Witnesses = append(tx.Witnesses, []byte{})
+			}
+			dasCache.ClearOutPoint([]string{common.OutPointStruct2String(v.OutPoint)})
+		}
+		if change > 0 {
+			tx.Outputs = append(tx.Outputs, &types.CellOutput{Capacity: change, Lock: ownerLock})
+			tx.OutputsData = append(tx.OutputsData, []byte{})
+		}
+		res, err := txBuilder.Simulate(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Ok() || res.TxFee != fee {
+			t.Fatal("invalid tx", res.Violations, res.TxFee)
+		}
+		return tx
+	}
+	// 150 + 50 is an exact match, no change output
+	if tx := transfer(200 * common.OneCkb); len(tx.Inputs) != 2 || len(tx.Outputs) != 1 {
+		t.Fatal("unexpected exact match tx", len(tx.Inputs), len(tx.Outputs))
+	}
+	// no exact match, the change is not less than CapacityForChange
+	if tx := transfer(210 * common.OneCkb); len(tx.Inputs) != 1 || len(tx.Outputs) != 2 || tx.Outputs[1].Capacity < 61*common.OneCkb {
+		t.Fatal("unexpected tx with change", len(tx.Inputs), len(tx.Outputs))
+	}
+}
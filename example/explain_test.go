@@ -0,0 +1,253 @@
+package example
+
+import (
+	"context"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExplainTransaction(t *testing.T) {
+	reader := core.NewMemChainReader("ckb_testnet")
+	preTx := &types.Transaction{
+		Outputs: []*types.CellOutput{
+			{Capacity: 1000 * common.OneCkb, Lock: common.GetNormalLockScript("0x0101010101010101010101010101010101010101")},
+			{Capacity: 500 * common.OneCkb, Lock: common.GetNormalLockScript("0x0101010101010101010101010101010101010101")},
+		},
+		OutputsData: [][]byte{{}, {}},
+		Witnesses:   [][]byte{},
+	}
+	if err := reader.ApplyTransaction(preTx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	preTxHash, _ := preTx.ComputeHash()
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	tx := &types.Transaction{
+		Inputs: []*types.CellInput{
+			{PreviousOutput: &types.OutPoint{TxHash: preTxHash, Index: 0}},
+			{PreviousOutput: &types.OutPoint{TxHash: preTxHash, Index: 1}},
+		},
+		Outputs: []*types.CellOutput{
+			{Capacity: 1200 * common.OneCkb, Lock: common.GetNormalLockScript("0x0202020202020202020202020202020202020202")},
+			{Capacity: 300*common.OneCkb - 1000, Lock: common.GetNormalLockScript("0x0101010101010101010101010101010101010101")},
+		},
+		OutputsData: [][]byte{{}, {}},
+		Witnesses:   [][]byte{},
+	}
+	res, err := dc.ExplainTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(res.String())
+	if res.Fee != 1000 || len(res.Capacity) != 2 || len(res.SignGroups) != 1 || len(res.SignGroups[0].Inputs) != 2 {
+		t.Fatal("unexpected explain", res)
+	}
+	if res.Capacity[0].Net() != -1200*int64(common.OneCkb)-1000 || res.Capacity[1].Net() != 1200*int64(common.OneCkb) {
+		t.Fatal("unexpected capacity flow", res.Capacity)
+	}
+	if !strings.Contains(res.String(), "fee: 1000") {
+		t.Fatal("unexpected text", res.String())
+	}
+}
+
+func TestExplainTransactionDasActions(t *testing.T) {
+	seedSignerContracts(t)
+	dispatch, _ := core.GetDasContractInfo(common.DasContractNameDispatchCellType)
+	core.DasContractMap.Store(common.DasContractNameAccountCellType, &core.DasContractInfo{
+		ContractName:   common.DasContractNameAccountCellType,
+		OutPoint:       &types.OutPoint{},
+		ContractTypeId: types.HexToHash("0x000000000000000000000000000000000000000000000000000000000000ff10"),
+	})
+	accContract, _ := core.GetDasContractInfo(common.DasContractNameAccountCellType)
+
+	daf := core.DasAddressFormat{DasNetType: common.DasNetTypeTestnet2}
+	dasLock := func(owner, manager string) *types.Script {
+		args, err := daf.HexToArgs(
+			core.DasAddressHex{DasAlgorithmId: common.DasAlgorithmIdEth, AddressHex: owner, ChainType: common.ChainTypeEth},
+			core.DasAddressHex{DasAlgorithmId: common.DasAlgorithmIdEth, AddressHex: manager, ChainType: common.ChainTypeEth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &types.Script{CodeHash: dispatch.ContractTypeId, HashType: types.HashTypeType, Args: args}
+	}
+	ownerA := "0x000000000000000000000000000000000000000a"
+	ownerB := "0x000000000000000000000000000000000000000b"
+	managerC := "0x000000000000000000000000000000000000000c"
+
+	account := "explain.bit"
+	accountId := common.GetAccountIdByAccount(account)
+	moleculeAccountId, _ := molecule.AccountIdFromSlice(accountId, true)
+	var charSet []common.AccountCharSet
+	for _, v := range "explain" {
+		charSet = append(charSet, common.AccountCharSet{CharSetName: common.AccountCharTypeEn, Char: string(v)})
+	}
+	accountCellData := molecule.NewAccountCellDataBuilder().
+		Id(*moleculeAccountId).
+		Account(*common.ConvertToAccountChars(charSet)).
+		Records(*witness.ConvertToCellRecords([]witness.Record{{Key: "60", Type: "address", Value: "0x01", TTL: 300}})).
+		Build()
+	accountBuilder := witness.AccountCellDataBuilder{Version: common.GoDataEntityVersion4, AccountCellData: &accountCellData}
+	outputData := func(expiredAt uint64) []byte {
+		var data []byte
+		data = append(data, make([]byte, 32)...)
+		data = append(data, accountId...)
+		data = append(data, make([]byte, 20)...)
+		data = append(data, molecule.GoU64ToBytes(expiredAt)...)
+		return append(data, account...)
+	}
+	accountCell := func(lock *types.Script) *types.CellOutput {
+		return &types.CellOutput{Capacity: 200 * common.OneCkb, Lock: lock, Type: &types.Script{CodeHash: accContract.ContractTypeId, HashType: types.HashTypeType}}
+	}
+
+	reader := core.NewMemChainReader("ckb_testnet")
+	preTx := &types.Transaction{
+		Outputs:     []*types.CellOutput{accountCell(dasLock(ownerA, ownerA))},
+		OutputsData: [][]byte{outputData(1700000000)},
+		Witnesses:   [][]byte{},
+	}
+	if err := reader.ApplyTransaction(preTx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	preTxHash, _ := preTx.ComputeHash()
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+
+	ownerAddr := func(addr string) string {
+		normal, err := daf.HexToNormal(core.DasAddressHex{DasAlgorithmId: common.DasAlgorithmIdEth, AddressHex: addr, ChainType: common.ChainTypeEth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return normal.AddressNormal
+	}
+	records := []witness.Record{{Key: "60", Type: "address", Value: "0x02", TTL: 300}, {Key: "twitter", Type: "profile", Value: "das", TTL: 300}}
+	for _, c := range []struct {
+		action        common.DasAction
+		actionWitness []byte
+		param         witness.AccountCellParam
+		output        *types.CellOutput
+		expiredAt     uint64
+		check         func(acc core.TxExplainAccount) bool
+		role          string
+	}{
+		{
+			action: common.DasActionEditRecords,
+			param:  witness.AccountCellParam{Action: common.DasActionEditRecords, Records: records},
+			output: accountCell(dasLock(ownerA, ownerA)),
+			check: func(acc core.TxExplainAccount) bool {
+				return !acc.OwnerChanged && !acc.ManagerChanged && len(acc.RecordDiffs) == 2 &&
+					acc.RecordDiffs[0].Op == core.TxExplainRecordOpUpdate && acc.RecordDiffs[0].NewValue == "0x02" &&
+					acc.RecordDiffs[1].Op == core.TxExplainRecordOpAdd && acc.RecordDiffs[1].Key == "twitter"
+			},
+			role: "manager",
+		},
+		{
+			// the role of edit_records is manager without the param
+			action: common.DasActionEditRecords,
+			actionWitness: func() []byte {
+				bys, _ := witness.GenActionDataWitnessV3(common.DasActionEditRecords, nil)
+				return bys
+			}(),
+			param:  witness.AccountCellParam{Action: common.DasActionEditRecords, Records: records[:1]},
+			output: accountCell(dasLock(ownerA, ownerA)),
+			check: func(acc core.TxExplainAccount) bool {
+				return len(acc.RecordDiffs) == 1 && acc.RecordDiffs[0].Op == core.TxExplainRecordOpUpdate
+			},
+			role: "manager",
+		},
+		{
+			action: common.DasActionEditManager,
+			param:  witness.AccountCellParam{Action: common.DasActionEditManager},
+			output: accountCell(dasLock(ownerA, managerC)),
+			check: func(acc core.TxExplainAccount) bool {
+				return !acc.OwnerChanged && acc.ManagerChanged && acc.OldManager == ownerAddr(ownerA) &&
+					acc.NewManager == ownerAddr(managerC) && len(acc.RecordDiffs) == 0
+			},
+			role: "owner",
+		},
+		{
+			action: common.DasActionTransferAccount,
+			param:  witness.AccountCellParam{Action: common.DasActionTransferAccount},
+			output: accountCell(dasLock(ownerB, ownerB)),
+			check: func(acc core.TxExplainAccount) bool {
+				return acc.OwnerChanged && acc.ManagerChanged && acc.OldOwner == ownerAddr(ownerA) && acc.NewOwner == ownerAddr(ownerB) &&
+					len(acc.RecordDiffs) == 1 && acc.RecordDiffs[0].Op == core.TxExplainRecordOpRemove
+			},
+			role: "owner",
+		},
+		{
+			action:    common.DasActionRenewAccount,
+			param:     witness.AccountCellParam{Action: common.DasActionRenewAccount},
+			output:    accountCell(dasLock(ownerA, ownerA)),
+			expiredAt: 1700000000 + uint64(common.OneYearSec),
+			check: func(acc core.TxExplainAccount) bool {
+				return !acc.OwnerChanged && acc.OldExpiredAt == 1700000000 && acc.NewExpiredAt == 1700000000+uint64(common.OneYearSec)
+			},
+			role: "owner",
+		},
+	} {
+		actionWitness := c.actionWitness
+		if actionWitness == nil {
+			actionWitness, _ = witness.GenActionDataWitness(c.action, nil)
+		}
+		accountWitness, _, err := accountBuilder.GenWitness(&c.param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expiredAt := c.expiredAt
+		if expiredAt == 0 {
+			expiredAt = 1700000000
+		}
+		tx := &types.Transaction{
+			Inputs:      []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: preTxHash, Index: 0}}},
+			Outputs:     []*types.CellOutput{c.output},
+			OutputsData: [][]byte{outputData(expiredAt)},
+			Witnesses:   [][]byte{{}, actionWitness, accountWitness},
+		}
+		tx.Outputs[0].Capacity -= 1000
+		res, err := dc.ExplainTransaction(tx)
+		if err != nil {
+			t.Fatal(c.action, err)
+		}
+		if res.Action != c.action || res.Fee != 1000 || len(res.Accounts) != 1 || res.Accounts[0].Account != account {
+			t.Fatal(c.action, "unexpected explain", res)
+		}
+		if !c.check(res.Accounts[0]) {
+			t.Fatal(c.action, "unexpected account", res.Accounts[0])
+		}
+		if len(res.SignGroups) != 1 || res.SignGroups[0].Role != c.role {
+			t.Fatal(c.action, "unexpected sign groups", res.SignGroups)
+		}
+		if !strings.Contains(res.String(), "("+c.role+")") {
+			t.Fatal(c.action, "unexpected text", res.String())
+		}
+	}
+
+	// the outputs data of the previous tx may be missing
+	preTx = &types.Transaction{
+		Outputs:   []*types.CellOutput{{Capacity: 100 * common.OneCkb, Lock: common.GetNormalLockScript(ownerA)}},
+		Witnesses: [][]byte{},
+	}
+	if err := reader.ApplyTransaction(preTx, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	preTxHash, _ = preTx.ComputeHash()
+	res, err := dc.ExplainTransaction(&types.Transaction{
+		Inputs:      []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: preTxHash, Index: 0}}},
+		Outputs:     []*types.CellOutput{{Capacity: 99 * common.OneCkb, Lock: common.GetNormalLockScript(ownerB)}},
+		OutputsData: [][]byte{{}},
+		Witnesses:   [][]byte{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Fee != common.OneCkb {
+		t.Fatal("unexpected fee", res.Fee)
+	}
+}
@@ -0,0 +1,52 @@
+package example
+
+import (
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"testing"
+)
+
+// keepDasGlobals restores the contracts and config cells of core after the test, for the tests seeding them
+func keepDasGlobals(t *testing.T) {
+	contracts := make(map[interface{}]interface{})
+	core.DasContractMap.Range(func(key, value interface{}) bool {
+		contracts[key] = value
+		return true
+	})
+	configCells := make(map[interface{}]interface{})
+	core.DasConfigCellMap.Range(func(key, value interface{}) bool {
+		configCells[key] = value
+		return true
+	})
+	t.Cleanup(func() {
+		core.DasContractMap.Range(func(key, _ interface{}) bool {
+			core.DasContractMap.Delete(key)
+			return true
+		})
+		for k, v := range contracts {
+			core.DasContractMap.Store(k, v)
+		}
+		core.DasConfigCellMap.Range(func(key, _ interface{}) bool {
+			core.DasConfigCellMap.Delete(key)
+			return true
+		})
+		for k, v := range configCells {
+			core.DasConfigCellMap.Store(k, v)
+		}
+	})
+}
+
+// keepNetworks restores the registered networks after the test, for the tests registering networks
+func keepNetworks(t *testing.T) {
+	networks := common.Networks()
+	t.Cleanup(func() {
+		for _, v := range common.Networks() {
+			common.UnregisterNetwork(v.NetType)
+		}
+		for _, v := range networks {
+			if err := common.RegisterNetwork(v); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package example
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/keystore"
+	"github.com/dotbitHQ/das-lib/sign"
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeystore(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := keystore.NewKeystore(dir, keystore.WithScrypt(ethkeystore.LightScryptN, ethkeystore.LightScryptP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	info, err := ks.Import("eth", common.DasAlgorithmIdEth, 0, hex.EncodeToString(crypto.FromECDSA(key)), "pwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Address != common.FormatAddressPayload(addr.Bytes(), common.DasAlgorithmIdEth) {
+		t.Fatal("unexpected address", info.Address)
+	}
+	if _, err = ks.Import("eth", common.DasAlgorithmIdEth, 0, hex.EncodeToString(crypto.FromECDSA(key)), "pwd"); err != keystore.ErrKeyExist {
+		t.Fatal("imported twice", err)
+	}
+	if _, err = ks.Import("../eth", common.DasAlgorithmIdEth, 0, hex.EncodeToString(crypto.FromECDSA(key)), "pwd"); err == nil {
+		t.Fatal("invalid name imported")
+	}
+	// the evm key file is an ethereum keystore v3
+	keyJson, err := os.ReadFile(filepath.Join(dir, "eth.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, err := ethkeystore.DecryptKey(keyJson, "pwd"); err != nil || k.Address != addr {
+		t.Fatal("not ethereum keystore v3", err)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 1
+	if info, err = ks.Import("ed25519", common.DasAlgorithmIdEd25519, 0, hex.EncodeToString(seed), "pwd2"); err != nil {
+		t.Fatal(err)
+	}
+	edPrivate := ed25519.NewKeyFromSeed(seed)
+	if info.Address != hex.EncodeToString(edPrivate.Public().(ed25519.PublicKey)) {
+		t.Fatal("unexpected address", info.Address)
+	}
+
+	signer, err := ks.Signer("eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := common.DotBitPrefix + hex.EncodeToString(make([]byte, 32))
+	if _, err = signer.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdEth, SignMsg: msg}); err != keystore.ErrKeyLocked {
+		t.Fatal("signed by locked key", err)
+	}
+	if err = ks.Unlock("eth", "wrong", 0); err != keystore.ErrDecryptFailed {
+		t.Fatal("unlocked by wrong passphrase", err)
+	}
+	if err = ks.Unlock("eth", "pwd", 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := sign.NewSigners(signer).Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdEth, SignMsg: msg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := sign.VerifyPersonalSignature(sig, []byte(msg), addr.Hex()); err != nil || !ok {
+		t.Fatal("signature is invalid", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if ks.IsUnlocked("eth") {
+		t.Fatal("key not locked after timeout")
+	}
+
+	// reload from dir
+	ks, err = keystore.NewKeystore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := ks.List(); len(list) != 2 || list[0].Name != "ed25519" || list[1].AlgorithmId != common.DasAlgorithmIdEth {
+		t.Fatal("unexpected keys", list)
+	}
+	if err = ks.Unlock("ed25519", "pwd", 0); err != keystore.ErrDecryptFailed {
+		t.Fatal("unlocked by wrong passphrase", err)
+	}
+	if err = ks.Unlock("ed25519", "pwd2", 0); err != nil {
+		t.Fatal(err)
+	}
+	edSigner, _ := ks.Signer("ed25519")
+	message := make([]byte, 32)
+	if sig, err = edSigner.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdEd25519, SignMsg: hex.EncodeToString(message)}); err != nil {
+		t.Fatal(err)
+	}
+	if !sign.VerifyEd25519Signature(edPrivate.Public().(ed25519.PublicKey), message, sig) {
+		t.Fatal("ed25519 signature is invalid")
+	}
+	ks.Lock("ed25519")
+	if err = ks.WithPrivateKey("ed25519", func(string) error { return nil }); err != keystore.ErrKeyLocked {
+		t.Fatal("key not locked", err)
+	}
+	if err = ks.WithPrivateKey("none", func(string) error { return nil }); err != keystore.ErrKeyNotExist {
+		t.Fatal("key exists", err)
+	}
+}
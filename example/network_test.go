@@ -0,0 +1,130 @@
+package example
+
+import (
+	"context"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const devnetProfile = `
+- name: devnet
+  net_type: 100
+  chain: ckb_dev
+  main_net: false
+  contract_args_multi_sig: false
+  thq_code_hash: "0x96248cdefb09eed910018a847cfb51ad044c2d7db650112931760e3ef34a7e9a"
+  contract_args: "0xc126635ece567c71c50f7482c5db80603852c306"
+  contract_code_hash: "0x00000000000000000000000000000000000000000000000000545950455f4944"
+  contracts:
+    das-lock: "0xeedd10c7d8fee85c119daf2077fea9cf76b9a92ddca546f1f8e0031682e65aee"
+    balance-cell-type: "0x27560fe2daa6150b771621300d1d4ea127832b7b326f2d70eed63f5333b4a8a9"
+  so_scripts:
+    Eth: "0x644ead361f2a2fe32431a81489a7a0f8d7332ba17918ab5184461d588f011a82"
+  contract_versions:
+    balance-cell-type: "1.0.0"
+  secp_dep:
+    tx_hash: "0x0000000000000000000000000000000000000000000000000000000000000d01"
+    dep_type: dep_group
+  any_lock_deps:
+    joyid:
+      tx_hash: "0x0000000000000000000000000000000000000000000000000000000000000d02"
+      index: 1
+      dep_type: dep_group
+  did_cell_recycle_dep:
+    tx_hash: "0x0000000000000000000000000000000000000000000000000000000000000d03"
+    dep_type: code
+  cluster_id: "0x0000000000000000000000000000000000000000000000000000000000000d04"
+`
+
+func TestLoadNetworkProfiles(t *testing.T) {
+	keepNetworks(t)
+	path := filepath.Join(t.TempDir(), "devnet.yaml")
+	if err := os.WriteFile(path, []byte(devnetProfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	list, err := common.LoadNetworkProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].NetType != 100 {
+		t.Fatal("profiles:", list)
+	}
+	net := common.DasNetType(100)
+
+	if common.IsMainNet(net) || !common.IsMainNet(common.DasNetTypeMainNet) {
+		t.Fatal("IsMainNet")
+	}
+	if profile, err := common.GetNetworkByChain("ckb_dev"); err != nil || profile.Name != "devnet" {
+		t.Fatal("GetNetworkByChain:", err)
+	}
+	if profile, err := common.GetNetworkByChain("ckb"); err != nil || profile.NetType != common.DasNetTypeMainNet {
+		t.Fatal("GetNetworkByChain:", err)
+	}
+
+	env := core.InitEnv(net)
+	if env.ContractArgs != "0xc126635ece567c71c50f7482c5db80603852c306" || len(env.MapContract) != 2 {
+		t.Fatal("InitEnv:", env)
+	}
+	env = core.InitEnvOpt(net, common.DasContractNameBalanceCellType)
+	if len(env.MapContract) != 1 || env.MapSoScript[common.SoScriptTypeEth] == "" {
+		t.Fatal("InitEnvOpt:", env)
+	}
+	if core.InitEnv(common.DasNetTypeTestnet2).ContractArgs != core.EnvTestnet2.ContractArgs {
+		t.Fatal("InitEnv testnet2")
+	}
+	if common.Bytes2Hex(witness.GetClusterId(net)) != "0x0000000000000000000000000000000000000000000000000000000000000d04" ||
+		witness.GetDidCellRecycleCellDeps(net).OutPoint.TxHash.Hex() != "0x0000000000000000000000000000000000000000000000000000000000000d03" {
+		t.Fatal("did cell deps of devnet")
+	}
+	if !witness.IsDidCellRecycleCellDep(witness.GetDidCellRecycleCellDeps(common.DasNetTypeTestnet2).OutPoint) {
+		t.Fatal("did cell recycle dep of testnet2")
+	}
+
+	// the first registered network of the chain is returned
+	second := *list[0]
+	second.Name, second.NetType = "devnet2", 101
+	if err = common.RegisterNetwork(&second); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if profile, err := common.GetNetworkByChain("ckb_dev"); err != nil || profile.Name != "devnet" {
+			t.Fatal("GetNetworkByChain:", profile, err)
+		}
+	}
+
+	dc := core.NewDasCore(context.Background(), &sync.WaitGroup{}, core.WithDasNetType(net))
+	if dep, err := dc.GetAnyLockCellDep(core.AnyLockNameJoyID); err != nil || dep.OutPoint.Index != 1 || dep.DepType != types.DepTypeDepGroup {
+		t.Fatal("GetAnyLockCellDep:", dep, err)
+	}
+	if _, err = dc.GetAnyLockCellDep(core.AnyLockNameOmniLock); err == nil {
+		t.Fatal("omni-lock dep of devnet")
+	}
+	lock := dc.GetDasLock()
+	if lock == nil || lock.CodeHash.Hex() != "0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8" ||
+		common.Bytes2Hex(lock.Args) != "0xc126635ece567c71c50f7482c5db80603852c306" {
+		t.Fatal("GetDasLock:", lock)
+	}
+
+	res, err := dc.Daf().NormalToHex(core.DasAddressNormal{
+		ChainType:     common.ChainTypeCkb,
+		AddressNormal: "ckt1qyqvsv5240xeh85wvnau2eky8pwrhh4jr8ts8vyj37",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(res.AddressPayload) != "0xc8328aabcd9b9e8e64fbc566c4385c3bdeb219d7" {
+		t.Fatal("NormalToHex:", res)
+	}
+	if _, err = dc.Daf().NormalToHex(core.DasAddressNormal{
+		ChainType:     common.ChainTypeCkb,
+		AddressNormal: "ckb1qyqvsv5240xeh85wvnau2eky8pwrhh4jr8tsnrk5vk",
+	}); err == nil {
+		t.Fatal("mainnet address on devnet")
+	}
+}
@@ -0,0 +1,233 @@
+package example
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/sign"
+	"github.com/dotbitHQ/das-lib/smt"
+	"github.com/dotbitHQ/das-lib/txbuilder"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReverseSmtRecordVerify(t *testing.T) {
+	// the reverse smt witness of a testnet update_reverse_record_root tx
+	tx := &types.Transaction{Witnesses: [][]byte{common.Hex2Bytes("0x6461730a000000040000000100000006000000757064617465430000007df51a5d516cd0595bf4a202277931599b1c07720da24c81e25c368f5d5371fb56cca7c8a330e6a0612d24e70bd4189b33b05321b5adcc61967cf385ca4b62ca0101000100000009140000005ef634a3ddc0b2cf9a6804c6a3cc3251ea5c8e44ea0000004c4ffa504f3528a830511d93952465424186d2b55f46c0967335bc7d97ddf0aad97e5abc51fb2273a351c4867f6604b17d3285f073f9afa1c95b8b53deb506a3cc824871e16eda6c8e75bd81e2d9d60d1ce2df4256186cffe598ed3ce3477719a0ae32dcf80150a4c5137c3d4b212e126910433aa3c00adf46a427b5426adf9fefcdf1c7ab2b13500425c9884c96ee9ba0e75725de6cc6d134d752f25090e8d8098a2e56deb01a56502bd6035a9f621b4f3b07c340ebbea00e65f71389bd426991a2d1f0b86f98ca62506264846d3d53b85ffbd9a736e81aa6e26318158090bee751d729ee8b97a04f4800000000000000002000000068743c8ed1b3f67ad393b619a870d210acbd86ebb9f6bac536f8c15c581cca560c00000032303234303533302e626974")}}
+	var records []*witness.ReverseSmtRecord
+	if err := witness.ParseFromTx(tx, common.ActionDataTypeReverseSmt, &records); err != nil {
+		t.Fatal(err)
+	}
+	record := records[0]
+	declaration := txbuilder.ReverseSmtDeclaration{
+		Action:    record.Action,
+		SignType:  common.DasAlgorithmId(record.SignType),
+		Address:   record.Address,
+		Account:   record.NextAccount,
+		Signature: record.Signature,
+	}
+	if ok, err := declaration.Verify(record.PrevNonce + 1); err != nil || !ok {
+		t.Fatal("verify", err)
+	}
+	if ok, _ := declaration.Verify(record.PrevNonce + 2); ok {
+		t.Fatal("verify with a wrong nonce")
+	}
+	proof := smt.CompiledMerkleProof(record.Proof)
+	key := txbuilder.ReverseSmtKey(record.Address)
+	value := txbuilder.ReverseSmtValue(record.PrevNonce+1, record.NextAccount)
+	if ok, err := smt.Verify(record.NextRoot, &proof, []smt.H256{key}, []smt.H256{value}); err != nil || !ok {
+		t.Fatal("smt verify", err)
+	}
+}
+
+func TestReverseSmtSignData(t *testing.T) {
+	digest := common.Blake2b(append(molecule.GoU32ToBytes(3), []byte("alice.bit")...))
+	if msg := txbuilder.ReverseSmtSignMsg(3, "alice.bit"); msg != common.DotBitPrefix+hex.EncodeToString(digest) {
+		t.Fatal("unexpected sign msg", msg)
+	}
+
+	// ed25519 signs the digest itself like the tx digest, so sign.Verify accepts the signature as well
+	edPublic, edPrivate, _ := ed25519.GenerateKey(nil)
+	signData := txbuilder.ReverseSmtSignData(common.DasAlgorithmIdEd25519, 3, "alice.bit")
+	if signData.SignMsg != common.Bytes2Hex(digest) {
+		t.Fatal("unexpected ed25519 sign msg", signData.SignMsg)
+	}
+	declaration := txbuilder.ReverseSmtDeclaration{
+		Action:    witness.ReverseSmtRecordActionUpdate,
+		SignType:  common.DasAlgorithmIdEd25519,
+		Address:   edPublic,
+		Account:   "alice.bit",
+		Signature: sign.Ed25519Signature(edPrivate, common.Hex2Bytes(signData.SignMsg)),
+	}
+	if ok, err := declaration.Verify(3); err != nil || !ok {
+		t.Fatal("verify", err)
+	}
+	if _, ok, err := sign.Verify(common.DasAlgorithmIdEd25519, 0, signData.SignMsg, declaration.Signature, common.FormatAddressPayload(edPublic, common.DasAlgorithmIdEd25519)); err != nil || !ok {
+		t.Fatal("sign.Verify", err)
+	}
+
+	// the signature of the text message is rejected
+	declaration.Signature = sign.Ed25519Signature(edPrivate, []byte(txbuilder.ReverseSmtSignMsg(3, "alice.bit")))
+	if ok, _ := declaration.Verify(3); ok {
+		t.Fatal("verify the text message")
+	}
+}
+
+func TestBuildReverseSmtTx(t *testing.T) {
+	keepDasGlobals(t)
+	env := core.InitEnv(common.DasNetTypeTestnet2)
+	reader := core.NewMemChainReader("ckb_testnet")
+
+	// contracts, config cells and the reverse record root cell
+	rootTypeId := types.HexToHash("0x00000000000000000000000000000000000000000000000000000000000fe002")
+	core.DasContractMap.Store(common.DasContractNameReverseRecordRootCellType, &core.DasContractInfo{
+		ContractName:   common.DasContractNameReverseRecordRootCellType,
+		OutPoint:       &types.OutPoint{},
+		ContractTypeId: rootTypeId,
+	})
+	for i, name := range []common.DasContractName{common.DasContractNameDispatchCellType, common.DasContractNameBalanceCellType} {
+		if _, err := core.GetDasContractInfo(name); err != nil {
+			core.DasContractMap.Store(name, &core.DasContractInfo{
+				ContractName:   name,
+				OutPoint:       &types.OutPoint{},
+				ContractTypeId: types.HexToHash(fmt.Sprintf("0x%064x", 0xff00+i)),
+			})
+		}
+	}
+	core.DasConfigCellMap.Store(common.ConfigCellTypeArgsReverseRecord, &core.DasConfigCellInfo{Name: "ConfigCellReverseRecord"})
+	core.DasConfigCellMap.Store(common.ConfigCellTypeArgsSMTNodeWhitelist, &core.DasConfigCellInfo{Name: "ConfigCellSMTNodeWhitelist"})
+
+	payerLock := common.GetNormalLockScript("0x0000000000000000000000000000000000000007")
+	if err := reader.ApplyTransaction(&types.Transaction{
+		Outputs: []*types.CellOutput{
+			{Capacity: 200 * common.OneCkb, Lock: common.GetNormalLockScript("0x01"), Type: &types.Script{CodeHash: rootTypeId, HashType: types.HashTypeType}},
+			{Capacity: 1000 * common.OneCkb, Lock: payerLock},
+		},
+		OutputsData: [][]byte{smt.H256Zero(), {}},
+	}, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader),
+		core.WithDasNetType(common.DasNetTypeTestnet2), core.WithTHQCodeHash(env.THQCodeHash))
+	tree := smt.NewSparseMerkleTree(nil)
+	store := txbuilder.NewMemReverseSmtStateStore()
+
+	// declarations of an eth address and an ed25519 public key
+	ethKey, _ := crypto.GenerateKey()
+	ethPrivate := hex.EncodeToString(crypto.FromECDSA(ethKey))
+	ethAddress := crypto.PubkeyToAddress(ethKey.PublicKey).Bytes()
+	edPublic, edPrivate, _ := ed25519.GenerateKey(nil)
+	declare := func(nonce uint32, account string) []*txbuilder.ReverseSmtDeclaration {
+		ethSig, err := sign.PersonalSignature([]byte(txbuilder.ReverseSmtSignMsg(nonce, account)), ethPrivate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		action := witness.ReverseSmtRecordActionUpdate
+		if account == "" {
+			action = witness.ReverseSmtRecordActionRemove
+		}
+		return []*txbuilder.ReverseSmtDeclaration{
+			{Action: action, SignType: common.DasAlgorithmIdEth, Address: ethAddress, Account: account, Signature: ethSig},
+			{Action: action, SignType: common.DasAlgorithmIdEd25519, Address: edPublic, Account: account,
+				Signature: sign.Ed25519Signature(edPrivate, common.Hex2Bytes(txbuilder.ReverseSmtSignData(common.DasAlgorithmIdEd25519, nonce, account).SignMsg))},
+		}
+	}
+	build := func(declarations []*txbuilder.ReverseSmtDeclaration, tree txbuilder.SubAccountSmt) (*txbuilder.BuildTransactionParams, []*witness.ReverseSmtRecord, error) {
+		return txbuilder.BuildReverseSmtTx(txbuilder.ReverseSmtTxParams{
+			DasCore:      dc,
+			DasCache:     dascache.NewDasCache(context.Background(), &wg),
+			ReverseSmt:   tree,
+			StateStore:   store,
+			Declarations: declarations,
+			PayerLock:    payerLock,
+		})
+	}
+	apply := func(res *txbuilder.BuildTransactionParams, records []*witness.ReverseSmtRecord, blockNumber uint64) {
+		tx := &types.Transaction{Inputs: res.Inputs, Outputs: res.Outputs, OutputsData: res.OutputsData, Witnesses: res.Witnesses}
+		if err := reader.ApplyTransaction(tx, blockNumber, 0); err != nil {
+			t.Fatal(err)
+		}
+		store.Apply(records)
+	}
+
+	// update
+	res, records, err := build(declare(1, "alice.bit"), tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := tree.Root()
+	if common.Bytes2Hex(res.OutputsData[0]) != common.Bytes2Hex(root) || res.Outputs[0].Capacity != 200*common.OneCkb ||
+		res.Outputs[1].Capacity != 1000*common.OneCkb-common.UserCellTxFeeLimit {
+		t.Fatal("unexpected outputs", res.Outputs, res.OutputsData)
+	}
+	var txRecords []*witness.ReverseSmtRecord
+	if err := witness.ParseFromTx(&types.Transaction{Witnesses: res.Witnesses}, common.ActionDataTypeReverseSmt, &txRecords); err != nil {
+		t.Fatal(err)
+	}
+	if len(txRecords) != 2 || txRecords[1].NextAccount != "alice.bit" || common.Bytes2Hex(txRecords[1].NextRoot) != common.Bytes2Hex(root) {
+		t.Fatal("unexpected records", txRecords)
+	}
+	apply(res, records, 2)
+
+	// a replayed declaration is rejected
+	if _, _, err = build(declare(1, "alice.bit"), tree); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatal("replay", err)
+	}
+
+	// a stale smt is rejected before it is updated
+	stale := smt.NewSparseMerkleTree(nil)
+	staleRoot, _ := stale.Root()
+	if _, _, err = build(declare(2, "bob.bit"), stale); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Fatal("stale smt", err)
+	}
+	if root, _ := stale.Root(); common.Bytes2Hex(root) != common.Bytes2Hex(staleRoot) {
+		t.Fatal("stale smt is updated")
+	}
+
+	// the smt is rolled back if the proof is invalid
+	root, _ = tree.Root()
+	if _, _, err = build(declare(2, "bob.bit"), &corruptSmt{tree: tree}); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Fatal("corrupt smt", err)
+	}
+	if newRoot, _ := tree.Root(); common.Bytes2Hex(newRoot) != common.Bytes2Hex(root) {
+		t.Fatal("smt is not rolled back")
+	}
+
+	// the smt is not updated if the payer can't pay the fee
+	if _, _, err = txbuilder.BuildReverseSmtTx(txbuilder.ReverseSmtTxParams{
+		DasCore:      dc,
+		ReverseSmt:   tree,
+		StateStore:   store,
+		Declarations: declare(2, "bob.bit"),
+		PayerLock:    common.GetNormalLockScript("0x0000000000000000000000000000000000000008"),
+	}); err == nil || !strings.Contains(err.Error(), "GetBalanceCellWithLock") {
+		t.Fatal("payer without cells", err)
+	}
+	if newRoot, _ := tree.Root(); common.Bytes2Hex(newRoot) != common.Bytes2Hex(root) {
+		t.Fatal("smt is updated without the payer cells")
+	}
+
+	// remove keeps the nonce
+	res, records, err = build(declare(2, ""), tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(res, records, 3)
+	if state, _ := store.GetReverseSmtState(ethAddress); state.Nonce != 2 || state.Account != "" {
+		t.Fatal("unexpected state", state)
+	}
+	if _, _, err = build(declare(3, ""), tree); err == nil {
+		t.Fatal("remove twice")
+	}
+}
@@ -0,0 +1,301 @@
+package example
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/checkpoint"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/scanner"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sync"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	reader := core.NewMemChainReader("ckb_testnet")
+	now := int64(1700000000)
+
+	// account cell of scan.bit
+	account := "scan.bit"
+	accountId := common.GetAccountIdByAccount(account)
+	moleculeAccountId, _ := molecule.AccountIdFromSlice(accountId, true)
+	var charSet []common.AccountCharSet
+	for _, v := range "scan" {
+		charSet = append(charSet, common.AccountCharSet{CharSetName: common.AccountCharTypeEn, Char: string(v)})
+	}
+	accountCellData := molecule.NewAccountCellDataBuilder().
+		Id(*moleculeAccountId).
+		Account(*common.ConvertToAccountChars(charSet)).
+		RegisteredAt(molecule.GoU64ToMoleculeU64(uint64(now))).
+		Build()
+	accountBuilder := witness.AccountCellDataBuilder{Version: common.GoDataEntityVersion4, AccountCellData: &accountCellData}
+	var outputData []byte
+	outputData = append(outputData, make([]byte, 32)...)
+	outputData = append(outputData, accountId...)
+	outputData = append(outputData, make([]byte, 20)...)
+	outputData = append(outputData, molecule.GoU64ToBytes(uint64(now+common.OneYearSec))...)
+	outputData = append(outputData, account...)
+	accountCell := func(args string) *types.CellOutput {
+		return &types.CellOutput{Capacity: 200 * common.OneCkb, Lock: common.GetNormalLockScript(args)}
+	}
+	dasTx := func(action common.DasAction, param *witness.AccountCellParam, preTx *types.Transaction, args string) *types.Transaction {
+		actionWitness, err := witness.GenActionDataWitness(action, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		accountWitness, _, err := accountBuilder.GenWitness(param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		preTxHash, _ := preTx.ComputeHash()
+		return &types.Transaction{
+			Inputs:      []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: preTxHash, Index: 0}}},
+			Outputs:     []*types.CellOutput{accountCell(args)},
+			OutputsData: [][]byte{outputData},
+			Witnesses:   [][]byte{{}, actionWitness, accountWitness},
+		}
+	}
+	ownerA := "0x000000000000000000000000000000000000000a"
+	ownerB := "0x000000000000000000000000000000000000000b"
+	tx0 := &types.Transaction{Outputs: []*types.CellOutput{accountCell(ownerA)}, OutputsData: [][]byte{outputData}}
+	tx1 := dasTx(common.DasActionTransferAccount, &witness.AccountCellParam{Action: common.DasActionTransferAccount}, tx0, ownerB)
+	tx2 := dasTx(common.DasActionEditRecords, &witness.AccountCellParam{
+		Action:  common.DasActionEditRecords,
+		Records: []witness.Record{{Key: "60", Type: "address", Value: "0x01", TTL: 300}},
+	}, tx1, ownerB)
+
+	// blocks, the hash of block n of fork f is n + f<<8, fork f starts at block f+1
+	addBlock := func(number uint64, fork uint64, txs ...*types.Transaction) {
+		parentFork := fork
+		if fork > 0 && number == fork+1 {
+			parentFork = fork - 1
+		}
+		header := &types.Header{
+			Number:     number,
+			Hash:       types.HexToHash(fmt.Sprintf("0x%064x", number+fork<<8)),
+			ParentHash: types.HexToHash(fmt.Sprintf("0x%064x", number-1+parentFork<<8)),
+			Timestamp:  uint64(now) * 1000,
+		}
+		reader.AddBlock(&types.Block{Header: header, Transactions: txs})
+		for i, tx := range txs {
+			if err := reader.ApplyTransaction(tx, number, uint(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	addBlock(0, 0, tx0)
+	addBlock(1, 0, tx1)
+	addBlock(2, 0, tx2)
+	addBlock(3, 0)
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	store := checkpoint.NewMemStore(0)
+	s, err := scanner.NewScanner(context.Background(), &wg, dc, scanner.WithConfirmations(1), scanner.WithBlockStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []scanner.Event
+	s.Subscribe(func(e scanner.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	var actions []common.DasAction
+	s.Subscribe(func(e scanner.Event) error {
+		actions = append(actions, e.Base().Action)
+		return nil
+	}, scanner.EventTypeAction)
+
+	count, err := s.ScanOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || len(actions) != 2 || actions[0] != common.DasActionTransferAccount || actions[1] != common.DasActionEditRecords {
+		t.Fatal("unexpected scan", count, actions)
+	}
+	var eventTypes []scanner.EventType
+	for _, v := range events {
+		eventTypes = append(eventTypes, v.Type())
+	}
+	if fmt.Sprint(eventTypes) != "[action owner_transferred action records_edited]" {
+		t.Fatal("unexpected events", eventTypes)
+	}
+	transfer := events[1].(*scanner.OwnerTransferredEvent)
+	if transfer.Account != account || transfer.OldOwner == transfer.NewOwner || transfer.BlockNumber != 1 {
+		t.Fatal("unexpected transfer", transfer)
+	}
+	edit := events[3].(*scanner.RecordsEditedEvent)
+	if len(edit.Diffs) != 1 || edit.Diffs[0].Op != core.TxExplainRecordOpAdd || edit.Diffs[0].NewValue != "0x01" {
+		t.Fatal("unexpected records", edit.Diffs)
+	}
+	if cp, _ := store.Latest(); cp == nil || cp.Number != 2 {
+		t.Fatal("unexpected checkpoint", cp)
+	}
+
+	// block 2 is replaced, its events are rolled back before the new blocks
+	events = nil
+	addBlock(2, 1)
+	addBlock(3, 1)
+	addBlock(4, 1)
+	if count, err = s.ScanOnce(); err != nil || count != 2 {
+		t.Fatal("scan after fork", count, err)
+	}
+	if len(events) != 1 || events[0].Type() != scanner.EventTypeBlockRolledBack || events[0].Base().BlockNumber != 2 {
+		t.Fatal("unexpected rollback", events)
+	}
+	if cp := s.Checkpoint(); cp.BlockNumber != 3 || cp.BlockHash != types.HexToHash(fmt.Sprintf("0x%064x", 3+1<<8)).Hex() {
+		t.Fatal("unexpected checkpoint", cp)
+	}
+
+	// a new scanner continues from the checkpoint, block 3 is replaced while it is stopped
+	addBlock(3, 2)
+	addBlock(4, 2)
+	s, err = scanner.NewScanner(context.Background(), &wg, dc, scanner.WithConfirmations(1), scanner.WithBlockStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = nil
+	s.Subscribe(func(e scanner.Event) error {
+		events = append(events, e)
+		return nil
+	}, scanner.EventTypeBlockRolledBack)
+	if count, err = s.ScanOnce(); err != nil || count != 1 {
+		t.Fatal("scan after restart", count, err)
+	}
+	if len(events) != 1 || events[0].Base().BlockNumber != 3 {
+		t.Fatal("unexpected rollback", events)
+	}
+
+	// the legacy store keeps only the checkpoint, the fork of it is rolled back after restart
+	addBlock(5, 2)
+	cpStore := &scanner.MemCheckpointStore{}
+	legacy, err := scanner.NewScanner(context.Background(), &wg, dc, scanner.WithConfirmations(1), scanner.WithCheckpointStore(cpStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err = legacy.ScanOnce(); err != nil || count != 5 {
+		t.Fatal("scan by the checkpoint store", count, err)
+	}
+	addBlock(4, 3)
+	addBlock(5, 3)
+	addBlock(6, 3)
+	s, err = scanner.NewScanner(context.Background(), &wg, dc, scanner.WithConfirmations(1), scanner.WithCheckpointStore(cpStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = nil
+	s.Subscribe(func(e scanner.Event) error {
+		events = append(events, e)
+		return nil
+	}, scanner.EventTypeBlockRolledBack)
+	var ancestors []uint64
+	s.OnRollback(func(ancestor checkpoint.Block, removed []checkpoint.Block) error {
+		ancestors = append(ancestors, ancestor.Number)
+		return nil
+	})
+	if _, err = s.ScanOnce(); err == nil {
+		t.Fatal("forked checkpoint without the earlier blocks")
+	}
+	if len(events) != 0 || len(ancestors) != 0 {
+		t.Fatal("unexpected rollback", events, ancestors)
+	}
+	if cp, _ := cpStore.LoadCheckpoint(); cp == nil || cp.BlockNumber != 4 {
+		t.Fatal("unexpected checkpoint", cp)
+	}
+	// the running scanner has the blocks before the checkpoint in memory
+	if count, err = legacy.ScanOnce(); err != nil || count != 2 {
+		t.Fatal("scan after fork", count, err)
+	}
+	if cp, _ := cpStore.LoadCheckpoint(); cp == nil || cp.BlockNumber != 5 || cp.BlockHash != types.HexToHash(fmt.Sprintf("0x%064x", 5+3<<8)).Hex() {
+		t.Fatal("unexpected checkpoint", cp)
+	}
+}
+
+func TestScannerDecodeError(t *testing.T) {
+	reader := core.NewMemChainReader("ckb_testnet")
+	actionWitness, err := witness.GenActionDataWitness(common.DasActionUpdateReverseRecordRoot, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx0 := &types.Transaction{
+		Outputs:     []*types.CellOutput{{Capacity: 200 * common.OneCkb, Lock: common.GetNormalLockScript("0x000000000000000000000000000000000000000a")}},
+		OutputsData: [][]byte{{}},
+	}
+	tx0Hash, _ := tx0.ComputeHash()
+	// the reverse smt witness can't be decoded
+	badTx := &types.Transaction{
+		Inputs:      []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: tx0Hash, Index: 0}}},
+		Outputs:     tx0.Outputs,
+		OutputsData: tx0.OutputsData,
+		Witnesses:   [][]byte{{}, actionWitness, witness.GenDasDataWitnessWithByte(common.ActionDataTypeReverseSmt, []byte{1, 2, 3})},
+	}
+	for i, txs := range [][]*types.Transaction{{tx0}, {badTx}, nil} {
+		number := uint64(i)
+		reader.AddBlock(&types.Block{Header: &types.Header{
+			Number:     number,
+			Hash:       types.HexToHash(fmt.Sprintf("0x%064x", number+1)),
+			ParentHash: types.HexToHash(fmt.Sprintf("0x%064x", number)),
+		}, Transactions: txs})
+		for j, tx := range txs {
+			if err := reader.ApplyTransaction(tx, number, uint(j)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	if _, err = scanner.DecodeTransaction(dc, badTx, scanner.EventBase{}); err == nil {
+		t.Fatal("bad tx is decoded")
+	}
+
+	// the tx is stopped by the handler, and the block is delivered again
+	var handled []*scanner.TxDecodeFailedEvent
+	s, err := scanner.NewScanner(context.Background(), &wg, dc, scanner.WithConfirmations(1),
+		scanner.WithDecodeErrorHandler(func(e *scanner.TxDecodeFailedEvent, err error) error {
+			handled = append(handled, e)
+			return err
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if count, err := s.ScanOnce(); err == nil || count != i^1 {
+			t.Fatal("scan with the strict handler", count, err)
+		}
+	}
+	if cp := s.Checkpoint(); cp == nil || cp.BlockNumber != 0 {
+		t.Fatal("unexpected checkpoint", cp)
+	}
+	if len(handled) != 2 || handled[0].BlockNumber != 1 || handled[0].TxIndex != 0 || handled[0].Err == "" {
+		t.Fatal("unexpected handled", handled)
+	}
+
+	// the tx is skipped by default
+	s, err = scanner.NewScanner(context.Background(), &wg, dc, scanner.WithConfirmations(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []scanner.Event
+	s.Subscribe(func(e scanner.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if count, err := s.ScanOnce(); err != nil || count != 2 {
+		t.Fatal("scan", count, err)
+	}
+	if len(events) != 1 || events[0].Type() != scanner.EventTypeTxDecodeFailed {
+		t.Fatal("unexpected events", events)
+	}
+	failed := events[0].(*scanner.TxDecodeFailedEvent)
+	tx1Hash, _ := badTx.ComputeHash()
+	if failed.BlockNumber != 1 || failed.TxHash != tx1Hash.Hex() || failed.Action != common.DasActionUpdateReverseRecordRoot || failed.Transaction != badTx {
+		t.Fatal("unexpected failed event", failed)
+	}
+	if cp := s.Checkpoint(); cp == nil || cp.BlockNumber != 1 {
+		t.Fatal("unexpected checkpoint", cp)
+	}
+}
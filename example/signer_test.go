@@ -0,0 +1,383 @@
+package example
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/sign"
+	"github.com/dotbitHQ/das-lib/txbuilder"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSigners(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	ethSigner, err := sign.NewLocalSigner(common.DasAlgorithmIdEth, hex.EncodeToString(crypto.FromECDSA(key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tronSigner, _ := sign.NewLocalSigner(common.DasAlgorithmIdTron, hex.EncodeToString(crypto.FromECDSA(key)))
+	mock := sign.NewMockSigner(common.DasAlgorithmIdDogeChain)
+	signers := sign.NewSigners(ethSigner, tronSigner, mock)
+
+	msg := common.DotBitPrefix + hex.EncodeToString(make([]byte, 32))
+	sig, err := signers.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdEth, SignMsg: msg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := sign.VerifyPersonalSignature(sig, []byte(msg), crypto.PubkeyToAddress(key.PublicKey).Hex()); err != nil || !ok {
+		t.Fatal("eth signature is invalid", err)
+	}
+	sig, err = signers.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdTron, SignMsg: msg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base58Addr, _ := common.TronHexToBase58(common.TronPreFix + crypto.PubkeyToAddress(key.PublicKey).Hex()[2:])
+	if !sign.TronVerifySignature(true, sig, []byte(msg), base58Addr) {
+		t.Fatal("tron signature is invalid")
+	}
+	if sig, err = signers.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdDogeChain, SignMsg: msg}); err != nil || len(sig) != 66 || len(mock.Requests()) != 1 {
+		t.Fatal("unexpected mock signature", len(sig), err)
+	}
+	if _, err = signers.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdBitcoin, SignMsg: msg}); err == nil {
+		t.Fatal("signer of bitcoin not exist")
+	}
+	if _, err = sign.NewLocalSigner(common.DasAlgorithmIdWebauthn, ""); err == nil {
+		t.Fatal("webauthn is not supported by local signer")
+	}
+}
+
+// seedSignerContracts seeds the contracts read by the digest of the groups
+func seedSignerContracts(t *testing.T) {
+	keepDasGlobals(t)
+	for i, name := range []common.DasContractName{common.DasContractNameDispatchCellType, common.DasContractNameBalanceCellType} {
+		if _, err := core.GetDasContractInfo(name); err != nil {
+			core.DasContractMap.Store(name, &core.DasContractInfo{
+				ContractName:   name,
+				OutPoint:       &types.OutPoint{},
+				ContractTypeId: types.HexToHash(fmt.Sprintf("0x%064x", 0xff00+i)),
+			})
+		}
+	}
+	core.DasConfigCellMap.LoadOrStore(common.ConfigCellTypeArgsMain, &core.DasConfigCellInfo{Name: "ConfigCellMain"})
+}
+
+func TestAddSignatureForTxBySigners(t *testing.T) {
+	seedSignerContracts(t)
+	dispatch, _ := core.GetDasContractInfo(common.DasContractNameDispatchCellType)
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	daf := core.DasAddressFormat{DasNetType: common.DasNetTypeTestnet2}
+	owner := core.DasAddressHex{DasAlgorithmId: common.DasAlgorithmIdEth, AddressHex: addr, ChainType: common.ChainTypeEth}
+	args, err := daf.HexToArgs(owner, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &types.Script{CodeHash: dispatch.ContractTypeId, HashType: types.HashTypeType, Args: args}
+
+	reader := core.NewMemChainReader("ckb_testnet")
+	input := &types.OutPoint{TxHash: types.HexToHash("0x01"), Index: 0}
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 1,
+		OutPoint:    input,
+		Output:      &types.CellOutput{Capacity: 200 * common.OneCkb, Lock: lock},
+	})
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	actionWitness, _ := witness.GenActionDataWitness(common.DasActionTransfer, nil)
+	if err = txBuilder.BuildTransaction(&txbuilder.BuildTransactionParams{
+		Inputs:      []*types.CellInput{{PreviousOutput: input}},
+		Outputs:     []*types.CellOutput{{Capacity: 199 * common.OneCkb, Lock: lock}},
+		OutputsData: [][]byte{{}},
+		Witnesses:   [][]byte{actionWitness},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ethSigner, _ := sign.NewLocalSigner(common.DasAlgorithmIdEth, hex.EncodeToString(crypto.FromECDSA(key)))
+	txBuilder.SetSigners(sign.NewSigners(ethSigner))
+	digestList, err := txBuilder.GenerateDigestListFromTx(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = txBuilder.AddSignatureForTx(nil); err != nil {
+		t.Fatal(err)
+	}
+	// WitnessArgs: total size and 3 offsets, the length of lock, lock
+	sig := txBuilder.Transaction.Witnesses[0][20:]
+	if ok, err := sign.VerifyPersonalSignature(sig, []byte(digestList[0].SignMsg), addr); err != nil || !ok {
+		t.Fatal("signature of the group is invalid", err)
+	}
+
+	// no signer of the sign type
+	txBuilder.SetSigners(sign.NewSigners(sign.NewMockSigner(common.DasAlgorithmIdTron)))
+	if err = txBuilder.AddSignatureForTx(nil); err == nil {
+		t.Fatal("signed without the signer")
+	}
+}
+
+// the digest hashes the baseline placeholders of the contracts, not the signature sizes, and the fee takes the signature sizes
+func TestDigestPlaceholderSize(t *testing.T) {
+	seedSignerContracts(t)
+	dispatch, _ := core.GetDasContractInfo(common.DasContractNameDispatchCellType)
+	daf := core.DasAddressFormat{DasNetType: common.DasNetTypeTestnet2}
+	owner := core.DasAddressHex{DasAlgorithmId: common.DasAlgorithmIdEd25519, AddressHex: "0x" + hex.EncodeToString(make([]byte, 32)), ChainType: common.ChainTypeCkb}
+	args, err := daf.HexToArgs(owner, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &types.Script{CodeHash: dispatch.ContractTypeId, HashType: types.HashTypeType, Args: args}
+
+	reader := core.NewMemChainReader("ckb_testnet")
+	input := &types.OutPoint{TxHash: types.HexToHash("0x03"), Index: 0}
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 1,
+		OutPoint:    input,
+		Output:      &types.CellOutput{Capacity: 200 * common.OneCkb, Lock: lock},
+	})
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	actionWitness, _ := witness.GenActionDataWitness(common.DasActionTransfer, nil)
+	if err = txBuilder.BuildTransaction(&txbuilder.BuildTransactionParams{
+		Inputs:      []*types.CellInput{{PreviousOutput: input}},
+		Outputs:     []*types.CellOutput{{Capacity: 199 * common.OneCkb, Lock: lock}},
+		OutputsData: [][]byte{{}},
+		Witnesses:   [][]byte{actionWitness},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	digestList, err := txBuilder.GenerateDigestListFromTx(nil)
+	if err != nil || len(digestList) != 1 || digestList[0].SignType != common.DasAlgorithmIdEd25519 {
+		t.Fatal("unexpected digest list", err, digestList)
+	}
+
+	digest := func(tx *types.Transaction, lockSize int) string {
+		wa, _ := (&types.WitnessArgs{Lock: make([]byte, lockSize)}).Serialize()
+		hash, _ := tx.ComputeHash()
+		message := hash.Bytes()
+		for _, v := range append([][]byte{wa}, tx.Witnesses[len(tx.Inputs):]...) {
+			length := make([]byte, 8)
+			binary.LittleEndian.PutUint64(length, uint64(len(v)))
+			message = append(append(message, length...), v...)
+		}
+		return common.Bytes2Hex(common.Blake2b(message))
+	}
+	if digestList[0].SignMsg != digest(txBuilder.Transaction, 65) {
+		t.Fatal("digest not of the 65 bytes placeholder", digestList[0].SignMsg)
+	}
+
+	// the ed25519 signature is 64 bytes in the signed witness
+	size, err := txBuilder.EstimateTxSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := *txBuilder.Transaction
+	wa, _ := (&types.WitnessArgs{Lock: make([]byte, sign.SignatureSize(common.DasAlgorithmIdEd25519))}).Serialize()
+	tx.Witnesses = append([][]byte{wa}, tx.Witnesses[1:]...)
+	if signedSize, _ := tx.SizeInBlock(); size != signedSize {
+		t.Fatal("unexpected estimated size", size, signedSize)
+	}
+}
+
+func TestSignatureSize(t *testing.T) {
+	for algId, size := range map[common.DasAlgorithmId]int{
+		common.DasAlgorithmIdCkb:       65,
+		common.DasAlgorithmIdEth712:    105,
+		common.DasAlgorithmIdDogeChain: 66,
+		common.DasAlgorithmIdBitcoin:   67,
+		common.DasAlgorithmIdEd25519:   64,
+		common.DasAlgorithmIdWebauthn:  800,
+	} {
+		if sign.SignatureSize(algId) != size || len(sign.NewMockSigner(algId).Signature) != size {
+			t.Fatal("unexpected signature size of", algId)
+		}
+	}
+}
+
+func TestWebauthnSigner(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := sign.NewWebauthnSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := hex.EncodeToString(common.CalculateCid1("01020304")) + hex.EncodeToString(common.CalculatePk1(&key.PublicKey))
+	msg := common.DotBitPrefix + hex.EncodeToString(make([]byte, 32))
+	sig, err := signer.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdWebauthn, SignMsg: msg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := sign.Verify(common.DasAlgorithmIdWebauthn, common.DasWebauthnSubAlgorithmIdES256, msg, sig, payload); err != nil || !ok {
+		t.Fatal("webauthn signature is invalid", err)
+	}
+	if _, err = sign.NewWebauthnSigner(nil); err == nil {
+		t.Fatal("webauthn signer without key")
+	}
+}
+
+func TestBitcoinTxSigner(t *testing.T) {
+	addr := "DFpN6QqFfUm3gKNaxN6tNcab1FArL9cZLE"
+	privateKey := "0000000000000000000000000000000000000000000000000000000000000001"
+	tool := &bitcoin.TxTool{Ctx: context.Background(), DustLimit: bitcoin.DustLimitDoge, Params: bitcoin.GetDogeMainNetParams()}
+	pkScript, _, _, err := bitcoin.HexPrivateKeyToScript(addr, tool.Params, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPayload := func(private string) *sign.BitcoinTxSignPayload {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(bitcoin.DustLimitDoge, pkScript))
+		return &sign.BitcoinTxSignPayload{
+			Tx:             tx,
+			UnspentOutputs: []bitcoin.UnspentOutputs{{Private: private, Address: addr, Hash: chainhash.Hash{1}.String(), Value: 2 * bitcoin.DustLimitDoge}},
+		}
+	}
+	checkTx := func(name string, rawTx []byte) {
+		var tx wire.MsgTx
+		if err := tx.DeserializeNoWitness(bytes.NewReader(rawTx)); err != nil {
+			t.Fatal(name, err)
+		}
+		vm, err := txscript.NewEngine(pkScript, &tx, 0, txscript.StandardVerifyFlags, nil, nil, 2*bitcoin.DustLimitDoge, nil)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if err = vm.Execute(); err != nil {
+			t.Fatal(name, "signed tx is invalid", err)
+		}
+	}
+
+	local := sign.NewLocalBitcoinTxSigner(tool, common.DasAlgorithmIdDogeChain)
+	rawTx, err := local.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdDogeChain, Payload: newPayload(privateKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTx("local", rawTx)
+	if _, err = local.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdDogeChain}); err == nil {
+		t.Fatal("signed without payload")
+	}
+
+	// the remote sign server signs the tx by the key of the address
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []struct {
+				Tx string `json:"tx"`
+			} `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		var tx wire.MsgTx
+		_ = tx.DeserializeNoWitness(hex.NewDecoder(bytes.NewReader([]byte(req.Params[0].Tx))))
+		res, err := tool.LocalSignTx(&tx, newPayload(privateKey).UnspentOutputs)
+		result := map[string]interface{}{"errno": 0, "errmsg": "", "data": res}
+		if err != nil || req.Method != bitcoin.RemoteSignMethodDogeTx {
+			result = map[string]interface{}{"errno": 1, "errmsg": fmt.Sprint("sign failed ", err)}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.Id, "result": result})
+	}))
+	defer ts.Close()
+	if tool.RemoteSignClient, err = rpc.Dial(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	signers := sign.NewSigners(sign.NewRemoteBitcoinTxSigner(tool, common.DasAlgorithmIdDogeChain, bitcoin.RemoteSignMethodDogeTx))
+	if rawTx, err = signers.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdDogeChain, Payload: newPayload("")}); err != nil {
+		t.Fatal(err)
+	}
+	checkTx("remote", rawTx)
+	if _, err = sign.NewRemoteBitcoinTxSigner(tool, common.DasAlgorithmIdDogeChain, "wallet_unknown").Sign(&sign.SignRequest{Payload: newPayload("")}); err == nil {
+		t.Fatal("signed by the unknown method")
+	}
+}
+
+func TestAddSignatureForTxByMultisigSigner(t *testing.T) {
+	seedSignerContracts(t)
+	// multisig 2 of 3, the first key is required
+	var keys []sign.Signer
+	config := sign.MultisigConfig{RequireFirstN: 1, Threshold: 2}
+	for i := 0; i < 3; i++ {
+		k, _ := crypto.ToECDSA(common.Blake2b([]byte{byte(i)}))
+		signer, _ := sign.NewLocalSigner(common.DasAlgorithmIdCkb, hex.EncodeToString(crypto.FromECDSA(k)))
+		keys = append(keys, signer)
+		config.PubKeyHashes = append(config.PubKeyHashes, common.Blake2b(crypto.CompressPubkey(&k.PublicKey))[:20])
+	}
+	multisigSigner, err := sign.NewMultisigSigner(config, keys[0], keys[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sign.NewMultisigSigner(config, keys[0]); err == nil {
+		t.Fatal("signers less than threshold")
+	}
+	lock := &types.Script{
+		CodeHash: types.HexToHash(transaction.SECP256K1_BLAKE160_MULTISIG_ALL_TYPE_HASH),
+		HashType: types.HashTypeType,
+		Args:     config.Args(),
+	}
+
+	reader := core.NewMemChainReader("ckb_testnet")
+	input := &types.OutPoint{TxHash: types.HexToHash("0x02"), Index: 0}
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 1,
+		OutPoint:    input,
+		Output:      &types.CellOutput{Capacity: 200 * common.OneCkb, Lock: lock},
+	})
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), nil)
+	if err = txBuilder.BuildTransaction(&txbuilder.BuildTransactionParams{
+		Inputs:      []*types.CellInput{{PreviousOutput: input}},
+		Outputs:     []*types.CellOutput{{Capacity: 199 * common.OneCkb, Lock: lock}},
+		OutputsData: [][]byte{{}},
+		Witnesses:   [][]byte{{}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := txBuilder.GenerateMultiSignDigest([]int{0}, config.RequireFirstN, make([][]byte, config.Threshold), config.PubKeyHashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the any-lock group without the signer is skipped, so is the digest returned for it
+	txBuilder.SetSigners(sign.NewSigners(sign.NewMockSigner(common.DasAlgorithmIdEth)))
+	if err = txBuilder.AddSignatureForTx(nil); err != nil || len(txBuilder.Transaction.Witnesses[0]) != 0 {
+		t.Fatal("any-lock group not skipped", err)
+	}
+	digestList, err := txBuilder.GenerateDigestListFromTx(nil)
+	if err != nil || len(digestList) != 1 || digestList[0].SignType != common.DasAlgorithmIdAnyLock {
+		t.Fatal("unexpected digest list", err, digestList)
+	}
+	if err = txBuilder.AddSignatureForTx(digestList); err != nil || len(txBuilder.Transaction.Witnesses[0]) != 0 {
+		t.Fatal("digest written into the any-lock witness", err)
+	}
+
+	txBuilder.SetSigners(sign.NewSigners(multisigSigner))
+	if err = txBuilder.AddSignatureForTx(nil); err != nil {
+		t.Fatal(err)
+	}
+	// WitnessArgs: total size and 3 offsets, the length of lock, lock
+	sig := txBuilder.Transaction.Witnesses[0][20:]
+	if _, ok, err := sign.Verify(common.DasAlgorithmIdCkbMulti, 0, common.Bytes2Hex(digest), sig, hex.EncodeToString(config.Args())); err != nil || !ok {
+		t.Fatal("multisig signature is invalid", err)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/smt"
+	"github.com/dotbitHQ/das-lib/smt_server"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func getTree(smtName string) *smt.SmtServer {
@@ -100,3 +104,98 @@ func TestRsTree(t *testing.T) {
 	}
 	fmt.Println("root", res.Root)
 }
+
+func TestLocalSmtServer(t *testing.T) {
+	dir := t.TempDir()
+	srv := smt_server.NewSmtRpcServer(func(smtName string) (smt.Store, error) {
+		return smt.OpenLevelDbStore(dir+"/"+smtName, smtName)
+	})
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	var kvs []smt.SmtKv
+	for i := 0; i < 10; i++ {
+		kvs = append(kvs, smt.SmtKv{Key: smt.Sha256(fmt.Sprintf("key-%d", i)), Value: smt.Sha256(fmt.Sprintf("value-%d", i))})
+	}
+	memTree := smt.NewSparseMerkleTree(nil)
+	_ = memTree.UpdateAll(kvs)
+	memRoot, _ := memTree.Root()
+
+	opt := smt.SmtOpt{GetRoot: true, GetProof: true}
+	res, err := smt.NewSmtSrv(ts.URL, "").UpdateSmt(kvs, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(res.Root) != common.Bytes2Hex(memRoot) {
+		t.Fatal("memory smt root mismatch", common.Bytes2Hex(res.Root))
+	}
+
+	tree := smt.NewSmtSrv(ts.URL, "tree1")
+	if _, err = tree.UpdateSmt(kvs[:5], opt); err != nil {
+		t.Fatal(err)
+	}
+	middle, err := tree.UpdateMiddleSmt(kvs[5:], opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range kvs[5:] {
+		key := common.Bytes2Hex(v.Key)
+		proof := smt.CompiledMerkleProof(common.Hex2Bytes(middle.Proofs[key]))
+		if ok, err := smt.Verify(middle.Roots[key], &proof, []smt.H256{v.Key}, []smt.H256{v.Value}); err != nil || !ok {
+			t.Fatal("verify middle proof failed", key, err)
+		}
+	}
+	root, err := tree.GetSmtRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(root) != common.Bytes2Hex(memRoot) {
+		t.Fatal("db smt root mismatch", common.Bytes2Hex(root))
+	}
+	if common.Bytes2Hex(middle.Roots[common.Bytes2Hex(kvs[9].Key)]) != common.Bytes2Hex(memRoot) {
+		t.Fatal("last middle root mismatch")
+	}
+
+	if ok, err := tree.DeleteSmt(); err != nil || !ok {
+		t.Fatal("delete smt failed", err)
+	}
+	root, err = tree.GetSmtRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(root) != common.Bytes2Hex(smt.H256Zero()) {
+		t.Fatal("root not zero after delete")
+	}
+
+	// Close stops serving and closes the stores, so the dbs can be opened again
+	if _, err = tree.UpdateSmt(kvs, opt); err != nil {
+		t.Fatal(err)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe("127.0.0.1:0")
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err = srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-serveErr:
+		if err != http.ErrServerClosed {
+			t.Fatal("unexpected serve err", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe not stopped")
+	}
+	if _, err = tree.GetSmtRoot(); err == nil {
+		t.Fatal("call after close should fail")
+	}
+	store, err := smt.OpenLevelDbStore(dir+"/tree1", "tree1")
+	if err != nil {
+		t.Fatal("store not closed", err)
+	}
+	defer store.Close()
+	if root, _ = smt.NewSparseMerkleTree(store).Root(); common.Bytes2Hex(root) != common.Bytes2Hex(memRoot) {
+		t.Fatal("db smt root mismatch after close", common.Bytes2Hex(root))
+	}
+}
@@ -2,6 +2,7 @@ package example
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/smt"
@@ -360,3 +361,394 @@ func TestAddSmt(t *testing.T) {
 	fmt.Println(root.String())
 
 }
+
+func TestLevelDbStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := smt.OpenLevelDbStore(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := smt.NewSparseMerkleTree(store)
+	memTree := smt.NewSparseMerkleTree(nil)
+
+	var keys, values []smt.H256
+	for i := 0; i < 20; i++ {
+		keys = append(keys, smt.Sha256(fmt.Sprintf("key-%d", i)))
+		values = append(values, smt.Sha256(fmt.Sprintf("value-%d", i)))
+	}
+	for i := 0; i < 10; i++ {
+		if err = tree.Update(keys[i], values[i]); err != nil {
+			t.Fatal(err)
+		}
+		_ = memTree.Update(keys[i], values[i])
+	}
+	err = store.Batch(func() error {
+		for i := 10; i < 20; i++ {
+			if err := tree.Update(keys[i], values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i < 20; i++ {
+		_ = memTree.Update(keys[i], values[i])
+	}
+	root, _ := tree.Root()
+	memRoot, _ := memTree.Root()
+	if common.Bytes2Hex(root) != common.Bytes2Hex(memRoot) {
+		t.Fatal("root mismatch", common.Bytes2Hex(root), common.Bytes2Hex(memRoot))
+	}
+
+	// a failed batch leaves nothing behind
+	err = store.Batch(func() error {
+		_ = tree.Update(smt.Sha256("discard"), smt.Sha256("discard"))
+		return fmt.Errorf("abort")
+	})
+	if err == nil {
+		t.Fatal("want batch err")
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = smt.OpenLevelDbStore(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	tree = smt.NewSparseMerkleTree(store)
+	reopenRoot, _ := tree.Root()
+	if common.Bytes2Hex(reopenRoot) != common.Bytes2Hex(memRoot) {
+		t.Fatal("root mismatch after reopen", common.Bytes2Hex(reopenRoot))
+	}
+	for i := range keys {
+		proof, err := tree.MerkleProof([]smt.H256{keys[i]}, []smt.H256{values[i]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := smt.Verify(reopenRoot, proof, []smt.H256{keys[i]}, []smt.H256{values[i]}); !ok {
+			t.Fatal("verify failed", i)
+		}
+	}
+
+	if err = store.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	root, _ = tree.Root()
+	if common.Bytes2Hex(root) != common.Bytes2Hex(smt.H256Zero()) {
+		t.Fatal("root not zero after delete")
+	}
+
+	// the path of each vector is stored, the root and the proof read back from the db are the ones of the Rust smt
+	for i, v := range rustSmtVectors {
+		dir := t.TempDir()
+		store, err := smt.OpenLevelDbStore(dir, "rust")
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, value, proof := smt.H256(common.Hex2Bytes(v.key)), smt.H256(common.Hex2Bytes(v.value)), common.Hex2Bytes(v.proof)
+		if err = insertProofPath(store, key, value, proof); err != nil {
+			t.Fatal(i, err)
+		}
+		_ = store.Close()
+
+		if store, err = smt.OpenLevelDbStore(dir, "rust"); err != nil {
+			t.Fatal(err)
+		}
+		tree := smt.NewSparseMerkleTree(store)
+		if root, _ := tree.Root(); common.Bytes2Hex(root) != v.root {
+			t.Fatal(i, "root mismatch", common.Bytes2Hex(root))
+		}
+		if got, _ := tree.Get(key); common.Bytes2Hex(got) != v.value {
+			t.Fatal(i, "value mismatch", common.Bytes2Hex(got))
+		}
+		got, err := tree.MerkleProof([]smt.H256{key}, []smt.H256{value})
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if common.Bytes2Hex(*got) != v.proof {
+			t.Fatal(i, "proof mismatch", common.Bytes2Hex(*got))
+		}
+		if ok, err := smt.Verify(common.Hex2Bytes(v.root), got, []smt.H256{key}, []smt.H256{value}); err != nil || !ok {
+			t.Fatal(i, "verify failed", err)
+		}
+		_ = store.Close()
+	}
+}
+
+// rustSmtVectors are the proofs of one leaf made by the Rust smt and accepted on chain,
+// the reverse smt witness of TestReverseSmtRecordVerify and the sub-accounts created by the tx of TestUpdateSubAccountTx
+var rustSmtVectors = []struct {
+	key, value, root, proof string
+}{
+	{
+		key:   "0xb461d449b79737350b3d7db6ad6412cb00a150173584f1502fd82cac13f0b6a5",
+		value: "0x7992045aec9c90e39f48addb28ccf3f8e07893c5a6ab8625fa51513f11638062",
+		root:  "0x68743c8ed1b3f67ad393b619a870d210acbd86ebb9f6bac536f8c15c581cca56",
+		proof: "0x4c4ffa504f3528a830511d93952465424186d2b55f46c0967335bc7d97ddf0aad97e5abc51fb2273a351c4867f6604b17d3285f073f9afa1c95b8b53deb506a3cc824871e16eda6c8e75bd81e2d9d60d1ce2df4256186cffe598ed3ce3477719a0ae32dcf80150a4c5137c3d4b212e126910433aa3c00adf46a427b5426adf9fefcdf1c7ab2b13500425c9884c96ee9ba0e75725de6cc6d134d752f25090e8d8098a2e56deb01a56502bd6035a9f621b4f3b07c340ebbea00e65f71389bd426991a2d1f0b86f98ca62506264846d3d53b85ffbd9a736e81aa6e26318158090bee751d729ee8b97a04f48",
+	},
+	{
+		key:   "0x327a39873964df443922936e0c16479f6fa705fb000000000000000000000000",
+		value: "0xa40f5f7a7046fb00cfb3ea874840c3567fd25bc6a1d125f08bebc54ab9a34c24",
+		root:  "0x13ac39a68f3053fc16adf5a8883700ac4ec146b645269ffa0589fd6582587826",
+		proof: "0x4c4f9c519cc5735e3b9a08c3ca63fd852a58ac3984125159bb23d9350a7a1a11531934ffd7e856edd2cc15a0869b6fe3d455949a754cac82060000000000000000000000004f015101bb5c44ba510d68622f58954841efde6c9563a725440553fb9101632ff5fd222500000000000000000000000000000000000000200000000000000000000000005101d39ae2a8bccd175ee4b47032e9212a70df4cc04408d96796166e7716eac788ef00000000000000000000000000000000000000000000000000000000000000004f60",
+	},
+	{
+		key:   "0x7cc39589ab548073c6e1b9cbb8bdc9c0415a3478000000000000000000000000",
+		value: "0xdc7649afa45c94bf37318cc306ef1b1bed5c1445a3568d2a8f3f44686e453e7e",
+		root:  "0x9c7abd4c74844452e5c436fe3f4e14100178564a9731976578955035f84b3369",
+		proof: "0x4c4f9e5056ac8156f5e79d73491da7bc11b654de4784cafb7185185b38f8baa17697ebb3507811316a60f374dc0d1009a724c0201651365701f5f34365afef0e78ff7352cd4f60",
+	},
+}
+
+// insertProofPath stores the branches from the leaf of key to the root with the siblings of the proof of one leaf,
+// the same as Update does, so the store holds the part of the tree proved by the proof
+func insertProofPath(store smt.Store, key, value smt.H256, proof []byte) error {
+	var siblings []smt.MergeValue
+	for i := 0; i < len(proof); {
+		code := proof[i]
+		i++
+		switch code {
+		case 0x4C:
+		case 0x4F:
+			if i >= len(proof) {
+				return fmt.Errorf("invalid proof")
+			}
+			n := int(proof[i])
+			if n == 0 {
+				n = 256
+			}
+			for j := 0; j < n; j++ {
+				siblings = append(siblings, smt.MergeValueFromZero())
+			}
+			i++
+		case 0x50:
+			if i+32 > len(proof) {
+				return fmt.Errorf("invalid proof")
+			}
+			siblings = append(siblings, smt.MergeValueFromH256(proof[i:i+32]))
+			i += 32
+		case 0x51:
+			if i+65 > len(proof) {
+				return fmt.Errorf("invalid proof")
+			}
+			siblings = append(siblings, smt.MergeValue{ZeroCount: proof[i], BaseNode: proof[i+1 : i+33], ZeroBits: proof[i+33 : i+65]})
+			i += 65
+		default:
+			return fmt.Errorf("unsupported code: %x", code)
+		}
+	}
+	if len(siblings) != 256 {
+		return fmt.Errorf("invalid proof height: %d", len(siblings))
+	}
+	currentKey, currentNode := key, smt.MergeValueFromH256(value)
+	for i, sibling := range siblings {
+		height := byte(i)
+		parentKey := currentKey.ParentPath(height)
+		left, right := currentNode, sibling
+		if currentKey.IsRight(height) {
+			left, right = sibling, currentNode
+		}
+		if err := store.InsertBranch(smt.BranchKey{Height: height, NodeKey: *parentKey}, smt.BranchNode{Left: left, Right: right}); err != nil {
+			return err
+		}
+		currentKey, currentNode = *parentKey, smt.Merge(height, *parentKey, left, right)
+	}
+	return store.UpdateRoot(currentNode.Hash())
+}
+
+func TestUpdateAll(t *testing.T) {
+	tree := smt.NewSparseMerkleTree(nil)
+	batchTree := smt.NewSparseMerkleTree(nil)
+
+	var kvs []smt.SmtKv
+	for i := 0; i < 200; i++ {
+		kvs = append(kvs, smt.SmtKv{Key: smt.Sha256(fmt.Sprintf("key-%d", i)), Value: smt.Sha256(fmt.Sprintf("value-%d", i))})
+	}
+	// update existing keys, delete some and add a duplicated key
+	kvs2 := []smt.SmtKv{
+		{Key: kvs[0].Key, Value: smt.Sha256("new-0")},
+		{Key: kvs[1].Key, Value: smt.H256Zero()},
+		{Key: smt.Sha256("dup"), Value: smt.Sha256("dup-1")},
+		{Key: smt.Sha256("dup"), Value: smt.Sha256("dup-2")},
+	}
+	for _, list := range [][]smt.SmtKv{kvs, kvs2} {
+		for _, v := range list {
+			if err := tree.Update(v.Key, v.Value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := batchTree.UpdateAll(list); err != nil {
+			t.Fatal(err)
+		}
+		root, _ := tree.Root()
+		batchRoot, _ := batchTree.Root()
+		if common.Bytes2Hex(root) != common.Bytes2Hex(batchRoot) {
+			t.Fatal("root mismatch", common.Bytes2Hex(root), common.Bytes2Hex(batchRoot))
+		}
+	}
+
+	root, _ := batchTree.Root()
+	keys := []smt.H256{kvs[5].Key, smt.Sha256("dup"), kvs[1].Key, kvs[100].Key, smt.Sha256("not-exist")}
+	values := []smt.H256{kvs[5].Value, smt.Sha256("dup-2"), smt.H256Zero(), kvs[100].Value, smt.H256Zero()}
+	proof, err := batchTree.MerkleProof(keys, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := smt.Verify(root, proof, keys, values); err != nil || !ok {
+		t.Fatal("verify failed", err)
+	}
+	values[0] = smt.Sha256("wrong")
+	if ok, _ := smt.Verify(root, proof, keys, values); ok {
+		t.Fatal("verify should fail")
+	}
+}
+
+func TestSmtHistoryStore(t *testing.T) {
+	path := t.TempDir()
+	levelDbStore, err := smt.OpenLevelDbStore(path, "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer levelDbStore.Close()
+	store, err := smt.NewHistoryStore(levelDbStore, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := smt.NewSparseMerkleTree(store)
+
+	key1, key2 := smt.Sha256("key-1"), smt.Sha256("key-2")
+	_ = tree.Update(key1, smt.Sha256("value-1"))
+	if err = store.Snapshot("s1"); err != nil {
+		t.Fatal(err)
+	}
+	root1, _ := tree.Root()
+
+	_ = tree.Update(key1, smt.Sha256("value-1-new"))
+	_ = tree.Update(key2, smt.Sha256("value-2"))
+	root2, _ := tree.Root()
+	if len(store.Roots()) != 4 {
+		t.Fatal("unexpected roots", len(store.Roots()))
+	}
+
+	value, err := store.GetLeafAt(root1, key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(value) != common.Bytes2Hex(smt.Sha256("value-1")) {
+		t.Fatal("unexpected leaf at root1", common.Bytes2Hex(value))
+	}
+	if value, _ = store.GetLeafAt(root1, key2); !value.IsZero() {
+		t.Fatal("key2 should not exist at root1")
+	}
+	if value, _ = tree.Get(key1); common.Bytes2Hex(value) != common.Bytes2Hex(smt.Sha256("value-1-new")) {
+		t.Fatal("unexpected current leaf", common.Bytes2Hex(value))
+	}
+
+	// a failed transaction leaves the store untouched
+	if err = tree.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.Update(smt.Sha256("key-3"), smt.Sha256("value-3"))
+	if err = tree.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if root, _ := tree.Root(); common.Bytes2Hex(root) != common.Bytes2Hex(root2) {
+		t.Fatal("root changed after rollback")
+	}
+
+	if err = store.RollbackToSnapshot("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if root, _ := tree.Root(); common.Bytes2Hex(root) != common.Bytes2Hex(root1) {
+		t.Fatal("root mismatch after rollback to snapshot")
+	}
+
+	// a committed transaction is one root update
+	memTree := smt.NewSparseMerkleTree(nil)
+	_ = memTree.Update(key1, smt.Sha256("value-1"))
+	if err = tree.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i < 10; i++ {
+		_ = tree.Update(smt.Sha256(fmt.Sprintf("key-%d", i)), smt.Sha256(fmt.Sprintf("value-%d", i)))
+		_ = memTree.Update(smt.Sha256(fmt.Sprintf("key-%d", i)), smt.Sha256(fmt.Sprintf("value-%d", i)))
+	}
+	if err = tree.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	root, _ := tree.Root()
+	memRoot, _ := memTree.Root()
+	if common.Bytes2Hex(root) != common.Bytes2Hex(memRoot) {
+		t.Fatal("root mismatch after commit")
+	}
+	if len(store.Roots()) != 3 {
+		t.Fatal("unexpected roots after commit", len(store.Roots()))
+	}
+	if err = store.RollbackToRoot(root1); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ = tree.Get(smt.Sha256("key-5")); !value.IsZero() {
+		t.Fatal("key-5 should not exist after rollback")
+	}
+
+	// the history and the snapshots are persisted with the branches
+	_ = tree.Update(key2, smt.Sha256("value-2"))
+	if err = store.Snapshot("s2"); err != nil {
+		t.Fatal(err)
+	}
+	root3, _ := tree.Root()
+	_ = tree.Update(key2, smt.Sha256("value-2-new"))
+	roots := store.Roots()
+	_ = levelDbStore.Close()
+	if levelDbStore, err = smt.OpenLevelDbStore(path, "history"); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = smt.NewHistoryStore(levelDbStore, 100); err != nil {
+		t.Fatal(err)
+	}
+	tree = smt.NewSparseMerkleTree(store)
+	if fmt.Sprint(store.Roots()) != fmt.Sprint(roots) {
+		t.Fatal("unexpected roots after reopen", len(store.Roots()), len(roots))
+	}
+	if value, err = store.GetLeafAt(root3, key2); err != nil || common.Bytes2Hex(value) != common.Bytes2Hex(smt.Sha256("value-2")) {
+		t.Fatal("unexpected leaf at root3 after reopen", err)
+	}
+	if _, err = store.SnapshotRoot("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.RollbackToSnapshot("s2"); err != nil {
+		t.Fatal(err)
+	}
+	if root, _ := tree.Root(); common.Bytes2Hex(root) != common.Bytes2Hex(root3) {
+		t.Fatal("root mismatch after rollback to snapshot of reopened store")
+	}
+
+	// the rollback is persisted as well
+	_ = levelDbStore.Close()
+	if levelDbStore, err = smt.OpenLevelDbStore(path, "history"); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = smt.NewHistoryStore(levelDbStore, 2); err != nil {
+		t.Fatal(err)
+	}
+	if root, _ := store.Root(); common.Bytes2Hex(root) != common.Bytes2Hex(root3) || len(store.Roots()) != len(roots)-1 {
+		t.Fatal("unexpected history after rollback and reopen", len(store.Roots()))
+	}
+	tree = smt.NewSparseMerkleTree(store)
+	for i := 0; i < 3; i++ {
+		_ = tree.Update(key1, smt.Sha256(fmt.Sprintf("value-1-%d", i)))
+	}
+	if len(store.Roots()) != 3 {
+		t.Fatal("unexpected roots of max history", len(store.Roots()))
+	}
+	if _, err = store.SnapshotRoot("s2"); !errors.Is(err, smt.ErrHistoryIncomplete) {
+		t.Fatal("snapshot out of history:", err)
+	}
+	if history, err := levelDbStore.LoadHistory(); err != nil || len(history) != 3 {
+		t.Fatal("unexpected persisted history", len(history), err)
+	}
+}
@@ -2,11 +2,20 @@ package example
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/sign"
+	"github.com/dotbitHQ/das-lib/smt"
+	"github.com/dotbitHQ/das-lib/txbuilder"
 	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/nervosnetwork/ckb-sdk-go/rpc"
 	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"strings"
 	"testing"
 	"time"
 )
@@ -146,3 +155,117 @@ func TestUpdateSubAccountTx2(t *testing.T) {
 		}
 	}
 }
+
+func TestSubAccountSignBuilder(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	privateKey := common.Bytes2Hex(crypto.FromECDSA(key))[2:]
+
+	builder, err := txbuilder.NewSubAccountSignBuilder(common.ActionDataTypeSubAccountMintSign, common.ParamManager, common.DasAlgorithmIdEth, uint64(time.Now().Add(time.Hour).Unix()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountIds := []string{
+		common.Bytes2Hex(common.GetAccountIdByAccount("a.test.bit")),
+		common.Bytes2Hex(common.GetAccountIdByAccount("b.test.bit")),
+	}
+	for _, v := range accountIds {
+		builder.AddItem(v, txbuilder.SubAccountSignValueByExpiredAt(uint64(time.Now().AddDate(1, 0, 0).Unix())))
+	}
+	root, err := builder.BuildAccountListSmt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := builder.MerkleProof(accountIds[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiledProof := smt.CompiledMerkleProof(common.Hex2Bytes(proof))
+	if ok, err := smt.Verify(root, &compiledProof, []smt.H256{smt.AccountIdToSmtH256(accountIds[1])}, []smt.H256{builder.Items[1].Value}); err != nil || !ok {
+		t.Fatal("proof", ok, err)
+	}
+	if _, err = builder.MerkleProof(common.Bytes2Hex(common.GetAccountIdByAccount("c.test.bit"))); err == nil {
+		t.Fatal("proof of account not in the list")
+	}
+
+	signData, err := builder.SignData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signData.SignMsg, common.DotBitPrefix) {
+		t.Fatal(signData.SignMsg)
+	}
+	signature, err := sign.PersonalSignature([]byte(signData.SignMsg), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := builder.Verify(signature, address); err != nil || !ok {
+		t.Fatal("verify", ok, err)
+	}
+	if ok, _ := builder.Verify(signature, "0x0000000000000000000000000000000000000001"); ok {
+		t.Fatal("verify with other address")
+	}
+
+	wit, mintSign, err := builder.GenWitness(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sanb witness.SubAccountNewBuilder
+	res, err := sanb.ConvertSubAccountMintSignFromBytes(wit[common.WitnessDasTableTypeEndIndex:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExpiredAt != mintSign.ExpiredAt || common.Bytes2Hex(res.AccountListSmtRoot) != common.Bytes2Hex(root) ||
+		common.Bytes2Hex(res.SignRole) != common.ParamManager || len(res.Signature) != 65 {
+		t.Fatal("witness", res)
+	}
+}
+
+// the repo has no mint sign witness of a real account list, the vector is pinned from the private key 1
+func TestSubAccountSignBuilderVector(t *testing.T) {
+	builder, err := txbuilder.NewSubAccountSignBuilder(common.ActionDataTypeSubAccountMintSign, common.ParamManager, common.DasAlgorithmIdEth, 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"a.test.bit", "b.test.bit"} {
+		builder.AddItem(common.Bytes2Hex(common.GetAccountIdByAccount(v)), txbuilder.SubAccountSignValueByExpiredAt(1731536000))
+	}
+	root, err := builder.BuildAccountListSmt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signData, err := builder.SignData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(root) != "0xf6020a220900f9af38f61a4ccb32c19c6603f46b4bc62ffc7e879386f9025b9c" ||
+		signData.SignMsg != common.DotBitPrefix+"4358dd32081733fa443f46fddfa5b269a8e9080f3d688b816b87efed1f93567a" {
+		t.Fatal("unexpected sign data", common.Bytes2Hex(root), signData.SignMsg)
+	}
+	signature := common.Hex2Bytes("0xc82de2f93fed92b014ab53fa449804a0907cf27b8cb5586e35969c90bd8d85a14f70e65135c22f9132b81a600c71485ef525f25a238536d7f66eb9fa510407ab00")
+	if ok, err := builder.Verify(signature, "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"); err != nil || !ok {
+		t.Fatal("verify", ok, err)
+	}
+	builder.ExpiredAt++
+	if ok, _ := builder.Verify(signature, "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"); ok {
+		t.Fatal("verify with other expired at")
+	}
+
+	// webauthn signs the same message as eth
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, _ := sign.NewWebauthnSigner(key)
+	builder.SignType = common.DasAlgorithmIdWebauthn
+	if signData, err = builder.SignData(); err != nil {
+		t.Fatal(err)
+	}
+	if signature, err = signer.Sign(&sign.SignRequest{SignType: builder.SignType, SignMsg: signData.SignMsg}); err != nil {
+		t.Fatal(err)
+	}
+	payload := hex.EncodeToString(common.CalculateCid1("01020304")) + hex.EncodeToString(common.CalculatePk1(&key.PublicKey))
+	if ok, err := builder.Verify(signature, payload); err != nil || !ok {
+		t.Fatal("verify webauthn", ok, err)
+	}
+}
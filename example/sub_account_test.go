@@ -6,7 +6,9 @@ import (
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/core"
 	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/molecule"
 	"github.com/dotbitHQ/das-lib/smt"
+	"github.com/dotbitHQ/das-lib/txbuilder"
 	"github.com/dotbitHQ/das-lib/witness"
 	"github.com/nervosnetwork/ckb-sdk-go/indexer"
 	"github.com/nervosnetwork/ckb-sdk-go/types"
@@ -236,3 +238,274 @@ func TestArgsAndConfigHash(t *testing.T) {
 	var de witness.SubAccountCellDataDetail
 	fmt.Println(de.ArgsAndConfigHash())
 }
+
+func TestBuildSubAccountTx(t *testing.T) {
+	keepDasGlobals(t)
+	env := core.InitEnv(common.DasNetTypeTestnet2)
+	now := int64(1700000000)
+	reader := core.NewMemChainReader("ckb_testnet")
+
+	// contract, config cells and time cell
+	subAccTypeId := types.HexToHash("0x00000000000000000000000000000000000000000000000000000000000fe001")
+	core.DasContractMap.Store(common.DASContractNameSubAccountCellType, &core.DasContractInfo{
+		ContractName:   common.DASContractNameSubAccountCellType,
+		OutPoint:       &types.OutPoint{},
+		ContractTypeId: subAccTypeId,
+	})
+	for i, name := range []common.DasContractName{common.DasContractNameDispatchCellType, common.DasContractNameBalanceCellType} {
+		if _, err := core.GetDasContractInfo(name); err != nil {
+			core.DasContractMap.Store(name, &core.DasContractInfo{
+				ContractName:   name,
+				OutPoint:       &types.OutPoint{},
+				ContractTypeId: types.HexToHash(fmt.Sprintf("0x%064x", 0xff00+i)),
+			})
+		}
+	}
+	configAccount := molecule.ConfigCellAccountDefault()
+	configAccountBuilder := configAccount.AsBuilder()
+	configAccount = configAccountBuilder.ExpirationGracePeriod(molecule.GoU32ToMoleculeU32(90 * 86400)).Build()
+	configSubAccount := molecule.ConfigCellSubAccountDefault()
+	configSubAccountBuilder := configSubAccount.AsBuilder()
+	configSubAccount = configSubAccountBuilder.
+		NewSubAccountPrice(molecule.GoU64ToMoleculeU64(5 * common.OneCkb)).
+		RenewSubAccountPrice(molecule.GoU64ToMoleculeU64(5 * common.OneCkb)).
+		CommonFee(molecule.GoU64ToMoleculeU64(10000)).Build()
+	configTx := &types.Transaction{
+		Outputs: []*types.CellOutput{
+			{Lock: common.GetNormalLockScript("0x01"), Type: common.GetScript(env.ContractCodeHash, common.ConfigCellTypeArgsAccount)},
+			{Lock: common.GetNormalLockScript("0x01"), Type: common.GetScript(env.ContractCodeHash, common.ConfigCellTypeArgsSubAccount)},
+			{Lock: common.GetNormalLockScript("0x01"), Type: common.GetScript(env.THQCodeHash, common.ArgsTimeCell)},
+		},
+		OutputsData: [][]byte{
+			append([]byte{0}, configAccount.AsSlice()...),
+			append([]byte{0}, configSubAccount.AsSlice()...),
+			append([]byte{0, 0}, common.Hex2Bytes(fmt.Sprintf("0x%016x", now))...),
+		},
+	}
+	if err := reader.ApplyTransaction(configTx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	configTxHash, _ := configTx.ComputeHash()
+	core.DasConfigCellMap.Store(common.ConfigCellTypeArgsAccount, &core.DasConfigCellInfo{Name: "ConfigCellAccount", OutPoint: types.OutPoint{TxHash: configTxHash, Index: 0}})
+	core.DasConfigCellMap.Store(common.ConfigCellTypeArgsSubAccount, &core.DasConfigCellInfo{Name: "ConfigCellSubAccount", OutPoint: types.OutPoint{TxHash: configTxHash, Index: 1}})
+
+	// parent account cell and its sub-account cell
+	parentAccount := "parent.bit"
+	parentAccountId := common.GetAccountIdByAccount(parentAccount)
+	accountId, _ := molecule.AccountIdFromSlice(parentAccountId, true)
+	enCharSet := func(account string) (list []common.AccountCharSet) {
+		for _, v := range account {
+			list = append(list, common.AccountCharSet{CharSetName: common.AccountCharTypeEn, Char: string(v)})
+		}
+		return
+	}
+	parentCharSet := enCharSet("parent")
+	accountCellData := molecule.NewAccountCellDataBuilder().
+		Id(*accountId).
+		Account(*common.ConvertToAccountChars(parentCharSet)).
+		RegisteredAt(molecule.GoU64ToMoleculeU64(uint64(now))).
+		EnableSubAccount(molecule.GoU8ToMoleculeU8(1)).
+		Build()
+	accountBuilder := witness.AccountCellDataBuilder{Version: common.GoDataEntityVersion4, AccountCellData: &accountCellData}
+	accountWitness, _, err := accountBuilder.GenWitness(&witness.AccountCellParam{Action: common.DasActionRenewAccount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parentData []byte
+	parentData = append(parentData, make([]byte, 32)...)
+	parentData = append(parentData, parentAccountId...)
+	parentData = append(parentData, make([]byte, 20)...)
+	parentData = append(parentData, molecule.GoU64ToBytes(uint64(now+common.OneYearSec))...)
+	parentData = append(parentData, parentAccount...)
+	parentTx := &types.Transaction{
+		Outputs: []*types.CellOutput{
+			{Capacity: 300 * common.OneCkb, Lock: common.GetNormalLockScript("0x02")},
+			{Capacity: 100 * common.OneCkb, Lock: common.GetNormalLockScript("0x02"), Type: &types.Script{CodeHash: subAccTypeId, HashType: types.HashTypeType, Args: parentAccountId}},
+		},
+		OutputsData: [][]byte{parentData, witness.BuildSubAccountCellOutputData(witness.SubAccountCellDataDetail{SmtRoot: smt.H256Zero()})},
+		Witnesses:   [][]byte{accountWitness},
+	}
+	if err := reader.ApplyTransaction(parentTx, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	parentTxHash, _ := parentTx.ComputeHash()
+
+	payerLock := common.GetNormalLockScript("0x0000000000000000000000000000000000000003")
+	if err := reader.ApplyTransaction(&types.Transaction{
+		Outputs:     []*types.CellOutput{{Capacity: 1000 * common.OneCkb, Lock: payerLock}},
+		OutputsData: [][]byte{{}},
+	}, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader),
+		core.WithDasNetType(common.DasNetTypeTestnet2), core.WithTHQCodeHash(env.THQCodeHash))
+	tree := smt.NewSparseMerkleTree(nil)
+	applyTx := func(res *txbuilder.BuildTransactionParams, blockNumber uint64) *types.Transaction {
+		tx := &types.Transaction{Inputs: res.Inputs, Outputs: res.Outputs, OutputsData: res.OutputsData, Witnesses: res.Witnesses}
+		if err := reader.ApplyTransaction(tx, blockNumber, 0); err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	// create
+	var subAccountList []*witness.SubAccountNew
+	for _, v := range []string{"alice", "bob"} {
+		subAccountList = append(subAccountList, &witness.SubAccountNew{
+			SubAccountData: &witness.SubAccountData{
+				Lock:           common.GetNormalLockScript("0x04"),
+				AccountId:      common.Bytes2Hex(common.GetAccountIdByAccount(v + "." + parentAccount)),
+				AccountCharSet: enCharSet(v),
+				Suffix:         "." + parentAccount,
+				ExpiredAt:      uint64(now + common.OneYearSec),
+			},
+			EditKey: common.EditKeyManual,
+		})
+	}
+	// no payer, the smt is not changed
+	dasCache := dascache.NewDasCache(context.Background(), &wg)
+	if _, err = txbuilder.BuildSubAccountTx(txbuilder.SubAccountTxParams{
+		DasCore:                   dc,
+		DasCache:                  dasCache,
+		Action:                    common.SubActionCreate,
+		ParentAccountCellOutPoint: &types.OutPoint{TxHash: parentTxHash, Index: 0},
+		SubAccountSmt:             tree,
+		SubAccountList:            subAccountList,
+	}); err == nil || !strings.Contains(err.Error(), "PayerLock") {
+		t.Fatal("built without payer", err)
+	}
+	if root, _ := tree.Root(); common.Bytes2Hex(root) != common.Bytes2Hex(smt.H256Zero()) {
+		t.Fatal("smt changed by the failed build")
+	}
+	res, err := txbuilder.BuildSubAccountTx(txbuilder.SubAccountTxParams{
+		DasCore:                   dc,
+		DasCache:                  dasCache,
+		Action:                    common.SubActionCreate,
+		ParentAccountCellOutPoint: &types.OutPoint{TxHash: parentTxHash, Index: 0},
+		SubAccountSmt:             tree,
+		SubAccountList:            subAccountList,
+		PayerLock:                 payerLock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := tree.Root()
+	detail := witness.ConvertSubAccountCellOutputData(res.OutputsData[0])
+	if common.Bytes2Hex(detail.SmtRoot) != common.Bytes2Hex(root) || detail.DasProfit != 10*common.OneCkb {
+		t.Fatal("unexpected sub-account cell data", detail)
+	}
+	if len(res.Inputs) != 2 || len(res.Outputs) != 2 || res.Outputs[0].Capacity != 110*common.OneCkb-10000 ||
+		res.Outputs[1].Capacity != 990*common.OneCkb {
+		t.Fatal("unexpected capacity", len(res.Inputs), len(res.Outputs))
+	}
+	createTx := applyTx(res, 4)
+	var builder witness.SubAccountNewBuilder
+	subAccountMap, err := builder.SubAccountNewMapFromTx(createTx)
+	if err != nil || len(subAccountMap) != 2 {
+		t.Fatal(err)
+	}
+
+	// edit the owner of alice, the sub-account cell is found by the parent account id
+	alice := subAccountMap[subAccountList[0].SubAccountData.AccountId]
+	if alice.CurrentSubAccountData.RegisteredAt != uint64(now) {
+		t.Fatal("unexpected registered_at", alice.CurrentSubAccountData.RegisteredAt)
+	}
+	core.DasConfigCellMap.Store(common.ConfigCellTypeArgsRecordNamespace, &core.DasConfigCellInfo{Name: "ConfigCellRecordNamespace"})
+	res, err = txbuilder.BuildSubAccountTx(txbuilder.SubAccountTxParams{
+		DasCore:                   dc,
+		Action:                    common.SubActionEdit,
+		ParentAccountCellOutPoint: &types.OutPoint{TxHash: parentTxHash, Index: 0},
+		SubAccountSmt:             tree,
+		SubAccountList: []*witness.SubAccountNew{{
+			SubAccountData: alice.CurrentSubAccountData,
+			EditKey:        common.EditKeyOwner,
+			EditValue:      common.Hex2Bytes("0x05"),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	editTx := applyTx(res, 5)
+	if res.Outputs[0].Capacity != 110*common.OneCkb-20000 {
+		t.Fatal("unexpected capacity", res.Outputs[0].Capacity)
+	}
+	subAccountMap, err = builder.SubAccountNewMapFromTx(editTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice = subAccountMap[alice.SubAccountData.AccountId]
+	if alice.CurrentSubAccountData.Nonce != 1 || common.Bytes2Hex(alice.CurrentSubAccountData.Lock.Args) != "0x05" {
+		t.Fatal("unexpected sub-account data", alice.CurrentSubAccountData)
+	}
+	root, _ = tree.Root()
+	if detail = witness.ConvertSubAccountCellOutputData(res.OutputsData[0]); common.Bytes2Hex(detail.SmtRoot) != common.Bytes2Hex(root) {
+		t.Fatal("unexpected smt root")
+	}
+
+	// not expired yet, and a stale smt is rejected
+	if _, err = txbuilder.BuildSubAccountTx(txbuilder.SubAccountTxParams{
+		DasCore:                   dc,
+		Action:                    common.SubActionRecycle,
+		ParentAccountCellOutPoint: &types.OutPoint{TxHash: parentTxHash, Index: 0},
+		SubAccountSmt:             tree,
+		SubAccountList:            []*witness.SubAccountNew{{SubAccountData: alice.CurrentSubAccountData}},
+	}); err == nil {
+		t.Fatal("recycle before expired")
+	}
+	staleTree := smt.NewSparseMerkleTree(nil)
+	if _, err = txbuilder.BuildSubAccountTx(txbuilder.SubAccountTxParams{
+		DasCore:                   dc,
+		Action:                    common.SubActionEdit,
+		ParentAccountCellOutPoint: &types.OutPoint{TxHash: parentTxHash, Index: 0},
+		SubAccountSmt:             staleTree,
+		SubAccountList: []*witness.SubAccountNew{{
+			SubAccountData: alice.CurrentSubAccountData,
+			EditKey:        common.EditKeyManager,
+			EditValue:      common.Hex2Bytes("0x06"),
+		}},
+	}); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Fatal("stale smt", err)
+	}
+	if root, _ := staleTree.Root(); common.Bytes2Hex(root) != common.Bytes2Hex(smt.H256Zero()) {
+		t.Fatal("stale smt changed")
+	}
+
+	// the smt is rolled back if the build fails after the update
+	root, _ = tree.Root()
+	rootHex := common.Bytes2Hex(root)
+	if _, err = txbuilder.BuildSubAccountTx(txbuilder.SubAccountTxParams{
+		DasCore:                   dc,
+		Action:                    common.SubActionEdit,
+		ParentAccountCellOutPoint: &types.OutPoint{TxHash: parentTxHash, Index: 0},
+		SubAccountSmt:             &corruptSmt{tree: tree},
+		SubAccountList: []*witness.SubAccountNew{{
+			SubAccountData: alice.CurrentSubAccountData,
+			EditKey:        common.EditKeyManager,
+			EditValue:      common.Hex2Bytes("0x06"),
+		}},
+	}); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Fatal("corrupt smt", err)
+	}
+	if root, _ = tree.Root(); common.Bytes2Hex(root) != rootHex {
+		t.Fatal("smt not rolled back")
+	}
+}
+
+// corruptSmt returns wrong roots for the first update, like a faulty smt server
+type corruptSmt struct {
+	tree      *smt.SparseMerkleTree
+	corrupted bool
+}
+
+func (c *corruptSmt) UpdateMiddleSmt(kv []smt.SmtKv, opt smt.SmtOpt) (*smt.UpdateMiddleSmtOut, error) {
+	res, err := c.tree.UpdateMiddleSmt(kv, opt)
+	if err == nil && !c.corrupted {
+		c.corrupted = true
+		for k := range res.Roots {
+			res.Roots[k] = smt.H256Zero()
+		}
+	}
+	return res, err
+}
@@ -0,0 +1,293 @@
+package example
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/dotbitHQ/das-lib/sign"
+	"github.com/dotbitHQ/das-lib/txbuilder"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/crypto/secp256k1"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sync"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	privateKey := "1f3a2b7c5d4e6f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708"
+	key, _ := crypto.HexToECDSA(privateKey)
+	ethPayload := common.FormatAddressPayload(crypto.PubkeyToAddress(key.PublicKey).Bytes(), common.DasAlgorithmIdEth)
+	tronPayload := common.FormatAddressPayload(crypto.PubkeyToAddress(key.PublicKey).Bytes(), common.DasAlgorithmIdTron)
+	hash160 := hex.EncodeToString(btcutil.Hash160(crypto.CompressPubkey(&key.PublicKey)))
+	ckbPayload := hex.EncodeToString(common.Blake2b(crypto.CompressPubkey(&key.PublicKey))[:20])
+	ckbMsg := common.HexPreFix + "8f1c4a2bb2ea21d1cf5a0b5e5c9b3b7e06d3a3e6c2b1f1e4e0d9a8b7c6d5e4f3"
+	dotBitMsg := common.DotBitPrefix + ckbMsg[2:]
+	signBy := func(algId common.DasAlgorithmId, msg string) []byte {
+		signer, err := sign.NewLocalSigner(algId, privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := signer.Sign(&sign.SignRequest{SignType: algId, SignMsg: msg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	check := func(name string, algId common.DasAlgorithmId, subAlgId common.DasSubAlgorithmId, msg string, sig []byte, payload string, opts ...sign.VerifyOption) {
+		identity, ok, err := sign.Verify(algId, subAlgId, msg, sig, payload, opts...)
+		if err != nil || !ok || identity != payload {
+			t.Fatal(name, "verify failed", identity, ok, err)
+		}
+		// another message
+		if _, ok, _ = sign.Verify(algId, subAlgId, msg[:len(msg)-2]+"00", sig, payload, opts...); ok {
+			t.Fatal(name, "verified another message")
+		}
+	}
+
+	ckbSig := signBy(common.DasAlgorithmIdCkb, ckbMsg)
+	check("ckb", common.DasAlgorithmIdCkb, 0, ckbMsg, ckbSig, ckbPayload)
+
+	ethSig := signBy(common.DasAlgorithmIdEth, dotBitMsg)
+	if ok, err := sign.VerifyPersonalSignature(append([]byte{}, ethSig...), []byte(dotBitMsg), ethPayload); err != nil || !ok {
+		t.Fatal("eth vector is invalid", err)
+	}
+	check("eth", common.DasAlgorithmIdEth, 0, dotBitMsg, ethSig, ethPayload)
+	if identity, ok, err := sign.Verify(common.DasAlgorithmIdEth, 0, dotBitMsg, ethSig, tronPayload); ok || err != nil || identity != ethPayload {
+		t.Fatal("eth verified by another payload", identity)
+	}
+
+	tronSig := signBy(common.DasAlgorithmIdTron, dotBitMsg)
+	base58Addr, _ := common.TronHexToBase58(tronPayload)
+	if !sign.TronVerifySignature(true, append([]byte{}, tronSig...), []byte(dotBitMsg), base58Addr) {
+		t.Fatal("tron vector is invalid")
+	}
+	check("tron", common.DasAlgorithmIdTron, 0, dotBitMsg, tronSig, tronPayload)
+
+	dogeSig := signBy(common.DasAlgorithmIdDogeChain, dotBitMsg)
+	if ok, err := sign.VerifyDogeSignature(append([]byte{}, dogeSig...), []byte(dotBitMsg), hash160); err != nil || !ok {
+		t.Fatal("doge vector is invalid", err)
+	}
+	check("doge", common.DasAlgorithmIdDogeChain, 0, dotBitMsg, dogeSig, hash160)
+
+	btcSig := signBy(common.DasAlgorithmIdBitcoin, ckbMsg)
+	if ok, err := sign.VerifyBitcoinSignature(append([]byte{}, btcSig...), []byte(ckbMsg), hash160); err != nil || !ok {
+		t.Fatal("bitcoin vector is invalid", err)
+	}
+	check("bitcoin", common.DasAlgorithmIdBitcoin, common.DasSubAlgorithmIdBitcoinP2WPKH, ckbMsg, btcSig, hash160)
+	if _, _, err := sign.Verify(common.DasAlgorithmIdBitcoin, common.DasSubAlgorithmIdBitcoinP2PKH, ckbMsg, btcSig, hash160); err == nil {
+		t.Fatal("verified by another sub algorithm")
+	}
+	btcSig, _ = sign.BitcoinSignature([]byte(ckbMsg), privateKey, false, sign.P2PKH)
+	check("bitcoin p2pkh", common.DasAlgorithmIdBitcoin, common.DasSubAlgorithmIdBitcoinP2PKH, ckbMsg, btcSig, hex.EncodeToString(btcutil.Hash160(crypto.FromECDSAPub(&key.PublicKey))))
+
+	edPrivate := ed25519.NewKeyFromSeed(crypto.FromECDSA(key))
+	edPayload := hex.EncodeToString(edPrivate.Public().(ed25519.PublicKey))
+	edSig := sign.Ed25519Signature(edPrivate, common.Hex2Bytes(ckbMsg))
+	check("ed25519", common.DasAlgorithmIdEd25519, 0, ckbMsg, edSig, edPayload)
+
+	// 712, chain id 5
+	var mmJson common.MMJsonObj
+	if err := json.Unmarshal([]byte(common.MMJsonObjStr), &mmJson); err != nil {
+		t.Fatal(err)
+	}
+	mmJson.Domain.ChainID = 5
+	mmJson.Message.DasMessage = "TRANSFER FROM test.bit"
+	mmJson.Message.Action = &common.MMJsonAction{Action: "transfer", Params: "0x00"}
+	eip712Sig, err := sign.DoEIP712Sign(5, ckbMsg, privateKey, &mmJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("712", common.DasAlgorithmIdEth712, 0, ckbMsg, common.Hex2Bytes(eip712Sig), ethPayload, sign.WithMMJson(&mmJson))
+	if _, _, err = sign.Verify(common.DasAlgorithmIdEth712, 0, ckbMsg, common.Hex2Bytes(eip712Sig), ethPayload); err == nil {
+		t.Fatal("712 verified without mm json")
+	}
+	mmJson.Message.DasMessage = "TRANSFER FROM another.bit"
+	if _, ok, _ := sign.Verify(common.DasAlgorithmIdEth712, 0, ckbMsg, common.Hex2Bytes(eip712Sig), ethPayload, sign.WithMMJson(&mmJson)); ok {
+		t.Fatal("712 verified by another typed data")
+	}
+
+	// multisig 2 of 3, the first key is required
+	var keys []*ecdsa.PrivateKey
+	script := []byte{0, 1, 2, 3}
+	for i := 0; i < 3; i++ {
+		k, _ := crypto.ToECDSA(common.Blake2b([]byte{byte(i)}))
+		keys = append(keys, k)
+		script = append(script, common.Blake2b(crypto.CompressPubkey(&k.PublicKey))[:20]...)
+	}
+	multiPayload := hex.EncodeToString(common.Blake2b(script)[:20])
+	multiSig := func(idx ...int) []byte {
+		res := append([]byte{}, script...)
+		for _, i := range idx {
+			sig, _ := sign.LocalSign(hex.EncodeToString(crypto.FromECDSA(keys[i])))(ckbMsg)
+			res = append(res, sig...)
+		}
+		return res
+	}
+	check("multisig", common.DasAlgorithmIdCkbMulti, 0, ckbMsg, multiSig(2, 0), multiPayload)
+	for _, idx := range [][]int{{1, 2}, {0, 0}, {0}} {
+		if _, ok, _ := sign.Verify(common.DasAlgorithmIdCkbMulti, 0, ckbMsg, multiSig(idx...), multiPayload); ok {
+			t.Fatal("multisig verified by", idx)
+		}
+	}
+
+	// webauthn
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	pk1 := hex.EncodeToString(common.CalculatePk1(&p256Key.PublicKey))
+	webauthnPayload := hex.EncodeToString(common.CalculateCid1("01020304")) + pk1
+	otherPayload := hex.EncodeToString(common.CalculateCid1("05060708")) + hex.EncodeToString(make([]byte, 10))
+	webauthnSig := func(pkIndex byte, msg string) []byte {
+		authData := make([]byte, 37)
+		clientDataJson := []byte(`{"type":"webauthn.get","challenge":"` + base64.RawURLEncoding.EncodeToString([]byte(msg)) + `","origin":"https://d.id"}`)
+		clientDataHash := sha256.Sum256(clientDataJson)
+		hash := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+		r, s, _ := ecdsa.Sign(rand.Reader, p256Key, hash[:])
+		signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		pubKey := append(p256Key.X.FillBytes(make([]byte, 32)), p256Key.Y.FillBytes(make([]byte, 32))...)
+		res := []byte{1, pkIndex, 64}
+		res = append(append(res, signature...), 64)
+		res = append(append(res, pubKey...), byte(len(authData)))
+		res = append(res, authData...)
+		clientDataLen := make([]byte, 2)
+		binary.LittleEndian.PutUint16(clientDataLen, uint16(len(clientDataJson)))
+		res = append(res, clientDataLen...)
+		return append(res, clientDataJson...)
+	}
+	sig := webauthnSig(255, dotBitMsg)
+	if ok, err := sign.VerifyWebauthnSignature([]byte(dotBitMsg), append([]byte{}, sig...), webauthnPayload[20:]); err != nil || !ok {
+		t.Fatal("webauthn vector is invalid", err)
+	}
+	check("webauthn", common.DasAlgorithmIdWebauthn, common.DasWebauthnSubAlgorithmIdES256, dotBitMsg, sig, webauthnPayload)
+	// signed by the second key of the key list of otherPayload
+	sig = webauthnSig(1, dotBitMsg)
+	if _, _, err = sign.Verify(common.DasAlgorithmIdWebauthn, common.DasWebauthnSubAlgorithmIdES256, dotBitMsg, sig, otherPayload); err == nil {
+		t.Fatal("webauthn verified without key list")
+	}
+	identity, ok, err := sign.Verify(common.DasAlgorithmIdWebauthn, common.DasWebauthnSubAlgorithmIdES256, dotBitMsg, sig, otherPayload,
+		sign.WithWebauthnKeyList([]string{otherPayload, webauthnPayload}))
+	if err != nil || !ok || identity != webauthnPayload {
+		t.Fatal("webauthn key list verify failed", identity, ok, err)
+	}
+	if _, ok, _ = sign.Verify(common.DasAlgorithmIdWebauthn, common.DasWebauthnSubAlgorithmIdES256, dotBitMsg, sig, otherPayload,
+		sign.WithWebauthnKeyList([]string{webauthnPayload, otherPayload})); ok {
+		t.Fatal("webauthn verified by another key")
+	}
+	if _, _, err = sign.Verify(common.DasAlgorithmIdWebauthn, 0, dotBitMsg, webauthnSig(255, dotBitMsg), webauthnPayload); err == nil {
+		t.Fatal("webauthn verified without the sub algorithm ES256")
+	}
+	ok, _, err = http_api.VerifySignature(common.DasAlgorithmIdWebauthn, dotBitMsg, common.Bytes2Hex(webauthnSig(255, dotBitMsg)), webauthnPayload)
+	if err != nil || !ok {
+		t.Fatal("http_api webauthn verify failed", err)
+	}
+	mmJson.Message.DasMessage = "TRANSFER FROM test.bit"
+	ok, _, err = http_api.VerifySignature(common.DasAlgorithmIdEth712, ckbMsg, eip712Sig, ethPayload, sign.WithMMJson(&mmJson))
+	if err != nil || !ok {
+		t.Fatal("http_api 712 verify failed", err)
+	}
+
+	if _, _, err = sign.Verify(common.DasAlgorithmIdAnyLock, 0, ckbMsg, ckbSig, ckbPayload); err == nil {
+		t.Fatal("any lock is not supported")
+	}
+}
+
+func TestVerifyKnownAnswer(t *testing.T) {
+	seedSignerContracts(t)
+
+	// ckb: the secp256k1 input of a testnet tx, the digest is generated by txbuilder
+	tx, err := rpc.TransactionFromString(btcReverseTxJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := core.NewMemChainReader("ckb_testnet")
+	ckbLock := tx.Outputs[1].Lock
+	reader.AddLiveCell(&indexer.LiveCell{OutPoint: tx.Inputs[0].PreviousOutput, Output: &types.CellOutput{Lock: tx.Outputs[0].Lock}})
+	reader.AddLiveCell(&indexer.LiveCell{OutPoint: tx.Inputs[1].PreviousOutput, Output: &types.CellOutput{Lock: ckbLock}})
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader), core.WithDasNetType(common.DasNetTypeTestnet2))
+	txBuilder := txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), &txbuilder.DasTxBuilderTransaction{Transaction: tx, MapInputsCell: map[string]*types.CellWithStatus{}})
+	digestList, err := txBuilder.GenerateDigestListFromTx([]int{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ckbPayload := hex.EncodeToString(ckbLock.Args)
+	verified := 0
+	for _, v := range digestList {
+		if v.SignMsg == "" {
+			continue
+		}
+		// WitnessArgs: total size and 3 offsets, the length of lock, lock
+		identity, ok, err := sign.Verify(common.DasAlgorithmIdCkb, 0, v.SignMsg, tx.Witnesses[1][20:85], ckbPayload)
+		if err != nil || !ok || identity != ckbPayload {
+			t.Fatal("ckb vector verify failed", identity, err)
+		}
+		verified++
+	}
+	if verified != 1 {
+		t.Fatal("digest of the secp256k1 input not found")
+	}
+
+	// multisig: the address of the multisig script from the vector of ckb-sdk
+	var config sign.MultisigConfig
+	config.Threshold = 2
+	for _, v := range []string{
+		"032edb83018b57ddeb9bcc7287c5cc5da57e6e0289d31c9e98cb361e88678d6288",
+		"033aeb3fdbfaac72e9e34c55884a401ee87115302c146dd9e314677d826375dc8f",
+		"029a685b8206550ea1b600e347f18fd6115bffe582089d3567bec7eba57d04df01",
+	} {
+		config.PubKeyHashes = append(config.PubKeyHashes, common.Blake2b(common.Hex2Bytes(v))[:20])
+	}
+	parsed, err := address.Parse("ckt1qpw9q60tppt7l3j7r09qcp7lxnp3vcanvgha8pmvsa3jplykxn32sq0sfnkgf0ph76pkzwld9ujzex4pkeuwnlsdc5tqu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Script.CodeHash.Hex() != transaction.SECP256K1_BLAKE160_MULTISIG_ALL_TYPE_HASH || !bytes.Equal(parsed.Script.Args, config.Args()) {
+		t.Fatal("multisig args not match the address of ckb-sdk", common.Bytes2Hex(config.Args()))
+	}
+
+	// multisig: the tx signed by transaction.MultiSignTransaction of ckb-sdk
+	config = sign.MultisigConfig{RequireFirstN: 1, Threshold: 2}
+	var keys []*secp256k1.Secp256k1Key
+	for i := 0; i < 3; i++ {
+		key, _ := secp256k1.HexToKey(hex.EncodeToString(common.Blake2b([]byte{byte(i)})))
+		keys = append(keys, key)
+		config.PubKeyHashes = append(config.PubKeyHashes, common.Blake2b(key.PubKey())[:20])
+	}
+	multiTx := &types.Transaction{
+		Inputs:      []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: types.HexToHash("0x03")}}},
+		Outputs:     []*types.CellOutput{{Capacity: 100 * common.OneCkb, Lock: ckbLock}},
+		OutputsData: [][]byte{{}},
+		Witnesses:   [][]byte{{}},
+	}
+	if err = transaction.MultiSignTransaction(multiTx, []int{0}, &types.WitnessArgs{}, config.Script(), keys[0], keys[2]); err != nil {
+		t.Fatal(err)
+	}
+	multiSig := append([]byte{}, multiTx.Witnesses[0][20:]...)
+	multiTx.Witnesses[0] = []byte{}
+	txBuilder = txbuilder.NewDasTxBuilderFromBase(txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, ""), &txbuilder.DasTxBuilderTransaction{Transaction: multiTx})
+	digest, err := txBuilder.GenerateMultiSignDigest([]int{0}, config.RequireFirstN, make([][]byte, config.Threshold), config.PubKeyHashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiPayload := hex.EncodeToString(config.Args())
+	if _, ok, err := sign.Verify(common.DasAlgorithmIdCkbMulti, 0, common.Bytes2Hex(digest), multiSig, multiPayload); err != nil || !ok {
+		t.Fatal("ckb-sdk multisig verify failed", err)
+	}
+	if ok, _, err := http_api.VerifySignature(common.DasAlgorithmIdCkbMulti, common.Bytes2Hex(digest), common.Bytes2Hex(multiSig), multiPayload); err != nil || !ok {
+		t.Fatal("http_api multisig verify failed", err)
+	}
+}
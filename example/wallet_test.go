@@ -0,0 +1,139 @@
+package example
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/txbuilder"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sync"
+	"testing"
+)
+
+func TestPlanWalletMaintenance(t *testing.T) {
+	reader := core.NewMemChainReader("ckb_testnet")
+	lock := common.GetNormalLockScript("0x0000000000000000000000000000000000000001")
+	index := 0
+	addCells := func(capacity uint64, count int) []*indexer.LiveCell {
+		var cells []*indexer.LiveCell
+		for i := 0; i < count; i++ {
+			index++
+			cells = append(cells, &indexer.LiveCell{
+				BlockNumber: uint64(index),
+				OutPoint:    &types.OutPoint{TxHash: types.HexToHash(fmt.Sprintf("0x%x", index)), Index: 0},
+				Output:      &types.CellOutput{Capacity: capacity, Lock: lock},
+			})
+		}
+		reader.AddLiveCell(cells...)
+		return cells
+	}
+	dust := addCells(70*common.OneCkb, 10)
+	addCells(500*common.OneCkb, 2)
+	addCells(5000*common.OneCkb, 1)
+	// not balance cells
+	reader.AddLiveCell(&indexer.LiveCell{
+		BlockNumber: 100,
+		OutPoint:    &types.OutPoint{TxHash: types.HexToHash("0x100"), Index: 0},
+		Output:      &types.CellOutput{Capacity: 70 * common.OneCkb, Lock: lock, Type: lock},
+	})
+
+	seedSignerContracts(t)
+	var wg sync.WaitGroup
+	dc := core.NewDasCore(context.Background(), &wg, core.WithChainReader(reader))
+	base := txbuilder.NewDasTxBuilderBase(context.Background(), dc, nil, "")
+	dasCache := dascache.NewDasCache(context.Background(), &wg)
+	dasCache.AddOutPoint([]string{common.OutPointStruct2String(dust[0].OutPoint)})
+	target := txbuilder.WalletTarget{
+		PoolSize:      6,
+		PoolCapacity:  500 * common.OneCkb,
+		DustThreshold: 100 * common.OneCkb,
+		MaxInputs:     4,
+		DasCache:      dasCache,
+	}
+	params, err := base.PlanWalletMaintenance(lock, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shape []string
+	var fees []uint64
+	for _, p := range params {
+		txType := "consolidate"
+		if len(p.Outputs) > 1 {
+			txType = "split"
+		}
+		shape = append(shape, fmt.Sprintf("%s:%d>%d", txType, len(p.Inputs), len(p.Outputs)))
+
+		// the fee is the one estimated by the tx builder
+		txBuilder := txbuilder.NewDasTxBuilderFromBase(base, nil)
+		if err := txBuilder.BuildTransaction(p); err != nil {
+			t.Fatal(err)
+		}
+		fee, err := txBuilder.EstimateTxFee(txbuilder.DefaultFeeRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := uint64(0)
+		for _, v := range p.Inputs {
+			cell, err := reader.GetLiveCell(context.Background(), v.PreviousOutput, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total += cell.Cell.Output.Capacity
+			if !dasCache.ExistOutPoint(common.OutPointStruct2String(v.PreviousOutput)) {
+				t.Fatal("planned input not locked", v.PreviousOutput.TxHash)
+			}
+		}
+		if total != txBuilder.Transaction.OutputsCapacity()+fee || fee == 0 || fee >= common.OneCkb {
+			t.Fatal("unexpected fee", txType, total, fee)
+		}
+		fees = append(fees, fee)
+	}
+	// 4 pool cells from the large cell, the 9 dust cells not pending are merged in batches of 4
+	if fmt.Sprint(shape) != "[split:1>5 consolidate:4>1 consolidate:4>1]" {
+		t.Fatal("unexpected plan", shape)
+	}
+	if params[0].Outputs[4].Capacity != 3000*common.OneCkb-fees[0] {
+		t.Fatal("unexpected change", params[0].Outputs[4].Capacity)
+	}
+	// the cells not planned are released, the planned ones are not planned again
+	for _, v := range dust[9:] {
+		if dasCache.ExistOutPoint(common.OutPointStruct2String(v.OutPoint)) {
+			t.Fatal("unplanned cell not released", v.OutPoint.TxHash)
+		}
+	}
+	again, err := base.PlanWalletMaintenance(lock, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 0 {
+		t.Fatal("pending cells are planned again", len(again))
+	}
+
+	// the tx size limit
+	target.MaxInputs, target.MaxTxSize = 0, 800
+	target.DasCache = dascache.NewDasCache(context.Background(), &wg)
+	if params, err = base.PlanWalletMaintenance(lock, target); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range params {
+		txBuilder := txbuilder.NewDasTxBuilderFromBase(base, nil)
+		if err := txBuilder.BuildTransaction(p); err != nil {
+			t.Fatal(err)
+		}
+		if size, _ := txBuilder.EstimateTxSize(); size > target.MaxTxSize {
+			t.Fatal("tx is too large", size)
+		}
+	}
+	// the 10 dust cells are merged by 8 and 2
+	if len(params) != 3 || len(params[0].Outputs) != 4 || len(params[1].Inputs) != 8 || len(params[2].Inputs) != 2 {
+		t.Fatal("unexpected plan", len(params))
+	}
+	for _, p := range params {
+		if len(p.Witnesses) != 0 {
+			t.Fatal("unexpected witnesses", len(p.Witnesses))
+		}
+	}
+}
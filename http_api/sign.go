@@ -6,6 +6,7 @@ import (
 	"github.com/dotbitHQ/das-lib/sign"
 	"github.com/dotbitHQ/das-lib/txbuilder"
 	"github.com/scorpiotzh/toolib"
+	"strings"
 )
 
 type SignInfo struct {
@@ -29,45 +30,29 @@ func fixSignature(signMsg string) string {
 	return signMsg
 }
 
-func VerifySignature(signType common.DasAlgorithmId, signMsg, signature, address string) (bool, string, error) {
-	signOk := false
-	var err error
+// VerifySignature verifies the signature by sign.Verify, address is the hex address of the sign type
+// (the public key for ed25519, cid1+pk1 for webauthn), the typed data of 712 and the key list of webauthn are passed by opts
+func VerifySignature(signType common.DasAlgorithmId, signMsg, signature, address string, opts ...sign.VerifyOption) (bool, string, error) {
+	var subAlgId common.DasSubAlgorithmId
 	switch signType {
-	case common.DasAlgorithmIdEth:
+	case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712:
 		signature = fixSignature(signature)
-		signOk, err = sign.VerifyPersonalSignature(common.Hex2Bytes(signature), []byte(signMsg), address)
-		if err != nil {
-			return false, signature, fmt.Errorf("VerifyPersonalSignature err: %s", err.Error())
-		}
+		address = common.HexPreFix + strings.TrimPrefix(address, common.HexPreFix)
 	case common.DasAlgorithmIdTron:
 		signature = fixSignature(signature)
-		if address, err = common.TronHexToBase58(address); err != nil {
-			return false, signature, fmt.Errorf("TronHexToBase58 err: %s [%s]", err.Error(), address)
-		}
-		signOk = sign.TronVerifySignature(true, common.Hex2Bytes(signature), []byte(signMsg), address)
-	case common.DasAlgorithmIdEd25519:
-		signOk = sign.VerifyEd25519Signature(common.Hex2Bytes(address), common.Hex2Bytes(signMsg), common.Hex2Bytes(signMsg))
-	case common.DasAlgorithmIdDogeChain:
-		signOk, err = sign.VerifyDogeSignature(common.Hex2Bytes(signature), []byte(signMsg), address)
-		if err != nil {
-			return false, signature, fmt.Errorf("VerifyDogeSignature err: %s [%s]", err.Error(), address)
-		}
-	case common.DasAlgorithmIdBitcoin:
-		signOk, err = sign.VerifyBitcoinSignature(common.Hex2Bytes(signature), []byte(signMsg), address)
-		if err != nil {
-			return false, signature, fmt.Errorf("VerifyBitcoinSignature err: %s [%s]", err.Error(), address)
-		}
 	case common.DasAlgorithmIdWebauthn:
-		signOk, err = sign.VerifyWebauthnSignature([]byte(signMsg), common.Hex2Bytes(signature), address[20:])
-		if err != nil {
-			return false, signature, fmt.Errorf("VerifyWebauthnSignature err: %s [%s]", err.Error(), address)
-		}
+		subAlgId = common.DasWebauthnSubAlgorithmIdES256
+		address = strings.TrimPrefix(address, common.HexPreFix)
+	case common.DasAlgorithmIdCkb, common.DasAlgorithmIdCkbSingle, common.DasAlgorithmIdCkbMulti,
+		common.DasAlgorithmIdEd25519, common.DasAlgorithmIdDogeChain, common.DasAlgorithmIdBitcoin:
+		address = strings.TrimPrefix(address, common.HexPreFix)
 	default:
 		return false, signature, fmt.Errorf("not exist sign type[%d]", signType)
 	}
 
-	if !signOk {
-		return false, signature, nil
+	_, signOk, err := sign.Verify(signType, subAlgId, signMsg, common.Hex2Bytes(signature), address, opts...)
+	if err != nil {
+		return false, signature, fmt.Errorf("Verify err: %s [%s]", err.Error(), address)
 	}
-	return true, signature, nil
+	return signOk, signature, nil
 }
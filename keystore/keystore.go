@@ -0,0 +1,517 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/scorpiotzh/mylog"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = logger.NewLogger("keystore", mylog.LevelDebug)
+
+var (
+	ErrKeyNotExist   = errors.New("key not exist")
+	ErrKeyExist      = errors.New("key already exists")
+	ErrKeyLocked     = errors.New("key is locked")
+	ErrDecryptFailed = errors.New("could not decrypt key with given passphrase")
+)
+
+const (
+	cipherAes128Ctr = "aes-128-ctr" // ethereum keystore v3
+	cipherAes256Gcm = "aes-256-gcm"
+	kdfScrypt       = "scrypt"
+	scryptR         = 8
+	scryptDKLen     = 32
+	keyFileVersion  = 3
+	keyFileExt      = ".json"
+)
+
+var keyNameReg = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]{0,63}$`)
+
+// KeyInfo is the metadata of a key, saved in plain text with the encrypted key
+type KeyInfo struct {
+	Name           string                   `json:"name"`
+	AlgorithmId    common.DasAlgorithmId    `json:"algorithm_id"`
+	SubAlgorithmId common.DasSubAlgorithmId `json:"sub_algorithm_id"`
+	Address        string                   `json:"address"` // payload of the public key, in the format of common.FormatAddressPayload
+}
+
+type cryptoJSON struct {
+	Cipher       string `json:"cipher"`
+	CipherText   string `json:"ciphertext"`
+	CipherParams struct {
+		IV string `json:"iv"`
+	} `json:"cipherparams"`
+	KDF       string                 `json:"kdf"`
+	KDFParams map[string]interface{} `json:"kdfparams"`
+	MAC       string                 `json:"mac"`
+}
+
+// keyFile is the ethereum keystore v3 with the das metadata,
+// the evm keys are encrypted by aes-128-ctr so the file can be imported by the ethereum wallets, the others by aes-256-gcm
+type keyFile struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Id      string     `json:"id"`
+	Version int        `json:"version"`
+	Das     *KeyInfo   `json:"das"`
+}
+
+type unlockedKey struct {
+	key   []byte
+	timer *time.Timer
+}
+
+// Keystore keeps the keys of dir, one file per key, the keys are decrypted in memory only when unlocked
+type Keystore struct {
+	dir     string
+	scryptN int
+	scryptP int
+
+	rw       sync.RWMutex
+	keys     map[string]*KeyInfo
+	unlocked map[string]*unlockedKey
+}
+
+type KeystoreOption func(*Keystore)
+
+// WithScrypt sets the scrypt params of the new keys, ethkeystore.StandardScryptN and StandardScryptP by default
+func WithScrypt(n, p int) KeystoreOption {
+	return func(ks *Keystore) {
+		ks.scryptN, ks.scryptP = n, p
+	}
+}
+
+func NewKeystore(dir string, opts ...KeystoreOption) (*Keystore, error) {
+	ks := Keystore{
+		dir:      dir,
+		scryptN:  ethkeystore.StandardScryptN,
+		scryptP:  ethkeystore.StandardScryptP,
+		keys:     make(map[string]*KeyInfo),
+		unlocked: make(map[string]*unlockedKey),
+	}
+	for _, opt := range opts {
+		opt(&ks)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("MkdirAll err: %s", err.Error())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ReadDir err: %s", err.Error())
+	}
+	for _, v := range entries {
+		name := strings.TrimSuffix(v.Name(), keyFileExt)
+		if v.IsDir() || !strings.HasSuffix(v.Name(), keyFileExt) || !keyNameReg.MatchString(name) {
+			continue
+		}
+		kf, err := ks.readKeyFile(name)
+		if err != nil {
+			log.Warn("readKeyFile err:", v.Name(), err.Error())
+			continue
+		}
+		info := *kf.Das
+		info.Name = name
+		ks.keys[name] = &info
+	}
+	return &ks, nil
+}
+
+// Import encrypts the hex private key by passphrase and saves it as name,
+// the key of ed25519 is the 32-byte seed or the 64-byte seed+public key
+func (ks *Keystore) Import(name string, algId common.DasAlgorithmId, subAlgId common.DasSubAlgorithmId, hexPrivateKey, passphrase string) (*KeyInfo, error) {
+	key, err := hex.DecodeString(strings.TrimPrefix(hexPrivateKey, common.HexPreFix))
+	if err != nil {
+		return nil, fmt.Errorf("hex.DecodeString err: %s", err.Error())
+	}
+	defer zeroBytes(key)
+	return ks.importKey(name, algId, subAlgId, key, passphrase)
+}
+
+// ImportEthKeystore imports the ethereum keystore v3 file as a key of eth or 712
+func (ks *Keystore) ImportEthKeystore(name string, algId common.DasAlgorithmId, keyJson []byte, passphrase string) (*KeyInfo, error) {
+	if algId != common.DasAlgorithmIdEth && algId != common.DasAlgorithmIdEth712 {
+		return nil, fmt.Errorf("not support sign type[%d]", algId)
+	}
+	k, err := ethkeystore.DecryptKey(keyJson, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey err: %s", err.Error())
+	}
+	key := crypto.FromECDSA(k.PrivateKey)
+	defer zeroBytes(key)
+	return ks.importKey(name, algId, 0, key, passphrase)
+}
+
+func (ks *Keystore) importKey(name string, algId common.DasAlgorithmId, subAlgId common.DasSubAlgorithmId, key []byte, passphrase string) (*KeyInfo, error) {
+	if !keyNameReg.MatchString(name) {
+		return nil, fmt.Errorf("invalid key name: %s", name)
+	}
+	if algId == common.DasAlgorithmIdEd25519 && len(key) == ed25519.SeedSize {
+		key = ed25519.NewKeyFromSeed(key)
+		defer zeroBytes(key)
+	}
+	payload, err := addressPayload(algId, key)
+	if err != nil {
+		return nil, err
+	}
+	info := KeyInfo{
+		Name:           name,
+		AlgorithmId:    algId,
+		SubAlgorithmId: subAlgId,
+		Address:        common.FormatAddressPayload(payload, algId),
+	}
+	kf := keyFile{
+		Id:      uuid.New().String(),
+		Version: keyFileVersion,
+		Das:     &info,
+	}
+	switch algId {
+	case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712:
+		kf.Address = hex.EncodeToString(payload)
+		cj, err := ethkeystore.EncryptDataV3(key, []byte(passphrase), ks.scryptN, ks.scryptP)
+		if err != nil {
+			return nil, fmt.Errorf("EncryptDataV3 err: %s", err.Error())
+		}
+		if err = convert(cj, &kf.Crypto); err != nil {
+			return nil, err
+		}
+	default:
+		if kf.Crypto, err = encryptGcm(key, passphrase, ks.scryptN, ks.scryptP); err != nil {
+			return nil, err
+		}
+	}
+
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if _, ok := ks.keys[name]; ok {
+		return nil, ErrKeyExist
+	}
+	if err = ks.writeKeyFile(name, &kf); err != nil {
+		return nil, err
+	}
+	ks.keys[name] = &info
+	res := info
+	return &res, nil
+}
+
+// List returns the keys sorted by name
+func (ks *Keystore) List() []KeyInfo {
+	ks.rw.RLock()
+	defer ks.rw.RUnlock()
+	var list []KeyInfo
+	for _, v := range ks.keys {
+		list = append(list, *v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func (ks *Keystore) KeyInfo(name string) (*KeyInfo, error) {
+	ks.rw.RLock()
+	defer ks.rw.RUnlock()
+	info, ok := ks.keys[name]
+	if !ok {
+		return nil, ErrKeyNotExist
+	}
+	res := *info
+	return &res, nil
+}
+
+// Unlock decrypts the key and keeps it in memory for timeout, until Lock if timeout is 0
+func (ks *Keystore) Unlock(name, passphrase string, timeout time.Duration) error {
+	if _, err := ks.KeyInfo(name); err != nil {
+		return err
+	}
+	kf, err := ks.readKeyFile(name)
+	if err != nil {
+		return err
+	}
+	key, err := decryptKey(&kf.Crypto, passphrase)
+	if err != nil {
+		return err
+	}
+
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if old, ok := ks.unlocked[name]; ok {
+		ks.lock(name, old)
+	}
+	u := unlockedKey{key: key}
+	if timeout > 0 {
+		u.timer = time.AfterFunc(timeout, func() {
+			ks.rw.Lock()
+			defer ks.rw.Unlock()
+			if cur, ok := ks.unlocked[name]; ok && cur == &u {
+				ks.lock(name, cur)
+			}
+		})
+	}
+	ks.unlocked[name] = &u
+	return nil
+}
+
+// Lock removes the decrypted key from memory
+func (ks *Keystore) Lock(name string) {
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if u, ok := ks.unlocked[name]; ok {
+		ks.lock(name, u)
+	}
+}
+
+func (ks *Keystore) lock(name string, u *unlockedKey) {
+	if u.timer != nil {
+		u.timer.Stop()
+	}
+	zeroBytes(u.key)
+	delete(ks.unlocked, name)
+}
+
+func (ks *Keystore) IsUnlocked(name string) bool {
+	ks.rw.RLock()
+	defer ks.rw.RUnlock()
+	_, ok := ks.unlocked[name]
+	return ok
+}
+
+// WithPrivateKey calls fn with the hex private key of the unlocked key, fn should not keep the key
+func (ks *Keystore) WithPrivateKey(name string, fn func(hexPrivateKey string) error) error {
+	ks.rw.RLock()
+	u, ok := ks.unlocked[name]
+	var hexKey string
+	if ok {
+		hexKey = hex.EncodeToString(u.key)
+	}
+	ks.rw.RUnlock()
+	if !ok {
+		if _, err := ks.KeyInfo(name); err != nil {
+			return err
+		}
+		return ErrKeyLocked
+	}
+	return fn(hexKey)
+}
+
+func (ks *Keystore) keyFilePath(name string) string {
+	return filepath.Join(ks.dir, name+keyFileExt)
+}
+
+func (ks *Keystore) readKeyFile(name string) (*keyFile, error) {
+	bys, err := os.ReadFile(ks.keyFilePath(name))
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile err: %s", err.Error())
+	}
+	var kf keyFile
+	if err = json.Unmarshal(bys, &kf); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal err: %s", err.Error())
+	}
+	if kf.Version != keyFileVersion || kf.Das == nil {
+		return nil, fmt.Errorf("unknown key file version[%d]", kf.Version)
+	}
+	return &kf, nil
+}
+
+// writeKeyFile writes a temp file then renames it, so a key file is never half written
+func (ks *Keystore) writeKeyFile(name string, kf *keyFile) error {
+	bys, err := json.Marshal(kf)
+	if err != nil {
+		return fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	f, err := os.CreateTemp(ks.dir, "."+name+".tmp")
+	if err != nil {
+		return fmt.Errorf("CreateTemp err: %s", err.Error())
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(bys); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("Write err: %s", err.Error())
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("Sync err: %s", err.Error())
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("Close err: %s", err.Error())
+	}
+	if err = os.Chmod(f.Name(), 0600); err != nil {
+		return fmt.Errorf("Chmod err: %s", err.Error())
+	}
+	if err = os.Rename(f.Name(), ks.keyFilePath(name)); err != nil {
+		return fmt.Errorf("Rename err: %s", err.Error())
+	}
+	return nil
+}
+
+// addressPayload returns the payload of the lock args, like the args of core.DasAddressHex
+func addressPayload(algId common.DasAlgorithmId, key []byte) ([]byte, error) {
+	switch algId {
+	case common.DasAlgorithmIdCkb, common.DasAlgorithmIdCkbSingle, common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712,
+		common.DasAlgorithmIdTron, common.DasAlgorithmIdDogeChain, common.DasAlgorithmIdBitcoin:
+		private, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, fmt.Errorf("crypto.ToECDSA err: %s", err.Error())
+		}
+		switch algId {
+		case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712, common.DasAlgorithmIdTron:
+			return crypto.PubkeyToAddress(private.PublicKey).Bytes(), nil
+		case common.DasAlgorithmIdDogeChain, common.DasAlgorithmIdBitcoin:
+			return btcutil.Hash160(crypto.CompressPubkey(&private.PublicKey)), nil
+		default:
+			return common.Blake2b(crypto.CompressPubkey(&private.PublicKey))[:20], nil
+		}
+	case common.DasAlgorithmIdEd25519:
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("invalid ed25519 private key length[%d]", len(key))
+		}
+		return append([]byte{}, key[ed25519.SeedSize:]...), nil
+	default:
+		return nil, fmt.Errorf("not support sign type[%d]", algId)
+	}
+}
+
+func encryptGcm(key []byte, passphrase string, scryptN, scryptP int) (cj cryptoJSON, e error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		e = fmt.Errorf("rand.Read err: %s", err.Error())
+		return
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		e = fmt.Errorf("scrypt.Key err: %s", err.Error())
+		return
+	}
+	defer zeroBytes(derivedKey)
+	gcm, err := newGcm(derivedKey)
+	if err != nil {
+		e = err
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		e = fmt.Errorf("rand.Read err: %s", err.Error())
+		return
+	}
+	cj.Cipher = cipherAes256Gcm
+	cj.CipherText = hex.EncodeToString(gcm.Seal(nil, nonce, key, nil))
+	cj.CipherParams.IV = hex.EncodeToString(nonce)
+	cj.KDF = kdfScrypt
+	cj.KDFParams = map[string]interface{}{
+		"n":     scryptN,
+		"r":     scryptR,
+		"p":     scryptP,
+		"dklen": scryptDKLen,
+		"salt":  hex.EncodeToString(salt),
+	}
+	return
+}
+
+func decryptKey(cj *cryptoJSON, passphrase string) ([]byte, error) {
+	switch cj.Cipher {
+	case cipherAes128Ctr:
+		var ethCj ethkeystore.CryptoJSON
+		if err := convert(cj, &ethCj); err != nil {
+			return nil, err
+		}
+		key, err := ethkeystore.DecryptDataV3(ethCj, passphrase)
+		if err == ethkeystore.ErrDecrypt {
+			return nil, ErrDecryptFailed
+		} else if err != nil {
+			return nil, fmt.Errorf("DecryptDataV3 err: %s", err.Error())
+		}
+		return key, nil
+	case cipherAes256Gcm:
+		if cj.KDF != kdfScrypt {
+			return nil, fmt.Errorf("not support kdf: %s", cj.KDF)
+		}
+		salt, err := hex.DecodeString(fmt.Sprint(cj.KDFParams["salt"]))
+		if err != nil {
+			return nil, fmt.Errorf("hex.DecodeString err: %s", err.Error())
+		}
+		n, r, p, dkLen := kdfParamInt(cj, "n"), kdfParamInt(cj, "r"), kdfParamInt(cj, "p"), kdfParamInt(cj, "dklen")
+		derivedKey, err := scrypt.Key([]byte(passphrase), salt, n, r, p, dkLen)
+		if err != nil {
+			return nil, fmt.Errorf("scrypt.Key err: %s", err.Error())
+		}
+		defer zeroBytes(derivedKey)
+		gcm, err := newGcm(derivedKey)
+		if err != nil {
+			return nil, err
+		}
+		nonce, err := hex.DecodeString(cj.CipherParams.IV)
+		if err != nil || len(nonce) != gcm.NonceSize() {
+			return nil, fmt.Errorf("invalid iv: %s", cj.CipherParams.IV)
+		}
+		cipherText, err := hex.DecodeString(cj.CipherText)
+		if err != nil {
+			return nil, fmt.Errorf("hex.DecodeString err: %s", err.Error())
+		}
+		key, err := gcm.Open(nil, nonce, cipherText, nil)
+		if err != nil {
+			return nil, ErrDecryptFailed
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("not support cipher: %s", cj.Cipher)
+	}
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher err: %s", err.Error())
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM err: %s", err.Error())
+	}
+	return gcm, nil
+}
+
+func kdfParamInt(cj *cryptoJSON, key string) int {
+	if v, ok := cj.KDFParams[key].(float64); ok {
+		return int(v)
+	}
+	if v, ok := cj.KDFParams[key].(int); ok {
+		return v
+	}
+	return 0
+}
+
+// convert copies between cryptoJSON and ethkeystore.CryptoJSON, whose cipherparams type is not exported
+func convert(from, to interface{}) error {
+	bys, err := json.Marshal(from)
+	if err != nil {
+		return fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	if err = json.Unmarshal(bys, to); err != nil {
+		return fmt.Errorf("json.Unmarshal err: %s", err.Error())
+	}
+	return nil
+}
+
+func zeroBytes(bys []byte) {
+	for i := range bys {
+		bys[i] = 0
+	}
+}
@@ -0,0 +1,99 @@
+package keystore
+
+import (
+	"fmt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/dotbitHQ/das-lib/chain/chain_evm"
+	"github.com/dotbitHQ/das-lib/chain/chain_tron"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/sign"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/api"
+)
+
+// KeySigner is a sign.Signer of a key in the keystore, it fails with ErrKeyLocked until the key is unlocked
+type KeySigner struct {
+	ks         *Keystore
+	name       string
+	algId      common.DasAlgorithmId
+	Compress   bool            // for doge and bitcoin, true by default
+	SegwitType sign.SegwitType // for bitcoin, by the sub algorithm id of the key by default
+	EvmChainId int64           // for 712
+}
+
+func (ks *Keystore) Signer(name string) (*KeySigner, error) {
+	info, err := ks.KeyInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	s := KeySigner{ks: ks, name: name, algId: info.AlgorithmId, Compress: true, SegwitType: sign.P2WPKH}
+	switch info.SubAlgorithmId {
+	case common.DasSubAlgorithmIdBitcoinP2PKH:
+		s.SegwitType = sign.P2PKH
+	case common.DasSubAlgorithmIdBitcoinP2SHP2WPKH:
+		s.SegwitType = sign.P2SH_P2WPKH
+	}
+	return &s, nil
+}
+
+func (k *KeySigner) AlgorithmId() common.DasAlgorithmId {
+	return k.algId
+}
+
+func (k *KeySigner) Sign(req *sign.SignRequest) (sig []byte, e error) {
+	e = k.ks.WithPrivateKey(k.name, func(hexPrivateKey string) error {
+		local, err := sign.NewLocalSigner(k.algId, hexPrivateKey)
+		if err != nil {
+			return fmt.Errorf("NewLocalSigner err: %s", err.Error())
+		}
+		local.Compress, local.SegwitType, local.EvmChainId = k.Compress, k.SegwitType, k.EvmChainId
+		sig, err = local.Sign(req)
+		return err
+	})
+	return
+}
+
+// LocalSign is the sign.HandleSignCkbMessage of the key, replaces sign.LocalSign
+func (ks *Keystore) LocalSign(name string) sign.HandleSignCkbMessage {
+	return func(message string) (sig []byte, e error) {
+		e = ks.WithPrivateKey(name, func(hexPrivateKey string) error {
+			var err error
+			sig, err = sign.LocalSign(hexPrivateKey)(message)
+			return err
+		})
+		return
+	}
+}
+
+// SignEvmTx replaces chain_evm.ChainEvm.SignWithPrivateKey
+func (ks *Keystore) SignEvmTx(c *chain_evm.ChainEvm, name string, tx *types.Transaction) (sigTx *types.Transaction, e error) {
+	e = ks.WithPrivateKey(name, func(hexPrivateKey string) error {
+		var err error
+		sigTx, err = c.SignWithPrivateKey(hexPrivateKey, tx)
+		return err
+	})
+	return
+}
+
+// SignTronTx replaces chain_tron.ChainTron.LocalSign
+func (ks *Keystore) SignTronTx(c *chain_tron.ChainTron, name string, tx *api.TransactionExtention) error {
+	return ks.WithPrivateKey(name, func(hexPrivateKey string) error {
+		return c.LocalSign(tx, hexPrivateKey)
+	})
+}
+
+// SignBitcoinTx replaces bitcoin.TxTool.LocalSignTx, all the inputs are signed by the key, the Private of uos is ignored
+func (ks *Keystore) SignBitcoinTx(t *bitcoin.TxTool, name string, tx *wire.MsgTx, uos []bitcoin.UnspentOutputs) (hexTx string, e error) {
+	e = ks.WithPrivateKey(name, func(hexPrivateKey string) error {
+		list := make([]bitcoin.UnspentOutputs, len(uos))
+		for i, v := range uos {
+			list[i] = v
+			list[i].Private = hexPrivateKey
+		}
+		var err error
+		hexTx, err = t.LocalSignTx(tx, list)
+		return err
+	})
+	return
+}
@@ -0,0 +1,70 @@
+package remote_sign
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/dotbitHQ/das-lib/sign"
+)
+
+// Signer signs the digests of txbuilder by the v2 remote sign server,
+// the ckb digests are signed as txs, the others as messages, 712 by the typed data of the tx
+type Signer struct {
+	url        string
+	addr       string
+	algId      common.DasAlgorithmId
+	EvmChainId int64 // for 712
+}
+
+func NewSigner(url, addr string, algId common.DasAlgorithmId) *Signer {
+	return &Signer{url: url, addr: addr, algId: algId}
+}
+
+func (s *Signer) AlgorithmId() common.DasAlgorithmId {
+	return s.algId
+}
+
+func (s *Signer) Sign(req *sign.SignRequest) ([]byte, error) {
+	switch s.algId {
+	case common.DasAlgorithmIdCkb, common.DasAlgorithmIdCkbSingle:
+		return SignTxForCKB(s.url, s.addr, req.SignMsg)
+	case common.DasAlgorithmIdEth712:
+		if req.BuildMMJson == nil {
+			return nil, fmt.Errorf("BuildMMJson is nil")
+		}
+		mmJson, err := req.BuildMMJson(s.EvmChainId)
+		if err != nil {
+			return nil, fmt.Errorf("BuildMMJson err: %s", err.Error())
+		}
+		sig, err := SignTxFor712(s.url, s.addr, req.SignMsg, s.EvmChainId, mmJson)
+		if err != nil {
+			return nil, err
+		}
+		return common.Hex2Bytes(sig), nil
+	case common.DasAlgorithmIdEth, common.DasAlgorithmIdTron, common.DasAlgorithmIdDogeChain,
+		common.DasAlgorithmIdBitcoin, common.DasAlgorithmIdEd25519:
+		return signMsg(s.url, s.addr, req.SignMsg)
+	default:
+		return nil, fmt.Errorf("not support sign type[%d]", s.algId)
+	}
+}
+
+func signMsg(url, addr, data string) ([]byte, error) {
+	resp, res, err := RemoteSign(url, ReqRemoteSign{
+		SignType: SignTypeMsg,
+		Address:  addr,
+		Data:     data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RemoteSign err: %s", err.Error())
+	}
+	if resp.ErrNo != http_api.ApiCodeSuccess {
+		return nil, fmt.Errorf("RemoteSign fail code: %d, msg: %s", resp.ErrNo, resp.ErrMsg)
+	}
+	bys, err := hex.DecodeString(res.Data)
+	if err != nil {
+		return nil, fmt.Errorf("hex.DecodeString err: %s", err.Error())
+	}
+	return bys, nil
+}
@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/checkpoint"
+	"sync"
+)
+
+// Checkpoint is the last block processed by the scanner
+type Checkpoint struct {
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+}
+
+// CheckpointStore persists the checkpoint, the scanner continues from it after restart
+type CheckpointStore interface {
+	LoadCheckpoint() (*Checkpoint, error) // nil if no block is processed
+	SaveCheckpoint(cp Checkpoint) error
+}
+
+type MemCheckpointStore struct {
+	lock sync.RWMutex
+	cp   *Checkpoint
+}
+
+func (m *MemCheckpointStore) LoadCheckpoint() (*Checkpoint, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if m.cp == nil {
+		return nil, nil
+	}
+	cp := *m.cp
+	return &cp, nil
+}
+
+func (m *MemCheckpointStore) SaveCheckpoint(cp Checkpoint) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.cp = &cp
+	return nil
+}
+
+// checkpointBlockStore keeps the processed blocks in the reorg window in memory for the fork detection
+// and saves the latest one to the CheckpointStore, only the checkpoint is checked against the chain after restart
+type checkpointBlockStore struct {
+	*checkpoint.MemStore
+	cs CheckpointStore
+}
+
+func newCheckpointBlockStore(cs CheckpointStore, keep int) (*checkpointBlockStore, error) {
+	c := checkpointBlockStore{MemStore: checkpoint.NewMemStore(keep), cs: cs}
+	cp, err := cs.LoadCheckpoint()
+	if err != nil {
+		return nil, fmt.Errorf("LoadCheckpoint err: %s", err.Error())
+	}
+	if cp != nil {
+		if err := c.MemStore.Save(checkpoint.Block{Number: cp.BlockNumber, Hash: cp.BlockHash}); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+func (c *checkpointBlockStore) Save(block checkpoint.Block) error {
+	if latest, _ := c.MemStore.Latest(); latest != nil && block.Number <= latest.Number {
+		return fmt.Errorf("%w: %d <= %d", checkpoint.ErrNotAfterLatest, block.Number, latest.Number)
+	}
+	if err := c.cs.SaveCheckpoint(Checkpoint{BlockNumber: block.Number, BlockHash: block.Hash}); err != nil {
+		return fmt.Errorf("SaveCheckpoint err: %s", err.Error())
+	}
+	return c.MemStore.Save(block)
+}
+
+func (c *checkpointBlockStore) Rollback(number uint64) ([]checkpoint.Block, error) {
+	list, _ := c.MemStore.List()
+	var latest *checkpoint.Block
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Number <= number {
+			latest = &list[i]
+			break
+		}
+	}
+	if latest == nil || (len(list) > 0 && list[len(list)-1].Number == latest.Number) {
+		return c.MemStore.Rollback(number)
+	}
+	if err := c.cs.SaveCheckpoint(Checkpoint{BlockNumber: latest.Number, BlockHash: latest.Hash}); err != nil {
+		return nil, fmt.Errorf("SaveCheckpoint err: %s", err.Error())
+	}
+	return c.MemStore.Rollback(number)
+}
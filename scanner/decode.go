@@ -0,0 +1,196 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sort"
+)
+
+// DecodeTransaction returns the events of a das tx, nil if tx has no action witness.
+// The inputs are resolved by the chain reader of dasCore, so the previous txs must be readable.
+// ManagerChanged is not emitted when the owner is transferred, the manager is reset by the transfer
+func DecodeTransaction(dasCore *core.DasCore, tx *types.Transaction, base EventBase) ([]Event, error) {
+	builder, err := witness.ActionDataBuilderFromTx(tx)
+	if err == witness.ErrNotExistActionData {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("ActionDataBuilderFromTx err: %s", err.Error())
+	}
+	base.Action = builder.Action
+	if base.TxHash == "" {
+		if txHash, err := tx.ComputeHash(); err == nil {
+			base.TxHash = txHash.Hex()
+		}
+	}
+
+	explain, err := dasCore.ExplainTransaction(tx)
+	if err != nil {
+		return nil, fmt.Errorf("ExplainTransaction err: %s", err.Error())
+	}
+	events := []Event{&ActionEvent{EventBase: base, Transaction: tx, Explain: explain}}
+
+	// account cells
+	for _, v := range explain.Accounts {
+		switch {
+		case v.OldOwner == "" && v.NewOwner != "":
+			events = append(events, &AccountRegisteredEvent{
+				EventBase: base,
+				Account:   v.Account,
+				AccountId: v.AccountId,
+				Owner:     v.NewOwner,
+				Manager:   v.NewManager,
+				ExpiredAt: v.NewExpiredAt,
+			})
+			continue
+		case v.NewOwner == "":
+			continue
+		}
+		if v.OldExpiredAt > 0 && v.NewExpiredAt > v.OldExpiredAt {
+			events = append(events, &AccountRenewedEvent{
+				EventBase:    base,
+				Account:      v.Account,
+				AccountId:    v.AccountId,
+				OldExpiredAt: v.OldExpiredAt,
+				NewExpiredAt: v.NewExpiredAt,
+			})
+		}
+		if v.OwnerChanged {
+			events = append(events, &OwnerTransferredEvent{
+				EventBase: base,
+				Account:   v.Account,
+				AccountId: v.AccountId,
+				OldOwner:  v.OldOwner,
+				NewOwner:  v.NewOwner,
+			})
+		} else if v.ManagerChanged {
+			events = append(events, &ManagerChangedEvent{
+				EventBase:  base,
+				Account:    v.Account,
+				AccountId:  v.AccountId,
+				OldManager: v.OldManager,
+				NewManager: v.NewManager,
+			})
+		}
+		if len(v.RecordDiffs) > 0 {
+			events = append(events, &RecordsEditedEvent{
+				EventBase: base,
+				Account:   v.Account,
+				AccountId: v.AccountId,
+				Diffs:     v.RecordDiffs,
+			})
+		}
+	}
+
+	// dp
+	if len(explain.DP) > 0 {
+		events = append(events, &DPTransferredEvent{EventBase: base, Flows: explain.DP})
+	}
+
+	switch builder.Action {
+	case common.DasActionUpdateSubAccount:
+		list, err := decodeSubAccounts(dasCore, tx, base)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, list...)
+	case common.DasActionAccountCellUpgrade:
+		list, err := decodeDidCells(dasCore, tx, base)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, list...)
+	case common.DasActionUpdateReverseRecordRoot:
+		var records []*witness.ReverseSmtRecord
+		if err := witness.ParseFromTx(tx, common.ActionDataTypeReverseSmt, &records); err != nil {
+			return nil, fmt.Errorf("ParseFromTx err: %s", err.Error())
+		}
+		events = append(events, &ReverseRecordUpdatedEvent{EventBase: base, Records: records})
+	}
+	return events, nil
+}
+
+func decodeSubAccounts(dasCore *core.DasCore, tx *types.Transaction, base EventBase) ([]Event, error) {
+	var builder witness.SubAccountNewBuilder
+	subAccountMap, err := builder.SubAccountNewMapFromTx(tx)
+	if err != nil {
+		return nil, fmt.Errorf("SubAccountNewMapFromTx err: %s", err.Error())
+	}
+	list := make([]*witness.SubAccountNew, 0, len(subAccountMap))
+	for _, v := range subAccountMap {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Index < list[j].Index
+	})
+
+	events := make([]Event, 0, len(list))
+	for _, v := range list {
+		data := v.CurrentSubAccountData
+		if data == nil {
+			data = v.SubAccountData
+		}
+		if v.Action != common.SubActionCreate {
+			events = append(events, &SubAccountUpdatedEvent{
+				EventBase:  base,
+				SubAction:  v.Action,
+				Account:    data.Account(),
+				AccountId:  data.AccountId,
+				EditKey:    v.EditKey,
+				SubAccount: data,
+			})
+			continue
+		}
+		owner := ""
+		if data.Lock != nil {
+			owner = common.Bytes2Hex(data.Lock.Args)
+			if ownerNormal, _, err := dasCore.Daf().ArgsToNormal(data.Lock.Args); err == nil {
+				owner = ownerNormal.AddressNormal
+			}
+		}
+		events = append(events, &SubAccountCreatedEvent{
+			EventBase: base,
+			Account:   data.Account(),
+			AccountId: data.AccountId,
+			Owner:     owner,
+			ExpiredAt: data.ExpiredAt,
+		})
+	}
+	return events, nil
+}
+
+func decodeDidCells(dasCore *core.DasCore, tx *types.Transaction, base EventBase) ([]Event, error) {
+	_, didCellMap, err := dasCore.TxToDidCellEntityAndAction(tx)
+	if err != nil {
+		return nil, fmt.Errorf("TxToDidCellEntityAndAction err: %s", err.Error())
+	}
+	list := make([]core.DidCellInfo, 0, len(didCellMap.Outputs))
+	for _, v := range didCellMap.Outputs {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Index < list[j].Index
+	})
+
+	events := make([]Event, 0, len(list))
+	for _, v := range list {
+		_, data, err := v.GetDataInfo()
+		if err != nil {
+			return nil, fmt.Errorf("GetDataInfo err: %s", err.Error())
+		}
+		owner, err := v.GetLockAddress(dasCore.NetType())
+		if err != nil {
+			return nil, fmt.Errorf("GetLockAddress err: %s", err.Error())
+		}
+		events = append(events, &DidCellUpgradedEvent{
+			EventBase: base,
+			Account:   data.Account,
+			Owner:     owner,
+			ExpiredAt: data.ExpireAt,
+			OutPoint:  v.OutPoint,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+type EventType string
+
+const (
+	EventTypeAction               EventType = "action" // every das tx
+	EventTypeAccountRegistered    EventType = "account_registered"
+	EventTypeAccountRenewed       EventType = "account_renewed"
+	EventTypeRecordsEdited        EventType = "records_edited"
+	EventTypeOwnerTransferred     EventType = "owner_transferred"
+	EventTypeManagerChanged       EventType = "manager_changed"
+	EventTypeSubAccountCreated    EventType = "sub_account_created"
+	EventTypeSubAccountUpdated    EventType = "sub_account_updated"
+	EventTypeDPTransferred        EventType = "dp_transferred"
+	EventTypeDidCellUpgraded      EventType = "did_cell_upgraded"
+	EventTypeReverseRecordUpdated EventType = "reverse_record_updated"
+	EventTypeBlockRolledBack      EventType = "block_rolled_back" // the events of the block are reverted
+	EventTypeTxDecodeFailed       EventType = "tx_decode_failed"  // the tx is skipped unless the DecodeErrorHandler returns an error
+)
+
+type Event interface {
+	Type() EventType
+	Base() EventBase
+}
+
+// EventBase is the position of the event, TxHash is empty for EventTypeBlockRolledBack
+type EventBase struct {
+	BlockNumber uint64           `json:"block_number"`
+	BlockHash   string           `json:"block_hash"`
+	BlockTime   uint64           `json:"block_time"`
+	TxHash      string           `json:"tx_hash"`
+	TxIndex     int              `json:"tx_index"`
+	Action      common.DasAction `json:"action"`
+}
+
+func (e EventBase) Base() EventBase {
+	return e
+}
+
+type ActionEvent struct {
+	EventBase
+	Transaction *types.Transaction `json:"-"`
+	Explain     *core.TxExplain    `json:"explain"`
+}
+
+type AccountRegisteredEvent struct {
+	EventBase
+	Account   string `json:"account"`
+	AccountId string `json:"account_id"`
+	Owner     string `json:"owner"`
+	Manager   string `json:"manager"`
+	ExpiredAt uint64 `json:"expired_at"`
+}
+
+type AccountRenewedEvent struct {
+	EventBase
+	Account      string `json:"account"`
+	AccountId    string `json:"account_id"`
+	OldExpiredAt uint64 `json:"old_expired_at"`
+	NewExpiredAt uint64 `json:"new_expired_at"`
+}
+
+type RecordsEditedEvent struct {
+	EventBase
+	Account   string                     `json:"account"`
+	AccountId string                     `json:"account_id"`
+	Diffs     []core.TxExplainRecordDiff `json:"diffs"`
+}
+
+type OwnerTransferredEvent struct {
+	EventBase
+	Account   string `json:"account"`
+	AccountId string `json:"account_id"`
+	OldOwner  string `json:"old_owner"`
+	NewOwner  string `json:"new_owner"`
+}
+
+type ManagerChangedEvent struct {
+	EventBase
+	Account    string `json:"account"`
+	AccountId  string `json:"account_id"`
+	OldManager string `json:"old_manager"`
+	NewManager string `json:"new_manager"`
+}
+
+type SubAccountCreatedEvent struct {
+	EventBase
+	Account   string `json:"account"`
+	AccountId string `json:"account_id"`
+	Owner     string `json:"owner"`
+	ExpiredAt uint64 `json:"expired_at"`
+}
+
+// SubAccountUpdatedEvent is the sub-account actions except create, SubAction is edit, renew, recycle or the approval actions
+type SubAccountUpdatedEvent struct {
+	EventBase
+	SubAction  common.SubAction        `json:"sub_action"`
+	Account    string                  `json:"account"`
+	AccountId  string                  `json:"account_id"`
+	EditKey    common.EditKey          `json:"edit_key"`
+	SubAccount *witness.SubAccountData `json:"sub_account"` // the data after the action
+}
+
+type DPTransferredEvent struct {
+	EventBase
+	Flows []core.TxExplainDPFlow `json:"flows"`
+}
+
+type DidCellUpgradedEvent struct {
+	EventBase
+	Account   string          `json:"account"`
+	Owner     string          `json:"owner"`
+	ExpiredAt uint64          `json:"expired_at"`
+	OutPoint  *types.OutPoint `json:"out_point"`
+}
+
+type ReverseRecordUpdatedEvent struct {
+	EventBase
+	Records []*witness.ReverseSmtRecord `json:"records"`
+}
+
+type BlockRolledBackEvent struct {
+	EventBase
+}
+
+type TxDecodeFailedEvent struct {
+	EventBase
+	Transaction *types.Transaction `json:"-"`
+	Err         string             `json:"err"`
+}
+
+func (e *ActionEvent) Type() EventType               { return EventTypeAction }
+func (e *AccountRegisteredEvent) Type() EventType    { return EventTypeAccountRegistered }
+func (e *AccountRenewedEvent) Type() EventType       { return EventTypeAccountRenewed }
+func (e *RecordsEditedEvent) Type() EventType        { return EventTypeRecordsEdited }
+func (e *OwnerTransferredEvent) Type() EventType     { return EventTypeOwnerTransferred }
+func (e *ManagerChangedEvent) Type() EventType       { return EventTypeManagerChanged }
+func (e *SubAccountCreatedEvent) Type() EventType    { return EventTypeSubAccountCreated }
+func (e *SubAccountUpdatedEvent) Type() EventType    { return EventTypeSubAccountUpdated }
+func (e *DPTransferredEvent) Type() EventType        { return EventTypeDPTransferred }
+func (e *DidCellUpgradedEvent) Type() EventType      { return EventTypeDidCellUpgraded }
+func (e *ReverseRecordUpdatedEvent) Type() EventType { return EventTypeReverseRecordUpdated }
+func (e *BlockRolledBackEvent) Type() EventType      { return EventTypeBlockRolledBack }
+func (e *TxDecodeFailedEvent) Type() EventType       { return EventTypeTxDecodeFailed }
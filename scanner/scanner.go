@@ -0,0 +1,334 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/checkpoint"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"github.com/scorpiotzh/mylog"
+	"sync"
+	"time"
+)
+
+var log = logger.NewLogger("scanner", mylog.LevelDebug)
+
+// Handler receives the events, a block is delivered again if any handler of it returns an error,
+// so the handlers should be idempotent
+type Handler func(e Event) error
+
+// DecodeErrorHandler decides what to do with a tx failed to decode, after the TxDecodeFailed event,
+// nil skips the tx and an error stops the block to be delivered again
+type DecodeErrorHandler func(e *TxDecodeFailedEvent, err error) error
+
+type subscriber struct {
+	eventTypes map[EventType]struct{}
+	handler    Handler
+}
+
+// Scanner follows the blocks from the checkpoint (or the start block number) and publishes the events of the das txs.
+// A block is processed when it has Confirmations blocks after it, and a fork is detected by the parent hash
+// (and by the saved blocks after restart), the processed blocks of the old chain are rolled back
+// by BlockRolledBack events before the blocks of the new chain
+type Scanner struct {
+	ctx     context.Context
+	wg      *sync.WaitGroup
+	dasCore *core.DasCore
+	chain   core.ChainReader
+
+	startBlockNumber uint64
+	confirmations    uint64
+	reorgWindow      int
+	interval         time.Duration
+	checkpointStore  CheckpointStore
+	blockStore       checkpoint.Store
+	tracker          *checkpoint.Tracker
+	reconciled       bool
+	onDecodeError    DecodeErrorHandler
+
+	lock        sync.Mutex
+	subscribers []subscriber
+}
+
+type ScannerOption func(*Scanner)
+
+// WithStartBlockNumber is the first block to scan if there is no checkpoint
+func WithStartBlockNumber(blockNumber uint64) ScannerOption {
+	return func(s *Scanner) {
+		s.startBlockNumber = blockNumber
+	}
+}
+
+func WithConfirmations(confirmations uint64) ScannerOption {
+	return func(s *Scanner) {
+		s.confirmations = confirmations
+	}
+}
+
+// WithReorgWindow is the number of processed blocks kept for the rollback by the default store, 100 by default
+func WithReorgWindow(window int) ScannerOption {
+	return func(s *Scanner) {
+		if window > 0 {
+			s.reorgWindow = window
+		}
+	}
+}
+
+func WithInterval(interval time.Duration) ScannerOption {
+	return func(s *Scanner) {
+		s.interval = interval
+	}
+}
+
+// WithCheckpointStore persists only the latest processed block, the blocks before it in the reorg window
+// are kept in memory, so a fork deeper than the checkpoint while the scanner is stopped fails the scan
+func WithCheckpointStore(store CheckpointStore) ScannerOption {
+	return func(s *Scanner) {
+		s.checkpointStore = store
+	}
+}
+
+// WithBlockStore persists the processed blocks, like checkpoint.NewFileStore, to roll back the forks
+// after restart, it replaces WithCheckpointStore
+func WithBlockStore(store checkpoint.Store) ScannerOption {
+	return func(s *Scanner) {
+		s.blockStore = store
+	}
+}
+
+// WithDecodeErrorHandler is called for the txs failed to decode, they are skipped by default
+func WithDecodeErrorHandler(handler DecodeErrorHandler) ScannerOption {
+	return func(s *Scanner) {
+		s.onDecodeError = handler
+	}
+}
+
+func NewScanner(ctx context.Context, wg *sync.WaitGroup, dasCore *core.DasCore, opts ...ScannerOption) (*Scanner, error) {
+	if dasCore == nil {
+		return nil, fmt.Errorf("dasCore is nil")
+	}
+	s := Scanner{
+		ctx:         ctx,
+		wg:          wg,
+		dasCore:     dasCore,
+		chain:       dasCore.ChainReader(),
+		reorgWindow: 100,
+		interval:    time.Second * 5,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if s.blockStore == nil {
+		if s.checkpointStore == nil {
+			s.checkpointStore = &MemCheckpointStore{}
+		}
+		store, err := newCheckpointBlockStore(s.checkpointStore, s.reorgWindow)
+		if err != nil {
+			return nil, err
+		}
+		s.blockStore = store
+	}
+	s.tracker = checkpoint.NewTracker(s.blockStore, s.chain)
+	s.tracker.OnRollback(func(ancestor checkpoint.Block, removed []checkpoint.Block) error {
+		for _, v := range removed {
+			if err := s.publish(&BlockRolledBackEvent{EventBase{BlockNumber: v.Number, BlockHash: v.Hash}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return &s, nil
+}
+
+// Subscribe adds the handler of the event types, all the events if eventTypes is empty
+func (s *Scanner) Subscribe(handler Handler, eventTypes ...EventType) {
+	sub := subscriber{handler: handler}
+	if len(eventTypes) > 0 {
+		sub.eventTypes = make(map[EventType]struct{})
+		for _, v := range eventTypes {
+			sub.eventTypes[v] = struct{}{}
+		}
+	}
+	s.lock.Lock()
+	s.subscribers = append(s.subscribers, sub)
+	s.lock.Unlock()
+}
+
+// OnRollback adds a hook called after the BlockRolledBack events, like reloading the config cells by DasCore.RollbackDasConfig
+func (s *Scanner) OnRollback(fn checkpoint.RollbackFunc) {
+	s.tracker.OnRollback(fn)
+}
+
+// Checkpoint returns the last processed block, nil if no block is processed
+func (s *Scanner) Checkpoint() *Checkpoint {
+	cp, err := s.latest()
+	if err != nil {
+		log.Error("latest err:", err.Error())
+		return nil
+	}
+	if cp == nil {
+		return nil
+	}
+	return &Checkpoint{BlockNumber: cp.Number, BlockHash: cp.Hash}
+}
+
+func (s *Scanner) latest() (*checkpoint.Block, error) {
+	return s.blockStore.Latest()
+}
+
+func (s *Scanner) Run() {
+	ticker := time.NewTicker(s.interval)
+	s.wg.Add(1)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.ScanOnce(); err != nil {
+					log.Error("ScanOnce err:", err.Error())
+				}
+			case <-s.ctx.Done():
+				s.wg.Done()
+				return
+			}
+		}
+	}()
+}
+
+// ScanOnce processes the confirmed blocks after the checkpoint and returns the number of blocks processed
+func (s *Scanner) ScanOnce() (int, error) {
+	tip, err := s.chain.GetTipBlockNumber(s.ctx)
+	if err != nil {
+		return 0, fmt.Errorf("GetTipBlockNumber err: %s", err.Error())
+	}
+	if tip < s.confirmations {
+		return 0, nil
+	}
+	target := tip - s.confirmations
+
+	// the saved blocks may be forked while the scanner is stopped
+	if !s.reconciled {
+		if _, err := s.tracker.Reconcile(s.ctx); err != nil {
+			return 0, fmt.Errorf("Reconcile err: %s", err.Error())
+		}
+		s.reconciled = true
+	}
+
+	count := 0
+	for {
+		select {
+		case <-s.ctx.Done():
+			return count, nil
+		default:
+		}
+		cp, err := s.latest()
+		if err != nil {
+			return count, fmt.Errorf("Latest err: %s", err.Error())
+		}
+		next := s.startBlockNumber
+		if cp != nil {
+			next = cp.Number + 1
+		}
+		if next > target {
+			return count, nil
+		}
+		block, err := s.chain.GetBlockByNumber(s.ctx, next)
+		if err != nil {
+			return count, fmt.Errorf("GetBlockByNumber err: %s", err.Error())
+		}
+		if block == nil || block.Header == nil {
+			return count, fmt.Errorf("block %d not exist", next)
+		}
+		if cp != nil && block.Header.ParentHash.Hex() != cp.Hash {
+			log.Warn("fork at block:", next, block.Header.ParentHash.Hex(), cp.Hash)
+			ancestor, err := s.tracker.Reconcile(s.ctx)
+			if err != nil {
+				return count, fmt.Errorf("Reconcile err: %s", err.Error())
+			}
+			if ancestor != nil && ancestor.Number == cp.Number {
+				return count, fmt.Errorf("parent of block %d is not the checkpoint %s", next, cp.Hash)
+			}
+			continue
+		}
+		if err := s.processBlock(block); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func (s *Scanner) processBlock(block *types.Block) error {
+	base := EventBase{
+		BlockNumber: block.Header.Number,
+		BlockHash:   block.Header.Hash.Hex(),
+		BlockTime:   block.Header.Timestamp,
+	}
+	for i, tx := range block.Transactions {
+		base.TxIndex = i
+		base.TxHash = ""
+		if tx.Hash != (types.Hash{}) {
+			base.TxHash = tx.Hash.Hex()
+		}
+		events, err := DecodeTransaction(s.dasCore, tx, base)
+		if err != nil {
+			if err := s.decodeFailed(tx, base, err); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, e := range events {
+			if err := s.publish(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ok, err := s.tracker.Advance(block.Header); err != nil {
+		return fmt.Errorf("Advance err: %s", err.Error())
+	} else if !ok {
+		return fmt.Errorf("parent of block %d is not the checkpoint", base.BlockNumber)
+	}
+	return nil
+}
+
+func (s *Scanner) decodeFailed(tx *types.Transaction, base EventBase, err error) error {
+	log.Warn("DecodeTransaction err:", base.BlockNumber, base.TxIndex, err.Error())
+	if builder, e := witness.ActionDataBuilderFromTx(tx); e == nil {
+		base.Action = builder.Action
+	}
+	if base.TxHash == "" {
+		if txHash, e := tx.ComputeHash(); e == nil {
+			base.TxHash = txHash.Hex()
+		}
+	}
+	event := &TxDecodeFailedEvent{EventBase: base, Transaction: tx, Err: err.Error()}
+	if err := s.publish(event); err != nil {
+		return err
+	}
+	if s.onDecodeError == nil {
+		return nil
+	}
+	if err := s.onDecodeError(event, err); err != nil {
+		return fmt.Errorf("DecodeTransaction block %d tx %d err: %s", base.BlockNumber, base.TxIndex, err.Error())
+	}
+	return nil
+}
+
+func (s *Scanner) publish(e Event) error {
+	s.lock.Lock()
+	subscribers := s.subscribers
+	s.lock.Unlock()
+	for _, v := range subscribers {
+		if v.eventTypes != nil {
+			if _, ok := v.eventTypes[e.Type()]; !ok {
+				continue
+			}
+		}
+		if err := v.handler(e); err != nil {
+			return fmt.Errorf("handler of %s at block %d err: %s", e.Type(), e.Base().BlockNumber, err.Error())
+		}
+	}
+	return nil
+}
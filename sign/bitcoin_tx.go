@@ -0,0 +1,64 @@
+package sign
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/dotbitHQ/das-lib/common"
+)
+
+// BitcoinTxSignPayload is the SignRequest Payload of BitcoinTxSigner
+type BitcoinTxSignPayload struct {
+	Tx             *wire.MsgTx
+	UnspentOutputs []bitcoin.UnspentOutputs
+}
+
+// BitcoinTxSigner adapts the RemoteSignTx and LocalSignTx of bitcoin.TxTool to Signer,
+// the signature is the signed tx serialized without witness
+type BitcoinTxSigner struct {
+	tool   *bitcoin.TxTool
+	algId  common.DasAlgorithmId
+	method bitcoin.RemoteSignMethod // empty for LocalSignTx
+}
+
+// NewRemoteBitcoinTxSigner signs the txs by the method of the remote sign server
+func NewRemoteBitcoinTxSigner(tool *bitcoin.TxTool, algId common.DasAlgorithmId, method bitcoin.RemoteSignMethod) *BitcoinTxSigner {
+	return &BitcoinTxSigner{tool: tool, algId: algId, method: method}
+}
+
+// NewLocalBitcoinTxSigner signs the txs by the private keys of the UnspentOutputs
+func NewLocalBitcoinTxSigner(tool *bitcoin.TxTool, algId common.DasAlgorithmId) *BitcoinTxSigner {
+	return &BitcoinTxSigner{tool: tool, algId: algId}
+}
+
+func (s *BitcoinTxSigner) AlgorithmId() common.DasAlgorithmId {
+	return s.algId
+}
+
+func (s *BitcoinTxSigner) Sign(req *SignRequest) ([]byte, error) {
+	if s.tool == nil {
+		return nil, fmt.Errorf("tx tool is nil")
+	}
+	payload, ok := req.Payload.(*BitcoinTxSignPayload)
+	if !ok || payload == nil {
+		return nil, fmt.Errorf("payload is not a BitcoinTxSignPayload")
+	}
+	tx := payload.Tx
+	if s.method == "" {
+		if _, err := s.tool.LocalSignTx(tx, payload.UnspentOutputs); err != nil {
+			return nil, fmt.Errorf("LocalSignTx err: %s", err.Error())
+		}
+	} else {
+		signTx, err := s.tool.RemoteSignTx(s.method, tx, payload.UnspentOutputs)
+		if err != nil {
+			return nil, fmt.Errorf("RemoteSignTx err: %s", err.Error())
+		}
+		tx = signTx
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSizeStripped()))
+	if err := tx.SerializeNoWitness(buf); err != nil {
+		return nil, fmt.Errorf("SerializeNoWitness err: %s", err.Error())
+	}
+	return buf.Bytes(), nil
+}
@@ -151,16 +151,7 @@ func DoEIP712Sign(chainId int64, signMsg, private string, mmJsonObj *common.MMJs
 	log.Info("DoSign:", chainId, signMsg)
 	var signData []byte
 
-	var obj3 apitypes.TypedData
-	mmJson := mmJsonObj.String()
-	oldChainId := fmt.Sprintf("chainId\":%d", chainId)
-	newChainId := fmt.Sprintf("chainId\":\"%d\"", chainId)
-	mmJson = strings.ReplaceAll(mmJson, oldChainId, newChainId)
-	oldDigest := "\"digest\":\"\""
-	newDigest := fmt.Sprintf("\"digest\":\"%s\"", signMsg)
-	mmJson = strings.ReplaceAll(mmJson, oldDigest, newDigest)
-
-	_ = json.Unmarshal([]byte(mmJson), &obj3)
+	obj3 := EIP712TypedData(chainId, signMsg, mmJsonObj)
 	var mmHash, signature []byte
 	mmHash, signature, err := EIP712Signature(obj3, private)
 	if err != nil {
@@ -175,3 +166,18 @@ func DoEIP712Sign(chainId int64, signMsg, private string, mmJsonObj *common.MMJs
 
 	return common.Bytes2Hex(signData), nil
 }
+
+// EIP712TypedData fills the chain id and the digest of the tx into the typed data
+func EIP712TypedData(chainId int64, signMsg string, mmJsonObj *common.MMJsonObj) apitypes.TypedData {
+	var typedData apitypes.TypedData
+	mmJson := mmJsonObj.String()
+	oldChainId := fmt.Sprintf("chainId\":%d", chainId)
+	newChainId := fmt.Sprintf("chainId\":\"%d\"", chainId)
+	mmJson = strings.ReplaceAll(mmJson, oldChainId, newChainId)
+	oldDigest := "\"digest\":\"\""
+	newDigest := fmt.Sprintf("\"digest\":\"%s\"", signMsg)
+	mmJson = strings.ReplaceAll(mmJson, oldDigest, newDigest)
+
+	_ = json.Unmarshal([]byte(mmJson), &typedData)
+	return typedData
+}
@@ -27,7 +27,7 @@ func RemoteSign(c *Client, net common.DasNetType, args string) HandleSignCkbMess
 func GenerateAddressByArgs(net common.DasNetType, args string) (string, error) {
 	serverLock := common.GetNormalLockScript(args)
 	netMode := address.Testnet
-	if net == common.DasNetTypeMainNet {
+	if common.IsMainNet(net) {
 		netMode = address.Mainnet
 	}
 	return common.ConvertScriptToAddress(netMode, serverLock)
@@ -0,0 +1,325 @@
+package sign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"strings"
+	"sync"
+)
+
+// signatureSizes is the size of the signatures in the witness lock, webauthn is the size of the padded lock
+var signatureSizes = map[common.DasAlgorithmId]int{
+	common.DasAlgorithmIdCkb:       65,
+	common.DasAlgorithmIdCkbSingle: 65,
+	common.DasAlgorithmIdEth:       65,
+	common.DasAlgorithmIdTron:      65,
+	common.DasAlgorithmIdEth712:    65 + 32 + 8,
+	common.DasAlgorithmIdDogeChain: 66,
+	common.DasAlgorithmIdBitcoin:   67,
+	common.DasAlgorithmIdEd25519:   64,
+	common.DasAlgorithmIdWebauthn:  800,
+}
+
+// SignatureSize returns the size of the signature of the algorithm in the witness lock, 65 for the others,
+// the multisig is the multisig script followed by the signatures
+func SignatureSize(algId common.DasAlgorithmId) int {
+	if size, ok := signatureSizes[algId]; ok {
+		return size
+	}
+	return 65
+}
+
+// SignRequest is a group of inputs to sign, SignMsg is the digest of txbuilder.SignData
+type SignRequest struct {
+	SignType common.DasAlgorithmId
+	SignMsg  string
+	// BuildMMJson builds the typed data of the tx for DasAlgorithmIdEth712
+	BuildMMJson func(evmChainId int64) (*common.MMJsonObj, error)
+	// Payload is the data of the signers signing more than the digest, like BitcoinTxSignPayload
+	Payload interface{}
+}
+
+// Signer signs the digests of an algorithm, the signature is in the form of txbuilder.SignData.SignMsg
+type Signer interface {
+	AlgorithmId() common.DasAlgorithmId
+	Sign(req *SignRequest) ([]byte, error)
+}
+
+// LocalSigner signs by the private key in memory, the key is in hex, the 64-byte seed+public key for ed25519
+type LocalSigner struct {
+	algId      common.DasAlgorithmId
+	privateKey string
+	Compress   bool       // for doge and bitcoin, true by default
+	SegwitType SegwitType // for bitcoin, P2WPKH by default
+	EvmChainId int64      // for 712
+}
+
+func NewLocalSigner(algId common.DasAlgorithmId, hexPrivateKey string) (*LocalSigner, error) {
+	switch algId {
+	case common.DasAlgorithmIdCkb, common.DasAlgorithmIdCkbSingle, common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712,
+		common.DasAlgorithmIdTron, common.DasAlgorithmIdDogeChain, common.DasAlgorithmIdBitcoin, common.DasAlgorithmIdEd25519:
+	default:
+		return nil, fmt.Errorf("not support sign type[%d]", algId)
+	}
+	return &LocalSigner{
+		algId:      algId,
+		privateKey: strings.TrimPrefix(hexPrivateKey, common.HexPreFix),
+		Compress:   true,
+		SegwitType: P2WPKH,
+	}, nil
+}
+
+func (l *LocalSigner) AlgorithmId() common.DasAlgorithmId {
+	return l.algId
+}
+
+func (l *LocalSigner) Sign(req *SignRequest) ([]byte, error) {
+	switch l.algId {
+	case common.DasAlgorithmIdCkb, common.DasAlgorithmIdCkbSingle:
+		return LocalSign(l.privateKey)(req.SignMsg)
+	case common.DasAlgorithmIdEth:
+		return PersonalSignature([]byte(req.SignMsg), l.privateKey)
+	case common.DasAlgorithmIdEth712:
+		if req.BuildMMJson == nil {
+			return nil, fmt.Errorf("BuildMMJson is nil")
+		}
+		mmJson, err := req.BuildMMJson(l.EvmChainId)
+		if err != nil {
+			return nil, fmt.Errorf("BuildMMJson err: %s", err.Error())
+		}
+		sig, err := DoEIP712Sign(l.EvmChainId, req.SignMsg, l.privateKey, mmJson)
+		if err != nil {
+			return nil, fmt.Errorf("DoEIP712Sign err: %s", err.Error())
+		}
+		return common.Hex2Bytes(sig), nil
+	case common.DasAlgorithmIdTron:
+		return TronSignature(true, []byte(req.SignMsg), l.privateKey)
+	case common.DasAlgorithmIdDogeChain:
+		return DogeSignature([]byte(req.SignMsg), l.privateKey, l.Compress)
+	case common.DasAlgorithmIdBitcoin:
+		return BitcoinSignature([]byte(req.SignMsg), l.privateKey, l.Compress, l.SegwitType)
+	case common.DasAlgorithmIdEd25519:
+		return Ed25519Signature(common.Hex2Bytes(l.privateKey), common.Hex2Bytes(req.SignMsg)), nil
+	default:
+		return nil, fmt.Errorf("not support sign type[%d]", l.algId)
+	}
+}
+
+// HandleSigner adapts a HandleSignCkbMessage, like LocalSign, RemoteSign and RemoteSignNew
+type HandleSigner struct {
+	algId  common.DasAlgorithmId
+	handle HandleSignCkbMessage
+}
+
+func NewHandleSigner(algId common.DasAlgorithmId, handle HandleSignCkbMessage) *HandleSigner {
+	return &HandleSigner{algId: algId, handle: handle}
+}
+
+// NewRemoteV1Signer signs the ckb digests by the wallet_cKBSignMsg of the remote sign server
+func NewRemoteV1Signer(c *Client, addr string) *HandleSigner {
+	return NewHandleSigner(common.DasAlgorithmIdCkb, RemoteSignNew(c, addr))
+}
+
+func (h *HandleSigner) AlgorithmId() common.DasAlgorithmId {
+	return h.algId
+}
+
+func (h *HandleSigner) Sign(req *SignRequest) ([]byte, error) {
+	if h.handle == nil {
+		return nil, fmt.Errorf("sign func is nil")
+	}
+	return h.handle(req.SignMsg)
+}
+
+// WebauthnSigner signs like a webauthn device by the P-256 key, for the keys held by the server and tests,
+// the signature is in the format of sign.Verify
+type WebauthnSigner struct {
+	key      *ecdsa.PrivateKey
+	PkIndex  byte   // 255 for the key of the address by default, the index of the key list otherwise
+	Origin   string // origin of the client data
+	AuthData []byte // authenticator data, rp id hash + flags + counter, 37 zero bytes by default
+}
+
+func NewWebauthnSigner(key *ecdsa.PrivateKey) (*WebauthnSigner, error) {
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("key is not a P-256 key")
+	}
+	return &WebauthnSigner{key: key, PkIndex: webauthnOwnerKeyIndex, Origin: "https://d.id", AuthData: make([]byte, 37)}, nil
+}
+
+func (w *WebauthnSigner) AlgorithmId() common.DasAlgorithmId {
+	return common.DasAlgorithmIdWebauthn
+}
+
+func (w *WebauthnSigner) Sign(req *SignRequest) ([]byte, error) {
+	clientDataJson, err := json.Marshal(ClientDataJson{
+		Type:      "webauthn.get",
+		Challenge: base64.RawURLEncoding.EncodeToString([]byte(req.SignMsg)),
+		Origin:    w.Origin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	clientDataHash := sha256.Sum256(clientDataJson)
+	hash := sha256.Sum256(append(append([]byte{}, w.AuthData...), clientDataHash[:]...))
+	r, s, err := ecdsa.Sign(rand.Reader, w.key, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("ecdsa.Sign err: %s", err.Error())
+	}
+
+	// pk index | signature | public key | authenticator data with 1-byte length, client data json with 2-byte length
+	res := []byte{1, w.PkIndex, 64}
+	res = append(append(res, r.FillBytes(make([]byte, 32))...), s.FillBytes(make([]byte, 32))...)
+	res = append(res, 64)
+	res = append(append(res, w.key.X.FillBytes(make([]byte, 32))...), w.key.Y.FillBytes(make([]byte, 32))...)
+	res = append(append(res, byte(len(w.AuthData))), w.AuthData...)
+	clientDataLen := make([]byte, 2)
+	binary.LittleEndian.PutUint16(clientDataLen, uint16(len(clientDataJson)))
+	return append(append(res, clientDataLen...), clientDataJson...), nil
+}
+
+// MultisigConfig is the ckb multisig script, the first RequireFirstN keys must sign and Threshold keys sign in total
+type MultisigConfig struct {
+	RequireFirstN uint8
+	Threshold     uint8
+	PubKeyHashes  [][]byte // blake160 of the compressed public keys
+}
+
+// Script returns the multisig script: 0 | RequireFirstN | Threshold | len(PubKeyHashes) | PubKeyHashes
+func (c *MultisigConfig) Script() []byte {
+	res := []byte{0, c.RequireFirstN, c.Threshold, byte(len(c.PubKeyHashes))}
+	for _, v := range c.PubKeyHashes {
+		res = append(res, v...)
+	}
+	return res
+}
+
+// Args is the lock args of the multisig address
+func (c *MultisigConfig) Args() []byte {
+	return blake160(c.Script())
+}
+
+// MultisigSigner signs the digest of the ckb multisig lock by Threshold ckb signers of the keys,
+// the signature is the multisig script followed by the signatures, the same as txbuilder.GenerateMultiSignWitnessArgs
+type MultisigSigner struct {
+	config  MultisigConfig
+	signers []Signer
+}
+
+// NewMultisigSigner takes the signers of the keys in the order of signing, the first Threshold of them sign
+func NewMultisigSigner(config MultisigConfig, signers ...Signer) (*MultisigSigner, error) {
+	n := len(config.PubKeyHashes)
+	if n == 0 || n > 255 || config.Threshold == 0 || int(config.Threshold) > n || config.RequireFirstN > config.Threshold {
+		return nil, fmt.Errorf("invalid multisig config")
+	}
+	for _, v := range config.PubKeyHashes {
+		if len(v) != 20 {
+			return nil, fmt.Errorf("invalid pub key hash: %s", common.Bytes2Hex(v))
+		}
+	}
+	if len(signers) < int(config.Threshold) {
+		return nil, fmt.Errorf("signers[%d] less than threshold[%d]", len(signers), config.Threshold)
+	}
+	return &MultisigSigner{config: config, signers: signers[:config.Threshold]}, nil
+}
+
+func (m *MultisigSigner) AlgorithmId() common.DasAlgorithmId {
+	return common.DasAlgorithmIdCkbMulti
+}
+
+func (m *MultisigSigner) Config() MultisigConfig {
+	return m.config
+}
+
+// Sign signs req.SignMsg, the digest of the tx with the multisig script and the signature placeholders in the lock
+func (m *MultisigSigner) Sign(req *SignRequest) ([]byte, error) {
+	res := m.config.Script()
+	for i, v := range m.signers {
+		sig, err := v.Sign(&SignRequest{SignType: common.DasAlgorithmIdCkb, SignMsg: req.SignMsg})
+		if err != nil {
+			return nil, fmt.Errorf("sign by signer[%d] err: %s", i, err.Error())
+		}
+		if len(sig) != 65 {
+			return nil, fmt.Errorf("invalid signature length[%d] of signer[%d]", len(sig), i)
+		}
+		res = append(res, sig...)
+	}
+	return res, nil
+}
+
+// MockSigner returns Signature (zero bytes of the placeholder size by default) and records the requests, for tests
+type MockSigner struct {
+	algId     common.DasAlgorithmId
+	Signature []byte
+	Err       error
+
+	lock     sync.Mutex
+	requests []SignRequest
+}
+
+func NewMockSigner(algId common.DasAlgorithmId) *MockSigner {
+	return &MockSigner{algId: algId, Signature: make([]byte, SignatureSize(algId))}
+}
+
+func (m *MockSigner) AlgorithmId() common.DasAlgorithmId {
+	return m.algId
+}
+
+func (m *MockSigner) Sign(req *SignRequest) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.requests = append(m.requests, *req)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return append([]byte{}, m.Signature...), nil
+}
+
+func (m *MockSigner) Requests() []SignRequest {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]SignRequest{}, m.requests...)
+}
+
+// Signers dispatches the requests to the signer of the sign type
+type Signers struct {
+	rw      sync.RWMutex
+	signers map[common.DasAlgorithmId]Signer
+}
+
+func NewSigners(signers ...Signer) *Signers {
+	s := Signers{signers: make(map[common.DasAlgorithmId]Signer)}
+	for _, v := range signers {
+		s.Register(v)
+	}
+	return &s
+}
+
+// Register replaces the signer of the same algorithm
+func (s *Signers) Register(signer Signer) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	s.signers[signer.AlgorithmId()] = signer
+}
+
+func (s *Signers) Get(algId common.DasAlgorithmId) (Signer, bool) {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	signer, ok := s.signers[algId]
+	return signer, ok
+}
+
+func (s *Signers) Sign(req *SignRequest) ([]byte, error) {
+	signer, ok := s.Get(req.SignType)
+	if !ok {
+		return nil, fmt.Errorf("signer of sign type[%d] not exist", req.SignType)
+	}
+	return signer.Sign(req)
+}
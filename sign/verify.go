@@ -0,0 +1,310 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strings"
+)
+
+const webauthnOwnerKeyIndex = 255
+
+type verifyOption struct {
+	mmJson         *common.MMJsonObj
+	webauthnKeys   []string
+	hasWebauthnKey bool
+}
+
+type VerifyOption func(*verifyOption)
+
+// WithMMJson is the typed data of the tx for DasAlgorithmIdEth712, the chain id is read from the signature
+func WithMMJson(mmJson *common.MMJsonObj) VerifyOption {
+	return func(o *verifyOption) {
+		o.mmJson = mmJson
+	}
+}
+
+// WithWebauthnKeyList is the cid1+pk1 payloads of the key list cell of the address, in the order of core.GetIdxOfKeylist,
+// a signature by the key list is accepted only with this option
+func WithWebauthnKeyList(keys []string) VerifyOption {
+	return func(o *verifyOption) {
+		o.webauthnKeys = keys
+		o.hasWebauthnKey = true
+	}
+}
+
+// Verify verifies the signature of message by the owner of addressPayload, message is the txbuilder.SignData.SignMsg and
+// addressPayload is in the format of common.FormatAddressPayload. It returns the payload recovered from the signature,
+// addressPayload itself for ed25519 and the cid1+pk1 of the signing key for webauthn.
+// the signature of multisig is the multisig script followed by the signatures, the address payload is the blake160 of the script
+func Verify(algId common.DasAlgorithmId, subAlgId common.DasSubAlgorithmId, message string, signature []byte, addressPayload string, opts ...VerifyOption) (string, bool, error) {
+	var o verifyOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	sig := append([]byte{}, signature...)
+	var recovered []byte
+	switch algId {
+	case common.DasAlgorithmIdCkb, common.DasAlgorithmIdCkbSingle:
+		pub, err := recoverSecp256k1(common.Hex2Bytes(message), sig)
+		if err != nil {
+			return "", false, err
+		}
+		recovered = blake160(crypto.CompressPubkey(pub))
+	case common.DasAlgorithmIdCkbMulti:
+		payload, err := verifyMultisig(common.Hex2Bytes(message), sig)
+		if err != nil {
+			return "", false, err
+		}
+		recovered = payload
+	case common.DasAlgorithmIdEth:
+		pub, err := recoverSecp256k1(personalHash(common.EthMessageHeader, []byte(message)), sig)
+		if err != nil {
+			return "", false, err
+		}
+		recovered = crypto.PubkeyToAddress(*pub).Bytes()
+	case common.DasAlgorithmIdTron:
+		pub, err := recoverSecp256k1(personalHash(common.TronMessageHeader, []byte(message)), sig)
+		if err != nil {
+			return "", false, err
+		}
+		recovered = crypto.PubkeyToAddress(*pub).Bytes()
+	case common.DasAlgorithmIdEth712:
+		pub, err := recoverEIP712(message, sig, o.mmJson)
+		if err != nil {
+			return "", false, err
+		}
+		recovered = crypto.PubkeyToAddress(*pub).Bytes()
+	case common.DasAlgorithmIdEd25519:
+		if len(sig) != ed25519.SignatureSize {
+			return "", false, fmt.Errorf("invalid signature length[%d]", len(sig))
+		}
+		publicKey := common.Hex2Bytes(addressPayload)
+		if len(publicKey) != ed25519.PublicKeySize {
+			return "", false, fmt.Errorf("invalid address payload: %s", addressPayload)
+		}
+		if !VerifyEd25519Signature(publicKey, common.Hex2Bytes(message), sig) {
+			return "", false, nil
+		}
+		recovered = publicKey
+	case common.DasAlgorithmIdDogeChain:
+		hash, err := magicHash([]byte(message))
+		if err != nil {
+			return "", false, fmt.Errorf("magicHash err: %s", err.Error())
+		}
+		if len(sig) != 66 {
+			return "", false, fmt.Errorf("invalid signature length[%d]", len(sig))
+		}
+		if recovered, err = recoverHash160(hash, sig[:65], sig[65] == 1); err != nil {
+			return "", false, err
+		}
+	case common.DasAlgorithmIdBitcoin:
+		hash, err := magicHashBTC([]byte(message))
+		if err != nil {
+			return "", false, fmt.Errorf("magicHashBTC err: %s", err.Error())
+		}
+		if len(sig) != 67 {
+			return "", false, fmt.Errorf("invalid signature length[%d]", len(sig))
+		}
+		compress, segwitType := sig[65] == 1, SegwitType(sig[66])
+		switch {
+		case segwitType == P2WPKH && compress && (subAlgId == 0 || subAlgId == common.DasSubAlgorithmIdBitcoinP2WPKH):
+		case segwitType == P2PKH && (subAlgId == 0 || subAlgId == common.DasSubAlgorithmIdBitcoinP2PKH):
+		default:
+			return "", false, fmt.Errorf("unsupport segwitType[%d] compress[%d] of sub algorithm[%d]", sig[66], sig[65], subAlgId)
+		}
+		if recovered, err = recoverHash160(hash, sig[:65], compress); err != nil {
+			return "", false, err
+		}
+	case common.DasAlgorithmIdWebauthn:
+		if subAlgId != common.DasWebauthnSubAlgorithmIdES256 {
+			return "", false, fmt.Errorf("not support sub algorithm[%d] of webauthn", subAlgId)
+		}
+		identity, err := verifyWebauthn(message, sig, addressPayload, &o)
+		if err != nil || identity == "" {
+			return identity, false, err
+		}
+		return identity, true, nil
+	default:
+		return "", false, fmt.Errorf("not support sign type[%d]", algId)
+	}
+
+	identity := common.FormatAddressPayload(recovered, algId)
+	return identity, strings.EqualFold(identity, addressPayload), nil
+}
+
+func personalHash(header string, data []byte) []byte {
+	return crypto.Keccak256(append([]byte(fmt.Sprintf(header, len(data))), data...))
+}
+
+func blake160(data []byte) []byte {
+	return common.Blake2b(data)[:20]
+}
+
+// recoverSecp256k1 accepts the recovery id of 0/1 and 27/28
+func recoverSecp256k1(hash, sig []byte) (*ecdsa.PublicKey, error) {
+	if len(hash) != 32 || len(sig) != 65 {
+		return nil, fmt.Errorf("invalid hash length[%d] or signature length[%d]", len(hash), len(sig))
+	}
+	sig = append([]byte{}, sig...)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return nil, fmt.Errorf("crypto.SigToPub err: %s", err.Error())
+	}
+	return pub, nil
+}
+
+func recoverHash160(hash, sig []byte, compress bool) ([]byte, error) {
+	pub, err := recoverSecp256k1(hash, sig)
+	if err != nil {
+		return nil, err
+	}
+	if compress {
+		return btcutil.Hash160(crypto.CompressPubkey(pub)), nil
+	}
+	return btcutil.Hash160(crypto.FromECDSAPub(pub)), nil
+}
+
+// recoverEIP712 checks the typed data hash in the signature of DoEIP712Sign: signature(65) + hash(32) + chain id(8)
+func recoverEIP712(message string, sig []byte, mmJson *common.MMJsonObj) (*ecdsa.PublicKey, error) {
+	if mmJson == nil {
+		return nil, fmt.Errorf("MMJson is nil")
+	}
+	if len(sig) != 65+32+8 {
+		return nil, fmt.Errorf("invalid signature length[%d]", len(sig))
+	}
+	chainId := int64(binary.BigEndian.Uint64(sig[97:]))
+	typedData := EIP712TypedData(chainId, message, mmJson)
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, fmt.Errorf("HashStruct err: %s", err.Error())
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, fmt.Errorf("HashStruct err: %s", err.Error())
+	}
+	hash := crypto.Keccak256([]byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash))))
+	if !bytes.Equal(hash, sig[65:97]) {
+		return nil, fmt.Errorf("typed data hash mismatch: %s", common.Bytes2Hex(hash))
+	}
+	return recoverSecp256k1(hash, sig[:65])
+}
+
+// verifyMultisig verifies the signature of secp256k1_blake160_multisig_all: S | R | M | N | blake160(pubkey) * N | signature * M
+func verifyMultisig(hash, sig []byte) ([]byte, error) {
+	if len(sig) < 4 || sig[0] != 0 {
+		return nil, fmt.Errorf("invalid multisig script")
+	}
+	r, m, n := int(sig[1]), int(sig[2]), int(sig[3])
+	scriptLen := 4 + 20*n
+	if n == 0 || m == 0 || m > n || r > m || len(sig) != scriptLen+65*m {
+		return nil, fmt.Errorf("invalid multisig script or signature length[%d]", len(sig))
+	}
+	script := sig[:scriptLen]
+	signed := make(map[int]bool)
+	for i := 0; i < m; i++ {
+		pub, err := recoverSecp256k1(hash, sig[scriptLen+65*i:scriptLen+65*(i+1)])
+		if err != nil {
+			return nil, err
+		}
+		pubHash := blake160(crypto.CompressPubkey(pub))
+		idx := -1
+		for j := 0; j < n; j++ {
+			if !signed[j] && bytes.Equal(pubHash, script[4+20*j:4+20*(j+1)]) {
+				idx = j
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, fmt.Errorf("signature[%d] is not signed by the multisig keys", i)
+		}
+		signed[idx] = true
+	}
+	for j := 0; j < r; j++ {
+		if !signed[j] {
+			return nil, fmt.Errorf("key[%d] of the first %d keys not signed", j, r)
+		}
+	}
+	return blake160(script), nil
+}
+
+// verifyWebauthn parses the signature of the webauthn devices:
+// pk index | signature | public key | authenticator data, with 1-byte length, and client data json with 2-byte length,
+// the pk index is 255 for the key of the address, the index of the key list otherwise
+func verifyWebauthn(message string, data []byte, addressPayload string, o *verifyOption) (string, error) {
+	var fields [][]byte
+	index := 0
+	for i := 0; i < 5; i++ {
+		lenSize := 1
+		if i == 4 {
+			lenSize = 2
+		}
+		if index+lenSize > len(data) {
+			return "", fmt.Errorf("invalid webauthn signature")
+		}
+		dataLen := int(data[index])
+		if lenSize == 2 {
+			dataLen = int(binary.LittleEndian.Uint16(data[index : index+2]))
+		}
+		index += lenSize
+		if index+dataLen > len(data) {
+			return "", fmt.Errorf("invalid webauthn signature")
+		}
+		fields = append(fields, data[index:index+dataLen])
+		index += dataLen
+	}
+	pkIndex, signature, pubKeyBytes, authenticatorData, clientDataJson := fields[0], fields[1], fields[2], fields[3], fields[4]
+	if len(pkIndex) != 1 || len(signature) != 64 || len(pubKeyBytes) != 64 {
+		return "", fmt.Errorf("invalid webauthn signature")
+	}
+
+	// the challenge is the base64url of message
+	var clientData ClientDataJson
+	if err := json.Unmarshal(clientDataJson, &clientData); err != nil {
+		return "", fmt.Errorf("json.Unmarshal err: %s", err.Error())
+	}
+	if clientData.Challenge != base64.RawURLEncoding.EncodeToString([]byte(message)) {
+		return "", fmt.Errorf("challenge mismatch: %s", clientData.Challenge)
+	}
+	clientDataHash := sha256.Sum256(clientDataJson)
+	hash := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	pubKey := ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pubKeyBytes[:32]),
+		Y:     new(big.Int).SetBytes(pubKeyBytes[32:]),
+	}
+	if !ecdsa.Verify(&pubKey, hash[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return "", nil
+	}
+
+	// cid1(10) + pk1(10)
+	pk1 := hex.EncodeToString(common.CalculatePk1(&pubKey))
+	var keyPayload string
+	if pkIndex[0] == webauthnOwnerKeyIndex {
+		keyPayload = strings.TrimPrefix(addressPayload, common.HexPreFix)
+	} else if !o.hasWebauthnKey {
+		return "", fmt.Errorf("key list is required for pk index[%d]", pkIndex[0])
+	} else if int(pkIndex[0]) < len(o.webauthnKeys) {
+		keyPayload = strings.TrimPrefix(o.webauthnKeys[pkIndex[0]], common.HexPreFix)
+	} else {
+		return "", fmt.Errorf("pk index[%d] out of the key list", pkIndex[0])
+	}
+	if len(keyPayload) != 40 || !strings.EqualFold(keyPayload[20:], pk1) {
+		return "", nil
+	}
+	return strings.ToLower(keyPayload), nil
+}
@@ -76,8 +76,9 @@ func (h *H256) GetBit(height byte) bool {
 	return (((*h)[bytePos] >> bitPos) & 1) != 0
 }
 
+// ForkHeight returns the height where the paths of the two keys join
 func (h *H256) ForkHeight(key *H256) byte {
-	for i := 0; i <= MaxU8; i++ {
+	for i := MaxU8; i >= 0; i-- {
 		height := byte(i)
 		if h.GetBit(height) != key.GetBit(height) {
 			return height
@@ -86,15 +87,23 @@ func (h *H256) ForkHeight(key *H256) byte {
 	return 0
 }
 
+// Compare compares bits from higher to lower (255..0), the same order as the leaves in the tree
+func (h *H256) Compare(key *H256) int {
+	for i := len(*h) - 1; i >= 0; i-- {
+		if (*h)[i] < (*key)[i] {
+			return -1
+		} else if (*h)[i] > (*key)[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
 // qsort
 type SortH256 []H256
 
 func (s SortH256) Len() int { return len(s) }
 func (s SortH256) Less(i, j int) bool {
-	if res := bytes.Compare(s[i], s[j]); res > 0 {
-		return false
-	} else {
-		return true
-	}
+	return s[i].Compare(&s[j]) < 0
 }
 func (s SortH256) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
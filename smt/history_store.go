@@ -0,0 +1,505 @@
+package smt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrRootNotInHistory  = errors.New("root not in history")
+	ErrSnapshotNotExist  = errors.New("snapshot not exist")
+	ErrHistoryIncomplete = errors.New("history incomplete")
+)
+
+type historyChange struct {
+	key  BranchKey
+	prev *BranchNode // nil if the branch did not exist
+}
+
+// historyEntry is the undo log of one UpdateRoot
+type historyEntry struct {
+	seq      uint64
+	prevRoot H256
+	root     H256
+	changes  []historyChange
+	touched  map[string]int // index of changes
+}
+
+// HistoryPersister is implemented by the stores persisting the undo log of HistoryStore,
+// the history written in Batch is committed together with the branches and the root
+type HistoryPersister interface {
+	PutHistory(key string, value []byte) error // nil value removes the key
+	LoadHistory() (map[string][]byte, error)
+}
+
+const (
+	historyMetaKey     = "meta"
+	historyEntryPrefix = "e/"
+)
+
+type historyLogChange struct {
+	Height  byte        `json:"h"`
+	NodeKey H256        `json:"k"`
+	Prev    *BranchNode `json:"p,omitempty"`
+}
+
+type historyLog struct {
+	Seq      uint64             `json:"seq"`
+	PrevRoot H256               `json:"prev_root"`
+	Root     H256               `json:"root"`
+	Changes  []historyLogChange `json:"changes"`
+}
+
+type historyMeta struct {
+	Seq       uint64            `json:"seq"`
+	Snapshots map[string]uint64 `json:"snapshots"`
+}
+
+func historyEntryKey(seq uint64) string {
+	return fmt.Sprintf("%s%016x", historyEntryPrefix, seq)
+}
+
+// HistoryStore wraps a Store and keeps the undo log of the last maxHistory root updates,
+// which makes snapshots, rolling back to a previous root and reading leaves of a past root possible.
+// The undo log and the snapshots are persisted if base is a HistoryPersister like LevelDbStore,
+// otherwise they are kept in memory only and lost on restart
+type HistoryStore struct {
+	rw         sync.RWMutex
+	base       Store
+	persister  HistoryPersister
+	maxHistory int
+	entries    []*historyEntry
+	current    *historyEntry
+	seq        uint64
+	snapshots  map[string]uint64
+	inBatch    bool
+}
+
+// NewHistoryStore maxHistory <= 0 means no limit, the persisted history of base is loaded
+func NewHistoryStore(base Store, maxHistory int) (*HistoryStore, error) {
+	if base == nil {
+		base = newDefaultStore()
+	}
+	h := HistoryStore{
+		base:       base,
+		maxHistory: maxHistory,
+		snapshots:  make(map[string]uint64),
+	}
+	if persister, ok := base.(HistoryPersister); ok {
+		h.persister = persister
+		if err := h.load(); err != nil {
+			return nil, fmt.Errorf("load history err: %s", err.Error())
+		}
+	}
+	return &h, nil
+}
+
+func (h *HistoryStore) load() error {
+	values, err := h.persister.LoadHistory()
+	if err != nil {
+		return fmt.Errorf("LoadHistory err: %s", err.Error())
+	}
+	if value, ok := values[historyMetaKey]; ok {
+		var meta historyMeta
+		if err := json.Unmarshal(value, &meta); err != nil {
+			return fmt.Errorf("json.Unmarshal meta err: %s", err.Error())
+		}
+		h.seq = meta.Seq
+		if meta.Snapshots != nil {
+			h.snapshots = meta.Snapshots
+		}
+	}
+	var keys []string
+	for k := range values {
+		if strings.HasPrefix(k, historyEntryPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var log historyLog
+		if err := json.Unmarshal(values[k], &log); err != nil {
+			return fmt.Errorf("json.Unmarshal %s err: %s", k, err.Error())
+		}
+		entry := historyEntry{seq: log.Seq, prevRoot: log.PrevRoot, root: log.Root, touched: make(map[string]int)}
+		for _, v := range log.Changes {
+			key := BranchKey{Height: v.Height, NodeKey: v.NodeKey}
+			entry.touched[key.GetHash()] = len(entry.changes)
+			entry.changes = append(entry.changes, historyChange{key: key, prev: v.Prev})
+		}
+		h.entries = append(h.entries, &entry)
+	}
+	if len(h.entries) > 0 {
+		root, err := h.base.Root()
+		if err != nil {
+			return fmt.Errorf("Root err: %s", err.Error())
+		}
+		if last := h.entries[len(h.entries)-1]; !bytes.Equal(last.root, root) || last.seq != h.seq {
+			return fmt.Errorf("%w: the last root of history is not the root of the store", ErrHistoryIncomplete)
+		}
+	}
+	return nil
+}
+
+// saveEntries persists the entries and the meta, the entries of seq in removed are deleted
+func (h *HistoryStore) saveEntries(entries []*historyEntry, removed []*historyEntry) error {
+	if h.persister == nil {
+		return nil
+	}
+	for _, entry := range entries {
+		log := historyLog{Seq: entry.seq, PrevRoot: entry.prevRoot, Root: entry.root}
+		for _, v := range entry.changes {
+			log.Changes = append(log.Changes, historyLogChange{Height: v.key.Height, NodeKey: v.key.NodeKey, Prev: v.prev})
+		}
+		value, err := json.Marshal(&log)
+		if err != nil {
+			return fmt.Errorf("json.Marshal err: %s", err.Error())
+		}
+		if err = h.persister.PutHistory(historyEntryKey(entry.seq), value); err != nil {
+			return fmt.Errorf("PutHistory err: %s", err.Error())
+		}
+	}
+	for _, entry := range removed {
+		if err := h.persister.PutHistory(historyEntryKey(entry.seq), nil); err != nil {
+			return fmt.Errorf("PutHistory err: %s", err.Error())
+		}
+	}
+	return h.saveMeta()
+}
+
+func (h *HistoryStore) saveMeta() error {
+	if h.persister == nil {
+		return nil
+	}
+	value, err := json.Marshal(&historyMeta{Seq: h.seq, Snapshots: h.snapshots})
+	if err != nil {
+		return fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	if err = h.persister.PutHistory(historyMetaKey, value); err != nil {
+		return fmt.Errorf("PutHistory err: %s", err.Error())
+	}
+	return nil
+}
+
+func (h *HistoryStore) GetBranch(key BranchKey) (*BranchNode, error) {
+	return h.base.GetBranch(key)
+}
+
+func (h *HistoryStore) record(key BranchKey) error {
+	if h.current == nil {
+		h.current = &historyEntry{touched: make(map[string]int)}
+	}
+	keyHash := key.GetHash()
+	if _, ok := h.current.touched[keyHash]; ok {
+		return nil
+	}
+	prev, err := h.base.GetBranch(key)
+	if err != nil && err != StoreErrorNotExist {
+		return fmt.Errorf("GetBranch err: %s", err.Error())
+	}
+	if prev != nil {
+		prev = &BranchNode{Left: prev.Left, Right: prev.Right}
+	}
+	h.current.touched[keyHash] = len(h.current.changes)
+	h.current.changes = append(h.current.changes, historyChange{key: key, prev: prev})
+	return nil
+}
+
+func (h *HistoryStore) InsertBranch(key BranchKey, node BranchNode) error {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+	if err := h.record(key); err != nil {
+		return err
+	}
+	return h.base.InsertBranch(key, node)
+}
+
+func (h *HistoryStore) RemoveBranch(key BranchKey) error {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+	if err := h.record(key); err != nil {
+		return err
+	}
+	return h.base.RemoveBranch(key)
+}
+
+func (h *HistoryStore) UpdateRoot(root H256) error {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+	prevRoot, err := h.base.Root()
+	if err != nil {
+		return fmt.Errorf("Root err: %s", err.Error())
+	}
+	entry := h.current
+	if entry == nil {
+		entry = &historyEntry{touched: make(map[string]int)}
+	}
+	entry.seq = h.seq + 1
+	entry.prevRoot = append(H256{}, prevRoot...)
+	entry.root = append(H256{}, root...)
+	entries := append(h.entries, entry)
+	var removed []*historyEntry
+	if h.maxHistory > 0 && len(entries) > h.maxHistory {
+		removed = entries[:len(entries)-h.maxHistory]
+		entries = entries[len(entries)-h.maxHistory:]
+	}
+
+	update := func() error {
+		if err := h.base.UpdateRoot(root); err != nil {
+			return err
+		}
+		h.seq++
+		if err := h.saveEntries([]*historyEntry{entry}, removed); err != nil {
+			h.seq--
+			return err
+		}
+		return nil
+	}
+	if h.inBatch || h.persister == nil {
+		err = update()
+	} else {
+		err = h.baseBatch(update)
+	}
+	if err != nil {
+		return err
+	}
+	h.current = nil
+	h.entries = entries
+	return nil
+}
+
+func (h *HistoryStore) Root() (H256, error) {
+	return h.base.Root()
+}
+
+// Batch passes through to the base store if it supports batch
+func (h *HistoryStore) Batch(fn func() error) error {
+	h.rw.Lock()
+	if h.inBatch {
+		h.rw.Unlock()
+		return fmt.Errorf("already in batch")
+	}
+	h.inBatch = true
+	h.rw.Unlock()
+	defer func() {
+		h.rw.Lock()
+		h.inBatch = false
+		h.rw.Unlock()
+	}()
+	return h.baseBatch(fn)
+}
+
+func (h *HistoryStore) baseBatch(fn func() error) error {
+	if bs, ok := h.base.(interface{ Batch(fn func() error) error }); ok {
+		return bs.Batch(fn)
+	}
+	return fn()
+}
+
+// Roots returns the roots kept in history, from old to new, the last one is the current root
+func (h *HistoryStore) Roots() []H256 {
+	h.rw.RLock()
+	defer h.rw.RUnlock()
+	var roots []H256
+	if len(h.entries) > 0 {
+		roots = append(roots, h.entries[0].prevRoot)
+	}
+	for _, v := range h.entries {
+		roots = append(roots, v.root)
+	}
+	return roots
+}
+
+// Snapshot names the current root, a later snapshot with the same name replaces it
+func (h *HistoryStore) Snapshot(name string) error {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+	prev, ok := h.snapshots[name]
+	h.snapshots[name] = h.seq
+	if err := h.saveMeta(); err != nil {
+		if ok {
+			h.snapshots[name] = prev
+		} else {
+			delete(h.snapshots, name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *HistoryStore) DeleteSnapshot(name string) error {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+	prev, ok := h.snapshots[name]
+	if !ok {
+		return nil
+	}
+	delete(h.snapshots, name)
+	if err := h.saveMeta(); err != nil {
+		h.snapshots[name] = prev
+		return err
+	}
+	return nil
+}
+
+// SnapshotRoot returns the root of the snapshot
+func (h *HistoryStore) SnapshotRoot(name string) (H256, error) {
+	h.rw.RLock()
+	defer h.rw.RUnlock()
+	seq, ok := h.snapshots[name]
+	if !ok {
+		return nil, ErrSnapshotNotExist
+	}
+	index, err := h.indexOfSeq(seq)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.entries) == 0 {
+		return h.base.Root()
+	} else if index == len(h.entries) {
+		return h.entries[index-1].root, nil
+	}
+	return h.entries[index].prevRoot, nil
+}
+
+// indexOfSeq returns the number of entries applied when the seq was reached
+func (h *HistoryStore) indexOfSeq(seq uint64) (int, error) {
+	if seq == h.seq {
+		return len(h.entries), nil
+	}
+	for i, v := range h.entries {
+		if v.seq == seq+1 {
+			return i, nil
+		}
+	}
+	return 0, ErrHistoryIncomplete
+}
+
+// indexOfRoot returns the newest state with root
+func (h *HistoryStore) indexOfRoot(root H256) (int, error) {
+	for i := len(h.entries); i > 0; i-- {
+		if bytes.Equal(h.entries[i-1].root, root) {
+			return i, nil
+		}
+	}
+	if len(h.entries) > 0 && bytes.Equal(h.entries[0].prevRoot, root) {
+		return 0, nil
+	}
+	if len(h.entries) == 0 {
+		current, err := h.base.Root()
+		if err != nil {
+			return 0, fmt.Errorf("Root err: %s", err.Error())
+		}
+		if bytes.Equal(current, root) {
+			return 0, nil
+		}
+	}
+	return 0, ErrRootNotInHistory
+}
+
+// RollbackToSnapshot rolls the store back to the root of the snapshot
+func (h *HistoryStore) RollbackToSnapshot(name string) error {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+	seq, ok := h.snapshots[name]
+	if !ok {
+		return ErrSnapshotNotExist
+	}
+	index, err := h.indexOfSeq(seq)
+	if err != nil {
+		return err
+	}
+	return h.rollback(index)
+}
+
+// RollbackToRoot rolls the store back to the newest state in history whose root is root
+func (h *HistoryStore) RollbackToRoot(root H256) error {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+	index, err := h.indexOfRoot(root)
+	if err != nil {
+		return err
+	}
+	return h.rollback(index)
+}
+
+func (h *HistoryStore) rollback(index int) error {
+	if h.current != nil {
+		return fmt.Errorf("there are branches not committed by UpdateRoot")
+	}
+	if index >= len(h.entries) {
+		return nil
+	}
+	seq := h.entries[index].seq - 1
+	snapshots := make(map[string]uint64, len(h.snapshots))
+	for name, v := range h.snapshots {
+		if v <= seq {
+			snapshots[name] = v
+		}
+	}
+	prevSeq, prevSnapshots := h.seq, h.snapshots
+	undo := func() error {
+		for i := len(h.entries) - 1; i >= index; i-- {
+			entry := h.entries[i]
+			for j := len(entry.changes) - 1; j >= 0; j-- {
+				change := entry.changes[j]
+				if change.prev == nil {
+					if err := h.base.RemoveBranch(change.key); err != nil {
+						return fmt.Errorf("RemoveBranch err: %s", err.Error())
+					}
+				} else if err := h.base.InsertBranch(change.key, *change.prev); err != nil {
+					return fmt.Errorf("InsertBranch err: %s", err.Error())
+				}
+			}
+		}
+		if err := h.base.UpdateRoot(h.entries[index].prevRoot); err != nil {
+			return fmt.Errorf("UpdateRoot err: %s", err.Error())
+		}
+		h.seq, h.snapshots = seq, snapshots
+		return h.saveEntries(nil, h.entries[index:])
+	}
+	var err error
+	if h.inBatch {
+		err = undo()
+	} else {
+		err = h.baseBatch(undo)
+	}
+	if err != nil {
+		h.seq, h.snapshots = prevSeq, prevSnapshots
+		return err
+	}
+	h.entries = h.entries[:index]
+	return nil
+}
+
+// GetLeafAt returns the value of key when the root of the tree was root
+func (h *HistoryStore) GetLeafAt(root, key H256) (H256, error) {
+	h.rw.RLock()
+	defer h.rw.RUnlock()
+	index, err := h.indexOfRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	return getLeaf(func(branchKey BranchKey) (*BranchNode, error) {
+		keyHash := branchKey.GetHash()
+		// the first change after index holds the branch of that time
+		entries := h.entries[index:]
+		if h.current != nil {
+			entries = append(entries[:len(entries):len(entries)], h.current)
+		}
+		for _, entry := range entries {
+			if i, ok := entry.touched[keyHash]; ok {
+				if entry.changes[i].prev == nil {
+					return nil, StoreErrorNotExist
+				}
+				return entry.changes[i].prev, nil
+			}
+		}
+		return h.base.GetBranch(branchKey)
+	}, key)
+}
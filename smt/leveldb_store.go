@@ -0,0 +1,260 @@
+package smt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"sync"
+)
+
+// LevelDbStore keeps the branches of one smt in an embedded leveldb, several smt can share one db by smtName.
+// Branch writes are buffered and written together with the root in one synced batch,
+// so the persisted branches always match the persisted root even if the process crashes during an update
+type LevelDbStore struct {
+	rw      sync.RWMutex
+	db      *leveldb.DB
+	smtName string
+	batch   *leveldb.Batch
+	pending map[string]*BranchNode // nil value for removed branch
+	root    H256
+	inBatch bool
+}
+
+func NewLevelDbStore(db *leveldb.DB, smtName string) (*LevelDbStore, error) {
+	l := LevelDbStore{
+		db:      db,
+		smtName: smtName,
+		batch:   new(leveldb.Batch),
+		pending: make(map[string]*BranchNode),
+	}
+	root, err := l.loadRoot()
+	if err != nil {
+		return nil, fmt.Errorf("loadRoot err: %s", err.Error())
+	}
+	l.root = root
+	return &l, nil
+}
+
+// OpenLevelDbStore opens or creates the db at path, the caller should Close the store when done
+func OpenLevelDbStore(path, smtName string) (*LevelDbStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("leveldb.OpenFile err: %s", err.Error())
+	}
+	l, err := NewLevelDbStore(db, smtName)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *LevelDbStore) Close() error {
+	return l.db.Close()
+}
+
+func (l *LevelDbStore) rootKey() []byte {
+	return []byte(l.smtName + "/root")
+}
+
+func (l *LevelDbStore) branchPrefix() []byte {
+	return []byte(l.smtName + "/b/")
+}
+
+func (l *LevelDbStore) branchKey(keyHash string) []byte {
+	return append(l.branchPrefix(), keyHash...)
+}
+
+func (l *LevelDbStore) historyPrefix() []byte {
+	return []byte(l.smtName + "/h/")
+}
+
+// PutHistory is written with the branches and the root, see HistoryStore
+func (l *LevelDbStore) PutHistory(key string, value []byte) error {
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	if value == nil {
+		l.batch.Delete(append(l.historyPrefix(), key...))
+	} else {
+		l.batch.Put(append(l.historyPrefix(), key...), value)
+	}
+	if l.inBatch {
+		return nil
+	}
+	return l.flush()
+}
+
+func (l *LevelDbStore) LoadHistory() (map[string][]byte, error) {
+	l.rw.RLock()
+	defer l.rw.RUnlock()
+	res := make(map[string][]byte)
+	prefix := l.historyPrefix()
+	iter := l.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		res[string(iter.Key()[len(prefix):])] = append([]byte{}, iter.Value()...)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator err: %s", err.Error())
+	}
+	return res, nil
+}
+
+func (l *LevelDbStore) loadRoot() (H256, error) {
+	value, err := l.db.Get(l.rootKey(), nil)
+	if err == leveldb.ErrNotFound {
+		return H256Zero(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (l *LevelDbStore) UpdateRoot(root H256) error {
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	l.root = append(H256{}, root...)
+	l.batch.Put(l.rootKey(), l.root)
+	if l.inBatch {
+		return nil
+	}
+	return l.flush()
+}
+
+func (l *LevelDbStore) Root() (H256, error) {
+	l.rw.RLock()
+	defer l.rw.RUnlock()
+	return l.root, nil
+}
+
+func (l *LevelDbStore) GetBranch(key BranchKey) (*BranchNode, error) {
+	keyHash := key.GetHash()
+	l.rw.RLock()
+	defer l.rw.RUnlock()
+	if item, ok := l.pending[keyHash]; ok {
+		if item == nil {
+			return nil, StoreErrorNotExist
+		}
+		return item, nil
+	}
+	value, err := l.db.Get(l.branchKey(keyHash), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, StoreErrorNotExist
+	} else if err != nil {
+		return nil, err
+	}
+	var node BranchNode
+	if err = json.Unmarshal(value, &node); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal err: %s", err.Error())
+	}
+	return &node, nil
+}
+
+func (l *LevelDbStore) InsertBranch(key BranchKey, node BranchNode) error {
+	keyHash := key.GetHash()
+	value, err := json.Marshal(&node)
+	if err != nil {
+		return fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	l.pending[keyHash] = &BranchNode{
+		Left:  node.Left,
+		Right: node.Right,
+	}
+	l.batch.Put(l.branchKey(keyHash), value)
+	return nil
+}
+
+func (l *LevelDbStore) RemoveBranch(key BranchKey) error {
+	keyHash := key.GetHash()
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	l.pending[keyHash] = nil
+	l.batch.Delete(l.branchKey(keyHash))
+	return nil
+}
+
+func (l *LevelDbStore) flush() error {
+	if l.batch.Len() == 0 {
+		return nil
+	}
+	if err := l.db.Write(l.batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("leveldb Write err: %s", err.Error())
+	}
+	l.batch.Reset()
+	l.pending = make(map[string]*BranchNode)
+	return nil
+}
+
+func (l *LevelDbStore) discard() error {
+	l.batch.Reset()
+	l.pending = make(map[string]*BranchNode)
+	root, err := l.loadRoot()
+	if err != nil {
+		return fmt.Errorf("loadRoot err: %s", err.Error())
+	}
+	l.root = root
+	return nil
+}
+
+// Batch runs fn with all writes buffered and commits them in one synced write,
+// nothing of fn is persisted if fn returns an error
+//
+//	err := store.Batch(func() error {
+//		for _, v := range list {
+//			if err := tree.Update(v.Key, v.Value); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	})
+func (l *LevelDbStore) Batch(fn func() error) error {
+	l.rw.Lock()
+	if l.inBatch {
+		l.rw.Unlock()
+		return fmt.Errorf("already in batch")
+	}
+	l.inBatch = true
+	l.rw.Unlock()
+
+	err := fn()
+
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	l.inBatch = false
+	if err != nil {
+		if e := l.discard(); e != nil {
+			return fmt.Errorf("%s, discard err: %s", err.Error(), e.Error())
+		}
+		return err
+	}
+	return l.flush()
+}
+
+// Delete removes all branches, the history and the root of the smt
+func (l *LevelDbStore) Delete() error {
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	batch := new(leveldb.Batch)
+	for _, prefix := range [][]byte{l.branchPrefix(), l.historyPrefix()} {
+		iter := l.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("iterator err: %s", err.Error())
+		}
+	}
+	batch.Delete(l.rootKey())
+	if err := l.db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("leveldb Write err: %s", err.Error())
+	}
+	l.batch.Reset()
+	l.pending = make(map[string]*BranchNode)
+	l.root = H256Zero()
+	return nil
+}
@@ -19,8 +19,7 @@ func (m *MerkleProof) compile(keys, values []H256) (*CompiledMerkleProof, error)
 	} else if len(keys) != len(m.LeavesBitmap) {
 		return nil, fmt.Errorf("len(keys) != LeavesCount")
 	}
-	// sort keys leaves.sort_unstable_by_key(|(k, _v)| *k);
-	// sort.Sort(SortH256(keys))
+	// keys are sorted by sortKeyValues
 
 	var proof []byte
 	stackForkHeight := make([]byte, MaxStackSize)
@@ -115,8 +114,7 @@ func (m *MerkleProof) compile(keys, values []H256) (*CompiledMerkleProof, error)
 type CompiledMerkleProof []byte
 
 func (c *CompiledMerkleProof) computeRoot(keys, values []H256) (*H256, error) {
-	// leaves.sort_unstable_by_key(|(k, _v)| *k);
-	// sort.Sort(SortH256(keys))
+	// keys are sorted by sortKeyValues
 
 	var stackHeights = make([]uint16, MaxStackSize)
 	var stackKeys = make([]H256, MaxStackSize)
@@ -82,3 +82,8 @@ func (m *MongodbStore) RemoveBranch(key BranchKey) error {
 	}
 	return nil
 }
+
+// Delete drops the collection of the smt
+func (m *MongodbStore) Delete() error {
+	return m.Collection().Drop(m.ctx)
+}
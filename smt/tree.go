@@ -3,10 +3,13 @@ package smt
 import (
 	"bytes"
 	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"sort"
 )
 
 type SparseMerkleTree struct {
 	store Store
+	tx    *txStore
 }
 
 func NewSparseMerkleTree(store Store) *SparseMerkleTree {
@@ -21,6 +24,63 @@ func (s *SparseMerkleTree) Root() (H256, error) {
 	return s.store.Root()
 }
 
+// Begin starts a transaction, the updates after it are kept in memory until Commit and dropped by Rollback
+func (s *SparseMerkleTree) Begin() error {
+	if s.tx != nil {
+		return fmt.Errorf("transaction already begun")
+	}
+	tx, err := newTxStore(s.store)
+	if err != nil {
+		return fmt.Errorf("newTxStore err: %s", err.Error())
+	}
+	s.tx = tx
+	s.store = tx
+	return nil
+}
+
+// Commit writes the updates of the transaction to the store, in one batch if the store supports it
+func (s *SparseMerkleTree) Commit() error {
+	if s.tx == nil {
+		return fmt.Errorf("no transaction")
+	}
+	tx := s.tx
+	s.store, s.tx = tx.base, nil
+	if err := tx.commit(); err != nil {
+		return fmt.Errorf("commit err: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *SparseMerkleTree) Rollback() error {
+	if s.tx == nil {
+		return fmt.Errorf("no transaction")
+	}
+	s.store, s.tx = s.tx.base, nil
+	return nil
+}
+
+// Get returns the value of key, H256Zero if not exist
+func (s *SparseMerkleTree) Get(key H256) (H256, error) {
+	return getLeaf(s.store.GetBranch, key)
+}
+
+func getLeaf(getBranch func(key BranchKey) (*BranchNode, error), key H256) (H256, error) {
+	branch, err := getBranch(BranchKey{Height: 0, NodeKey: *key.ParentPath(0)})
+	if err == StoreErrorNotExist {
+		return H256Zero(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetBranch err: %s", err.Error())
+	}
+	leaf := branch.Left
+	if key.IsRight(0) {
+		leaf = branch.Right
+	}
+	if leaf.Value == nil {
+		return H256Zero(), nil
+	}
+	return leaf.Value, nil
+}
+
 func (s *SparseMerkleTree) Update(key, value H256) error {
 	currentKey := key
 	currentNode := MergeValueFromH256(value)
@@ -73,14 +133,153 @@ func (s *SparseMerkleTree) Update(key, value H256) error {
 	return nil
 }
 
+// UpdateAll updates kvs level by level, the parent shared by several keys is computed and stored only once,
+// the last value wins if a key appears more than once
+func (s *SparseMerkleTree) UpdateAll(kvs []SmtKv) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+	type node struct {
+		key   H256
+		value MergeValue
+	}
+	leaves := sortLeaves(kvs)
+	nodes := make([]node, 0, len(leaves))
+	for _, v := range leaves {
+		nodes = append(nodes, node{key: v.Key, value: MergeValueFromH256(v.Value)})
+	}
+	for i := 0; i <= MaxU8; i++ {
+		height := byte(i)
+		parents := make([]node, 0, len(nodes))
+		for j := 0; j < len(nodes); j++ {
+			currentKey, currentNode := nodes[j].key, nodes[j].value
+			parentKey := currentKey.ParentPath(height)
+			parentBranchKey := BranchKey{
+				Height:  height,
+				NodeKey: *parentKey,
+			}
+			var left, right MergeValue
+
+			if j+1 < len(nodes) && bytes.Compare(*parentKey, *nodes[j+1].key.ParentPath(height)) == 0 {
+				// both children are updated, the sorted one is on the left
+				left, right = currentNode, nodes[j+1].value
+				j++
+			} else {
+				parentBranch, err := s.store.GetBranch(parentBranchKey)
+				if err != nil && err != StoreErrorNotExist {
+					return fmt.Errorf("GetBranch err: %s", err.Error())
+				}
+				if parentBranch != nil {
+					if currentKey.IsRight(height) {
+						left, right = parentBranch.Left, currentNode
+					} else {
+						left, right = currentNode, parentBranch.Right
+					}
+				} else if currentKey.IsRight(height) {
+					left, right = MergeValueFromZero(), currentNode
+				} else {
+					left, right = currentNode, MergeValueFromZero()
+				}
+			}
+
+			if !left.IsZero() || !right.IsZero() {
+				if err := s.store.InsertBranch(parentBranchKey, BranchNode{
+					Left:  left,
+					Right: right,
+				}); err != nil {
+					return fmt.Errorf("InsertBranch err: %s", err.Error())
+				}
+			} else {
+				if err := s.store.RemoveBranch(parentBranchKey); err != nil {
+					return fmt.Errorf("RemoveBranch err: %s", err.Error())
+				}
+			}
+			parents = append(parents, node{key: *parentKey, value: Merge(height, *parentKey, left, right)})
+		}
+		nodes = parents
+	}
+	if len(nodes) != 1 {
+		return fmt.Errorf("unreachable")
+	}
+	if err := s.store.UpdateRoot(nodes[0].value.Hash()); err != nil {
+		return fmt.Errorf("UpdateRoot err: %s", err.Error())
+	}
+	return nil
+}
+
+// UpdateMiddleSmt updates kvs one by one like the update_db_smt_middle of the smt server,
+// Roots is the root after each key is updated and Proofs is the proof of each key, both keyed by the 0x hex of the key
+func (s *SparseMerkleTree) UpdateMiddleSmt(kvs []SmtKv, opt SmtOpt) (*UpdateMiddleSmtOut, error) {
+	out := UpdateMiddleSmtOut{
+		Roots:  make(map[string]H256),
+		Proofs: make(map[string]string),
+	}
+	for _, v := range kvs {
+		if err := s.Update(v.Key, v.Value); err != nil {
+			return nil, fmt.Errorf("Update err: %s", err.Error())
+		}
+		key := common.Bytes2Hex(v.Key)
+		if opt.GetRoot {
+			root, err := s.Root()
+			if err != nil {
+				return nil, fmt.Errorf("Root err: %s", err.Error())
+			}
+			out.Roots[key] = append(H256{}, root...)
+		}
+		if opt.GetProof {
+			proof, err := s.MerkleProof([]H256{v.Key}, []H256{v.Value})
+			if err != nil {
+				return nil, fmt.Errorf("MerkleProof err: %s", err.Error())
+			}
+			out.Proofs[key] = common.Bytes2Hex(*proof)
+		}
+	}
+	return &out, nil
+}
+
+// sortLeaves sorts kvs by key in the order of the leaves in the tree and keeps the last value of duplicated keys
+func sortLeaves(kvs []SmtKv) []SmtKv {
+	list := make([]SmtKv, len(kvs))
+	copy(list, kvs)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Key.Compare(&list[j].Key) < 0
+	})
+	res := make([]SmtKv, 0, len(list))
+	for i := range list {
+		if i+1 < len(list) && list[i].Key.Compare(&list[i+1].Key) == 0 {
+			continue
+		}
+		res = append(res, list[i])
+	}
+	return res
+}
+
+// sortKeyValues sorts keys and values together, which is required by merkleProof, compile and computeRoot
+func sortKeyValues(keys, values []H256) ([]H256, []H256, error) {
+	if len(keys) != len(values) {
+		return nil, nil, fmt.Errorf("len(keys) != len(values)")
+	}
+	kvs := make([]SmtKv, 0, len(keys))
+	for i := range keys {
+		kvs = append(kvs, SmtKv{Key: keys[i], Value: values[i]})
+	}
+	leaves := sortLeaves(kvs)
+	if len(leaves) != len(kvs) {
+		return nil, nil, fmt.Errorf("DuplicateKeys")
+	}
+	sortedKeys, sortedValues := make([]H256, 0, len(leaves)), make([]H256, 0, len(leaves))
+	for _, v := range leaves {
+		sortedKeys = append(sortedKeys, v.Key)
+		sortedValues = append(sortedValues, v.Value)
+	}
+	return sortedKeys, sortedValues, nil
+}
+
+// merkleProof keys must be sorted
 func (s *SparseMerkleTree) merkleProof(keys []H256) (*MerkleProof, error) {
 	if len(keys) == 0 {
 		return nil, fmt.Errorf("EmptyKeys")
-	} else if len(keys) > 1 {
-		return nil, fmt.Errorf("len(keys) > 1")
 	}
-	// sort keys keys.sort_unstable();
-	// sort.Sort(SortH256(keys))
 
 	// Collect leaf bitmaps
 	var leavesBitMap []H256
@@ -165,7 +364,12 @@ func (s *SparseMerkleTree) merkleProof(keys []H256) (*MerkleProof, error) {
 	}, nil
 }
 
+// MerkleProof supports several keys in one proof, keys are not required to be sorted
 func (s *SparseMerkleTree) MerkleProof(keys, values []H256) (*CompiledMerkleProof, error) {
+	keys, values, err := sortKeyValues(keys, values)
+	if err != nil {
+		return nil, fmt.Errorf("sortKeyValues err: %s", err.Error())
+	}
 	merkleProof, err := s.merkleProof(keys)
 	if err != nil {
 		return nil, fmt.Errorf("merkleProof: %s", err.Error())
@@ -177,6 +381,10 @@ func Verify(root H256, proof *CompiledMerkleProof, keys, values []H256) (bool, e
 	if proof == nil {
 		return false, fmt.Errorf("proof is nil")
 	}
+	keys, values, err := sortKeyValues(keys, values)
+	if err != nil {
+		return false, fmt.Errorf("sortKeyValues err: %s", err.Error())
+	}
 	calculatedRoot, err := proof.computeRoot(keys, values)
 	if err != nil {
 		return false, fmt.Errorf("ComputeRoot err: %s", err.Error())
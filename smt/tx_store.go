@@ -0,0 +1,83 @@
+package smt
+
+import (
+	"fmt"
+)
+
+type txItem struct {
+	key  BranchKey
+	node *BranchNode // nil for removed branch
+}
+
+// txStore buffers the writes of a transaction of SparseMerkleTree, nothing reaches the base store before commit
+type txStore struct {
+	base    Store
+	pending map[string]txItem
+	root    H256
+}
+
+func newTxStore(base Store) (*txStore, error) {
+	root, err := base.Root()
+	if err != nil {
+		return nil, fmt.Errorf("Root err: %s", err.Error())
+	}
+	return &txStore{
+		base:    base,
+		pending: make(map[string]txItem),
+		root:    root,
+	}, nil
+}
+
+func (t *txStore) UpdateRoot(root H256) error {
+	t.root = append(H256{}, root...)
+	return nil
+}
+
+func (t *txStore) Root() (H256, error) {
+	return t.root, nil
+}
+
+func (t *txStore) GetBranch(key BranchKey) (*BranchNode, error) {
+	if item, ok := t.pending[key.GetHash()]; ok {
+		if item.node == nil {
+			return nil, StoreErrorNotExist
+		}
+		return item.node, nil
+	}
+	return t.base.GetBranch(key)
+}
+
+func (t *txStore) InsertBranch(key BranchKey, node BranchNode) error {
+	t.pending[key.GetHash()] = txItem{key: key, node: &node}
+	return nil
+}
+
+func (t *txStore) RemoveBranch(key BranchKey) error {
+	t.pending[key.GetHash()] = txItem{key: key}
+	return nil
+}
+
+func (t *txStore) commit() error {
+	if len(t.pending) == 0 {
+		return nil
+	}
+	apply := func() error {
+		for _, item := range t.pending {
+			if item.node == nil {
+				if err := t.base.RemoveBranch(item.key); err != nil {
+					return fmt.Errorf("RemoveBranch err: %s", err.Error())
+				}
+			} else if err := t.base.InsertBranch(item.key, *item.node); err != nil {
+				return fmt.Errorf("InsertBranch err: %s", err.Error())
+			}
+		}
+		if err := t.base.UpdateRoot(t.root); err != nil {
+			return fmt.Errorf("UpdateRoot err: %s", err.Error())
+		}
+		return nil
+	}
+	if bs, ok := t.base.(interface{ Batch(fn func() error) error }); ok {
+		return bs.Batch(apply)
+	}
+	return apply()
+}
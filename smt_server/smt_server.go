@@ -0,0 +1,368 @@
+package smt_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/smt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// the server side of smt.SmtServer, the named trees are kept in the stores created by StoreFactory
+
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+)
+
+// StoreFactory returns the store of smtName, a nil store keeps the tree in memory
+type StoreFactory func(smtName string) (smt.Store, error)
+
+func MemoryStoreFactory(smtName string) (smt.Store, error) {
+	return nil, nil
+}
+
+type batchStore interface {
+	Batch(fn func() error) error
+}
+
+type deletableStore interface {
+	Delete() error
+}
+
+type closableStore interface {
+	Close() error
+}
+
+type namedTree struct {
+	lock  sync.Mutex
+	store smt.Store
+	tree  *smt.SparseMerkleTree
+}
+
+// batch runs fn in one batch when the store supports it
+func (n *namedTree) batch(fn func() error) error {
+	if bs, ok := n.store.(batchStore); ok {
+		return bs.Batch(fn)
+	}
+	return fn()
+}
+
+type SmtRpcServer struct {
+	newStore   StoreFactory
+	rw         sync.Mutex
+	trees      map[string]*namedTree
+	httpServer *http.Server
+	closed     bool
+}
+
+func NewSmtRpcServer(newStore StoreFactory) *SmtRpcServer {
+	if newStore == nil {
+		newStore = MemoryStoreFactory
+	}
+	return &SmtRpcServer{
+		newStore: newStore,
+		trees:    make(map[string]*namedTree),
+	}
+}
+
+// ListenAndServe returns http.ErrServerClosed after Close
+func (s *SmtRpcServer) ListenAndServe(addr string) error {
+	s.rw.Lock()
+	if s.closed {
+		s.rw.Unlock()
+		return http.ErrServerClosed
+	}
+	s.httpServer = &http.Server{Addr: addr, Handler: s}
+	httpServer := s.httpServer
+	s.rw.Unlock()
+	return httpServer.ListenAndServe()
+}
+
+// Close stops ListenAndServe after the running requests are done and closes the stores of the trees,
+// the calls after Close fail
+func (s *SmtRpcServer) Close() error {
+	s.rw.Lock()
+	s.closed = true
+	httpServer := s.httpServer
+	s.rw.Unlock()
+
+	var errs []string
+	if httpServer != nil {
+		if err := httpServer.Shutdown(context.Background()); err != nil {
+			errs = append(errs, fmt.Sprintf("Shutdown err: %s", err.Error()))
+		}
+	}
+
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	for smtName, item := range s.trees {
+		item.lock.Lock()
+		if err := closeStore(item.store); err != nil {
+			errs = append(errs, fmt.Sprintf("[%s] Close err: %s", smtName, err.Error()))
+		}
+		item.lock.Unlock()
+		delete(s.trees, smtName)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func closeStore(store smt.Store) error {
+	if cs, ok := store.(closableStore); ok {
+		return cs.Close()
+	}
+	return nil
+}
+
+func (s *SmtRpcServer) getTree(smtName string) (*namedTree, error) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("server is closed")
+	}
+	if item, ok := s.trees[smtName]; ok {
+		return item, nil
+	}
+	store, err := s.newStore(smtName)
+	if err != nil {
+		return nil, fmt.Errorf("newStore err: %s", err.Error())
+	}
+	item := &namedTree{store: store, tree: smt.NewSparseMerkleTree(store)}
+	s.trees[smtName] = item
+	return item, nil
+}
+
+type jsonRpcReq struct {
+	Id      interface{}     `json:"id"`
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type jsonRpcResp struct {
+	Id      interface{}       `json:"id"`
+	Jsonrpc string            `json:"jsonrpc"`
+	Result  interface{}       `json:"result,omitempty"`
+	Error   *smt.JsonRpcError `json:"error,omitempty"`
+}
+
+type smtNameParam struct {
+	SmtName string `json:"smt_name"`
+}
+
+func newRpcError(code int, format string, a ...interface{}) *smt.JsonRpcError {
+	return &smt.JsonRpcError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+func (s *SmtRpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req jsonRpcReq
+	resp := jsonRpcResp{Jsonrpc: "2.0"}
+	if r.Method != http.MethodPost {
+		resp.Error = newRpcError(ErrCodeInvalidRequest, "method %s not allowed", r.Method)
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error = newRpcError(ErrCodeParse, "parse err: %s", err.Error())
+	} else {
+		resp.Id = req.Id
+		resp.Result, resp.Error = s.Handle(req.Method, req.Params)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&resp)
+}
+
+// Handle dispatches one json-rpc call, the result is the same as the external smt server
+func (s *SmtRpcServer) Handle(method string, params json.RawMessage) (interface{}, *smt.JsonRpcError) {
+	switch method {
+	case smt.GetSmtRoot:
+		var p smtNameParam
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, newRpcError(ErrCodeInvalidParams, "json.Unmarshal err: %s", err.Error())
+		}
+		return s.getSmtRoot(p.SmtName)
+	case smt.DeleteSmt:
+		var p smtNameParam
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, newRpcError(ErrCodeInvalidParams, "json.Unmarshal err: %s", err.Error())
+		}
+		return s.deleteSmt(p.SmtName)
+	case smt.UpdateMemorySmt, smt.UpdateDbSmt, smt.UpdateDbSmtMiddle:
+		var p smt.UpdateSmtParam
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, newRpcError(ErrCodeInvalidParams, "json.Unmarshal err: %s", err.Error())
+		}
+		kvs, err := parseSmtKvHex(p.Data)
+		if err != nil {
+			return nil, newRpcError(ErrCodeInvalidParams, "parseSmtKvHex err: %s", err.Error())
+		}
+		switch method {
+		case smt.UpdateMemorySmt:
+			item := &namedTree{tree: smt.NewSparseMerkleTree(nil)}
+			return s.updateSmt(item, kvs, p.Opt)
+		case smt.UpdateDbSmt:
+			if p.SmtName == "" {
+				return nil, newRpcError(ErrCodeInvalidParams, "smt_name is empty")
+			}
+			item, err := s.getTree(p.SmtName)
+			if err != nil {
+				return nil, newRpcError(ErrCodeInternal, "getTree err: %s", err.Error())
+			}
+			return s.updateSmt(item, kvs, p.Opt)
+		default:
+			if p.SmtName == "" {
+				return nil, newRpcError(ErrCodeInvalidParams, "smt_name is empty")
+			}
+			item, err := s.getTree(p.SmtName)
+			if err != nil {
+				return nil, newRpcError(ErrCodeInternal, "getTree err: %s", err.Error())
+			}
+			return s.updateSmtMiddle(item, kvs, p.Opt)
+		}
+	}
+	return nil, newRpcError(ErrCodeMethodNotFound, "method %s not found", method)
+}
+
+func parseSmtKvHex(data []smt.SmtKvHex) ([]smt.SmtKv, error) {
+	var kvs []smt.SmtKv
+	for _, v := range data {
+		key, value := common.Hex2Bytes(v.Key), common.Hex2Bytes(v.Value)
+		if len(key) != 32 || len(value) != 32 {
+			return nil, fmt.Errorf("invalid kv: %s %s", v.Key, v.Value)
+		}
+		kvs = append(kvs, smt.SmtKv{Key: key, Value: value})
+	}
+	return kvs, nil
+}
+
+func toHex(bys []byte) string {
+	return strings.TrimPrefix(common.Bytes2Hex(bys), common.HexPreFix)
+}
+
+func (s *SmtRpcServer) getSmtRoot(smtName string) (interface{}, *smt.JsonRpcError) {
+	if smtName == "" {
+		return nil, newRpcError(ErrCodeInvalidParams, "smt_name is empty")
+	}
+	item, err := s.getTree(smtName)
+	if err != nil {
+		return nil, newRpcError(ErrCodeInternal, "getTree err: %s", err.Error())
+	}
+	item.lock.Lock()
+	defer item.lock.Unlock()
+	root, err := item.tree.Root()
+	if err != nil {
+		return nil, newRpcError(ErrCodeInternal, "Root err: %s", err.Error())
+	}
+	return toHex(root), nil
+}
+
+func (s *SmtRpcServer) deleteSmt(smtName string) (interface{}, *smt.JsonRpcError) {
+	if smtName == "" {
+		return nil, newRpcError(ErrCodeInvalidParams, "smt_name is empty")
+	}
+	item, err := s.getTree(smtName)
+	if err != nil {
+		return nil, newRpcError(ErrCodeInternal, "getTree err: %s", err.Error())
+	}
+	if ds, ok := item.store.(deletableStore); ok {
+		item.lock.Lock()
+		defer item.lock.Unlock()
+		if err := ds.Delete(); err != nil {
+			return nil, newRpcError(ErrCodeInternal, "Delete err: %s", err.Error())
+		}
+		return true, nil
+	}
+	// the store can not be cleared, drop it and a new one will be created,
+	// s.rw is taken before item.lock in the same order as Close
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if s.trees[smtName] != item {
+		// dropped by Close or another deleteSmt
+		return true, nil
+	}
+	item.lock.Lock()
+	defer item.lock.Unlock()
+	if err := closeStore(item.store); err != nil {
+		return nil, newRpcError(ErrCodeInternal, "Close err: %s", err.Error())
+	}
+	delete(s.trees, smtName)
+	return true, nil
+}
+
+func (s *SmtRpcServer) updateSmt(item *namedTree, kvs []smt.SmtKv, opt smt.SmtOpt) (interface{}, *smt.JsonRpcError) {
+	item.lock.Lock()
+	defer item.lock.Unlock()
+
+	var res smt.UpdateResult
+	err := item.batch(func() error {
+		if err := item.tree.UpdateAll(kvs); err != nil {
+			return fmt.Errorf("UpdateAll err: %s", err.Error())
+		}
+		if opt.GetRoot {
+			root, err := item.tree.Root()
+			if err != nil {
+				return fmt.Errorf("Root err: %s", err.Error())
+			}
+			res.Root = toHex(root)
+		}
+		if opt.GetProof {
+			res.Proofs = make(map[string]string)
+			for _, v := range kvs {
+				proof, err := item.tree.MerkleProof([]smt.H256{v.Key}, []smt.H256{v.Value})
+				if err != nil {
+					return fmt.Errorf("MerkleProof err: %s", err.Error())
+				}
+				res.Proofs[toHex(v.Key)] = toHex(*proof)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, newRpcError(ErrCodeInternal, "%s", err.Error())
+	}
+	return &res, nil
+}
+
+// updateSmtMiddle updates kvs one by one, the roots and proofs are the ones right after each key is updated
+func (s *SmtRpcServer) updateSmtMiddle(item *namedTree, kvs []smt.SmtKv, opt smt.SmtOpt) (interface{}, *smt.JsonRpcError) {
+	item.lock.Lock()
+	defer item.lock.Unlock()
+
+	res := smt.UpdateMiddleResult{
+		Roots:  make(map[string]string),
+		Proofs: make(map[string]string),
+	}
+	err := item.batch(func() error {
+		for _, v := range kvs {
+			if err := item.tree.Update(v.Key, v.Value); err != nil {
+				return fmt.Errorf("Update err: %s", err.Error())
+			}
+			key := toHex(v.Key)
+			if opt.GetRoot {
+				root, err := item.tree.Root()
+				if err != nil {
+					return fmt.Errorf("Root err: %s", err.Error())
+				}
+				res.Roots[key] = toHex(root)
+			}
+			if opt.GetProof {
+				proof, err := item.tree.MerkleProof([]smt.H256{v.Key}, []smt.H256{v.Value})
+				if err != nil {
+					return fmt.Errorf("MerkleProof err: %s", err.Error())
+				}
+				res.Proofs[key] = toHex(*proof)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, newRpcError(ErrCodeInternal, "%s", err.Error())
+	}
+	return &res, nil
+}
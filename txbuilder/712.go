@@ -21,7 +21,7 @@ func (d *DasTxBuilder) BuildMMJsonObj(evmChainId int64) (*common.MMJsonObj, erro
 	res.Domain.ChainID = evmChainId
 	if res.Domain.ChainID == 0 {
 		res.Domain.ChainID = 1
-		if d.dasCore.NetType() != common.DasNetTypeMainNet {
+		if !common.IsMainNet(d.dasCore.NetType()) {
 			res.Domain.ChainID = 17000
 		}
 	}
@@ -307,7 +307,7 @@ func (d *DasTxBuilder) getWithdrawDasMessage() (string, error) {
 		return "", fmt.Errorf("GetDasContractInfo err: %s", err.Error())
 	}
 	mod := address.Testnet
-	if d.dasCore.NetType() == common.DasNetTypeMainNet {
+	if common.IsMainNet(d.dasCore.NetType()) {
 		mod = address.Mainnet
 	}
 
@@ -99,7 +99,7 @@ func BuildDidCellTxForRecycle(p DidCellTxParams) (*BuildTransactionParams, error
 	if p.DidCellOutPoint == nil {
 		return nil, fmt.Errorf("DidCellOutPoint is nil")
 	}
-	didCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
+	didCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -198,7 +198,7 @@ func BuildDidCellTxForEditRecords(p DidCellTxParams) (*BuildTransactionParams, e
 	if p.DidCellOutPoint == nil {
 		return nil, fmt.Errorf("DidCellOutPoint is nil")
 	}
-	didCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
+	didCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -304,7 +304,7 @@ func BuildAccountCellTxForEditRecords(p DidCellTxParams) (*BuildTransactionParam
 	if p.AccountCellOutPoint == nil {
 		return nil, fmt.Errorf("AccountCellOutPoint is nil")
 	}
-	accountCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
+	accountCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -395,7 +395,7 @@ func BuildDidCellTxForEditOwner(p DidCellTxParams) (*BuildTransactionParams, err
 	if p.DidCellOutPoint == nil {
 		return nil, fmt.Errorf("DidCellOutPoint is nil")
 	}
-	didCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
+	didCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -584,7 +584,7 @@ func BuildDidCellTxForEditOwnerFromAccountCell(p DidCellTxParams) (*BuildTransac
 	// witness
 
 	// witness account cell
-	accountCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
+	accountCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -775,7 +775,7 @@ func BuildAccountCellTxForEditOwner(p DidCellTxParams) (*BuildTransactionParams,
 	}
 
 	//  check old lock
-	accountCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
+	accountCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -892,7 +892,7 @@ func BuildAccountCellTxForRenew(p DidCellTxParams) (*BuildTransactionParams, err
 	}
 	quote := quoteCell.Quote()
 
-	accountCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
+	accountCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -1068,7 +1068,7 @@ func BuildDidCellTxForRenew(p DidCellTxParams) (*BuildTransactionParams, error)
 	if p.DidCellOutPoint == nil {
 		return nil, fmt.Errorf("DidCellOutPoint is nil")
 	}
-	didCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
+	didCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.DidCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -1099,7 +1099,7 @@ func BuildDidCellTxForRenew(p DidCellTxParams) (*BuildTransactionParams, error)
 	}
 	quote := quoteCell.Quote()
 
-	accountCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
+	accountCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -1340,7 +1340,7 @@ func BuildDidCellTxForUpgrade(p DidCellTxParams) (*BuildTransactionParams, error
 	}
 
 	// witness account cell
-	accountCellTx, err := p.DasCore.Client().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
+	accountCellTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.AccountCellOutPoint.TxHash)
 	if err != nil {
 		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/sign"
 	"github.com/dotbitHQ/das-lib/witness"
 	"github.com/nervosnetwork/ckb-sdk-go/crypto/blake2b"
 	"github.com/nervosnetwork/ckb-sdk-go/transaction"
@@ -127,101 +128,17 @@ func (d *DasTxBuilder) generateDigestByGroup(group []int, skipGroups []int) (Sig
 	if group == nil || len(group) < 1 {
 		return signData, fmt.Errorf("invalid param")
 	}
-	// check AlgorithmId
-	item, err := d.getInputCell(d.Transaction.Inputs[group[0]].PreviousOutput)
-	if err != nil {
-		return signData, fmt.Errorf("getInputCell err: %s", err.Error())
-	}
-	has712, action := false, ""
-
-	//didType, err := core.GetDasContractInfo(common.DasContractNameDidCellType)
-	//if err != nil {
-	//	return signData, fmt.Errorf("core.GetDasContractInfo err: %s", err.Error())
-	//}
-	//else if dasLock.IsSameTypeId(item.Cell.Output.Lock.CodeHash) && item.Cell.Output.Type != nil && didType.IsSameTypeId(item.Cell.Output.Type.CodeHash) {
-	//	log.Info("generateDigestByGroup did cell with daslock")
-	//	daf := core.DasAddressFormat{DasNetType: d.dasCore.NetType()}
-	//	ownerHex, _, _ := daf.ArgsToHex(item.Cell.Output.Lock.Args)
-	//	ownerAlgorithmId := ownerHex.DasAlgorithmId
-	//
-	//	signData.SignType = ownerAlgorithmId
-	//	if signData.SignType == common.DasAlgorithmIdEth712 {
-	//		has712 = true
-	//	}
-	//}
-
-	dasLock, err := core.GetDasContractInfo(common.DasContractNameDispatchCellType)
+	signType, has712, err := d.getSignTypeByGroup(group)
 	if err != nil {
-		return signData, fmt.Errorf("core.GetDasContractInfo err: %s", err.Error())
-	} else if dasLock.IsSameTypeId(item.Cell.Output.Lock.CodeHash) {
-		daf := core.DasAddressFormat{DasNetType: d.dasCore.NetType()}
-		ownerHex, managerHex, _ := daf.ArgsToHex(item.Cell.Output.Lock.Args)
-		ownerAlgorithmId, managerAlgorithmId := ownerHex.DasAlgorithmId, managerHex.DasAlgorithmId
-
-		signData.SignType = ownerAlgorithmId
-		actionDataBuilder, err := witness.ActionDataBuilderFromTx(d.Transaction)
-		if err != nil {
-			return signData, fmt.Errorf("ActionDataBuilderFromTx err: %s", err.Error())
-		}
-		if actionDataBuilder.ParamsStr == common.ParamManager {
-			signData.SignType = managerAlgorithmId
-		}
-
-		actionBuilder, err := witness.ActionDataBuilderFromTx(d.Transaction)
-		//actionBuilder.Params
-		if err != nil {
-			if err != witness.ErrNotExistActionData {
-				return signData, fmt.Errorf("witness.ActionDataBuilderFromTx err: %s", err.Error())
-			}
-		} else {
-			action = actionBuilder.Action
-			switch actionBuilder.Action {
-			case common.DasActionEditRecords:
-				signData.SignType = managerAlgorithmId
-			case common.DasActionEnableSubAccount, common.DasActionCreateSubAccount,
-				common.DasActionConfigSubAccountCustomScript, common.DasActionConfigSubAccount:
-				if signData.SignType == common.DasAlgorithmIdEth712 {
-					signData.SignType = common.DasAlgorithmIdEth
-				}
-			case common.DasActionRevokeApproval:
-				signData.SignType = common.DasAlgorithmIdEth
-			}
-			// 712
-			switch actionBuilder.Action {
-			case common.DasActionEditManager, common.DasActionEditRecords,
-				common.DasActionTransferAccount, common.DasActionTransfer,
-				common.DasActionWithdrawFromWallet, common.DasActionStartAccountSale,
-				common.DasActionEditAccountSale, common.DasActionCancelAccountSale,
-				common.DasActionBuyAccount, common.DasActionDeclareReverseRecord,
-				common.DasActionRedeclareReverseRecord, common.DasActionRetractReverseRecord,
-				common.DasActionMakeOffer, common.DasActionEditOffer, common.DasActionCancelOffer,
-				common.DasActionAcceptOffer, common.DasActionLockAccountForCrossChain,
-				common.DasActionCreateApproval, common.DasActionDelayApproval, common.DasActionFulfillApproval,
-				common.DasActionMintDP, common.DasActionTransferDP, common.DasActionBurnDP, common.DasActionBidExpiredAccountAuction:
-				has712 = true
-			}
-		}
-		// gen digest
-		log.Warn("generateDigestByGroup:", len(group), group, action, has712, actionDataBuilder.ParamsStr)
-	} else if item.Cell.Output.Lock.CodeHash.Hex() == transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH &&
-		d.equalArgs(common.Bytes2Hex(item.Cell.Output.Lock.Args), d.serverArgs) {
-		signData.SignType = common.DasAlgorithmIdCkb
-	} else {
-		signData.SignType = common.DasAlgorithmIdAnyLock
+		return signData, err
 	}
+	signData.SignType = signType
 
 	emptyWitnessArg := types.WitnessArgs{
-		Lock:       make([]byte, 65),
+		Lock:       make([]byte, getPlaceholderLockSize(signType, has712)),
 		InputType:  nil,
 		OutputType: nil,
 	}
-	if signData.SignType == common.DasAlgorithmIdDogeChain {
-		emptyWitnessArg.Lock = make([]byte, 66)
-	} else if signData.SignType == common.DasAlgorithmIdEth712 && has712 {
-		emptyWitnessArg.Lock = make([]byte, 105)
-	} else if signData.SignType == common.DasAlgorithmIdWebauthn {
-		emptyWitnessArg.Lock = make([]byte, 800)
-	}
 	data, err := emptyWitnessArg.Serialize()
 	if err != nil {
 		return signData, err
@@ -296,6 +213,116 @@ func (d *DasTxBuilder) generateDigestByGroup(group []int, skipGroups []int) (Sig
 	return signData, nil
 }
 
+// getSignTypeByGroup returns the sign type of the group and whether it is signed by 712
+func (d *DasTxBuilder) getSignTypeByGroup(group []int) (common.DasAlgorithmId, bool, error) {
+	var signType common.DasAlgorithmId
+	// check AlgorithmId
+	item, err := d.getInputCell(d.Transaction.Inputs[group[0]].PreviousOutput)
+	if err != nil {
+		return signType, false, fmt.Errorf("getInputCell err: %s", err.Error())
+	}
+	has712, action := false, ""
+
+	//didType, err := core.GetDasContractInfo(common.DasContractNameDidCellType)
+	//if err != nil {
+	//	return signType, has712, fmt.Errorf("core.GetDasContractInfo err: %s", err.Error())
+	//}
+	//else if dasLock.IsSameTypeId(item.Cell.Output.Lock.CodeHash) && item.Cell.Output.Type != nil && didType.IsSameTypeId(item.Cell.Output.Type.CodeHash) {
+	//	log.Info("generateDigestByGroup did cell with daslock")
+	//	daf := core.DasAddressFormat{DasNetType: d.dasCore.NetType()}
+	//	ownerHex, _, _ := daf.ArgsToHex(item.Cell.Output.Lock.Args)
+	//	ownerAlgorithmId := ownerHex.DasAlgorithmId
+	//
+	//	signType = ownerAlgorithmId
+	//	if signType == common.DasAlgorithmIdEth712 {
+	//		has712 = true
+	//	}
+	//}
+
+	dasLock, err := core.GetDasContractInfo(common.DasContractNameDispatchCellType)
+	if err != nil {
+		return signType, has712, fmt.Errorf("core.GetDasContractInfo err: %s", err.Error())
+	} else if dasLock.IsSameTypeId(item.Cell.Output.Lock.CodeHash) {
+		daf := core.DasAddressFormat{DasNetType: d.dasCore.NetType()}
+		ownerHex, managerHex, _ := daf.ArgsToHex(item.Cell.Output.Lock.Args)
+		ownerAlgorithmId, managerAlgorithmId := ownerHex.DasAlgorithmId, managerHex.DasAlgorithmId
+
+		signType = ownerAlgorithmId
+		actionDataBuilder, err := witness.ActionDataBuilderFromTx(d.Transaction)
+		if err != nil {
+			return signType, has712, fmt.Errorf("ActionDataBuilderFromTx err: %s", err.Error())
+		}
+		if actionDataBuilder.ParamsStr == common.ParamManager {
+			signType = managerAlgorithmId
+		}
+
+		actionBuilder, err := witness.ActionDataBuilderFromTx(d.Transaction)
+		//actionBuilder.Params
+		if err != nil {
+			if err != witness.ErrNotExistActionData {
+				return signType, has712, fmt.Errorf("witness.ActionDataBuilderFromTx err: %s", err.Error())
+			}
+		} else {
+			action = actionBuilder.Action
+			switch actionBuilder.Action {
+			case common.DasActionEditRecords:
+				signType = managerAlgorithmId
+			case common.DasActionEnableSubAccount, common.DasActionCreateSubAccount,
+				common.DasActionConfigSubAccountCustomScript, common.DasActionConfigSubAccount:
+				if signType == common.DasAlgorithmIdEth712 {
+					signType = common.DasAlgorithmIdEth
+				}
+			case common.DasActionRevokeApproval:
+				signType = common.DasAlgorithmIdEth
+			}
+			// 712
+			switch actionBuilder.Action {
+			case common.DasActionEditManager, common.DasActionEditRecords,
+				common.DasActionTransferAccount, common.DasActionTransfer,
+				common.DasActionWithdrawFromWallet, common.DasActionStartAccountSale,
+				common.DasActionEditAccountSale, common.DasActionCancelAccountSale,
+				common.DasActionBuyAccount, common.DasActionDeclareReverseRecord,
+				common.DasActionRedeclareReverseRecord, common.DasActionRetractReverseRecord,
+				common.DasActionMakeOffer, common.DasActionEditOffer, common.DasActionCancelOffer,
+				common.DasActionAcceptOffer, common.DasActionLockAccountForCrossChain,
+				common.DasActionCreateApproval, common.DasActionDelayApproval, common.DasActionFulfillApproval,
+				common.DasActionMintDP, common.DasActionTransferDP, common.DasActionBurnDP, common.DasActionBidExpiredAccountAuction:
+				has712 = true
+			}
+		}
+		// gen digest
+		log.Warn("generateDigestByGroup:", len(group), group, action, has712, actionDataBuilder.ParamsStr)
+	} else if item.Cell.Output.Lock.CodeHash.Hex() == transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH &&
+		d.equalArgs(common.Bytes2Hex(item.Cell.Output.Lock.Args), d.serverArgs) {
+		signType = common.DasAlgorithmIdCkb
+	} else {
+		signType = common.DasAlgorithmIdAnyLock
+	}
+
+	return signType, has712, nil
+}
+
+// getPlaceholderLockSize returns the lock size of the empty witness hashed into the digest, the contracts hash the same,
+// so it is not the size of the signature for all the algorithms, see getSignatureLockSize
+func getPlaceholderLockSize(signType common.DasAlgorithmId, has712 bool) int {
+	if signType == common.DasAlgorithmIdDogeChain {
+		return 66
+	} else if signType == common.DasAlgorithmIdEth712 && has712 {
+		return 105
+	} else if signType == common.DasAlgorithmIdWebauthn {
+		return 800
+	}
+	return 65
+}
+
+// getSignatureLockSize returns the lock size of the signature in witness after the tx is signed
+func getSignatureLockSize(signType common.DasAlgorithmId, has712 bool) int {
+	if signType == common.DasAlgorithmIdEth712 && !has712 {
+		signType = common.DasAlgorithmIdEth
+	}
+	return sign.SignatureSize(signType)
+}
+
 func (d *DasTxBuilder) getInputCell(o *types.OutPoint) (*types.CellWithStatus, error) {
 	if o == nil {
 		return nil, fmt.Errorf("OutPoint is nil")
@@ -306,7 +333,7 @@ func (d *DasTxBuilder) getInputCell(o *types.OutPoint) (*types.CellWithStatus, e
 			return item, nil
 		}
 	}
-	if cell, err := d.dasCore.Client().GetLiveCell(d.ctx, o, true); err != nil {
+	if cell, err := d.dasCore.ChainReader().GetLiveCell(d.ctx, o, true); err != nil {
 		return nil, fmt.Errorf("GetLiveCell err: %s", err.Error())
 	} else if cell.Cell.Output.Lock != nil {
 		d.MapInputsCell[key] = cell
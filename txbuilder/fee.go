@@ -0,0 +1,184 @@
+package txbuilder
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+const (
+	DefaultFeeRate = uint64(1000) // shannons/KB
+	maxFeeRounds   = 5
+)
+
+type ParamTxFee struct {
+	FeeRate     uint64        // shannons/KB, DefaultFeeRate if 0
+	ChangeLock  *types.Script // the lock of the change output appended to the outputs, the outputs of the params are not changed
+	BalanceLock *types.Script // the lock of balance cells to pull when short of capacity, ChangeLock if nil
+	DasCache    *dascache.DasCache
+	MaxFee      uint64 // common.OneCkb if 0, same as checkTxBeforeSend
+}
+
+// placeholderTx returns a copy of the tx whose empty lock witnesses are filled with placeholders of the signature size
+func (d *DasTxBuilder) placeholderTx() (*types.Transaction, error) {
+	groups, err := d.getGroupsFromTx()
+	if err != nil {
+		return nil, fmt.Errorf("getGroupsFromTx err: %s", err.Error())
+	}
+	tx := *d.Transaction
+	tx.Witnesses = make([][]byte, len(d.Transaction.Witnesses))
+	copy(tx.Witnesses, d.Transaction.Witnesses)
+	for _, group := range groups {
+		if group[0] >= len(tx.Witnesses) {
+			return nil, fmt.Errorf("witness of input[%d] not exist", group[0])
+		}
+		if len(tx.Witnesses[group[0]]) > 0 {
+			continue // signed or filled by caller
+		}
+		signType, has712, err := d.getSignTypeByGroup(group)
+		if err != nil {
+			return nil, fmt.Errorf("getSignTypeByGroup err: %s", err.Error())
+		}
+		wa := types.WitnessArgs{Lock: make([]byte, getSignatureLockSize(signType, has712))}
+		wab, err := wa.Serialize()
+		if err != nil {
+			return nil, fmt.Errorf("wa.Serialize err: %s", err.Error())
+		}
+		tx.Witnesses[group[0]] = wab
+	}
+	return &tx, nil
+}
+
+// EstimateTxSize returns the size in block of the tx after it is signed
+func (d *DasTxBuilder) EstimateTxSize() (uint64, error) {
+	tx, err := d.placeholderTx()
+	if err != nil {
+		return 0, err
+	}
+	return tx.SizeInBlock()
+}
+
+// EstimateTxFee returns the fee of the tx after it is signed
+func (d *DasTxBuilder) EstimateTxFee(feeRate uint64) (uint64, error) {
+	tx, err := d.placeholderTx()
+	if err != nil {
+		return 0, err
+	}
+	return transaction.CalculateTransactionFee(tx, feeRate)
+}
+
+// BuildTransactionWithFee builds the tx like BuildTransaction, then pays the fee of fp.FeeRate by the change output appended,
+// balance cells of fp.BalanceLock are added into inputs when the capacity is not enough, they are released if it fails
+func (d *DasTxBuilder) BuildTransactionWithFee(p *BuildTransactionParams, fp *ParamTxFee) (uint64, error) {
+	if p == nil || fp == nil || fp.ChangeLock == nil {
+		return 0, fmt.Errorf("param invalid")
+	}
+	if len(p.Outputs) != len(p.OutputsData) {
+		return 0, fmt.Errorf("outputs[%d] or outputDatas[%d]", len(p.Outputs), len(p.OutputsData))
+	}
+	feeRate, maxFee, balanceLock := fp.FeeRate, fp.MaxFee, fp.BalanceLock
+	if feeRate == 0 {
+		feeRate = DefaultFeeRate
+	}
+	if maxFee == 0 {
+		maxFee = common.OneCkb
+	}
+	if balanceLock == nil {
+		balanceLock = fp.ChangeLock
+	}
+
+	params := *p
+	params.Inputs = append([]*types.CellInput{}, p.Inputs...)
+	params.Outputs = append([]*types.CellOutput{}, p.Outputs...)
+	params.OutputsData = append([][]byte{}, p.OutputsData...)
+	change := &types.CellOutput{Lock: fp.ChangeLock}
+	params.Outputs = append(params.Outputs, change)
+	params.OutputsData = append(params.OutputsData, []byte{})
+	changeMin := change.OccupiedCapacity(nil) * common.OneCkb
+
+	var claimed []string
+	fail := func(err error) (uint64, error) {
+		if len(claimed) > 0 {
+			fp.DasCache.ClearOutPoint(claimed)
+		}
+		return 0, err
+	}
+	addBalanceCells := func(capacityNeed uint64) error {
+		if fp.DasCache == nil {
+			return fmt.Errorf("DasCache is nil")
+		}
+		// the inputs of the tx may have the balance lock
+		var exclude []*types.OutPoint
+		for _, v := range params.Inputs {
+			exclude = append(exclude, v.PreviousOutput)
+		}
+		_, liveCells, err := d.dasCore.GetBalanceCellWithLock(&core.ParamGetBalanceCells{
+			DasCache:          fp.DasCache,
+			LockScript:        balanceLock,
+			CapacityNeed:      capacityNeed,
+			CapacityForChange: common.OneCkb, // for the fee of the new inputs
+			SearchOrder:       indexer.SearchOrderDesc,
+			ExcludeOutPoints:  exclude,
+		})
+		if err != nil {
+			return fmt.Errorf("GetBalanceCellWithLock err: %s", err.Error())
+		}
+		for _, v := range liveCells {
+			params.Inputs = append(params.Inputs, &types.CellInput{
+				PreviousOutput: v.OutPoint,
+			})
+			claimed = append(claimed, common.OutPointStruct2String(v.OutPoint))
+		}
+		return nil
+	}
+	if len(params.Inputs) == 0 {
+		outputsCapacity := uint64(0)
+		for _, v := range params.Outputs {
+			outputsCapacity += v.Capacity
+		}
+		if err := addBalanceCells(outputsCapacity + changeMin); err != nil {
+			return fail(err)
+		}
+	}
+
+	for i := 0; i < maxFeeRounds; i++ {
+		txBuilder := NewDasTxBuilderFromBase(d.DasTxBuilderBase, nil)
+		txBuilder.MapInputsCell = d.MapInputsCell
+		if txBuilder.MapInputsCell == nil {
+			txBuilder.MapInputsCell = make(map[string]*types.CellWithStatus)
+		}
+		if err := txBuilder.BuildTransaction(&params); err != nil {
+			return fail(fmt.Errorf("BuildTransaction err: %s", err.Error()))
+		}
+		fee, err := txBuilder.EstimateTxFee(feeRate)
+		if err != nil {
+			return fail(fmt.Errorf("EstimateTxFee err: %s", err.Error()))
+		}
+		if fee >= maxFee {
+			return fail(fmt.Errorf("tx fee %d exceeds max fee %d", fee, maxFee))
+		}
+		inputsCapacity, err := txBuilder.getCapacityFromInputs()
+		if err != nil {
+			return fail(fmt.Errorf("getCapacityFromInputs err: %s", err.Error()))
+		}
+		outputsCapacity := txBuilder.Transaction.OutputsCapacity()
+		if inputsCapacity >= outputsCapacity+fee+changeMin {
+			// the size of tx does not change with the capacity
+			change.Capacity = inputsCapacity - outputsCapacity - fee
+			d.DasTxBuilderTransaction = txBuilder.DasTxBuilderTransaction
+			d.mapCellDep = txBuilder.mapCellDep
+			d.otherWitnesses = txBuilder.otherWitnesses
+			d.notCheckInputs = txBuilder.notCheckInputs
+			return fee, nil
+		}
+
+		if err := addBalanceCells(outputsCapacity + fee + changeMin - inputsCapacity); err != nil {
+			return fail(err)
+		}
+	}
+	return fail(fmt.Errorf("tx fee not converged after %d rounds", maxFeeRounds))
+}
@@ -0,0 +1,350 @@
+package txbuilder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/sign"
+	"github.com/dotbitHQ/das-lib/smt"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"strings"
+	"sync"
+)
+
+// the reverse smt:
+//   key   = blake2b(address payload)
+//   value = blake2b(nonce + account), the account is empty after remove so that the nonce is kept
+// each declaration is signed with the next nonce, a declaration can't be replayed once it is in the smt
+
+// ReverseSmtKey is the smt key of the address payload
+func ReverseSmtKey(address []byte) smt.H256 {
+	return common.Blake2b(address)
+}
+
+// ReverseSmtValue is the smt value of the nonce and the account, account is like xxx.bit
+func ReverseSmtValue(nonce uint32, account string) smt.H256 {
+	return common.Blake2b(append(molecule.GoU32ToBytes(nonce), []byte(account)...))
+}
+
+// ReverseSmtSignMsg is the message signed for the declaration of the nonce and the account by the personal sign algorithms,
+// ed25519 signs the digest itself, see ReverseSmtSignData
+func ReverseSmtSignMsg(nonce uint32, account string) string {
+	return ReverseSmtSignData(common.DasAlgorithmIdEth, nonce, account).SignMsg
+}
+
+// ReverseSmtSignData returns the message of the declaration in the same format as the tx digest (generateDigestByGroup):
+// ed25519 signs the 32 bytes digest blake2b(nonce + account), the others sign "From .bit: " + hex(digest)
+func ReverseSmtSignData(signType common.DasAlgorithmId, nonce uint32, account string) SignData {
+	data := common.Blake2b(append(molecule.GoU32ToBytes(nonce), []byte(account)...))
+	if signType == common.DasAlgorithmIdEd25519 {
+		return SignData{SignType: signType, SignMsg: common.Bytes2Hex(data)}
+	}
+	return SignData{SignType: signType, SignMsg: common.DotBitPrefix + hex.EncodeToString(data)}
+}
+
+// ReverseSmtState is the record of an address in the reverse smt, Nonce is 0 if the address has never declared
+type ReverseSmtState struct {
+	Nonce   uint32
+	Account string
+}
+
+// ReverseSmtStateStore returns the current records of the addresses,
+// the records of a tx are applied to it after the tx is committed
+type ReverseSmtStateStore interface {
+	GetReverseSmtState(address []byte) (ReverseSmtState, error)
+}
+
+type MemReverseSmtStateStore struct {
+	lock   sync.RWMutex
+	states map[string]ReverseSmtState
+}
+
+func NewMemReverseSmtStateStore() *MemReverseSmtStateStore {
+	return &MemReverseSmtStateStore{states: make(map[string]ReverseSmtState)}
+}
+
+func (m *MemReverseSmtStateStore) GetReverseSmtState(address []byte) (ReverseSmtState, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.states[common.Bytes2Hex(address)], nil
+}
+
+func (m *MemReverseSmtStateStore) SetReverseSmtState(address []byte, state ReverseSmtState) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.states[common.Bytes2Hex(address)] = state
+}
+
+// Apply updates the states by the records of a committed tx
+func (m *MemReverseSmtStateStore) Apply(records []*witness.ReverseSmtRecord) {
+	for _, v := range records {
+		m.SetReverseSmtState(v.Address, ReverseSmtState{Nonce: v.PrevNonce + 1, Account: v.NextAccount})
+	}
+}
+
+// ReverseSmtDeclaration is a signed declaration of an address,
+// Address is the payload of the address (the public key for ed25519), Account is empty for remove
+type ReverseSmtDeclaration struct {
+	Action    witness.ReverseSmtRecordAction
+	SignType  common.DasAlgorithmId
+	Address   []byte
+	Account   string
+	Signature []byte
+}
+
+// Verify checks the signature of the declaration with the nonce, the message is ReverseSmtSignData
+func (r *ReverseSmtDeclaration) Verify(nonce uint32) (bool, error) {
+	signMsg := ReverseSmtSignData(r.SignType, nonce, r.Account).SignMsg
+	addressHex := common.FormatAddressPayload(r.Address, r.SignType)
+	sig := make([]byte, len(r.Signature))
+	copy(sig, r.Signature)
+	switch r.SignType {
+	case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712:
+		return sign.VerifyPersonalSignature(sig, []byte(signMsg), addressHex)
+	case common.DasAlgorithmIdTron:
+		base58Addr, err := common.TronHexToBase58(addressHex)
+		if err != nil {
+			return false, fmt.Errorf("TronHexToBase58 err: %s", err.Error())
+		}
+		return sign.TronVerifySignature(true, sig, []byte(signMsg), base58Addr), nil
+	case common.DasAlgorithmIdDogeChain:
+		return sign.VerifyDogeSignature(sig, []byte(signMsg), addressHex)
+	case common.DasAlgorithmIdBitcoin:
+		return sign.VerifyBitcoinSignature(sig, []byte(signMsg), addressHex)
+	case common.DasAlgorithmIdEd25519:
+		return sign.VerifyEd25519Signature(r.Address, common.Hex2Bytes(signMsg), sig), nil
+	default:
+		return false, fmt.Errorf("not support sign type[%d]", r.SignType)
+	}
+}
+
+type ReverseSmtTxParams struct {
+	DasCore  *core.DasCore
+	DasCache *dascache.DasCache
+
+	// the reverse smt, *smt.SparseMerkleTree or *smt.SmtServer, roll it back if the tx is not sent
+	ReverseSmt   SubAccountSmt
+	StateStore   ReverseSmtStateStore
+	Declarations []*ReverseSmtDeclaration
+
+	PayerLock *types.Script // pays the tx fee
+	TxFee     uint64        // common.UserCellTxFeeLimit if 0
+}
+
+// BuildReverseSmtTx builds the tx of update_reverse_record_root for the declarations,
+// the records returned are the witnesses of the tx, apply them to the StateStore after the tx is committed.
+// The smt is updated after the payer cells are selected, and rolled back if the tx fails to build after the update
+func BuildReverseSmtTx(p ReverseSmtTxParams) (*BuildTransactionParams, []*witness.ReverseSmtRecord, error) {
+	var txParams BuildTransactionParams
+
+	// check
+	if p.ReverseSmt == nil {
+		return nil, nil, fmt.Errorf("ReverseSmt is nil")
+	}
+	if p.StateStore == nil {
+		return nil, nil, fmt.Errorf("StateStore is nil")
+	}
+	if p.PayerLock == nil {
+		return nil, nil, fmt.Errorf("PayerLock is nil")
+	}
+	if len(p.Declarations) == 0 {
+		return nil, nil, fmt.Errorf("Declarations is nil")
+	}
+	if p.TxFee == 0 {
+		p.TxFee = common.UserCellTxFeeLimit
+	}
+
+	// declarations
+	var kvs []smt.SmtKv
+	records := make([]*witness.ReverseSmtRecord, 0, len(p.Declarations))
+	oldValues := make([]smt.H256, 0, len(p.Declarations))
+	addresses := make(map[string]struct{})
+	for _, v := range p.Declarations {
+		addressHex := common.Bytes2Hex(v.Address)
+		if len(v.Address) == 0 {
+			return nil, nil, fmt.Errorf("address of declaration is nil")
+		}
+		if _, ok := addresses[addressHex]; ok {
+			return nil, nil, fmt.Errorf("address[%s] duplicated", addressHex)
+		}
+		addresses[addressHex] = struct{}{}
+
+		state, err := p.StateStore.GetReverseSmtState(v.Address)
+		if err != nil {
+			return nil, nil, fmt.Errorf("GetReverseSmtState err: %s", err.Error())
+		}
+		switch v.Action {
+		case witness.ReverseSmtRecordActionUpdate:
+			if !strings.HasSuffix(v.Account, common.DasAccountSuffix) {
+				return nil, nil, fmt.Errorf("account[%s] of address[%s] invalid", v.Account, addressHex)
+			}
+		case witness.ReverseSmtRecordActionRemove:
+			if v.Account != "" {
+				return nil, nil, fmt.Errorf("account of remove must be empty, address[%s]", addressHex)
+			}
+			if state.Account == "" {
+				return nil, nil, fmt.Errorf("address[%s] has no reverse record", addressHex)
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupport reverse smt action[%s]", v.Action)
+		}
+		if ok, err := v.Verify(state.Nonce + 1); err != nil {
+			return nil, nil, fmt.Errorf("Verify err: %s", err.Error())
+		} else if !ok {
+			return nil, nil, fmt.Errorf("signature of address[%s] invalid", addressHex)
+		}
+
+		signature := v.Signature
+		switch v.SignType {
+		case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712, common.DasAlgorithmIdTron, common.DasAlgorithmIdDogeChain:
+			signature = common.Hex2Bytes(fixSignature(v.SignType, common.Bytes2Hex(v.Signature)))
+		}
+
+		oldValue := smt.H256Zero()
+		if state.Nonce > 0 {
+			oldValue = ReverseSmtValue(state.Nonce, state.Account)
+		}
+		oldValues = append(oldValues, oldValue)
+		kvs = append(kvs, smt.SmtKv{Key: ReverseSmtKey(v.Address), Value: ReverseSmtValue(state.Nonce+1, v.Account)})
+		records = append(records, &witness.ReverseSmtRecord{
+			Version:     witness.ReverseSmtRecordVersion1,
+			Action:      v.Action,
+			Signature:   signature,
+			SignType:    uint8(v.SignType),
+			Address:     v.Address,
+			PrevNonce:   state.Nonce,
+			PrevAccount: state.Account,
+			NextAccount: v.Account,
+		})
+	}
+
+	// reverse record root cell
+	rootCell, err := p.DasCore.GetReverseRecordSmtCell()
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetReverseRecordSmtCell err: %s", err.Error())
+	}
+	prevRoot := smt.H256Zero()
+	if len(rootCell.OutputData) > 0 {
+		if len(rootCell.OutputData) != 32 {
+			return nil, nil, fmt.Errorf("reverse record root cell data is invalid")
+		}
+		prevRoot = rootCell.OutputData
+	}
+
+	// the smt must be in sync with the reverse record root cell before the update
+	if root, ok, err := subAccountSmtRoot(p.ReverseSmt); err != nil {
+		return nil, nil, fmt.Errorf("smt root err: %s", err.Error())
+	} else if ok && common.Bytes2Hex(root) != common.Bytes2Hex(prevRoot) {
+		return nil, nil, fmt.Errorf("smt root of reverse record root cell mismatch, smt root[%s]", common.Bytes2Hex(root))
+	}
+
+	// witness action
+	actionWitness, err := witness.GenActionDataWitness(common.DasActionUpdateReverseRecordRoot, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GenActionDataWitness err: %s", err.Error())
+	}
+
+	// cell deps
+	contractReverseRoot, err := core.GetDasContractInfo(common.DasContractNameReverseRecordRootCellType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetDasContractInfo err: %s", err.Error())
+	}
+	configCellReverse, err := core.GetDasConfigCellInfo(common.ConfigCellTypeArgsReverseRecord)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetDasConfigCellInfo err: %s", err.Error())
+	}
+	configCellSmtWhitelist, err := core.GetDasConfigCellInfo(common.ConfigCellTypeArgsSMTNodeWhitelist)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetDasConfigCellInfo err: %s", err.Error())
+	}
+	txParams.CellDeps = append(txParams.CellDeps,
+		contractReverseRoot.ToCellDep(),
+		configCellReverse.ToCellDep(),
+		configCellSmtWhitelist.ToCellDep(),
+	)
+
+	// payer, the last step before the smt update
+	change, payerLiveCells, err := p.DasCore.GetBalanceCellWithLock(&core.ParamGetBalanceCells{
+		DasCache:          p.DasCache,
+		LockScript:        p.PayerLock,
+		CapacityNeed:      p.TxFee,
+		CapacityForChange: common.MinCellOccupiedCkb,
+		SearchOrder:       indexer.SearchOrderDesc,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetBalanceCellWithLock err: %s", err.Error())
+	}
+
+	// smt, the proof of each record proves the old value under the previous root and the new value under NextRoot
+	res, err := p.ReverseSmt.UpdateMiddleSmt(kvs, smt.SmtOpt{GetProof: true, GetRoot: true})
+	if err != nil {
+		releaseLiveCells(p.DasCache, payerLiveCells)
+		return nil, nil, fmt.Errorf("UpdateMiddleSmt err: %s", err.Error())
+	}
+	rollback := func(e error) (*BuildTransactionParams, []*witness.ReverseSmtRecord, error) {
+		releaseLiveCells(p.DasCache, payerLiveCells)
+		if err := rollbackMiddleSmt(p.ReverseSmt, kvs, oldValues); err != nil {
+			return nil, nil, fmt.Errorf("%s, rollback smt err: %s", e.Error(), err.Error())
+		}
+		return nil, nil, e
+	}
+	for i, v := range records {
+		key := common.Bytes2Hex(kvs[i].Key)
+		root, ok := res.Roots[key]
+		if !ok {
+			return rollback(fmt.Errorf("root of address[%s] not exist", common.Bytes2Hex(v.Address)))
+		}
+		proof := smt.CompiledMerkleProof(common.Hex2Bytes(res.Proofs[key]))
+		if ok, err := smt.Verify(prevRoot, &proof, []smt.H256{kvs[i].Key}, []smt.H256{oldValues[i]}); err != nil || !ok {
+			return rollback(fmt.Errorf("smt root of reverse record root cell mismatch, address[%s]", common.Bytes2Hex(v.Address)))
+		}
+		if ok, err := smt.Verify(root, &proof, []smt.H256{kvs[i].Key}, []smt.H256{kvs[i].Value}); err != nil || !ok {
+			return rollback(fmt.Errorf("smt proof of address[%s] invalid", common.Bytes2Hex(v.Address)))
+		}
+		v.NextRoot = root
+		v.Proof = proof
+		prevRoot = root
+	}
+
+	// witness
+	txParams.Witnesses = append(txParams.Witnesses, actionWitness)
+	for _, v := range records {
+		recordWitness, err := witness.GenDasDataWitnessWithStruct(common.ActionDataTypeReverseSmt, v)
+		if err != nil {
+			return rollback(fmt.Errorf("GenDasDataWitnessWithStruct err: %s", err.Error()))
+		}
+		txParams.Witnesses = append(txParams.Witnesses, recordWitness)
+	}
+
+	// inputs
+	txParams.Inputs = append(txParams.Inputs, &types.CellInput{
+		Since:          0,
+		PreviousOutput: rootCell.OutPoint,
+	})
+	for _, v := range payerLiveCells {
+		txParams.Inputs = append(txParams.Inputs, &types.CellInput{
+			Since:          0,
+			PreviousOutput: v.OutPoint,
+		})
+	}
+
+	// outputs reverse record root cell and the change of payer
+	txParams.Outputs = append(txParams.Outputs, &types.CellOutput{
+		Capacity: rootCell.Output.Capacity,
+		Lock:     rootCell.Output.Lock,
+		Type:     rootCell.Output.Type,
+	})
+	txParams.OutputsData = append(txParams.OutputsData, prevRoot)
+	txParams.Outputs = append(txParams.Outputs, &types.CellOutput{
+		Capacity: change,
+		Lock:     p.PayerLock,
+	})
+	txParams.OutputsData = append(txParams.OutputsData, []byte{})
+
+	return &txParams, records, nil
+}
@@ -1,8 +1,10 @@
 package txbuilder
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/sign"
 	"github.com/nervosnetwork/ckb-sdk-go/transaction"
 	"github.com/nervosnetwork/ckb-sdk-go/types"
 	"strings"
@@ -59,7 +61,89 @@ func (d *DasTxBuilder) AddMultiSignatureForTx(group []int, firstN uint8, signatu
 	return nil
 }
 
+// SignBySigners signs the digests of GenerateDigestListFromTx(skipGroups) by the signers of SetSigners,
+// the groups skipped are left empty, the groups of the ckb multisig lock are signed by the sign.MultisigSigner of the lock args,
+// the other any-lock groups are left empty like the skipped ones
+func (d *DasTxBuilder) SignBySigners(skipGroups []int) ([]SignData, error) {
+	if d.signers == nil {
+		return nil, fmt.Errorf("signers is nil")
+	}
+	digestList, err := d.GenerateDigestListFromTx(skipGroups)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateDigestListFromTx err: %s", err.Error())
+	}
+	groups, err := d.getGroupsFromTx()
+	if err != nil {
+		return nil, fmt.Errorf("getGroupsFromTx err: %s", err.Error())
+	}
+	signData := make([]SignData, len(digestList))
+	for i, v := range digestList {
+		signData[i].SignType = v.SignType
+		if v.SignMsg == "" {
+			continue
+		}
+		if v.SignType == common.DasAlgorithmIdAnyLock {
+			multiSign, ok, err := d.multiSignDigest(groups[i])
+			if err != nil {
+				return nil, fmt.Errorf("group %d of any lock err: %s", i, err.Error())
+			} else if !ok {
+				continue
+			}
+			v = multiSign
+			signData[i].SignType = v.SignType
+		}
+		sig, err := d.signers.Sign(&sign.SignRequest{
+			SignType:    v.SignType,
+			SignMsg:     v.SignMsg,
+			BuildMMJson: d.BuildMMJsonObj,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("sign group %d err: %s", i, err.Error())
+		}
+		signData[i].SignMsg = common.Bytes2Hex(sig)
+	}
+	return signData, nil
+}
+
+// multiSignDigest returns the digest of the group of the ckb multisig lock signed by the MultisigSigner of the signers,
+// it is false if the lock is not the multisig lock or there is no MultisigSigner
+func (d *DasTxBuilder) multiSignDigest(group []int) (SignData, bool, error) {
+	item, err := d.getInputCell(d.Transaction.Inputs[group[0]].PreviousOutput)
+	if err != nil {
+		return SignData{}, false, fmt.Errorf("getInputCell err: %s", err.Error())
+	}
+	lock := item.Cell.Output.Lock
+	if lock.CodeHash.Hex() != transaction.SECP256K1_BLAKE160_MULTISIG_ALL_TYPE_HASH || len(lock.Args) < 20 {
+		return SignData{}, false, nil
+	}
+	signer, ok := d.signers.Get(common.DasAlgorithmIdCkbMulti)
+	if !ok {
+		return SignData{}, false, nil
+	}
+	multisigSigner, ok := signer.(*sign.MultisigSigner)
+	if !ok {
+		return SignData{}, false, fmt.Errorf("signer of multisig is not a MultisigSigner")
+	}
+	config := multisigSigner.Config()
+	if !bytes.Equal(config.Args(), lock.Args[:20]) {
+		return SignData{}, false, fmt.Errorf("multisig signer not match the lock args")
+	}
+	digest, err := d.GenerateMultiSignDigest(group, config.RequireFirstN, make([][]byte, config.Threshold), config.PubKeyHashes)
+	if err != nil {
+		return SignData{}, false, fmt.Errorf("GenerateMultiSignDigest err: %s", err.Error())
+	}
+	return SignData{SignType: common.DasAlgorithmIdCkbMulti, SignMsg: common.Bytes2Hex(digest)}, true, nil
+}
+
+// AddSignatureForTx adds the signatures of the groups in the order of GenerateDigestListFromTx, the groups are signed
+// by SignBySigners if signData is nil. The groups with empty SignMsg or of any lock are skipped, so are the ones after signData
 func (d *DasTxBuilder) AddSignatureForTx(signData []SignData) error {
+	if len(signData) == 0 && d.signers != nil {
+		var err error
+		if signData, err = d.SignBySigners(nil); err != nil {
+			return fmt.Errorf("SignBySigners err: %s", err.Error())
+		}
+	}
 	if signData == nil || len(signData) == 0 {
 		return fmt.Errorf("signData is nil")
 	}
@@ -71,6 +155,9 @@ func (d *DasTxBuilder) AddSignatureForTx(signData []SignData) error {
 
 	// [[0,1], [2]]
 	for i, group := range tmpMapForGroup {
+		if i >= len(signData) {
+			break
+		}
 		sig := signData[i].SignMsg
 
 		if sig == "" {
@@ -80,7 +167,7 @@ func (d *DasTxBuilder) AddSignatureForTx(signData []SignData) error {
 			continue
 		}
 		if signData[i].SignType == common.DasAlgorithmIdWebauthn {
-			temp := make([]byte, 800) //types.WitnessArgs(lock:800,)
+			temp := make([]byte, sign.SignatureSize(common.DasAlgorithmIdWebauthn)) //types.WitnessArgs(lock:800,)
 			copy(temp, common.Hex2Bytes(sig))
 			sig = common.Bytes2Hex(temp)
 		}
@@ -103,39 +190,53 @@ func (d *DasTxBuilder) AddSignatureForTx(signData []SignData) error {
 func (d *DasTxBuilder) fixDasSignature(signData []SignData) {
 	for i, v := range signData {
 		log.Info("fixDasSignature:", v.SignMsg)
-		switch v.SignType {
-		case common.DasAlgorithmIdCkb:
-		case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712, common.DasAlgorithmIdDogeChain:
-			if len(v.SignMsg) >= 132 && v.SignMsg[130:132] == "1b" {
-				signData[i].SignMsg = v.SignMsg[0:130] + "00" + v.SignMsg[132:len(v.SignMsg)]
-			}
-			if len(v.SignMsg) >= 132 && v.SignMsg[130:132] == "1c" {
-				signData[i].SignMsg = v.SignMsg[0:130] + "01" + v.SignMsg[132:len(v.SignMsg)]
-			}
-		case common.DasAlgorithmIdTron:
-			if strings.HasSuffix(v.SignMsg, "1b") {
-				signData[i].SignMsg = v.SignMsg[0:len(v.SignMsg)-2] + "00"
-			}
-			if strings.HasSuffix(v.SignMsg, "1c") {
-				signData[i].SignMsg = v.SignMsg[0:len(v.SignMsg)-2] + "01"
-			}
-		default:
-			log.Warn("unknown sign type:", v.SignType)
+		signData[i].SignMsg = fixSignature(v.SignType, v.SignMsg)
+	}
+}
+
+// fixSignature converts the recovery id 1b/1c of the signature to 00/01
+func fixSignature(signType common.DasAlgorithmId, signMsg string) string {
+	switch signType {
+	case common.DasAlgorithmIdCkb, common.DasAlgorithmIdCkbMulti:
+	case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712, common.DasAlgorithmIdDogeChain:
+		if len(signMsg) >= 132 && signMsg[130:132] == "1b" {
+			signMsg = signMsg[0:130] + "00" + signMsg[132:len(signMsg)]
+		}
+		if len(signMsg) >= 132 && signMsg[130:132] == "1c" {
+			signMsg = signMsg[0:130] + "01" + signMsg[132:len(signMsg)]
 		}
+	case common.DasAlgorithmIdTron:
+		if strings.HasSuffix(signMsg, "1b") {
+			signMsg = signMsg[0:len(signMsg)-2] + "00"
+		}
+		if strings.HasSuffix(signMsg, "1c") {
+			signMsg = signMsg[0:len(signMsg)-2] + "01"
+		}
+	default:
+		log.Warn("unknown sign type:", signType)
 	}
+	return signMsg
 }
 
 func (d *DasTxBuilder) serverSignTx() error {
 	if len(d.ServerSignGroup) == 0 {
 		return nil
 	}
-	if d.handleServerSign == nil {
+	handleServerSign := d.handleServerSign
+	if handleServerSign == nil && d.signers != nil {
+		if signer, ok := d.signers.Get(common.DasAlgorithmIdCkb); ok {
+			handleServerSign = func(message string) ([]byte, error) {
+				return signer.Sign(&sign.SignRequest{SignType: common.DasAlgorithmIdCkb, SignMsg: message})
+			}
+		}
+	}
+	if handleServerSign == nil {
 		return fmt.Errorf("handleRemoteSign is nil")
 	}
 	if digest, err := d.generateDigestByGroup(d.ServerSignGroup, []int{}); err != nil {
 		return fmt.Errorf("generateDigestByGroup err: %s", err.Error())
 	} else {
-		sig, err := d.handleServerSign(digest.SignMsg)
+		sig, err := handleServerSign(digest.SignMsg)
 		if err != nil {
 			return fmt.Errorf("handleServerSign err: %s", err.Error())
 		}
@@ -0,0 +1,454 @@
+package txbuilder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"time"
+)
+
+type SimulateCode = string
+
+const (
+	SimulateCodeTxSize              SimulateCode = "tx_size"
+	SimulateCodeInputNotLive        SimulateCode = "input_not_live"
+	SimulateCodeTxFee               SimulateCode = "tx_fee"
+	SimulateCodeOccupiedCapacity    SimulateCode = "occupied_capacity"
+	SimulateCodeCellDep             SimulateCode = "cell_dep"
+	SimulateCodeWitnessLock         SimulateCode = "witness_lock"
+	SimulateCodeActionData          SimulateCode = "action_data"
+	SimulateCodeWitnessData         SimulateCode = "witness_data"
+	SimulateCodeAccountIdChanged    SimulateCode = "account_id_changed"
+	SimulateCodeAccountStatus       SimulateCode = "account_status"
+	SimulateCodeAccountExpired      SimulateCode = "account_expired"
+	SimulateCodeAccountInGrace      SimulateCode = "account_in_grace_period"
+	SimulateCodeExpiredAt           SimulateCode = "expired_at"
+	SimulateCodeTransferThrottle    SimulateCode = "transfer_account_throttle"
+	SimulateCodeEditManagerThrottle SimulateCode = "edit_manager_throttle"
+	SimulateCodeEditRecordsThrottle SimulateCode = "edit_records_throttle"
+	SimulateCodeRecordTtl           SimulateCode = "record_ttl"
+	SimulateCodeSaleMinPrice        SimulateCode = "sale_min_price"
+	SimulateCodeSaleCapacity        SimulateCode = "sale_cell_capacity"
+	SimulateCodeIncomeCapacity      SimulateCode = "income_cell_capacity"
+	SimulateCodeIncomeRecord        SimulateCode = "income_record"
+)
+
+// SimulateViolation is a rule the contracts would reject the tx for,
+// Index is the index of the related input or output, -1 if it is about the whole tx
+type SimulateViolation struct {
+	Code    SimulateCode `json:"code"`
+	Index   int          `json:"index"`
+	Account string       `json:"account,omitempty"`
+	Message string       `json:"message"`
+}
+
+type SimulateResult struct {
+	Action     common.DasAction    `json:"action"`
+	Timestamp  int64               `json:"timestamp"`
+	TxFee      uint64              `json:"tx_fee"`
+	Violations []SimulateViolation `json:"violations"`
+}
+
+func (s *SimulateResult) Ok() bool {
+	return len(s.Violations) == 0
+}
+
+func (s *SimulateResult) addViolation(code SimulateCode, index int, account, format string, a ...interface{}) {
+	s.Violations = append(s.Violations, SimulateViolation{
+		Code:    code,
+		Index:   index,
+		Account: account,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// Simulate checks tx offline against the rules of the das contracts, the returned error is only for
+// failures of loading chain data, rule violations are collected in SimulateResult.Violations.
+// d.Transaction is used when tx is nil
+func (d *DasTxBuilder) Simulate(tx *types.Transaction) (*SimulateResult, error) {
+	if tx == nil {
+		tx = d.Transaction
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("tx is nil")
+	}
+	if d.MapInputsCell == nil {
+		d.MapInputsCell = make(map[string]*types.CellWithStatus)
+	}
+	var res SimulateResult
+
+	// same as checkTxBeforeSend
+	if len(tx.Inputs)+len(tx.Outputs) > 9000 {
+		res.addViolation(SimulateCodeTxSize, -1, "", "len of inputs: %d, outputs: %d", len(tx.Inputs), len(tx.Outputs))
+	}
+	inputsCell := make([]*types.CellWithStatus, len(tx.Inputs))
+	totalCapacityFromInputs, allLive := uint64(0), true
+	for i, v := range tx.Inputs {
+		item, err := d.getInputCell(v.PreviousOutput)
+		if err != nil {
+			allLive = false
+			res.addViolation(SimulateCodeInputNotLive, i, "", "getInputCell err: %s", err.Error())
+			continue
+		}
+		inputsCell[i] = item
+		totalCapacityFromInputs += item.Cell.Output.Capacity
+	}
+	totalCapacityFromOutputs := tx.OutputsCapacity()
+	if allLive {
+		if totalCapacityFromInputs <= totalCapacityFromOutputs {
+			res.addViolation(SimulateCodeTxFee, -1, "", "capacity of inputs: %d is not greater than outputs: %d", totalCapacityFromInputs, totalCapacityFromOutputs)
+		} else if res.TxFee = totalCapacityFromInputs - totalCapacityFromOutputs; res.TxFee >= common.OneCkb {
+			res.addViolation(SimulateCodeTxFee, -1, "", "tx fee: %d is not less than 1 CKB", res.TxFee)
+		}
+	}
+	for i, v := range tx.Outputs {
+		var data []byte
+		if i < len(tx.OutputsData) {
+			data = tx.OutputsData[i]
+		}
+		// OccupiedCapacity is in bytes, 1 byte takes 1 CKB
+		if occupied := v.OccupiedCapacity(data) * common.OneCkb; v.Capacity < occupied {
+			res.addViolation(SimulateCodeOccupiedCapacity, i, "", "occupied: %d capacity: %d", occupied, v.Capacity)
+		}
+	}
+
+	d.simulateCellDeps(tx, inputsCell, &res)
+	simulateWitnessLock(tx, inputsCell, &res)
+
+	// das rules
+	builder, err := witness.ActionDataBuilderFromTx(tx)
+	if err != nil {
+		if err != witness.ErrNotExistActionData {
+			res.addViolation(SimulateCodeActionData, -1, "", "ActionDataBuilderFromTx err: %s", err.Error())
+		}
+		return &res, nil
+	}
+	res.Action = builder.Action
+
+	if timeCell, err := d.dasCore.GetTimeCell(); err != nil {
+		log.Warn("Simulate GetTimeCell err:", err.Error())
+		res.Timestamp = time.Now().Unix()
+	} else {
+		res.Timestamp = timeCell.Timestamp()
+	}
+
+	if err := d.simulateAccountCell(tx, inputsCell, builder.Action, &res); err != nil {
+		return nil, fmt.Errorf("simulateAccountCell err: %s", err.Error())
+	}
+	if err := d.simulateAccountSaleCell(tx, builder.Action, &res); err != nil {
+		return nil, fmt.Errorf("simulateAccountSaleCell err: %s", err.Error())
+	}
+	if err := d.simulateIncomeCell(tx, builder.Action, &res); err != nil {
+		return nil, fmt.Errorf("simulateIncomeCell err: %s", err.Error())
+	}
+	return &res, nil
+}
+
+func (d *DasTxBuilder) simulateAccountCell(tx *types.Transaction, inputsCell []*types.CellWithStatus, action common.DasAction, res *SimulateResult) error {
+	switch action {
+	case common.DasActionTransferAccount, common.DasActionEditManager, common.DasActionEditRecords,
+		common.DasActionRenewAccount, common.DasActionStartAccountSale:
+	default:
+		return nil
+	}
+	mapOld, err := witness.AccountCellDataBuilderMapFromTx(tx, common.DataTypeOld)
+	if err != nil {
+		res.addViolation(SimulateCodeWitnessData, -1, "", "AccountCellDataBuilderMapFromTx old err: %s", err.Error())
+		return nil
+	}
+	mapNew, err := witness.AccountCellDataBuilderMapFromTx(tx, common.DataTypeNew)
+	if err != nil {
+		res.addViolation(SimulateCodeWitnessData, -1, "", "AccountCellDataBuilderMapFromTx new err: %s", err.Error())
+		return nil
+	}
+
+	configCell, err := d.dasCore.ConfigCellDataBuilderByTypeArgsList(common.ConfigCellTypeArgsAccount)
+	if err != nil {
+		return fmt.Errorf("ConfigCellDataBuilderByTypeArgsList err: %s", err.Error())
+	}
+	gracePeriod, err := configCell.ExpirationGracePeriod()
+	if err != nil {
+		return fmt.Errorf("ExpirationGracePeriod err: %s", err.Error())
+	}
+	now := uint64(res.Timestamp)
+
+	for acc, oldBuilder := range mapOld {
+		newBuilder, ok := mapNew[acc]
+		if !ok {
+			res.addViolation(SimulateCodeWitnessData, int(oldBuilder.Index), acc, "new account cell witness not exist")
+			continue
+		}
+		if newBuilder.AccountId != oldBuilder.AccountId {
+			res.addViolation(SimulateCodeAccountIdChanged, int(newBuilder.Index), acc, "account id changed from %s to %s", oldBuilder.AccountId, newBuilder.AccountId)
+		}
+
+		// the old data entity does not carry expired_at, read it from the input cell data
+		idx := int(oldBuilder.Index)
+		if idx >= len(inputsCell) || inputsCell[idx] == nil || inputsCell[idx].Cell.Data == nil {
+			res.addViolation(SimulateCodeWitnessData, idx, acc, "account cell input not exist")
+			continue
+		}
+		oldExpiredAt, err := common.GetAccountCellExpiredAtFromOutputData(inputsCell[idx].Cell.Data.Content)
+		if err != nil {
+			res.addViolation(SimulateCodeWitnessData, idx, acc, "GetAccountCellExpiredAtFromOutputData err: %s", err.Error())
+			continue
+		}
+
+		if action == common.DasActionRenewAccount {
+			if now >= oldExpiredAt+uint64(gracePeriod) {
+				res.addViolation(SimulateCodeAccountExpired, int(oldBuilder.Index), acc, "expired at: %d, grace period ended at: %d", oldExpiredAt, oldExpiredAt+uint64(gracePeriod))
+			}
+			if newBuilder.ExpiredAt <= oldExpiredAt {
+				res.addViolation(SimulateCodeExpiredAt, int(newBuilder.Index), acc, "new expired at: %d is not after old: %d", newBuilder.ExpiredAt, oldExpiredAt)
+			}
+			continue
+		}
+
+		if oldBuilder.Status != common.AccountStatusNormal {
+			res.addViolation(SimulateCodeAccountStatus, int(oldBuilder.Index), acc, "account status: %d is not normal", oldBuilder.Status)
+		}
+		if now >= oldExpiredAt+uint64(gracePeriod) {
+			res.addViolation(SimulateCodeAccountExpired, int(oldBuilder.Index), acc, "expired at: %d", oldExpiredAt)
+		} else if now >= oldExpiredAt {
+			res.addViolation(SimulateCodeAccountInGrace, int(oldBuilder.Index), acc, "expired at: %d, only renew is allowed until: %d", oldExpiredAt, oldExpiredAt+uint64(gracePeriod))
+		}
+
+		var throttle uint32
+		var code SimulateCode
+		var lastAt uint64
+		switch action {
+		case common.DasActionTransferAccount:
+			code, lastAt = SimulateCodeTransferThrottle, oldBuilder.LastTransferAccountAt
+			throttle, err = configCell.TransferAccountThrottle()
+		case common.DasActionEditManager:
+			code, lastAt = SimulateCodeEditManagerThrottle, oldBuilder.LastEditManagerAt
+			throttle, err = configCell.EditManagerThrottle()
+		case common.DasActionEditRecords:
+			code, lastAt = SimulateCodeEditRecordsThrottle, oldBuilder.LastEditRecordsAt
+			throttle, err = configCell.EditRecordsThrottle()
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get throttle err: %s", err.Error())
+		}
+		if lastAt+uint64(throttle) > now {
+			res.addViolation(code, int(oldBuilder.Index), acc, "last at: %d, throttle: %d, now: %d", lastAt, throttle, now)
+		}
+
+		if action == common.DasActionEditRecords {
+			minTtl, err := configCell.RecordMinTtl()
+			if err != nil {
+				return fmt.Errorf("RecordMinTtl err: %s", err.Error())
+			}
+			for _, v := range newBuilder.Records {
+				if v.TTL < minTtl {
+					res.addViolation(SimulateCodeRecordTtl, int(newBuilder.Index), acc, "record [%s.%s] ttl: %d is less than %d", v.Type, v.Key, v.TTL, minTtl)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DasTxBuilder) simulateAccountSaleCell(tx *types.Transaction, action common.DasAction, res *SimulateResult) error {
+	switch action {
+	case common.DasActionStartAccountSale, common.DasActionEditAccountSale:
+	default:
+		return nil
+	}
+	builder, err := witness.AccountSaleCellDataBuilderFromTx(tx, common.DataTypeNew)
+	if err != nil {
+		res.addViolation(SimulateCodeWitnessData, -1, "", "AccountSaleCellDataBuilderFromTx err: %s", err.Error())
+		return nil
+	}
+	configCell, err := d.dasCore.ConfigCellDataBuilderByTypeArgsList(common.ConfigCellTypeArgsSecondaryMarket)
+	if err != nil {
+		return fmt.Errorf("ConfigCellDataBuilderByTypeArgsList err: %s", err.Error())
+	}
+	minPrice, err := configCell.SaleMinPrice()
+	if err != nil {
+		return fmt.Errorf("SaleMinPrice err: %s", err.Error())
+	}
+	if builder.Price < minPrice {
+		res.addViolation(SimulateCodeSaleMinPrice, int(builder.Index), builder.Account, "price: %d is less than %d", builder.Price, minPrice)
+	}
+	if action != common.DasActionStartAccountSale {
+		return nil
+	}
+	basicCapacity, err := configCell.SaleCellBasicCapacity()
+	if err != nil {
+		return fmt.Errorf("SaleCellBasicCapacity err: %s", err.Error())
+	}
+	preparedFeeCapacity, err := configCell.SaleCellPreparedFeeCapacity()
+	if err != nil {
+		return fmt.Errorf("SaleCellPreparedFeeCapacity err: %s", err.Error())
+	}
+	if idx := int(builder.Index); idx < len(tx.Outputs) && tx.Outputs[idx].Capacity != basicCapacity+preparedFeeCapacity {
+		res.addViolation(SimulateCodeSaleCapacity, idx, builder.Account, "capacity: %d, want: %d", tx.Outputs[idx].Capacity, basicCapacity+preparedFeeCapacity)
+	}
+	return nil
+}
+
+func (d *DasTxBuilder) simulateIncomeCell(tx *types.Transaction, action common.DasAction, res *SimulateResult) error {
+	switch action {
+	case common.DasActionCreateIncome, common.DasActionConsolidateIncome:
+	default:
+		return nil
+	}
+	list, err := witness.IncomeCellDataBuilderListFromTx(tx, common.DataTypeNew)
+	if err != nil {
+		res.addViolation(SimulateCodeWitnessData, -1, "", "IncomeCellDataBuilderListFromTx err: %s", err.Error())
+		return nil
+	}
+	configCell, err := d.dasCore.ConfigCellDataBuilderByTypeArgsList(common.ConfigCellTypeArgsIncome)
+	if err != nil {
+		return fmt.Errorf("ConfigCellDataBuilderByTypeArgsList err: %s", err.Error())
+	}
+	basicCapacity, err := configCell.IncomeBasicCapacity()
+	if err != nil {
+		return fmt.Errorf("IncomeBasicCapacity err: %s", err.Error())
+	}
+	minTransferCapacity, err := configCell.IncomeMinTransferCapacity()
+	if err != nil {
+		return fmt.Errorf("IncomeMinTransferCapacity err: %s", err.Error())
+	}
+	for _, v := range list {
+		idx := int(v.Index)
+		if idx >= len(tx.Outputs) {
+			res.addViolation(SimulateCodeWitnessData, idx, "", "income cell index out of range")
+			continue
+		}
+		var creator []byte
+		if c := v.Creator(); c != nil {
+			creator = c.AsSlice()
+		}
+		total := uint64(0)
+		for _, r := range v.Records() {
+			total += r.Capacity
+			// the records of others reach the min transfer capacity should be transferred to their owners
+			if action == common.DasActionConsolidateIncome && r.Capacity >= minTransferCapacity &&
+				r.BelongTo != nil && !bytes.Equal(r.BelongTo.AsSlice(), creator) {
+				res.addViolation(SimulateCodeIncomeRecord, idx, "", "record capacity: %d should be transferred, min transfer capacity: %d", r.Capacity, minTransferCapacity)
+			}
+		}
+		if capacity := tx.Outputs[idx].Capacity; capacity != total {
+			res.addViolation(SimulateCodeIncomeCapacity, idx, "", "capacity: %d is not equal to the sum of records: %d", capacity, total)
+		} else if capacity < basicCapacity {
+			res.addViolation(SimulateCodeIncomeCapacity, idx, "", "capacity: %d is less than basic capacity: %d", capacity, basicCapacity)
+		}
+	}
+	return nil
+}
+
+// simulateCellDeps checks the das contracts and the secp lock run by the tx are in the cell deps,
+// they are the locks of the inputs and the types of the inputs and outputs
+func (d *DasTxBuilder) simulateCellDeps(tx *types.Transaction, inputsCell []*types.CellWithStatus, res *SimulateResult) {
+	mapDeps := make(map[string]struct{})
+	for _, v := range tx.CellDeps {
+		if v != nil && v.OutPoint != nil {
+			mapDeps[common.OutPointStruct2String(v.OutPoint)] = struct{}{}
+		}
+	}
+	var secpDep *types.CellDep
+	if profile, err := d.networkProfile(); err != nil {
+		log.Warn("Simulate networkProfile err:", err.Error())
+	} else {
+		secpDep = profile.SecpDep.CellDep()
+	}
+
+	mapChecked := make(map[string]struct{})
+	check := func(script *types.Script, index int) {
+		if script == nil {
+			return
+		}
+		if _, ok := mapChecked[script.CodeHash.Hex()]; ok {
+			return
+		}
+		mapChecked[script.CodeHash.Hex()] = struct{}{}
+		if script.CodeHash.Hex() == transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH {
+			if secpDep == nil {
+				return
+			}
+			if _, ok := mapDeps[common.OutPointStruct2String(secpDep.OutPoint)]; !ok {
+				res.addViolation(SimulateCodeCellDep, index, "", "secp256k1 dep not exist")
+			}
+			return
+		}
+		core.DasContractMap.Range(func(key, value interface{}) bool {
+			item, ok := value.(*core.DasContractInfo)
+			if !ok || !item.IsSameTypeId(script.CodeHash) {
+				return true
+			}
+			if _, ok := mapDeps[common.OutPointStruct2String(item.OutPoint)]; !ok {
+				res.addViolation(SimulateCodeCellDep, index, "", "contract [%s] dep not exist", item.ContractName)
+			}
+			return false
+		})
+	}
+	for i, v := range inputsCell {
+		if v != nil {
+			check(v.Cell.Output.Lock, i)
+			check(v.Cell.Output.Type, i)
+		}
+	}
+	// the output locks are not run
+	for i, v := range tx.Outputs {
+		check(v.Type, i)
+	}
+}
+
+// simulateWitnessLock checks the first input of every lock group has a WitnessArgs with the lock for the signature
+func simulateWitnessLock(tx *types.Transaction, inputsCell []*types.CellWithStatus, res *SimulateResult) {
+	alwaysSuccess, _ := core.GetDasContractInfo(common.DasContractNameAlwaysSuccess)
+	mapGroup := make(map[string]struct{})
+	for i, v := range inputsCell {
+		if v == nil {
+			continue
+		}
+		lockHash, err := v.Cell.Output.Lock.Hash()
+		if err != nil {
+			res.addViolation(SimulateCodeWitnessLock, i, "", "lock hash err: %s", err.Error())
+			continue
+		}
+		if _, ok := mapGroup[lockHash.Hex()]; ok {
+			continue
+		}
+		mapGroup[lockHash.Hex()] = struct{}{}
+		if alwaysSuccess != nil && alwaysSuccess.IsSameTypeId(v.Cell.Output.Lock.CodeHash) {
+			continue
+		}
+		if i >= len(tx.Witnesses) {
+			res.addViolation(SimulateCodeWitnessLock, i, "", "witness of lock group not exist")
+			continue
+		}
+		if size, err := witnessArgsLockSize(tx.Witnesses[i]); err != nil {
+			res.addViolation(SimulateCodeWitnessLock, i, "", "witness is not WitnessArgs: %s", err.Error())
+		} else if size == 0 {
+			res.addViolation(SimulateCodeWitnessLock, i, "", "lock of witness is empty")
+		}
+	}
+}
+
+// witnessArgsLockSize returns the size of the lock of the molecule table WitnessArgs{lock, input_type, output_type}
+func witnessArgsLockSize(w []byte) (int, error) {
+	if len(w) < 16 || int(binary.LittleEndian.Uint32(w[0:4])) != len(w) {
+		return 0, fmt.Errorf("invalid size: %d", len(w))
+	}
+	lockStart, lockEnd := binary.LittleEndian.Uint32(w[4:8]), binary.LittleEndian.Uint32(w[8:12])
+	if lockStart != 16 || lockEnd < lockStart || int(lockEnd) > len(w) {
+		return 0, fmt.Errorf("invalid offsets: %d %d", lockStart, lockEnd)
+	}
+	if lockEnd == lockStart {
+		return 0, nil
+	}
+	if lockEnd-lockStart < 4 || binary.LittleEndian.Uint32(w[lockStart:lockStart+4]) != lockEnd-lockStart-4 {
+		return 0, fmt.Errorf("invalid lock size")
+	}
+	return int(lockEnd - lockStart - 4), nil
+}
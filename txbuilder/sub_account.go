@@ -0,0 +1,658 @@
+package txbuilder
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/smt"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"github.com/sjatsh/uint128"
+)
+
+// SubAccountSmt is the smt of the sub-accounts of a parent account, *smt.SparseMerkleTree or *smt.SmtServer.
+// BuildSubAccountTx updates it only when the tx is built, roll it back (Begin/Rollback of the local tree) if the tx is not sent
+type SubAccountSmt interface {
+	UpdateMiddleSmt(kv []smt.SmtKv, opt smt.SmtOpt) (*smt.UpdateMiddleSmtOut, error)
+}
+
+type SubAccountTxParams struct {
+	DasCore  *core.DasCore
+	DasCache *dascache.DasCache
+	Action   common.SubAction
+
+	ParentAccountCellOutPoint *types.OutPoint
+	SubAccountCellOutPoint    *types.OutPoint // the live sub-account cell of the parent account if nil
+	SubAccountSmt             SubAccountSmt
+
+	// SubAccountData is the current data of the sub-account (the new one for create),
+	// EditKey and EditValue (or EditRecords) are the changes, the new expired_at is the EditValue of renew.
+	// Version, NewRoot, Proof and the sub-account versions are filled by the builder
+	SubAccountList []*witness.SubAccountNew
+
+	// the signature of the owner or manager of the parent account for create, renew and the approval actions,
+	// the Signature, SignRole and SignExpiredAt of the SubAccountList are filled by it
+	SignBuilder *SubAccountSignBuilder
+	Signature   []byte
+
+	// price of create and renew, the price of the config cell is used if nil
+	CustomScriptConfig *witness.CustomScriptConfig
+	PayerLock          *types.Script // pays the price of create and renew
+}
+
+func BuildSubAccountTx(p SubAccountTxParams) (*BuildTransactionParams, error) {
+	switch p.Action {
+	case common.SubActionCreate:
+		return BuildSubAccountTxForCreate(p)
+	case common.SubActionEdit:
+		return BuildSubAccountTxForEdit(p)
+	case common.SubActionRenew:
+		return BuildSubAccountTxForRenew(p)
+	case common.SubActionRecycle:
+		return BuildSubAccountTxForRecycle(p)
+	case common.SubActionCreateApproval, common.SubActionDelayApproval,
+		common.SubActionRevokeApproval, common.SubActionFullfillApproval:
+		return BuildSubAccountTxForApproval(p)
+	default:
+		return nil, fmt.Errorf("unsupport sub-account action[%s]", p.Action)
+	}
+}
+
+func BuildSubAccountTxForCreate(p SubAccountTxParams) (*BuildTransactionParams, error) {
+	if err := p.checkSignBuilder(common.ActionDataTypeSubAccountMintSign); err != nil {
+		return nil, err
+	}
+	sa, err := newSubAccountTx(p)
+	if err != nil {
+		return nil, err
+	}
+	if int64(sa.parentBuilder.ExpiredAt) < sa.timeCell.Timestamp() {
+		return nil, fmt.Errorf("parent account expired")
+	}
+	for _, v := range p.SubAccountList {
+		data := v.SubAccountData
+		if data.Lock == nil {
+			return nil, fmt.Errorf("lock of sub-account[%s] is nil", data.Account())
+		}
+		if data.Suffix != "."+sa.parentBuilder.Account {
+			return nil, fmt.Errorf("suffix of sub-account[%s] invalid", data.Account())
+		}
+		if data.AccountId != common.Bytes2Hex(common.GetAccountIdByAccount(data.Account())) {
+			return nil, fmt.Errorf("account id of sub-account[%s] invalid", data.Account())
+		}
+		if data.RegisteredAt == 0 {
+			data.RegisteredAt = uint64(sa.timeCell.Timestamp())
+		}
+		if data.ExpiredAt <= data.RegisteredAt {
+			return nil, fmt.Errorf("expired_at of sub-account[%s] invalid", data.Account())
+		}
+		data.Status = common.AccountStatusNormal
+		if err := sa.addProfit(p, data.Account(), subAccountYears(data.ExpiredAt-data.RegisteredAt), false); err != nil {
+			return nil, err
+		}
+	}
+	return sa.build(p)
+}
+
+func BuildSubAccountTxForEdit(p SubAccountTxParams) (*BuildTransactionParams, error) {
+	sa, err := newSubAccountTx(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range p.SubAccountList {
+		switch v.EditKey {
+		case common.EditKeyOwner, common.EditKeyManager:
+			if len(v.EditValue) == 0 {
+				return nil, fmt.Errorf("edit value of sub-account[%s] is nil", v.SubAccountData.Account())
+			}
+		case common.EditKeyRecords:
+			if len(v.EditValue) == 0 {
+				v.EditValue = witness.ConvertToCellRecords(v.EditRecords).AsSlice()
+			}
+		default:
+			return nil, fmt.Errorf("unsupport edit key[%s]", v.EditKey)
+		}
+		if err := sa.checkAvailable(v.SubAccountData); err != nil {
+			return nil, err
+		}
+	}
+	return sa.build(p)
+}
+
+func BuildSubAccountTxForRenew(p SubAccountTxParams) (*BuildTransactionParams, error) {
+	if err := p.checkSignBuilder(common.ActionDataTypeSubAccountRenewSign); err != nil {
+		return nil, err
+	}
+	sa, err := newSubAccountTx(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range p.SubAccountList {
+		data := v.SubAccountData
+		if int64(data.ExpiredAt+uint64(sa.gracePeriod)) < sa.timeCell.Timestamp() {
+			return nil, fmt.Errorf("sub-account[%s] cannot be renewed", data.Account())
+		}
+		if len(v.EditValue) < 8 {
+			return nil, fmt.Errorf("new expired_at of sub-account[%s] is nil", data.Account())
+		}
+		expiredAt, _ := molecule.Bytes2GoU64(v.EditValue[:8])
+		if expiredAt <= data.ExpiredAt {
+			return nil, fmt.Errorf("new expired_at of sub-account[%s] invalid", data.Account())
+		}
+		if v.EditKey == "" {
+			v.EditKey = common.EditKeyExpiredAt
+		}
+		if err := sa.addProfit(p, data.Account(), subAccountYears(expiredAt-data.ExpiredAt), true); err != nil {
+			return nil, err
+		}
+	}
+	return sa.build(p)
+}
+
+func BuildSubAccountTxForRecycle(p SubAccountTxParams) (*BuildTransactionParams, error) {
+	sa, err := newSubAccountTx(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range p.SubAccountList {
+		if int64(v.SubAccountData.ExpiredAt+uint64(sa.gracePeriod)) > sa.timeCell.Timestamp() {
+			return nil, fmt.Errorf("sub-account[%s] cannot be recycled", v.SubAccountData.Account())
+		}
+	}
+	return sa.build(p)
+}
+
+func BuildSubAccountTxForApproval(p SubAccountTxParams) (*BuildTransactionParams, error) {
+	signAction := map[common.SubAction]common.ActionDataType{
+		common.SubActionCreateApproval:   common.ActionDataTypeSubAccountCreateApprovalSign,
+		common.SubActionDelayApproval:    common.ActionDataTypeSubAccountDelayApprovalSign,
+		common.SubActionRevokeApproval:   common.ActionDataTypeSubAccountRevokeApprovalSign,
+		common.SubActionFullfillApproval: common.ActionDataTypeSubAccountFulfillApprovalSign,
+	}[p.Action]
+	if err := p.checkSignBuilder(signAction); err != nil {
+		return nil, err
+	}
+	sa, err := newSubAccountTx(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range p.SubAccountList {
+		switch p.Action {
+		case common.SubActionCreateApproval, common.SubActionDelayApproval:
+			if len(v.EditValue) == 0 {
+				return nil, fmt.Errorf("approval of sub-account[%s] is nil", v.SubAccountData.Account())
+			}
+		}
+		if v.EditKey == "" {
+			v.EditKey = common.EditKeyApproval
+		}
+		if err := sa.checkAvailable(v.SubAccountData); err != nil {
+			return nil, err
+		}
+	}
+	return sa.build(p)
+}
+
+func (p *SubAccountTxParams) checkSignBuilder(action common.ActionDataType) error {
+	if p.SignBuilder != nil && p.SignBuilder.Action != action {
+		return fmt.Errorf("sign action[%s] of sub-account action[%s] invalid", p.SignBuilder.Action, p.Action)
+	}
+	return nil
+}
+
+// subAccountYears rounds up, a part of a year is charged as a year
+func subAccountYears(duration uint64) uint64 {
+	return (duration + uint64(common.OneYearSec) - 1) / uint64(common.OneYearSec)
+}
+
+type subAccountTx struct {
+	timeCell        *core.TimeCell
+	quoteCell       *core.QuoteCell
+	parentAccountId string
+	parentBuilder   *witness.AccountCellDataBuilder
+	subAccountCell  *indexer.LiveCell
+	detail          witness.SubAccountCellDataDetail
+	configCell      *witness.ConfigCellDataBuilder
+	gracePeriod     uint32
+	dasProfit       uint64
+	ownerProfit     uint64
+}
+
+func newSubAccountTx(p SubAccountTxParams) (*subAccountTx, error) {
+	var sa subAccountTx
+
+	// check
+	if p.ParentAccountCellOutPoint == nil {
+		return nil, fmt.Errorf("ParentAccountCellOutPoint is nil")
+	}
+	if p.SubAccountSmt == nil {
+		return nil, fmt.Errorf("SubAccountSmt is nil")
+	}
+	if len(p.SubAccountList) == 0 {
+		return nil, fmt.Errorf("SubAccountList is nil")
+	}
+	accountIds := make(map[string]struct{})
+	for _, v := range p.SubAccountList {
+		if v.SubAccountData == nil {
+			return nil, fmt.Errorf("SubAccountData is nil")
+		}
+		if v.Action == "" {
+			v.Action = p.Action
+		} else if v.Action != p.Action {
+			return nil, fmt.Errorf("action of sub-account[%s] is %s", v.SubAccountData.Account(), v.Action)
+		}
+		if _, ok := accountIds[v.SubAccountData.AccountId]; ok {
+			return nil, fmt.Errorf("sub-account[%s] duplicated", v.SubAccountData.Account())
+		}
+		accountIds[v.SubAccountData.AccountId] = struct{}{}
+	}
+
+	// parent account
+	parentTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.ParentAccountCellOutPoint.TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
+	}
+	if int(p.ParentAccountCellOutPoint.Index) >= len(parentTx.Transaction.OutputsData) {
+		return nil, fmt.Errorf("ParentAccountCellOutPoint is invalid")
+	}
+	parentData := parentTx.Transaction.OutputsData[p.ParentAccountCellOutPoint.Index]
+	if len(parentData) < common.ExpireTimeEndIndex {
+		return nil, fmt.Errorf("ParentAccountCellOutPoint is invalid")
+	}
+	sa.parentAccountId = common.Bytes2Hex(parentData[32:52])
+	parentBuilderMap, err := witness.AccountIdCellDataBuilderFromTx(parentTx.Transaction, common.DataTypeNew)
+	if err != nil {
+		return nil, fmt.Errorf("AccountIdCellDataBuilderFromTx err: %s", err.Error())
+	}
+	parentBuilder, ok := parentBuilderMap[sa.parentAccountId]
+	if !ok {
+		return nil, fmt.Errorf("parentBuilderMap not exist accountId: %s", sa.parentAccountId)
+	}
+	sa.parentBuilder = parentBuilder
+
+	// sub-account cell
+	if p.SubAccountCellOutPoint == nil {
+		sa.subAccountCell, err = p.DasCore.GetSubAccountCell(sa.parentAccountId)
+		if err != nil {
+			return nil, fmt.Errorf("GetSubAccountCell err: %s", err.Error())
+		}
+	} else {
+		subAccountTx, err := p.DasCore.ChainReader().GetTransaction(context.Background(), p.SubAccountCellOutPoint.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("GetTransaction err: %s", err.Error())
+		}
+		if int(p.SubAccountCellOutPoint.Index) >= len(subAccountTx.Transaction.Outputs) {
+			return nil, fmt.Errorf("SubAccountCellOutPoint is invalid")
+		}
+		sa.subAccountCell = &indexer.LiveCell{
+			OutPoint:   p.SubAccountCellOutPoint,
+			Output:     subAccountTx.Transaction.Outputs[p.SubAccountCellOutPoint.Index],
+			OutputData: subAccountTx.Transaction.OutputsData[p.SubAccountCellOutPoint.Index],
+		}
+	}
+	contractSubAcc, err := core.GetDasContractInfo(common.DASContractNameSubAccountCellType)
+	if err != nil {
+		return nil, fmt.Errorf("GetDasContractInfo err: %s", err.Error())
+	}
+	if subAccType := sa.subAccountCell.Output.Type; subAccType == nil || !contractSubAcc.IsSameTypeId(subAccType.CodeHash) ||
+		common.Bytes2Hex(subAccType.Args) != sa.parentAccountId {
+		return nil, fmt.Errorf("sub-account cell is invalid")
+	}
+	sa.detail = witness.ConvertSubAccountCellOutputData(sa.subAccountCell.OutputData)
+	if len(sa.detail.SmtRoot) != 32 {
+		return nil, fmt.Errorf("sub-account cell data is invalid")
+	}
+
+	// config
+	sa.timeCell, err = p.DasCore.GetTimeCell()
+	if err != nil {
+		return nil, fmt.Errorf("GetTimeCell err: %s", err.Error())
+	}
+	sa.configCell, err = p.DasCore.ConfigCellDataBuilderByTypeArgsList(common.ConfigCellTypeArgsAccount, common.ConfigCellTypeArgsSubAccount)
+	if err != nil {
+		return nil, fmt.Errorf("ConfigCellDataBuilderByTypeArgsList err: %s", err.Error())
+	}
+	sa.gracePeriod, err = sa.configCell.ExpirationGracePeriod()
+	if err != nil {
+		return nil, fmt.Errorf("ExpirationGracePeriod err: %s", err.Error())
+	}
+	return &sa, nil
+}
+
+func (sa *subAccountTx) checkAvailable(data *witness.SubAccountData) error {
+	if int64(data.ExpiredAt) < sa.timeCell.Timestamp() {
+		return fmt.Errorf("sub-account[%s] expired", data.Account())
+	}
+	return nil
+}
+
+// addProfit adds the price of create or renew to the profits of the sub-account cell,
+// the custom price is in usd and split by the das profit rate, the das part is not less than the price of the config cell
+func (sa *subAccountTx) addProfit(p SubAccountTxParams, account string, years uint64, isRenew bool) error {
+	var (
+		basePrice uint64
+		rate      uint32
+		err       error
+	)
+	if isRenew {
+		basePrice, err = sa.configCell.RenewSubAccountPrice()
+	} else {
+		basePrice, err = sa.configCell.NewSubAccountPrice()
+	}
+	if err != nil {
+		return fmt.Errorf("SubAccountPrice err: %s", err.Error())
+	}
+	dasProfit := basePrice * years
+	if p.CustomScriptConfig == nil {
+		sa.dasProfit += dasProfit
+		return nil
+	}
+
+	price, err := p.CustomScriptConfig.GetPriceBySubAccount(account)
+	if err != nil {
+		return fmt.Errorf("GetPriceBySubAccount err: %s", err.Error())
+	}
+	usdPrice := price.New
+	if isRenew {
+		usdPrice = price.Renew
+		rate, err = sa.configCell.RenewSubAccountCustomPriceDasProfitRate()
+	} else {
+		rate, err = sa.configCell.NewSubAccountCustomPriceDasProfitRate()
+	}
+	if err != nil {
+		return fmt.Errorf("SubAccountCustomPriceDasProfitRate err: %s", err.Error())
+	}
+	if sa.quoteCell == nil {
+		if sa.quoteCell, err = p.DasCore.GetQuoteCell(); err != nil {
+			return fmt.Errorf("GetQuoteCell err: %s", err.Error())
+		}
+	}
+	priceCapacity := uint128.From64(usdPrice).Mul(uint128.From64(common.OneCkb)).Div(uint128.From64(sa.quoteCell.Quote())).Big().Uint64()
+	priceCapacity = priceCapacity * years
+	if rateProfit := priceCapacity / common.PercentRateBase * uint64(rate); rateProfit > dasProfit {
+		dasProfit = rateProfit
+	}
+	if priceCapacity < dasProfit {
+		return fmt.Errorf("custom price of sub-account[%s] is less than the das price", account)
+	}
+	sa.dasProfit += dasProfit
+	sa.ownerProfit += priceCapacity - dasProfit
+	return nil
+}
+
+// build checks the params and selects the payer cells before updating the smt,
+// the smt is rolled back if the tx fails to build after the update
+func (sa *subAccountTx) build(p SubAccountTxParams) (*BuildTransactionParams, error) {
+	var txParams BuildTransactionParams
+	var builder witness.SubAccountNewBuilder
+
+	// sign
+	var signWitness []byte
+	if p.SignBuilder != nil {
+		var err error
+		if signWitness, _, err = p.SignBuilder.GenWitness(p.Signature); err != nil {
+			return nil, fmt.Errorf("GenWitness err: %s", err.Error())
+		}
+		for _, v := range p.SubAccountList {
+			proof, err := p.SignBuilder.MerkleProof(v.SubAccountData.AccountId)
+			if err != nil {
+				return nil, fmt.Errorf("MerkleProof err: %s", err.Error())
+			}
+			v.Signature = common.Hex2Bytes(proof)
+			v.SignRole = common.Hex2Bytes(p.SignBuilder.SignRole)
+			v.SignExpiredAt = p.SignBuilder.ExpiredAt
+		}
+	}
+
+	// smt kvs
+	var kvs []smt.SmtKv
+	oldValues := make([]smt.H256, len(p.SubAccountList))
+	for i, v := range p.SubAccountList {
+		v.Index = i
+		v.Version = witness.SubAccountNewVersionLatest
+		v.OldSubAccountVersion = v.SubAccountData.Version
+		if v.Action == common.SubActionCreate || v.OldSubAccountVersion == 0 {
+			v.OldSubAccountVersion = witness.SubAccountVersionLatest
+		}
+		v.NewSubAccountVersion = witness.SubAccountVersionLatest
+		v.SubAccountData.Version = v.OldSubAccountVersion
+
+		oldValues[i] = smt.H256Zero()
+		if v.Action != common.SubActionCreate {
+			value, err := v.SubAccountData.ToH256()
+			if err != nil {
+				return nil, fmt.Errorf("ToH256 err: %s", err.Error())
+			}
+			oldValues[i] = value
+		}
+		if err := builder.ConvertCurrentSubAccountData(v); err != nil {
+			return nil, fmt.Errorf("ConvertCurrentSubAccountData err: %s", err.Error())
+		}
+		newValue := smt.H256Zero()
+		if v.Action != common.SubActionRecycle {
+			value, err := v.CurrentSubAccountData.ToH256()
+			if err != nil {
+				return nil, fmt.Errorf("ToH256 err: %s", err.Error())
+			}
+			newValue = value
+		}
+		kvs = append(kvs, smt.SmtKv{Key: smt.AccountIdToSmtH256(v.SubAccountData.AccountId), Value: newValue})
+	}
+
+	// the smt must be in sync with the sub-account cell before the update
+	if root, ok, err := subAccountSmtRoot(p.SubAccountSmt); err != nil {
+		return nil, fmt.Errorf("smt root err: %s", err.Error())
+	} else if ok && common.Bytes2Hex(root) != common.Bytes2Hex(sa.detail.SmtRoot) {
+		return nil, fmt.Errorf("smt root of sub-account cell mismatch, smt root[%s]", common.Bytes2Hex(root))
+	}
+
+	// capacity of sub-account cell, the fee is paid by it
+	commonFee, err := sa.configCell.SubAccountCommonFee()
+	if err != nil {
+		return nil, fmt.Errorf("SubAccountCommonFee err: %s", err.Error())
+	}
+	profit := sa.dasProfit + sa.ownerProfit
+	if sa.subAccountCell.Output.Capacity+profit < commonFee {
+		return nil, fmt.Errorf("capacity of sub-account cell is not enough")
+	}
+	if profit > 0 && p.PayerLock == nil {
+		return nil, fmt.Errorf("PayerLock is nil")
+	}
+
+	// witness action
+	actionWitness, err := witness.GenActionDataWitness(common.DasActionUpdateSubAccount, nil)
+	if err != nil {
+		return nil, fmt.Errorf("GenActionDataWitness err: %s", err.Error())
+	}
+
+	// witness parent account cell, the first cell dep
+	parentWitness, _, err := sa.parentBuilder.GenWitness(&witness.AccountCellParam{
+		OldIndex: 0,
+		Action:   common.DasActionUpdateSubAccount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GenWitness err: %s", err.Error())
+	}
+
+	// cell deps
+	configCellAcc, err := core.GetDasConfigCellInfo(common.ConfigCellTypeArgsAccount)
+	if err != nil {
+		return nil, fmt.Errorf("GetDasConfigCellInfo err: %s", err.Error())
+	}
+	configCellSubAcc, err := core.GetDasConfigCellInfo(common.ConfigCellTypeArgsSubAccount)
+	if err != nil {
+		return nil, fmt.Errorf("GetDasConfigCellInfo err: %s", err.Error())
+	}
+	txParams.CellDeps = append(txParams.CellDeps,
+		&types.CellDep{OutPoint: p.ParentAccountCellOutPoint, DepType: types.DepTypeCode},
+		configCellAcc.ToCellDep(),
+		configCellSubAcc.ToCellDep(),
+		sa.timeCell.ToCellDep(),
+	)
+	if p.Action == common.SubActionEdit {
+		configCellRecord, err := core.GetDasConfigCellInfo(common.ConfigCellTypeArgsRecordNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("GetDasConfigCellInfo err: %s", err.Error())
+		}
+		txParams.CellDeps = append(txParams.CellDeps, configCellRecord.ToCellDep())
+	}
+
+	// custom script
+	var customScriptWitness []byte
+	if p.CustomScriptConfig != nil && sa.quoteCell != nil {
+		var hash []byte
+		customScriptWitness, hash = witness.BuildCustomScriptConfig(*p.CustomScriptConfig)
+		if sa.detail.Flag != witness.FlagTypeCustomPrice || !sa.detail.IsSameCustomScriptConfig(common.Bytes2Hex(hash)) {
+			return nil, fmt.Errorf("CustomScriptConfig is not the config of the sub-account cell")
+		}
+		customScriptCell, err := p.DasCore.GetCustomScriptLiveCell(sa.subAccountCell.OutputData)
+		if err != nil {
+			return nil, fmt.Errorf("GetCustomScriptLiveCell err: %s", err.Error())
+		}
+		txParams.CellDeps = append(txParams.CellDeps,
+			sa.quoteCell.ToCellDep(),
+			&types.CellDep{OutPoint: customScriptCell.OutPoint, DepType: types.DepTypeCode},
+		)
+	}
+
+	// payer, the last step before the smt update
+	var payerLiveCells []*indexer.LiveCell
+	var change uint64
+	if profit > 0 {
+		change, payerLiveCells, err = p.DasCore.GetBalanceCellWithLock(&core.ParamGetBalanceCells{
+			DasCache:          p.DasCache,
+			LockScript:        p.PayerLock,
+			CapacityNeed:      profit,
+			CapacityForChange: common.MinCellOccupiedCkb,
+			SearchOrder:       indexer.SearchOrderDesc,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetBalanceCellWithLock err: %s", err.Error())
+		}
+	}
+
+	// smt
+	res, err := p.SubAccountSmt.UpdateMiddleSmt(kvs, smt.SmtOpt{GetProof: true, GetRoot: true})
+	if err != nil {
+		releaseLiveCells(p.DasCache, payerLiveCells)
+		return nil, fmt.Errorf("UpdateMiddleSmt err: %s", err.Error())
+	}
+	rollback := func(e error) (*BuildTransactionParams, error) {
+		releaseLiveCells(p.DasCache, payerLiveCells)
+		if err := rollbackMiddleSmt(p.SubAccountSmt, kvs, oldValues); err != nil {
+			return nil, fmt.Errorf("%s, rollback smt err: %s", e.Error(), err.Error())
+		}
+		return nil, e
+	}
+
+	// the proof of each sub-account proves the old value under the previous root and the new value under the new root
+	prevRoot := smt.H256(sa.detail.SmtRoot)
+	for i, v := range p.SubAccountList {
+		key := common.Bytes2Hex(kvs[i].Key)
+		root, ok := res.Roots[key]
+		if !ok {
+			return rollback(fmt.Errorf("root of sub-account[%s] not exist", v.SubAccountData.Account()))
+		}
+		proof := smt.CompiledMerkleProof(common.Hex2Bytes(res.Proofs[key]))
+		if ok, err := smt.Verify(prevRoot, &proof, []smt.H256{kvs[i].Key}, []smt.H256{oldValues[i]}); err != nil || !ok {
+			return rollback(fmt.Errorf("smt root of sub-account cell mismatch, sub-account[%s]", v.SubAccountData.Account()))
+		}
+		if ok, err := smt.Verify(root, &proof, []smt.H256{kvs[i].Key}, []smt.H256{kvs[i].Value}); err != nil || !ok {
+			return rollback(fmt.Errorf("smt proof of sub-account[%s] invalid", v.SubAccountData.Account()))
+		}
+		v.NewRoot = root
+		v.Proof = proof
+		prevRoot = root
+	}
+
+	// witnesses
+	txParams.Witnesses = append(txParams.Witnesses, actionWitness, parentWitness)
+	if signWitness != nil {
+		txParams.Witnesses = append(txParams.Witnesses, signWitness)
+	}
+	for _, v := range p.SubAccountList {
+		subAccountWitness, err := v.GenWitness()
+		if err != nil {
+			return rollback(fmt.Errorf("GenWitness err: %s", err.Error()))
+		}
+		txParams.Witnesses = append(txParams.Witnesses, subAccountWitness)
+	}
+	if customScriptWitness != nil {
+		txParams.Witnesses = append(txParams.Witnesses, customScriptWitness)
+	}
+
+	// inputs
+	txParams.Inputs = append(txParams.Inputs, &types.CellInput{
+		Since:          0,
+		PreviousOutput: sa.subAccountCell.OutPoint,
+	})
+	for _, v := range payerLiveCells {
+		txParams.Inputs = append(txParams.Inputs, &types.CellInput{
+			Since:          0,
+			PreviousOutput: v.OutPoint,
+		})
+	}
+
+	// outputs
+	detail := sa.detail
+	detail.SmtRoot = prevRoot
+	detail.DasProfit += sa.dasProfit
+	detail.OwnerProfit += sa.ownerProfit
+	txParams.Outputs = append(txParams.Outputs, &types.CellOutput{
+		Capacity: sa.subAccountCell.Output.Capacity + profit - commonFee,
+		Lock:     sa.subAccountCell.Output.Lock,
+		Type:     sa.subAccountCell.Output.Type,
+	})
+	txParams.OutputsData = append(txParams.OutputsData, witness.BuildSubAccountCellOutputData(detail))
+	if change > 0 {
+		txParams.Outputs = append(txParams.Outputs, &types.CellOutput{
+			Capacity: change,
+			Lock:     p.PayerLock,
+		})
+		txParams.OutputsData = append(txParams.OutputsData, []byte{})
+	}
+
+	return &txParams, nil
+}
+
+// rollbackMiddleSmt sets the keys of kvs back to oldValues in the reverse order
+func rollbackMiddleSmt(tree SubAccountSmt, kvs []smt.SmtKv, oldValues []smt.H256) error {
+	oldKvs := make([]smt.SmtKv, len(kvs))
+	for i := range kvs {
+		oldKvs[len(kvs)-1-i] = smt.SmtKv{Key: kvs[i].Key, Value: oldValues[i]}
+	}
+	if _, err := tree.UpdateMiddleSmt(oldKvs, smt.SmtOpt{}); err != nil {
+		log.Error("UpdateMiddleSmt rollback err:", err.Error())
+		return err
+	}
+	return nil
+}
+
+// releaseLiveCells clears the outpoints of the cells selected by GetBalanceCellWithLock from the cache
+func releaseLiveCells(dasCache *dascache.DasCache, cells []*indexer.LiveCell) {
+	if dasCache == nil || len(cells) == 0 {
+		return
+	}
+	var outpoints []string
+	for _, v := range cells {
+		outpoints = append(outpoints, common.OutPointStruct2String(v.OutPoint))
+	}
+	dasCache.ClearOutPoint(outpoints)
+}
+
+// subAccountSmtRoot returns the root of *smt.SparseMerkleTree and *smt.SmtServer, ok is false for other trees
+func subAccountSmtRoot(tree SubAccountSmt) (root smt.H256, ok bool, err error) {
+	switch v := tree.(type) {
+	case interface{ Root() (smt.H256, error) }:
+		root, err = v.Root()
+		return root, true, err
+	case interface{ GetSmtRoot() (smt.H256, error) }:
+		root, err = v.GetSmtRoot()
+		return root, true, err
+	}
+	return nil, false, nil
+}
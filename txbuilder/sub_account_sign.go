@@ -0,0 +1,178 @@
+package txbuilder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/dotbitHQ/das-lib/sign"
+	"github.com/dotbitHQ/das-lib/smt"
+	"github.com/dotbitHQ/das-lib/witness"
+	"strings"
+)
+
+// the owner or manager of the parent account signs the root of the account list smt once,
+// then the sub-accounts in the list can be minted, renewed or approved in any later tx before ExpiredAt
+
+type SubAccountSignItem struct {
+	AccountId string
+	Value     smt.H256
+}
+
+// SubAccountSignBuilder builds the SubAccountMintSign witness and the witnesses reusing it
+type SubAccountSignBuilder struct {
+	Action    common.ActionDataType // ActionDataTypeSubAccountMintSign, ActionDataTypeSubAccountRenewSign or the approval sign types
+	SignRole  string                // common.ParamOwner or common.ParamManager
+	SignType  common.DasAlgorithmId // the algorithm of the signer
+	ExpiredAt uint64
+	Items     []SubAccountSignItem
+
+	tree *smt.SparseMerkleTree
+	root smt.H256
+}
+
+func NewSubAccountSignBuilder(action common.ActionDataType, signRole string, signType common.DasAlgorithmId, expiredAt uint64) (*SubAccountSignBuilder, error) {
+	switch action {
+	case common.ActionDataTypeSubAccountMintSign, common.ActionDataTypeSubAccountRenewSign,
+		common.ActionDataTypeSubAccountCreateApprovalSign, common.ActionDataTypeSubAccountDelayApprovalSign,
+		common.ActionDataTypeSubAccountRevokeApprovalSign, common.ActionDataTypeSubAccountFulfillApprovalSign:
+	default:
+		return nil, fmt.Errorf("not support action[%s]", action)
+	}
+	if signRole != common.ParamOwner && signRole != common.ParamManager {
+		return nil, fmt.Errorf("not support sign role[%s]", signRole)
+	}
+	return &SubAccountSignBuilder{
+		Action:    action,
+		SignRole:  signRole,
+		SignType:  signType,
+		ExpiredAt: expiredAt,
+	}, nil
+}
+
+// SubAccountSignValueByExpiredAt is the leaf value of mint and renew, the expired_at of the sub-account
+func SubAccountSignValueByExpiredAt(expiredAt uint64) smt.H256 {
+	value := smt.H256Zero()
+	copy(value, molecule.GoU64ToBytes(expiredAt))
+	return value
+}
+
+// SubAccountSignValueByApproval is the leaf value of the approval actions, the hash of the approval
+func SubAccountSignValueByApproval(approval *witness.AccountApproval) (smt.H256, error) {
+	accountApproval, err := approval.GenToMolecule()
+	if err != nil {
+		return nil, fmt.Errorf("GenToMolecule err: %s", err.Error())
+	}
+	return common.Blake2b(accountApproval.AsSlice()), nil
+}
+
+func (s *SubAccountSignBuilder) AddItem(accountId string, value smt.H256) {
+	s.Items = append(s.Items, SubAccountSignItem{AccountId: accountId, Value: value})
+	s.tree, s.root = nil, nil
+}
+
+// BuildAccountListSmt builds the account list smt in memory and returns its root
+func (s *SubAccountSignBuilder) BuildAccountListSmt() (smt.H256, error) {
+	if s.tree != nil {
+		return s.root, nil
+	}
+	if len(s.Items) == 0 {
+		return nil, fmt.Errorf("account list is empty")
+	}
+	var kvs []smt.SmtKv
+	for _, v := range s.Items {
+		if len(v.Value) != 32 {
+			return nil, fmt.Errorf("value of account[%s] length error: %d", v.AccountId, len(v.Value))
+		}
+		kvs = append(kvs, smt.SmtKv{Key: smt.AccountIdToSmtH256(v.AccountId), Value: v.Value})
+	}
+	tree := smt.NewSparseMerkleTree(nil)
+	if err := tree.UpdateAll(kvs); err != nil {
+		return nil, fmt.Errorf("UpdateAll err: %s", err.Error())
+	}
+	root, err := tree.Root()
+	if err != nil {
+		return nil, fmt.Errorf("Root err: %s", err.Error())
+	}
+	s.tree, s.root = tree, root
+	return root, nil
+}
+
+// MerkleProof proves the account is in the signed list
+func (s *SubAccountSignBuilder) MerkleProof(accountId string) (string, error) {
+	if _, err := s.BuildAccountListSmt(); err != nil {
+		return "", err
+	}
+	key := smt.AccountIdToSmtH256(accountId)
+	value, err := s.tree.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("Get err: %s", err.Error())
+	}
+	if value.IsZero() {
+		return "", fmt.Errorf("account[%s] not in the list", accountId)
+	}
+	proof, err := s.tree.MerkleProof([]smt.H256{key}, []smt.H256{value})
+	if err != nil {
+		return "", fmt.Errorf("MerkleProof err: %s", err.Error())
+	}
+	return common.Bytes2Hex(*proof), nil
+}
+
+// SignData returns the message to be signed by the owner or manager,
+// blake2b(expired_at + account_list_smt_root) formatted the same as the tx digest of SignType
+func (s *SubAccountSignBuilder) SignData() (SignData, error) {
+	root, err := s.BuildAccountListSmt()
+	if err != nil {
+		return SignData{}, err
+	}
+	message := common.Blake2b(append(molecule.GoU64ToBytes(s.ExpiredAt), root...))
+	signData := SignData{SignType: s.SignType, SignMsg: common.Bytes2Hex(message)}
+	switch s.SignType {
+	case common.DasAlgorithmIdEth, common.DasAlgorithmIdEth712, common.DasAlgorithmIdTron,
+		common.DasAlgorithmIdDogeChain, common.DasAlgorithmIdWebauthn:
+		signData.SignMsg = common.DotBitPrefix + hex.EncodeToString(message)
+	}
+	return signData, nil
+}
+
+// Verify checks the signature against the address of the signer by sign.Verify,
+// addressHex is the hex address of the owner or manager (the public key for ed25519, cid1+pk1 for webauthn)
+func (s *SubAccountSignBuilder) Verify(signature []byte, addressHex string, opts ...sign.VerifyOption) (bool, error) {
+	signData, err := s.SignData()
+	if err != nil {
+		return false, err
+	}
+	algId, subAlgId := s.SignType, common.DasSubAlgorithmId(0)
+	switch algId {
+	case common.DasAlgorithmIdEth712:
+		// the account list is signed by personal sign
+		algId = common.DasAlgorithmIdEth
+	case common.DasAlgorithmIdWebauthn:
+		subAlgId = common.DasWebauthnSubAlgorithmIdES256
+	}
+	payload := addressHex
+	if algId != common.DasAlgorithmIdEth && algId != common.DasAlgorithmIdTron {
+		payload = strings.TrimPrefix(addressHex, common.HexPreFix)
+	}
+	_, ok, err := sign.Verify(algId, subAlgId, signData.SignMsg, signature, payload, opts...)
+	if err != nil {
+		return false, fmt.Errorf("sign.Verify err: %s", err.Error())
+	}
+	return ok, nil
+}
+
+// GenWitness builds the witness of Action with the signature of SignData
+func (s *SubAccountSignBuilder) GenWitness(signature []byte) ([]byte, *witness.SubAccountMintSign, error) {
+	root, err := s.BuildAccountListSmt()
+	if err != nil {
+		return nil, nil, err
+	}
+	mintSign := witness.SubAccountMintSign{
+		Version:            witness.SubAccountMintSignVersion1,
+		Signature:          common.Hex2Bytes(fixSignature(s.SignType, common.Bytes2Hex(signature))),
+		SignRole:           common.Hex2Bytes(s.SignRole),
+		ExpiredAt:          s.ExpiredAt,
+		AccountListSmtRoot: root,
+	}
+	return mintSign.GenWitnessWithAction(s.Action), &mintSign, nil
+}
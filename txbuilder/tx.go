@@ -12,6 +12,9 @@ import (
 )
 
 func (d *DasTxBuilder) newTx() error {
+	if d.dasCore.Client() == nil {
+		return d.newTxByChainReader()
+	}
 	systemScriptCell, err := utils.NewSystemScripts(d.dasCore.Client())
 	if err != nil {
 		return err
@@ -21,6 +24,45 @@ func (d *DasTxBuilder) newTx() error {
 	return nil
 }
 
+// without a rpc client (e.g. core.MemChainReader), use the well-known secp256k1 dep group of the chain
+func (d *DasTxBuilder) newTxByChainReader() error {
+	profile, err := d.networkProfile()
+	if err != nil {
+		return err
+	}
+	secpDep := profile.SecpDep.CellDep()
+	if secpDep == nil {
+		return fmt.Errorf("secp dep of %s not exist", profile.Name)
+	}
+	d.Transaction = transaction.NewSecp256k1SingleSigTx(&utils.SystemScripts{
+		SecpSingleSigCell: &utils.SystemScriptCell{
+			CellHash: types.HexToHash(transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+			OutPoint: secpDep.OutPoint,
+			HashType: types.HashTypeType,
+			DepType:  secpDep.DepType,
+		},
+	})
+	return nil
+}
+
+// networkProfile returns the profile of the chain of the reader, or of the net type if the chain is unknown
+func (d *DasTxBuilder) networkProfile() (*common.NetworkProfile, error) {
+	if d.dasCore.ChainReader() == nil {
+		return nil, fmt.Errorf("chain reader is nil")
+	}
+	info, err := d.dasCore.ChainReader().GetBlockchainInfo(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockchainInfo err: %s", err.Error())
+	}
+	profile, err := common.GetNetworkByChain(info.Chain)
+	if err != nil {
+		if profile, err = common.GetNetwork(d.dasCore.NetType()); err != nil {
+			return nil, fmt.Errorf("GetNetwork err: %s", err.Error())
+		}
+	}
+	return profile, nil
+}
+
 func (d *DasTxBuilder) equalArgs(src, dst string) bool {
 	if common.Has0xPrefix(src) {
 		src = src[2:]
@@ -193,7 +235,7 @@ func (d *DasTxBuilder) addWebauthnInfo() error {
 							DepType:  types.DepTypeCode,
 						})
 
-						keyListConfigTx, err := d.dasCore.Client().GetTransaction(d.ctx, cell.OutPoint.TxHash)
+						keyListConfigTx, err := d.dasCore.ChainReader().GetTransaction(d.ctx, cell.OutPoint.TxHash)
 						if err != nil {
 							return err
 						}
@@ -53,6 +53,13 @@ type DasTxBuilderBase struct {
 	dasCore          *core.DasCore
 	handleServerSign sign.HandleSignCkbMessage
 	serverArgs       string
+	signers          *sign.Signers
+}
+
+// SetSigners sets the signers of AddSignatureForTx(nil), the ckb signer also signs the server sign group
+// if the handle of NewDasTxBuilderBase is nil
+func (d *DasTxBuilderBase) SetSigners(signers *sign.Signers) {
+	d.signers = signers
 }
 
 type DasTxBuilderTransaction struct {
@@ -151,10 +158,8 @@ func GenerateDigestListFromTx(cli rpc.Client, txJson string, skipGroups []int) (
 		return nil, err
 	}
 
-	if blockInfo.Chain == "ckb" {
-		netType = common.DasNetTypeMainNet
-	} else if blockInfo.Chain == "ckb_testnet" {
-		netType = common.DasNetTypeTestnet2
+	if profile, err := common.GetNetworkByChain(blockInfo.Chain); err == nil {
+		netType = profile.NetType
 	} else {
 		netType = common.DasNetTypeTestnet3
 	}
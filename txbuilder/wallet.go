@@ -0,0 +1,306 @@
+package txbuilder
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/dascache"
+	"github.com/dotbitHQ/das-lib/witness"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// WalletTarget is the shape of a hot wallet: PoolSize cells of PoolCapacity for concurrent spending,
+// and no cells less than DustThreshold
+type WalletTarget struct {
+	PoolSize      int
+	PoolCapacity  uint64
+	DustThreshold uint64 // PoolCapacity by default
+
+	MaxInputs  int    // of each tx, 200 by default
+	MaxOutputs int    // of each tx, 200 by default
+	MaxTxSize  uint64 // bytes of each signed tx, 100000 by default
+	MaxFee     uint64 // of each tx, common.OneCkb by default, same as checkTxBeforeSend
+	FeeRate    uint64 // shannons/KB, DefaultFeeRate by default
+
+	Action   common.DasAction   // the action witness is added if not empty, like common.DasActionTransfer for the das-lock wallets
+	DasCache *dascache.DasCache // the pending cells are not planned, the planned ones are locked by TryAddOutPoint
+}
+
+func (t *WalletTarget) init() error {
+	if t.PoolSize < 0 {
+		return fmt.Errorf("PoolSize is invalid")
+	}
+	if t.PoolSize > 0 && t.PoolCapacity == 0 {
+		return fmt.Errorf("PoolCapacity is invalid")
+	}
+	if t.DustThreshold == 0 {
+		t.DustThreshold = t.PoolCapacity
+	}
+	if t.MaxInputs <= 0 {
+		t.MaxInputs = 200
+	}
+	if t.MaxOutputs <= 1 {
+		t.MaxOutputs = 200
+	}
+	if t.MaxTxSize == 0 {
+		t.MaxTxSize = 100000
+	}
+	if t.MaxFee == 0 {
+		t.MaxFee = common.OneCkb
+	}
+	if t.FeeRate == 0 {
+		t.FeeRate = DefaultFeeRate
+	}
+	return nil
+}
+
+// walletPlanner plans the txs of one PlanWalletMaintenance
+type walletPlanner struct {
+	base          *DasTxBuilderBase
+	lock          *types.Script
+	target        *WalletTarget
+	minCapacity   uint64
+	mapInputsCell map[string]*types.CellWithStatus
+}
+
+// PlanWalletMaintenance inspects the balance cells (no type, no data) of lock, and plans the txs
+// which split the large cells into the missing pool cells and consolidate the dust cells, the fee is paid by the last output.
+// The txs spend only the live cells and are independent of each other, so they can be sent together,
+// call it again after they are committed if the wallet is still not in shape
+func (d *DasTxBuilderBase) PlanWalletMaintenance(lock *types.Script, target WalletTarget) ([]*BuildTransactionParams, error) {
+	if lock == nil {
+		return nil, fmt.Errorf("lock is nil")
+	}
+	if err := target.init(); err != nil {
+		return nil, err
+	}
+	planner := walletPlanner{
+		base:          d,
+		lock:          lock,
+		target:        &target,
+		minCapacity:   (&types.CellOutput{Lock: lock}).OccupiedCapacity(nil) * common.OneCkb,
+		mapInputsCell: make(map[string]*types.CellWithStatus),
+	}
+	if target.PoolSize > 0 && target.PoolCapacity < planner.minCapacity {
+		return nil, fmt.Errorf("PoolCapacity is less than the occupied capacity %d", planner.minCapacity)
+	}
+
+	cells, err := planner.getWalletCells()
+	if err != nil {
+		return nil, err
+	}
+	res, used, err := planner.plan(cells)
+	// the cells claimed but not spent by the planned txs are released
+	var unused []string
+	for _, v := range cells {
+		if key := common.OutPointStruct2String(v.OutPoint); err != nil || !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	if target.DasCache != nil {
+		target.DasCache.ClearOutPoint(unused)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (w *walletPlanner) plan(cells []*indexer.LiveCell) ([]*BuildTransactionParams, map[string]bool, error) {
+	target := w.target
+	var pool, dust, large []*indexer.LiveCell
+	for _, v := range cells {
+		capacity := v.Output.Capacity
+		switch {
+		case target.PoolSize > 0 && capacity >= target.PoolCapacity && capacity < 2*target.PoolCapacity:
+			pool = append(pool, v)
+		case capacity < target.DustThreshold:
+			dust = append(dust, v)
+		default:
+			large = append(large, v)
+		}
+	}
+	log.Info("PlanWalletMaintenance:", len(pool), len(dust), len(large))
+
+	var res []*BuildTransactionParams
+	used := make(map[string]bool)
+	addTx := func(p *BuildTransactionParams) {
+		res = append(res, p)
+		for _, v := range p.Inputs {
+			used[common.OutPointStruct2String(v.PreviousOutput)] = true
+		}
+	}
+	// split the large cells, the largest first, the dust is kept for consolidation
+	large = core.SortCellsByCapacity(large, true)
+	missing := target.PoolSize - len(pool)
+	for missing > 0 && len(large) > 0 {
+		p, n, err := w.planSplitTx(large, missing)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p == nil {
+			break
+		}
+		addTx(p)
+		large = large[n:]
+		missing -= len(p.Outputs) - 1
+	}
+
+	// consolidate the dust, the smallest first so that the most cells are merged
+	dust = core.SortCellsByCapacity(dust, false)
+	for len(dust) > 1 {
+		p, n, err := w.planConsolidateTx(dust)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p == nil {
+			break
+		}
+		addTx(p)
+		dust = dust[n:]
+	}
+	return res, used, nil
+}
+
+// getWalletCells returns the balance cells of the lock claimed by TryAddOutPoint, the pending ones are skipped
+func (w *walletPlanner) getWalletCells() ([]*indexer.LiveCell, error) {
+	list, _, err := core.GetSatisfiedCapacityLiveCellWithOrder(w.base.dasCore.ChainReader(), nil, w.lock, nil, 0, 0, indexer.SearchOrderAsc)
+	if err != nil {
+		return nil, fmt.Errorf("GetSatisfiedCapacityLiveCellWithOrder err: %s", err.Error())
+	}
+	var cells []*indexer.LiveCell
+	for _, v := range list {
+		key := common.OutPointStruct2String(v.OutPoint)
+		if w.target.DasCache != nil && !w.target.DasCache.TryAddOutPoint([]string{key}) {
+			continue
+		}
+		cells = append(cells, v)
+		w.mapInputsCell[fmt.Sprintf("%s-%d", v.OutPoint.TxHash.Hex(), v.OutPoint.Index)] = &types.CellWithStatus{
+			Cell: &types.CellInfo{
+				Data:   &types.CellData{Content: v.OutputData},
+				Output: v.Output,
+			},
+			Status: "live",
+		}
+	}
+	return cells, nil
+}
+
+// newTxParams estimates the fee by EstimateTxFee, the capacity of the last output is set to total - fee of the other outputs,
+// it returns nil params if the tx is over the limits of the target
+func (w *walletPlanner) newTxParams(inputCells []*indexer.LiveCell, outputs []*types.CellOutput) (*BuildTransactionParams, error) {
+	var p BuildTransactionParams
+	total := uint64(0)
+	for _, v := range inputCells {
+		p.Inputs = append(p.Inputs, &types.CellInput{Since: 0, PreviousOutput: v.OutPoint})
+		total += v.Output.Capacity
+	}
+	p.Outputs = outputs
+	for range outputs {
+		p.OutputsData = append(p.OutputsData, []byte{})
+	}
+	if w.target.Action != "" {
+		actionWitness, err := witness.GenActionDataWitness(w.target.Action, nil)
+		if err != nil {
+			return nil, fmt.Errorf("GenActionDataWitness err: %s", err.Error())
+		}
+		p.Witnesses = append(p.Witnesses, actionWitness)
+	}
+
+	txBuilder := NewDasTxBuilderFromBase(w.base, nil)
+	txBuilder.MapInputsCell = w.mapInputsCell
+	if err := txBuilder.BuildTransaction(&p); err != nil {
+		return nil, fmt.Errorf("BuildTransaction err: %s", err.Error())
+	}
+	size, err := txBuilder.EstimateTxSize()
+	if err != nil {
+		return nil, fmt.Errorf("EstimateTxSize err: %s", err.Error())
+	}
+	fee, err := txBuilder.EstimateTxFee(w.target.FeeRate)
+	if err != nil {
+		return nil, fmt.Errorf("EstimateTxFee err: %s", err.Error())
+	}
+	if size > w.target.MaxTxSize || fee >= w.target.MaxFee {
+		return nil, nil
+	}
+	paid := uint64(0)
+	for _, v := range outputs[:len(outputs)-1] {
+		paid += v.Capacity
+	}
+	if total < paid+fee+w.minCapacity {
+		return nil, nil
+	}
+	outputs[len(outputs)-1].Capacity = total - paid - fee
+	return &p, nil
+}
+
+// planSplitTx spends the large cells (descending) for at most missing pool cells,
+// it returns the params and the number of the large cells spent, nil if none of the pool cells can be made
+func (w *walletPlanner) planSplitTx(large []*indexer.LiveCell, missing int) (*BuildTransactionParams, int, error) {
+	target := w.target
+	var inputCells []*indexer.LiveCell
+	total := uint64(0)
+	count := 0
+	for i := 0; i < len(large) && i < target.MaxInputs; i++ {
+		inputCells = append(inputCells, large[i])
+		total += large[i].Output.Capacity
+		// keep the change and the largest fee
+		count = 0
+		if total >= w.minCapacity+target.MaxFee {
+			count = int((total - w.minCapacity - target.MaxFee) / target.PoolCapacity)
+		}
+		if count >= missing || count >= target.MaxOutputs-1 {
+			break
+		}
+	}
+	if count > missing {
+		count = missing
+	}
+	if count > target.MaxOutputs-1 {
+		count = target.MaxOutputs - 1
+	}
+	for ; count > 0; count-- {
+		var outputs []*types.CellOutput
+		for i := 0; i < count; i++ {
+			outputs = append(outputs, &types.CellOutput{Capacity: target.PoolCapacity, Lock: w.lock})
+		}
+		outputs = append(outputs, &types.CellOutput{Lock: w.lock}) // change
+		p, err := w.newTxParams(inputCells, outputs)
+		if err != nil {
+			return nil, 0, err
+		}
+		if p != nil {
+			return p, len(inputCells), nil
+		}
+	}
+	return nil, 0, nil
+}
+
+// planConsolidateTx merges the dust cells (ascending) into one cell,
+// it returns the params and the number of the dust cells spent, nil if the dust cannot be merged
+func (w *walletPlanner) planConsolidateTx(dust []*indexer.LiveCell) (*BuildTransactionParams, int, error) {
+	n := len(dust)
+	if n > w.target.MaxInputs {
+		n = w.target.MaxInputs
+	}
+	// the tx is shrunk from the end until it is within the limits
+	for ; n > 1; n-- {
+		total := uint64(0)
+		for _, v := range dust[:n] {
+			total += v.Output.Capacity
+		}
+		if total < w.minCapacity+w.target.MaxFee {
+			// more dust is needed, fewer inputs would not help
+			return nil, 0, nil
+		}
+		p, err := w.newTxParams(dust[:n], []*types.CellOutput{{Lock: w.lock}})
+		if err != nil {
+			return nil, 0, err
+		}
+		if p != nil {
+			return p, n, nil
+		}
+	}
+	return nil, 0, nil
+}
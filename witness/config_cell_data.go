@@ -699,6 +699,27 @@ func (c *ConfigCellDataBuilder) RenewSubAccountPrice() (uint64, error) {
 	return 0, fmt.Errorf("ConfigCellSubAccount is nil")
 }
 
+func (c *ConfigCellDataBuilder) SubAccountCommonFee() (uint64, error) {
+	if c.ConfigCellSubAccount != nil {
+		return molecule.Bytes2GoU64(c.ConfigCellSubAccount.CommonFee().RawData())
+	}
+	return 0, fmt.Errorf("ConfigCellSubAccount is nil")
+}
+
+func (c *ConfigCellDataBuilder) NewSubAccountCustomPriceDasProfitRate() (uint32, error) {
+	if c.ConfigCellSubAccount != nil {
+		return molecule.Bytes2GoU32(c.ConfigCellSubAccount.NewSubAccountCustomPriceDasProfitRate().RawData())
+	}
+	return 0, fmt.Errorf("ConfigCellSubAccount is nil")
+}
+
+func (c *ConfigCellDataBuilder) RenewSubAccountCustomPriceDasProfitRate() (uint32, error) {
+	if c.ConfigCellSubAccount != nil {
+		return molecule.Bytes2GoU32(c.ConfigCellSubAccount.RenewSubAccountCustomPriceDasProfitRate().RawData())
+	}
+	return 0, fmt.Errorf("ConfigCellSubAccount is nil")
+}
+
 func (c *ConfigCellDataBuilder) GetContractStatus(contractName common.DasContractName) (res common.ContractStatus, err error) {
 	if c.ConfigCellSystemStatus == nil {
 		err = fmt.Errorf("ConfigCellSystemStatus is nil")
@@ -24,32 +24,49 @@ const (
 	DidCellDataLVFlag          uint8  = 0
 )
 
+// GetDidCellRecycleCellDeps returns the DidCellRecycleDep of the network profile,
+// the one of the ckb mainnet or testnet by IsMainNet if the profile has not
 func GetDidCellRecycleCellDeps(net common.DasNetType) *types.CellDep {
-	outPoint := types.OutPoint{
-		TxHash: types.HexToHash(DidCellCellDepsFalgMainnet),
-		Index:  0,
-	}
-	if net != common.DasNetTypeMainNet {
-		outPoint = types.OutPoint{
-			TxHash: types.HexToHash(DidCellCellDepsFalgTestnet),
-			Index:  0,
+	if profile, err := common.GetNetwork(net); err == nil {
+		if cellDep := profile.DidCellRecycleDep.CellDep(); cellDep != nil {
+			return cellDep
 		}
 	}
-
+	txHash := DidCellCellDepsFalgMainnet
+	if !common.IsMainNet(net) {
+		txHash = DidCellCellDepsFalgTestnet
+	}
 	return &types.CellDep{
-		OutPoint: &outPoint,
+		OutPoint: &types.OutPoint{TxHash: types.HexToHash(txHash), Index: 0},
 		DepType:  types.DepTypeCode,
 	}
 }
 
+// GetClusterId returns the ClusterId of the network profile, the one of the ckb mainnet or testnet by IsMainNet if the profile has not
 func GetClusterId(net common.DasNetType) []byte {
+	if profile, err := common.GetNetwork(net); err == nil && profile.ClusterId != "" {
+		return common.Hex2Bytes(profile.ClusterId)
+	}
 	id := ClusterIdMainnet
-	if net != common.DasNetTypeMainNet {
+	if !common.IsMainNet(net) {
 		id = ClusterIdTestnet
 	}
 	return common.Hex2Bytes(id)
 }
 
+// IsDidCellRecycleCellDep is true if the out point is the DidCellRecycleDep of any registered network
+func IsDidCellRecycleCellDep(outPoint *types.OutPoint) bool {
+	if outPoint == nil {
+		return false
+	}
+	for _, v := range common.Networks() {
+		if cellDep := v.DidCellRecycleDep.CellDep(); cellDep != nil && cellDep.OutPoint.TxHash == outPoint.TxHash {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *SporeData) ObjToBys() ([]byte, error) {
 	sporeDataBuilder := molecule.NewSporeDataBuilder()
 
@@ -168,8 +185,9 @@ var (
 	ErrorNotDidEntityWitness = errors.New("not did entity witness")
 )
 
-func (d *DidEntity) BysToObj(bys []byte) error {
-	if string(bys[:3]) != common.WitnessDID {
+func (d *DidEntity) BysToObj(bys []byte) (err error) {
+	defer recoverMolecule(&err)
+	if len(bys) < 3 || string(bys[:3]) != common.WitnessDID {
 		return ErrorNotDidEntityWitness
 	}
 
@@ -85,13 +85,11 @@ type DPData struct {
 
 func ConvertBysToDPData(data []byte) (DPData, error) {
 	var res DPData
-	index, indexLen, dataLen := uint32(0), uint32(4), uint32(0)
-
-	dataLen, _ = molecule.Bytes2GoU32(data[index : index+indexLen])
-	value := data[index+indexLen : index+indexLen+dataLen]
-	res.Value, _ = molecule.Bytes2GoU64(value)
-
-	index = index + indexLen + dataLen
+	value, _, err := NewLVReader(data).NextU64("value")
+	if err != nil {
+		return res, err
+	}
+	res.Value = value
 	return res, nil
 }
 
@@ -0,0 +1,129 @@
+package witness
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/molecule"
+)
+
+var (
+	ErrLVTruncated       = errors.New("lv header truncated")
+	ErrLVOverflow        = errors.New("lv length overflows the data")
+	ErrMoleculeMalformed = errors.New("molecule data malformed")
+)
+
+// recoverMolecule returns ErrMoleculeMalformed by err if the molecule decoders panic.
+// The decoders generated by Moleculec-Go 0.1.11 only check the table offsets in pairs,
+// decreasing offsets of a malformed table slice out of range, the generated code is kept as generated
+func recoverMolecule(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", ErrMoleculeMalformed, r)
+	}
+}
+
+// LVError is returned by LVReader, errors.Is works with ErrLVTruncated and ErrLVOverflow
+type LVError struct {
+	Err    error
+	Field  string
+	Offset int    // offset of the field
+	Need   uint64 // bytes the field needs
+	Remain int    // bytes remaining at offset
+}
+
+func (e *LVError) Error() string {
+	return fmt.Sprintf("%s: field[%s] offset[%d] need[%d] remain[%d]", e.Err.Error(), e.Field, e.Offset, e.Need, e.Remain)
+}
+
+func (e *LVError) Unwrap() error {
+	return e.Err
+}
+
+// LVReader reads the fields of the das witnesses encoded as 4 bytes little endian length + value.
+// The values returned share memory with data, the first error is kept and returned by all later reads
+type LVReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func NewLVReader(data []byte) *LVReader {
+	return &LVReader{data: data}
+}
+
+func (r *LVReader) Err() error {
+	return r.err
+}
+
+func (r *LVReader) Offset() int {
+	return r.offset
+}
+
+func (r *LVReader) Remain() int {
+	return len(r.data) - r.offset
+}
+
+// PeekLen returns the length of the next field without reading it
+func (r *LVReader) PeekLen() (uint32, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.Remain() < int(molecule.HeaderSizeUint) {
+		return 0, &LVError{Err: ErrLVTruncated, Offset: r.offset, Need: uint64(molecule.HeaderSizeUint), Remain: r.Remain()}
+	}
+	return binary.LittleEndian.Uint32(r.data[r.offset:]), nil
+}
+
+// Next reads one length-value field
+func (r *LVReader) Next(field string) ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	dataLen, err := r.PeekLen()
+	if err != nil {
+		err.(*LVError).Field = field
+		r.err = err
+		return nil, err
+	}
+	start := r.offset + int(molecule.HeaderSizeUint)
+	if uint64(dataLen) > uint64(len(r.data)-start) {
+		r.err = &LVError{Err: ErrLVOverflow, Field: field, Offset: r.offset, Need: uint64(molecule.HeaderSizeUint) + uint64(dataLen), Remain: r.Remain()}
+		return nil, r.err
+	}
+	r.offset = start + int(dataLen)
+	return r.data[start:r.offset:r.offset], nil
+}
+
+// NextU32 reads a field whose value is a molecule u32, a value of other size is decoded as 0
+func (r *LVReader) NextU32(field string) (uint32, []byte, error) {
+	value, err := r.Next(field)
+	if err != nil {
+		return 0, nil, err
+	}
+	num, _ := molecule.Bytes2GoU32(value)
+	return num, value, nil
+}
+
+// NextU64 reads a field whose value is a molecule u64, a value of other size is decoded as 0
+func (r *LVReader) NextU64(field string) (uint64, []byte, error) {
+	value, err := r.Next(field)
+	if err != nil {
+		return 0, nil, err
+	}
+	num, _ := molecule.Bytes2GoU64(value)
+	return num, value, nil
+}
+
+// Fixed reads n bytes without the length header
+func (r *LVReader) Fixed(field string, n int) ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if n < 0 || n > r.Remain() {
+		r.err = &LVError{Err: ErrLVTruncated, Field: field, Offset: r.offset, Need: uint64(n), Remain: r.Remain()}
+		return nil, r.err
+	}
+	start := r.offset
+	r.offset += n
+	return r.data[start:r.offset:r.offset], nil
+}
@@ -0,0 +1,160 @@
+package witness
+
+import (
+	"errors"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"testing"
+)
+
+func TestLVReader(t *testing.T) {
+	var data []byte
+	data = append(data, molecule.GoU32ToBytes(4)...)
+	data = append(data, molecule.GoU32ToBytes(7)...)
+	data = append(data, molecule.GoU32ToBytes(3)...)
+	data = append(data, "abc"...)
+
+	r := NewLVReader(data)
+	num, _, err := r.NextU32("num")
+	if err != nil || num != 7 {
+		t.Fatal(num, err)
+	}
+	if value, err := r.Next("str"); err != nil || string(value) != "abc" {
+		t.Fatal(string(value), err)
+	}
+	_, err = r.Next("more")
+	var lvErr *LVError
+	if !errors.Is(err, ErrLVTruncated) || !errors.As(err, &lvErr) || lvErr.Field != "more" || lvErr.Offset != len(data) {
+		t.Fatal(err)
+	}
+
+	r = NewLVReader(append(molecule.GoU32ToBytes(0xffffffff), 1, 2))
+	if _, err = r.Next("big"); !errors.Is(err, ErrLVOverflow) {
+		t.Fatal(err)
+	}
+	if _, err = r.Fixed("after", 1); !errors.Is(err, ErrLVOverflow) {
+		t.Fatal("the first error should be kept", err)
+	}
+}
+
+func TestLVDecodersTruncated(t *testing.T) {
+	mintSign := SubAccountMintSign{
+		Version:            SubAccountMintSignVersion1,
+		Signature:          []byte{1, 2, 3},
+		SignRole:           []byte{0},
+		ExpiredAt:          100,
+		AccountListSmtRoot: make([]byte, 32),
+	}
+	data := mintSign.GenSubAccountMintSignBytes()
+	var builder SubAccountNewBuilder
+	res, err := builder.ConvertSubAccountMintSignFromBytes(data)
+	if err != nil || res.ExpiredAt != 100 || len(res.AccountListSmtRoot) != 32 {
+		t.Fatal(res, err)
+	}
+	for i := 0; i < len(data); i++ {
+		if _, err = builder.ConvertSubAccountMintSignFromBytes(data[:i]); err == nil {
+			t.Fatal("truncated data parsed", i)
+		}
+	}
+
+	subAccountNew := fuzzSubAccountNew()
+	data, err = subAccountNew.GenSubAccountNewBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = builder.ConvertSubAccountNewFromBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i++ {
+		if _, err = builder.ConvertSubAccountNewFromBytes(data[:i]); err == nil {
+			t.Fatal("truncated data parsed", i)
+		}
+	}
+}
+
+func TestMoleculeMalformed(t *testing.T) {
+	// the offsets of the table decrease after the first pair, found by FuzzDidEntityBysToObj
+	data := []byte("DID|\x00\x00\x00$\x00\x00\x00000000\x00\x00000000\x00\x00000000\x00\x0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000")
+	var didEntity DidEntity
+	if err := didEntity.BysToObj(data); !errors.Is(err, ErrMoleculeMalformed) {
+		t.Fatal(err)
+	}
+}
+
+func fuzzSubAccountNew() *SubAccountNew {
+	accountCharSet, _ := common.AccountToAccountChars("abc")
+	return &SubAccountNew{
+		Version:       SubAccountNewVersion3,
+		Action:        common.SubActionRenew,
+		Signature:     []byte{1},
+		SignRole:      []byte{0},
+		SignExpiredAt: 1,
+		NewRoot:       make([]byte, 32),
+		Proof:         []byte{0x4c},
+		SubAccountData: &SubAccountData{
+			Lock:           &types.Script{CodeHash: types.Hash{}, HashType: types.HashTypeType, Args: []byte{1}},
+			AccountId:      "0x0000000000000000000000000000000000000001",
+			AccountCharSet: accountCharSet,
+			Suffix:         ".test.bit",
+		},
+		OldSubAccountVersion: SubAccountVersion2,
+		NewSubAccountVersion: SubAccountVersion2,
+		EditValue:            molecule.GoU64ToBytes(200),
+	}
+}
+
+func FuzzConvertSubAccountMintSignFromBytes(f *testing.F) {
+	mintSign := SubAccountMintSign{Version: SubAccountMintSignVersion1, Signature: []byte{1}, AccountListSmtRoot: make([]byte, 32)}
+	f.Add(mintSign.GenSubAccountMintSignBytes())
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var builder SubAccountNewBuilder
+		_, _ = builder.ConvertSubAccountMintSignFromBytes(data)
+	})
+}
+
+func FuzzConvertSubAccountNewFromBytes(f *testing.F) {
+	if data, err := fuzzSubAccountNew().GenSubAccountNewBytes(); err == nil {
+		f.Add(data)
+	}
+	subAccountNewV1 := fuzzSubAccountNew()
+	subAccountNewV1.Version, subAccountNewV1.Action = SubAccountNewVersion1, ""
+	subAccountNewV1.OldSubAccountVersion, subAccountNewV1.NewSubAccountVersion = 0, 0
+	if data, err := subAccountNewV1.GenSubAccountNewBytes(); err == nil {
+		f.Add(data)
+	}
+	f.Add([]byte{4, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var builder SubAccountNewBuilder
+		_, _ = builder.ConvertSubAccountNewFromBytes(data)
+	})
+}
+
+func FuzzDidEntityBysToObj(f *testing.F) {
+	didEntity := DidEntity{
+		Target:               CellMeta{Index: 1, Source: SourceTypeOutputs},
+		ItemId:               ItemIdWitnessDataDidCellV0,
+		DidCellWitnessDataV0: &DidCellWitnessDataV0{Records: []Record{{Key: "key", Type: "address", Value: "value"}}},
+	}
+	if data, err := didEntity.ObjToBys(); err == nil {
+		f.Add(data)
+	}
+	f.Add([]byte(common.WitnessDID))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var d DidEntity
+		_ = d.BysToObj(data)
+	})
+}
+
+func FuzzConvertSubAccountCellOutputData(f *testing.F) {
+	f.Add(BuildSubAccountCellOutputData(SubAccountCellDataDetail{
+		SmtRoot:          make([]byte, 32),
+		Flag:             FlagTypeCustomPrice,
+		CustomScriptArgs: make([]byte, 32),
+	}))
+	f.Add(make([]byte, 49))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_ = ConvertSubAccountCellOutputData(data)
+	})
+}
@@ -42,7 +42,7 @@ func (r *ReverseSmtRecord) GetP2SHP2WPKH(netType common.DasNetType) (string, err
 	if common.DasAlgorithmId(r.SignType) == common.DasAlgorithmIdBitcoin {
 		pkHash := r.Address
 		net := bitcoin.GetBTCMainNetParams()
-		if netType != common.DasNetTypeMainNet {
+		if !common.IsMainNet(netType) {
 			net = bitcoin.GetBTCTestNetParams()
 		}
 
@@ -68,7 +68,7 @@ func (r *ReverseSmtRecord) GetP2SHP2WPKH(netType common.DasNetType) (string, err
 func (r *ReverseSmtRecord) GetP2TR(netType common.DasNetType) (string, error) {
 	if common.DasAlgorithmId(r.SignType) == common.DasAlgorithmIdBitcoin {
 		net := bitcoin.GetBTCMainNetParams()
-		if netType != common.DasNetTypeMainNet {
+		if !common.IsMainNet(netType) {
 			net = bitcoin.GetBTCTestNetParams()
 		}
 
@@ -390,38 +390,30 @@ func (s *SubAccountCellDataDetail) ArgsAndConfigHash() string {
 	return common.Bytes2Hex(common.Blake2b(data)[10:])
 }
 
+// ConvertSubAccountCellOutputData the fields after a truncated one are left empty
 func ConvertSubAccountCellOutputData(data []byte) (detail SubAccountCellDataDetail) {
-	if len(data) >= 32 {
-		detail.SmtRoot = data[:32]
+	r := NewLVReader(data)
+	detail.SmtRoot, _ = r.Fixed("smt_root", 32)
+	if bys, err := r.Fixed("das_profit", 8); err == nil {
+		detail.DasProfit, _ = molecule.Bytes2GoU64(bys)
 	}
-	if len(data) >= 40 {
-		detail.DasProfit, _ = molecule.Bytes2GoU64(data[32:40])
+	if bys, err := r.Fixed("owner_profit", 8); err == nil {
+		detail.OwnerProfit, _ = molecule.Bytes2GoU64(bys)
 	}
-	if len(data) >= 48 {
-		detail.OwnerProfit, _ = molecule.Bytes2GoU64(data[40:48])
-	}
-	if len(data) >= 49 {
-		detail.Flag = FlagType(data[48:49][0])
+	if bys, err := r.Fixed("flag", 1); err == nil {
+		detail.Flag = FlagType(bys[0])
 	}
 
 	switch detail.Flag {
 	case FlagTypeDefault, FlagTypeCustomPrice:
-		if len(data) >= 81 {
-			detail.CustomScriptArgs = data[49:81]
-		}
-		if len(data) >= 91 {
-			detail.CustomScriptConfig = data[81:91]
-		}
+		detail.CustomScriptArgs, _ = r.Fixed("custom_script_args", 32)
+		detail.CustomScriptConfig, _ = r.Fixed("custom_script_config", 10)
 	case FlagTypeCustomRule:
-		if len(data) >= 50 {
-			detail.AutoDistribution = AutoDistribution(data[49:50][0])
-		}
-		if len(data) >= 60 {
-			detail.PriceRulesHash = data[50:60]
-		}
-		if len(data) >= 70 {
-			detail.PreservedRulesHash = data[60:70]
+		if bys, err := r.Fixed("auto_distribution", 1); err == nil {
+			detail.AutoDistribution = AutoDistribution(bys[0])
 		}
+		detail.PriceRulesHash, _ = r.Fixed("price_rules_hash", 10)
+		detail.PreservedRulesHash, _ = r.Fixed("preserved_rules_hash", 10)
 	}
 	return
 }
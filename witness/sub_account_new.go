@@ -36,30 +36,15 @@ type SubAccountMintSign struct {
 
 func (s *SubAccountNewBuilder) ConvertSubAccountMintSignFromBytes(dataBys []byte) (*SubAccountMintSign, error) {
 	var res SubAccountMintSign
-	index, indexLen, dataLen := uint32(0), uint32(4), uint32(0)
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.versionBys = dataBys[index+indexLen : index+indexLen+dataLen]
-	res.Version, _ = molecule.Bytes2GoU32(res.versionBys)
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.Signature = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.SignRole = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.expiredAtBys = dataBys[index+indexLen : index+indexLen+dataLen]
-	res.ExpiredAt, _ = molecule.Bytes2GoU64(res.expiredAtBys)
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.AccountListSmtRoot = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
+	r := NewLVReader(dataBys)
+	res.Version, res.versionBys, _ = r.NextU32("version")
+	res.Signature, _ = r.Next("signature")
+	res.SignRole, _ = r.Next("sign_role")
+	res.ExpiredAt, res.expiredAtBys, _ = r.NextU64("expired_at")
+	res.AccountListSmtRoot, _ = r.Next("account_list_smt_root")
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
 	return &res, nil
 }
 
@@ -280,123 +265,65 @@ func (s *SubAccountNew) GenWitness() ([]byte, error) {
 
 func (s *SubAccountNewBuilder) convertSubAccountNewFromBytesV1(dataBys []byte) (*SubAccountNew, error) {
 	var res SubAccountNew
-	index, indexLen, dataLen := uint32(0), uint32(4), uint32(0)
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.Signature = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.SignRole = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.PrevRoot = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.CurrentRoot = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.Proof = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.versionBys = dataBys[index+indexLen : index+indexLen+dataLen]
-	res.Version, _ = molecule.Bytes2GoU32(res.versionBys)
-	index = index + indexLen + dataLen
+	r := NewLVReader(dataBys)
+	res.Signature, _ = r.Next("signature")
+	res.SignRole, _ = r.Next("sign_role")
+	res.PrevRoot, _ = r.Next("prev_root")
+	res.CurrentRoot, _ = r.Next("current_root")
+	res.Proof, _ = r.Next("proof")
+	res.Version, res.versionBys, _ = r.NextU32("version")
+	res.subAccountDataBys, _ = r.Next("sub_account")
+	res.editKeyBys, _ = r.Next("edit_key")
+	res.EditValue, _ = r.Next("edit_value")
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
 
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.subAccountDataBys = dataBys[index+indexLen : index+indexLen+dataLen]
 	subAccount, err := s.ConvertSubAccountDataFromBytes(SubAccountVersion1, res.subAccountDataBys)
 	if err != nil {
 		return nil, fmt.Errorf("ConvertSubAccountDataFromBytes err: %s", err.Error())
 	}
 	res.SubAccountData = subAccount
 	res.Account = subAccount.Account()
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.editKeyBys = dataBys[index+indexLen : index+indexLen+dataLen]
 	res.EditKey = string(res.editKeyBys)
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.EditValue = dataBys[index+indexLen : index+indexLen+dataLen]
 	if err := s.convertCurrentSubAccountData(&res); err != nil {
 		return nil, err
 	}
-	index = index + indexLen + dataLen
-
 	return &res, nil
 }
 
 func (s *SubAccountNewBuilder) convertSubAccountNewFromBytes(dataBys []byte) (*SubAccountNew, error) {
 	var res SubAccountNew
-	index, indexLen, dataLen := uint32(0), uint32(4), uint32(0)
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.versionBys = dataBys[index+indexLen : index+indexLen+dataLen]
-	res.Version, _ = molecule.Bytes2GoU32(res.versionBys)
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.actionBys = dataBys[index+indexLen : index+indexLen+dataLen]
+	r := NewLVReader(dataBys)
+	res.Version, res.versionBys, _ = r.NextU32("version")
+	res.actionBys, _ = r.Next("action")
 	res.Action = string(res.actionBys)
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.Signature = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.SignRole = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.signExpiredAtBys = dataBys[index+indexLen : index+indexLen+dataLen]
-	res.SignExpiredAt, _ = molecule.Bytes2GoU64(res.signExpiredAtBys)
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.NewRoot = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.Proof = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
+	res.Signature, _ = r.Next("signature")
+	res.SignRole, _ = r.Next("sign_role")
+	res.SignExpiredAt, res.signExpiredAtBys, _ = r.NextU64("sign_expired_at")
+	res.NewRoot, _ = r.Next("new_root")
+	res.Proof, _ = r.Next("proof")
 
 	subAccountVersion := SubAccountVersion1
 	if res.Version >= SubAccountNewVersion3 {
-		dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-		res.OldSubAccountVersion, _ = molecule.Bytes2GoU32(dataBys[index+indexLen : index+indexLen+dataLen])
-		index = index + indexLen + dataLen
-
-		dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-		res.NewSubAccountVersion, _ = molecule.Bytes2GoU32(dataBys[index+indexLen : index+indexLen+dataLen])
-		index = index + indexLen + dataLen
-
+		res.OldSubAccountVersion, _, _ = r.NextU32("old_sub_account_version")
+		res.NewSubAccountVersion, _, _ = r.NextU32("new_sub_account_version")
 		subAccountVersion = res.OldSubAccountVersion
 	}
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.subAccountDataBys = dataBys[index+indexLen : index+indexLen+dataLen]
+	res.subAccountDataBys, _ = r.Next("sub_account")
+	res.editKeyBys, _ = r.Next("edit_key")
+	res.EditValue, _ = r.Next("edit_value")
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
 	subAccount, err := s.ConvertSubAccountDataFromBytes(subAccountVersion, res.subAccountDataBys)
 	if err != nil {
 		return nil, fmt.Errorf("ConvertSubAccountDataFromBytes err: %s", err.Error())
 	}
 	res.SubAccountData = subAccount
 	res.Account = subAccount.Account()
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.editKeyBys = dataBys[index+indexLen : index+indexLen+dataLen]
 	res.EditKey = string(res.editKeyBys)
-	index = index + indexLen + dataLen
-
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
-	res.EditValue = dataBys[index+indexLen : index+indexLen+dataLen]
-	index = index + indexLen + dataLen
 
 	if err := s.convertCurrentSubAccountData(&res); err != nil {
 		return nil, err
@@ -404,9 +331,12 @@ func (s *SubAccountNewBuilder) convertSubAccountNewFromBytes(dataBys []byte) (*S
 	return &res, nil
 }
 
-func (s *SubAccountNewBuilder) ConvertSubAccountNewFromBytes(dataBys []byte) (*SubAccountNew, error) {
-	index, indexLen, dataLen := uint32(0), uint32(4), uint32(0)
-	dataLen, _ = molecule.Bytes2GoU32(dataBys[index : index+indexLen])
+func (s *SubAccountNewBuilder) ConvertSubAccountNewFromBytes(dataBys []byte) (res *SubAccountNew, err error) {
+	defer recoverMolecule(&err)
+	dataLen, err := NewLVReader(dataBys).PeekLen()
+	if err != nil {
+		return nil, err
+	}
 	if dataLen == 4 {
 		return s.convertSubAccountNewFromBytes(dataBys)
 	} else {
@@ -480,6 +410,9 @@ func (s *SubAccountNewBuilder) convertCurrentSubAccountData(p *SubAccountNew) er
 
 	switch p.Action {
 	case common.SubActionRenew:
+		if len(p.EditValue) < 8 {
+			return fmt.Errorf("renew edit value length error: %d", len(p.EditValue))
+		}
 		expiredAt, _ := molecule.Bytes2GoU64(p.EditValue[:8])
 		p.CurrentSubAccountData.ExpiredAt = expiredAt
 	case common.SubActionCreateApproval:
@@ -507,6 +440,14 @@ func (s *SubAccountNewBuilder) convertCurrentSubAccountData(p *SubAccountNew) er
 	return nil
 }
 
+// ConvertCurrentSubAccountData sets CurrentSubAccountData, the sub-account data after p is applied
+func (s *SubAccountNewBuilder) ConvertCurrentSubAccountData(p *SubAccountNew) error {
+	if p.Action != common.SubActionRecycle && p.SubAccountData == nil {
+		return fmt.Errorf("SubAccountData is nil")
+	}
+	return s.convertCurrentSubAccountData(p)
+}
+
 // === SubAccountData ===
 type SubAccountData struct {
 	Version              SubAccountVersion       `json:"version"`
@@ -1,9 +1,11 @@
 package witness
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/Andrew-M-C/go.emoji/official"
 	"github.com/clipperhouse/uax29/graphemes"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/molecule"
@@ -12,6 +14,7 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 type (
@@ -51,6 +54,11 @@ const (
 	Lt  SymbolType = "<"
 	Lte SymbolType = "<="
 	Equ SymbolType = "=="
+	Add SymbolType = "+"
+	Sub SymbolType = "-"
+	Mul SymbolType = "*"
+	Div SymbolType = "/"
+	Mod SymbolType = "%"
 
 	FunctionIncludeCharts      FunctionType = "include_chars"
 	FunctionIncludeWords       FunctionType = "include_words"
@@ -59,6 +67,11 @@ const (
 	FunctionIncludeCharset     FunctionType = "include_charset"
 	FunctionStartsWith         FunctionType = "starts_with"
 	FunctionEndsWith           FunctionType = "ends_with"
+	FunctionMatchesPattern     FunctionType = "matches_pattern"
+	FunctionIsPalindrome       FunctionType = "is_palindrome"
+	FunctionRepeatCharCount    FunctionType = "repeat_char_count"
+	FunctionDigitCount         FunctionType = "digit_count"
+	FunctionEmojiCount         FunctionType = "emoji_count"
 
 	Account       VariableName = "account"
 	AccountChars  VariableName = "account_chars"
@@ -73,17 +86,26 @@ const (
 	String      ValueType = "string"
 	StringArray ValueType = "string[]"
 	Charset     ValueType = "charset_type"
+	Pattern     ValueType = "pattern"
 
 	SubAccountRuleVersionV1 SubAccountRuleVersion = 1
+	SubAccountRuleVersionV2 SubAccountRuleVersion = 2 // matches_pattern, is_palindrome, the count functions and the arithmetic operators
 )
 
 var (
-	Functions   = FunctionsType{FunctionIncludeCharts, FunctionIncludeWords, FunctionOnlyIncludeCharset, FunctionInList, FunctionIncludeCharset, FunctionStartsWith, FunctionEndsWith}
-	Values      = ValuesType{Bool, Uint8, Uint32, Uint64, Binary, BinaryArray, String, StringArray, Charset}
+	Functions   = FunctionsType{FunctionIncludeCharts, FunctionIncludeWords, FunctionOnlyIncludeCharset, FunctionInList, FunctionIncludeCharset, FunctionStartsWith, FunctionEndsWith, FunctionMatchesPattern, FunctionIsPalindrome, FunctionRepeatCharCount, FunctionDigitCount, FunctionEmojiCount}
+	Values      = ValuesType{Bool, Uint8, Uint32, Uint64, Binary, BinaryArray, String, StringArray, Charset, Pattern}
 	Variables   = VariablesName{Account, AccountChars, AccountLength}
-	Operators   = SymbolsType{Not, And, Or, Gt, Gte, Lt, Lte, Equ}
+	Operators   = SymbolsType{Not, And, Or, Gt, Gte, Lt, Lte, Equ, Add, Sub, Mul, Div, Mod}
 	Expressions = ExpressionsType{Operator, Function, Variable, Value}
 
+	// appended to the lists above by SubAccountRuleVersionV2, the indexes of the older items are unchanged
+	FunctionsV2       = FunctionsType{FunctionMatchesPattern, FunctionIsPalindrome, FunctionRepeatCharCount, FunctionDigitCount, FunctionEmojiCount}
+	ValuesV2          = ValuesType{Pattern}
+	OperatorsV2       = SymbolsType{Add, Sub, Mul, Div, Mod}
+	NumberFunctions   = FunctionsType{FunctionRepeatCharCount, FunctionDigitCount, FunctionEmojiCount}
+	ArithmeticSymbols = SymbolsType{Add, Sub, Mul, Div, Mod}
+
 	ParentAccountError = errors.New("parent account can't be empty, please init from NewSubAccountRuleEntity func")
 )
 
@@ -96,6 +118,24 @@ func (fs FunctionsType) Include(functionType FunctionType) bool {
 	return false
 }
 
+func (ss SymbolsType) Include(symbol SymbolType) bool {
+	for _, v := range ss {
+		if v == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+func (vs ValuesType) Include(valueType ValueType) bool {
+	for _, v := range vs {
+		if v == valueType {
+			return true
+		}
+	}
+	return false
+}
+
 func (cs CharsetsType) Include(charset CharsetType) bool {
 	for _, v := range cs {
 		if v == charset {
@@ -163,11 +203,24 @@ func (v *ValueType) Parse(data []byte) (interface{}, error) {
 			return nil, err
 		}
 		return val, nil
+	case Pattern:
+		if _, err := CompileRulePattern(string(data)); err != nil {
+			return nil, err
+		}
+		return string(data), nil
 	default:
 		return nil, fmt.Errorf("unknown value type: %s", *v)
 	}
 }
 
+func isAccountId(v string) bool {
+	if !strings.HasPrefix(v, common.HexPreFix) || len(v) != len(common.HexPreFix)+common.DasAccountIdLen*2 {
+		return false
+	}
+	_, err := hex.DecodeString(v[len(common.HexPreFix):])
+	return err == nil
+}
+
 func (v *ValueType) Gen(data interface{}, preExp *AstExpression) (molecule.Bytes, error) {
 	var res molecule.Bytes
 	switch *v {
@@ -190,7 +243,8 @@ func (v *ValueType) Gen(data interface{}, preExp *AstExpression) (molecule.Bytes
 		strs := gconv.Strings(data)
 		log.Infof("BinaryArray: %v, parentAccount: %s", strs, preExp.subAccountRuleEntity.ParentAccount)
 		for _, v := range strs {
-			if preExp != nil && preExp.Type == Variable && preExp.Name == string(Account) {
+			// the account names are converted to the account ids, the ids decoded from witness are kept
+			if preExp != nil && preExp.Type == Variable && preExp.Name == string(Account) && !isAccountId(v) {
 				account := strings.Split(v, ".")[0] + "." + preExp.subAccountRuleEntity.ParentAccount
 				accountId := common.GetAccountIdByAccount(account)
 				bsVecBuilder.Push(molecule.GoBytes2MoleculeBytes(accountId))
@@ -215,6 +269,11 @@ func (v *ValueType) Gen(data interface{}, preExp *AstExpression) (molecule.Bytes
 		}
 		u32 := molecule.GoU32ToMoleculeU32(gconv.Uint32(data))
 		res = molecule.GoBytes2MoleculeBytes(u32.AsSlice())
+	case Pattern:
+		if _, err := CompileRulePattern(gconv.String(data)); err != nil {
+			return res, err
+		}
+		res = molecule.GoBytes2MoleculeBytes(gconv.Bytes(data))
 	}
 	return res, nil
 }
@@ -282,6 +341,10 @@ func (s *SubAccountRuleEntity) Check() (err error) {
 			err = errors.New("price can't be negative number")
 			return
 		}
+		if version := v.Ast.MinVersion(); version > s.Version {
+			err = fmt.Errorf("rule %s needs version %d", v.Name, version)
+			return
+		}
 		v.Ast.subAccountRuleEntity = s
 		if _, err = v.Ast.Check(false, ""); err != nil {
 			return
@@ -344,37 +407,28 @@ func (s *SubAccountRuleEntity) ParseFromDasActionWitnessData(data [][]byte) erro
 	return s.ParseFromWitnessData(resData)
 }
 
-func (s *SubAccountRuleEntity) ParseFromWitnessData(data [][]byte) error {
+func (s *SubAccountRuleEntity) ParseFromWitnessData(data [][]byte) (err error) {
+	defer recoverMolecule(&err)
 	for _, v := range data {
-		index, indexLen, dataLen := uint32(0), molecule.HeaderSizeUint, uint32(0)
-		if int(indexLen) > len(v) {
-			return fmt.Errorf("data length error: %d", len(v))
-		}
-
-		dataLen, err := molecule.Bytes2GoU32(v[index : index+indexLen])
+		r := NewLVReader(v)
+		versionBys, err := r.Next("version")
 		if err != nil {
 			return err
 		}
-		index += indexLen
-
-		version, err := molecule.Bytes2GoU32(v[index : index+dataLen])
+		version, err := molecule.Bytes2GoU32(versionBys)
 		if err != nil {
 			return err
 		}
-		index += dataLen
 
 		if s.Version > 0 && uint32(s.Version) != version {
 			return errors.New("version aberrant")
 		}
 		s.Version = SubAccountRuleVersion(version)
 
-		dataLen, err = molecule.Bytes2GoU32(v[index : index+indexLen])
+		v, err = r.Next("rules")
 		if err != nil {
 			return err
 		}
-		index += indexLen
-
-		v = v[index : index+dataLen]
 
 		rules, err := molecule.SubAccountRulesFromSlice(v, true)
 		if err != nil {
@@ -408,6 +462,9 @@ func (s *SubAccountRuleEntity) ParseFromWitnessData(data [][]byte) error {
 			if err != nil {
 				return err
 			}
+			if v := exp.MinVersion(); v > s.Version {
+				return fmt.Errorf("rule %s needs version %d but the version is %d", name, v, s.Version)
+			}
 
 			status, err := molecule.Bytes2GoU8(r.Status().RawData())
 			if err != nil {
@@ -583,6 +640,9 @@ func (s *SubAccountRuleEntity) GenData() ([][]byte, error) {
 		if v.Price < 0 {
 			return nil, errors.New("price can't be negative number")
 		}
+		if version := v.Ast.MinVersion(); version > s.Version {
+			return nil, fmt.Errorf("rule %s needs version %d", v.Name, version)
+		}
 		if _, err := v.Ast.Check(false, ""); err != nil {
 			return nil, err
 		}
@@ -660,6 +720,12 @@ func (e *AstExpression) Check(checkHit bool, account string) (hit bool, err erro
 			hit, err = e.handleFunctionStartsWith(checkHit, account)
 		case FunctionEndsWith:
 			hit, err = e.handleFunctionEndsWith(checkHit, account)
+		case FunctionMatchesPattern:
+			hit, err = e.handleFunctionMatchesPattern(checkHit, account)
+		case FunctionIsPalindrome:
+			hit, err = e.handleFunctionIsPalindrome(checkHit, account)
+		case FunctionRepeatCharCount, FunctionDigitCount, FunctionEmojiCount:
+			err = fmt.Errorf("function %s returns number, it can only be compared", e.Name)
 		default:
 			err = fmt.Errorf("function %s can't be support", e.Name)
 			return
@@ -678,6 +744,13 @@ func (e *AstExpression) Check(checkHit bool, account string) (hit bool, err erro
 	return
 }
 
+// isUint32Value reports whether the number value following preExp is generated as uint32
+func (e *AstExpression) isUint32Value(preExp *AstExpression) bool {
+	return preExp != nil && (preExp.Type == Variable && preExp.Name == string(AccountLength) ||
+		preExp.ReturnType() == ReturnTypeNumber && preExp.Type != Value) &&
+		e.ReturnType() == ReturnTypeNumber
+}
+
 func (e *AstExpression) GenMoleculeASTExpression(preExp *AstExpression) (*molecule.ASTExpression, error) {
 	astExpBuilder := molecule.NewASTExpressionBuilder()
 	switch e.Type {
@@ -764,9 +837,7 @@ func (e *AstExpression) GenMoleculeASTExpression(preExp *AstExpression) (*molecu
 		astExpBuilder.ExpressionType(molecule.NewByte(0x03))
 		expBuilder := molecule.NewASTValueBuilder()
 
-		if preExp.Type == Variable &&
-			preExp.Name == string(AccountLength) &&
-			e.ReturnType() == ReturnTypeNumber {
+		if e.isUint32Value(preExp) {
 			e.ValueType = Uint32
 		}
 
@@ -815,6 +886,10 @@ func (s *SubAccountRule) Hit(account string) (hit bool, err error) {
 }
 
 func (e *AstExpression) ReturnType() ReturnType {
+	if e.Type == Operator && ArithmeticSymbols.Include(e.Symbol) ||
+		e.Type == Function && NumberFunctions.Include(FunctionType(e.Name)) {
+		return ReturnTypeNumber
+	}
 	if e.Type == Operator || e.Type == Function || e.Type == Value && e.ValueType == Bool {
 		return ReturnTypeBool
 	}
@@ -841,6 +916,37 @@ func (e *AstExpression) GetNumberValue(account string) float64 {
 	if e.Type == Variable && VariableName(e.Name) == AccountLength {
 		return float64(len([]rune(account)))
 	}
+	if e.Type == Function {
+		switch FunctionType(e.Name) {
+		case FunctionRepeatCharCount:
+			return float64(repeatCharCount(account))
+		case FunctionDigitCount:
+			return float64(digitCount(account))
+		case FunctionEmojiCount:
+			return float64(emojiCount(account))
+		}
+	}
+	if e.Type == Operator && len(e.Expressions) == 2 {
+		left := int64(e.Expressions[0].GetNumberValue(account))
+		right := int64(e.Expressions[1].GetNumberValue(account))
+		switch e.Symbol {
+		case Add:
+			return float64(left + right)
+		case Sub:
+			return float64(left - right)
+		case Mul:
+			return float64(left * right)
+		case Div:
+			if right != 0 {
+				return float64(left / right)
+			}
+		case Mod:
+			if right != 0 {
+				return float64(left % right)
+			}
+		}
+		return 0
+	}
 	if e.Type == Value && e.ReturnType() == ReturnTypeNumber {
 		return gconv.Float64(e.Value)
 	}
@@ -859,7 +965,7 @@ func (e *AstExpression) GetStringValue(account string) string {
 
 func (e *AstExpression) GetStringArray(account string) []string {
 	if e.Type == Variable && VariableName(e.Name) == AccountChars {
-		return gconv.Strings([]rune(account))
+		return strings.Split(account, "")
 	}
 	if e.Type == Value && e.ReturnType() == ReturnTypeStringArray {
 		return gconv.Strings(e.Value)
@@ -900,7 +1006,7 @@ func (e *AstExpression) ProcessOperator(checkHit bool, account string) (hit bool
 				return true, nil
 			}
 		}
-		return true, nil
+		return !checkHit, nil
 	case Not:
 		if len(e.Expressions) != 1 {
 			return false, errors.New("operator not must have one expression")
@@ -939,6 +1045,12 @@ func (e *AstExpression) ProcessOperator(checkHit bool, account string) (hit bool
 
 		switch left.ReturnType() {
 		case ReturnTypeNumber:
+			if err = left.checkNumber(); err != nil {
+				return false, err
+			}
+			if err = right.checkNumber(); err != nil {
+				return false, err
+			}
 			leftVal := left.GetNumberValue(account)
 			rightVal := right.GetNumberValue(account)
 			if e.Symbol == Gt {
@@ -984,12 +1096,65 @@ func (e *AstExpression) ProcessOperator(checkHit bool, account string) (hit bool
 		default:
 			return false, fmt.Errorf("type %s is not currently supported", left.ReturnType())
 		}
+	case Add, Sub, Mul, Div, Mod:
+		err = fmt.Errorf("operator '%s' returns number, it can only be compared", e.Symbol)
 	default:
 		err = fmt.Errorf("symbol %s can't be support", e.Symbol)
 	}
 	return
 }
 
+// checkNumber checks the number expressions, which are not checked by Check
+func (e *AstExpression) checkNumber() error {
+	switch e.Type {
+	case Value, Variable:
+		return nil
+	case Function:
+		if len(e.Arguments) != 1 {
+			return fmt.Errorf("%s function args length must one", e.Name)
+		}
+		accountVar := e.Arguments[0]
+		if accountVar.Type != Variable || VariableName(accountVar.Name) != Account {
+			return fmt.Errorf("first args type must variable and name is %s", Account)
+		}
+		return nil
+	case Operator:
+		if len(e.Expressions) != 2 {
+			return fmt.Errorf("operator '%s' must have two expression", e.Symbol)
+		}
+		for _, v := range e.Expressions {
+			if v.ReturnType() != ReturnTypeNumber {
+				return fmt.Errorf("operator '%s' every expression must be number return", e.Symbol)
+			}
+			if err := v.checkNumber(); err != nil {
+				return err
+			}
+		}
+		if divisor := e.Expressions[1]; (e.Symbol == Div || e.Symbol == Mod) &&
+			(divisor.Type != Value || gconv.Uint64(divisor.Value) == 0) {
+			return fmt.Errorf("operator '%s' divisor must be a number greater than 0", e.Symbol)
+		}
+		return nil
+	}
+	return fmt.Errorf("expression %s can't be support", e.Type)
+}
+
+// MinVersion returns the rule version the expression needs
+func (e *AstExpression) MinVersion() SubAccountRuleVersion {
+	version := SubAccountRuleVersionV1
+	if e.Type == Function && FunctionsV2.Include(FunctionType(e.Name)) ||
+		e.Type == Operator && OperatorsV2.Include(e.Symbol) ||
+		e.Type == Value && ValuesV2.Include(e.ValueType) {
+		version = SubAccountRuleVersionV2
+	}
+	for _, v := range append(append(AstExpressions{}, e.Expressions...), e.Arguments...) {
+		if child := v.MinVersion(); child > version {
+			version = child
+		}
+	}
+	return version
+}
+
 func (e *AstExpression) handleFunctionIncludeCharts(checkHit bool, account string) (hit bool, err error) {
 	if len(e.Arguments) != 2 {
 		err = fmt.Errorf("%s function args length must two", e.Name)
@@ -1237,3 +1402,96 @@ func (e *AstExpression) handleFunctionEndsWith(checkHit bool, account string) (h
 	}
 	return
 }
+
+func (e *AstExpression) handleFunctionMatchesPattern(checkHit bool, account string) (hit bool, err error) {
+	if len(e.Arguments) != 2 {
+		err = fmt.Errorf("%s function args length must two", e.Name)
+		return
+	}
+	accountVar := e.Arguments[0]
+	if accountVar.Type != Variable || VariableName(accountVar.Name) != Account {
+		err = fmt.Errorf("first args type must variable and name is %s", Account)
+		return
+	}
+
+	value := e.Arguments[1]
+	if value.Type != Value || value.ValueType != Pattern {
+		err = fmt.Errorf("function %s args[1] value must be %s", e.Name, Pattern)
+		return
+	}
+	pattern, err := CompileRulePattern(gconv.String(value.Value))
+	if err != nil {
+		return
+	}
+	if !checkHit {
+		return
+	}
+	hit = pattern.Match(account)
+	return
+}
+
+func (e *AstExpression) handleFunctionIsPalindrome(checkHit bool, account string) (hit bool, err error) {
+	if len(e.Arguments) != 1 {
+		err = fmt.Errorf("%s function args length must one", e.Name)
+		return
+	}
+	accountVar := e.Arguments[0]
+	if accountVar.Type != Variable || VariableName(accountVar.Name) != Account {
+		err = fmt.Errorf("first args type must variable and name is %s", Account)
+		return
+	}
+	if !checkHit {
+		return
+	}
+
+	chars := []rune(account)
+	for i, j := 0, len(chars)-1; i < j; i, j = i+1, j-1 {
+		if chars[i] != chars[j] {
+			return
+		}
+	}
+	hit = true
+	return
+}
+
+// repeatCharCount returns the length of the longest run of the same char
+func repeatCharCount(account string) int {
+	res, count := 0, 0
+	var pre rune
+	for i, v := range []rune(account) {
+		if i > 0 && v == pre {
+			count++
+		} else {
+			count = 1
+		}
+		pre = v
+		if count > res {
+			res = count
+		}
+	}
+	return res
+}
+
+func digitCount(account string) int {
+	count := 0
+	for _, v := range account {
+		if v >= '0' && v <= '9' {
+			count++
+		}
+	}
+	return count
+}
+
+func emojiCount(account string) int {
+	count := 0
+	for i := 0; i < len(account); {
+		if match, length := official.AllSequences.HasEmojiPrefix(account[i:]); match && length > 0 {
+			count++
+			i += length
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(account[i:])
+		i += size
+	}
+	return count
+}
@@ -0,0 +1,704 @@
+package witness
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/gogf/gf/v2/util/gconv"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// text syntax of the rule ast, e.g.
+//   account_length <= 3 and only_include_charset("digit")
+//   not in_list(["test", "reverse"]) or (starts_with(["a", "b"]) and account_length == 5)
+//   account_length % 2 == 0 and digit_count() >= account_length - 1 and matches_pattern("[a-c]*9")
+// precedence from low to high: or, and, not, comparison (== > >= < <=), + -, * / %.
+// the first argument of a function can be omitted when it is the default variable of the function.
+// in_list takes account ids, or sub-account names which are converted to the account ids under the parent account
+
+// RuleSyntaxError is returned by ParseAstExpression, Line and Column start from 1
+type RuleSyntaxError struct {
+	Offset int
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *RuleSyntaxError) Error() string {
+	return fmt.Sprintf("line %d column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// the variable and the value type of the arguments of the functions
+var functionArguments = map[FunctionType]struct {
+	variable  VariableName
+	valueType ValueType
+}{
+	FunctionIncludeCharts:      {Account, StringArray},
+	FunctionIncludeWords:       {Account, StringArray},
+	FunctionOnlyIncludeCharset: {AccountChars, Charset},
+	FunctionInList:             {Account, BinaryArray},
+	FunctionIncludeCharset:     {AccountChars, Charset},
+	FunctionStartsWith:         {Account, StringArray},
+	FunctionEndsWith:           {Account, StringArray},
+	FunctionMatchesPattern:     {Account, Pattern},
+	FunctionIsPalindrome:       {Account, ""},
+	FunctionRepeatCharCount:    {Account, ""},
+	FunctionDigitCount:         {Account, ""},
+	FunctionEmojiCount:         {Account, ""},
+}
+
+type ruleTokenType int
+
+const (
+	ruleTokenEOF ruleTokenType = iota
+	ruleTokenIdent
+	ruleTokenNumber
+	ruleTokenHex
+	ruleTokenString
+	ruleTokenSymbol
+	ruleTokenArith
+	ruleTokenPunct
+)
+
+type ruleToken struct {
+	typ ruleTokenType
+	pos int
+	raw string
+	val string
+}
+
+type ruleParser struct {
+	text          string
+	parentAccount string
+	tokens        []ruleToken
+	idx           int
+}
+
+// ParseAstExpression parses the text syntax into AstExpression,
+// the result should be checked by SubAccountRuleEntity.Check before use.
+// The sub-account names of in_list need the parent account, use SubAccountRuleEntity.AddRuleFromText for them
+func ParseAstExpression(text string) (*AstExpression, error) {
+	return parseAstExpression(text, "")
+}
+
+func parseAstExpression(text, parentAccount string) (*AstExpression, error) {
+	p := &ruleParser{text: text, parentAccount: parentAccount}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	exp, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != ruleTokenEOF {
+		return nil, p.errorf(tok.pos, "unexpected %s", tok.describe())
+	}
+	normalizeValueTypes(exp, nil)
+	return exp, nil
+}
+
+// normalizeValueTypes sets the number types as GenMoleculeASTExpression does,
+// so the parsed ast is the same as the one decoded from witness
+func normalizeValueTypes(e, preExp *AstExpression) {
+	if e.Type == Value && e.isUint32Value(preExp) {
+		e.ValueType = Uint32
+		e.Value = gconv.Uint32(e.Value)
+	}
+	children := e.Expressions
+	if e.Type == Function {
+		children = e.Arguments
+	}
+	for idx, v := range children {
+		if idx == 0 {
+			normalizeValueTypes(v, e)
+		} else {
+			normalizeValueTypes(v, children[idx-1])
+		}
+	}
+}
+
+// AddRuleFromText parses the text and appends it as an enabled rule, the rule is not added if Check fails
+func (s *SubAccountRuleEntity) AddRuleFromText(name, note string, price float64, text string) (*SubAccountRule, error) {
+	exp, err := parseAstExpression(text, s.ParentAccount)
+	if err != nil {
+		return nil, err
+	}
+	rule := NewSubAccountRule()
+	rule.Index = uint32(len(s.Rules))
+	rule.Name = name
+	rule.Note = note
+	rule.Price = price
+	rule.Ast = *exp
+	rule.Status = 1
+
+	s.Rules = append(s.Rules, rule)
+	if err = s.Check(); err != nil {
+		s.Rules = s.Rules[:len(s.Rules)-1]
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (t ruleToken) describe() string {
+	if t.typ == ruleTokenEOF {
+		return "end of rule"
+	}
+	return fmt.Sprintf("'%s'", t.raw)
+}
+
+func (p *ruleParser) errorf(pos int, format string, args ...interface{}) error {
+	line, column := 1, 1
+	for _, r := range p.text[:pos] {
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &RuleSyntaxError{Offset: pos, Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isRuleIdentChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+func (p *ruleParser) lex() error {
+	text := p.text
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isRuleIdentChar(c, true):
+			start := i
+			for i < len(text) && isRuleIdentChar(text[i], false) {
+				i++
+			}
+			p.tokens = append(p.tokens, ruleToken{typ: ruleTokenIdent, pos: start, raw: text[start:i], val: text[start:i]})
+		case c >= '0' && c <= '9':
+			start, typ := i, ruleTokenNumber
+			if strings.HasPrefix(text[i:], "0x") || strings.HasPrefix(text[i:], "0X") {
+				i += 2
+				typ = ruleTokenHex
+			}
+			for i < len(text) && (isRuleIdentChar(text[i], false)) {
+				i++
+			}
+			p.tokens = append(p.tokens, ruleToken{typ: typ, pos: start, raw: text[start:i], val: text[start:i]})
+		case c == '"':
+			start := i
+			for i++; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(text) {
+				return p.errorf(start, "unterminated string")
+			}
+			i++
+			val, err := strconv.Unquote(text[start:i])
+			if err != nil {
+				return p.errorf(start, "invalid string %s", text[start:i])
+			}
+			p.tokens = append(p.tokens, ruleToken{typ: ruleTokenString, pos: start, raw: text[start:i], val: val})
+		case c == '+' || c == '-' || c == '*' || c == '/' || c == '%':
+			p.tokens = append(p.tokens, ruleToken{typ: ruleTokenArith, pos: i, raw: text[i : i+1], val: text[i : i+1]})
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			p.tokens = append(p.tokens, ruleToken{typ: ruleTokenPunct, pos: i, raw: text[i : i+1], val: text[i : i+1]})
+			i++
+		case c == '=' || c == '>' || c == '<' || c == '!':
+			start := i
+			i++
+			if i < len(text) && text[i] == '=' {
+				i++
+			}
+			symbol := text[start:i]
+			if symbol == "=" || symbol == "!" || symbol == "!=" {
+				return p.errorf(start, "operator '%s' is not supported", symbol)
+			}
+			p.tokens = append(p.tokens, ruleToken{typ: ruleTokenSymbol, pos: start, raw: symbol, val: symbol})
+		default:
+			r, _ := utf8.DecodeRuneInString(text[i:])
+			return p.errorf(i, "unexpected character '%c'", r)
+		}
+	}
+	p.tokens = append(p.tokens, ruleToken{typ: ruleTokenEOF, pos: len(text)})
+	return nil
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.idx]
+}
+
+func (p *ruleParser) next() ruleToken {
+	tok := p.tokens[p.idx]
+	if tok.typ != ruleTokenEOF {
+		p.idx++
+	}
+	return tok
+}
+
+func (p *ruleParser) isKeyword(word string) bool {
+	tok := p.peek()
+	return tok.typ == ruleTokenIdent && tok.val == word
+}
+
+func (p *ruleParser) isPunct(punct string) bool {
+	tok := p.peek()
+	return tok.typ == ruleTokenPunct && tok.val == punct
+}
+
+func (p *ruleParser) expectPunct(punct string) error {
+	if !p.isPunct(punct) {
+		tok := p.peek()
+		return p.errorf(tok.pos, "expected '%s' but got %s", punct, tok.describe())
+	}
+	p.next()
+	return nil
+}
+
+func (p *ruleParser) parseOr() (*AstExpression, error) {
+	return p.parseLogic(Or, p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (*AstExpression, error) {
+	return p.parseLogic(And, p.parseNot)
+}
+
+func (p *ruleParser) parseLogic(symbol SymbolType, parseOperand func() (*AstExpression, error)) (*AstExpression, error) {
+	exp, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword(string(symbol)) {
+		return exp, nil
+	}
+	res := &AstExpression{Type: Operator, Symbol: symbol, Expressions: AstExpressions{exp}}
+	for p.isKeyword(string(symbol)) {
+		p.next()
+		exp, err = parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		res.Expressions = append(res.Expressions, exp)
+	}
+	return res, nil
+}
+
+func (p *ruleParser) parseNot() (*AstExpression, error) {
+	if !p.isKeyword(string(Not)) {
+		return p.parseComparison()
+	}
+	p.next()
+	exp, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return &AstExpression{Type: Operator, Symbol: Not, Expressions: AstExpressions{exp}}, nil
+}
+
+func (p *ruleParser) parseComparison() (*AstExpression, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.typ != ruleTokenSymbol {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.typ == ruleTokenSymbol {
+		return nil, p.errorf(next.pos, "comparison '%s' can't be chained, use 'and'", next.val)
+	}
+	return &AstExpression{Type: Operator, Symbol: SymbolType(tok.val), Expressions: AstExpressions{left, right}}, nil
+}
+
+func (p *ruleParser) parseAdditive() (*AstExpression, error) {
+	return p.parseArithmetic(SymbolsType{Add, Sub}, p.parseMultiplicative)
+}
+
+func (p *ruleParser) parseMultiplicative() (*AstExpression, error) {
+	return p.parseArithmetic(SymbolsType{Mul, Div, Mod}, p.parsePrimary)
+}
+
+// parseArithmetic is left associative, every operator has two expressions
+func (p *ruleParser) parseArithmetic(symbols SymbolsType, parseOperand func() (*AstExpression, error)) (*AstExpression, error) {
+	exp, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.typ == ruleTokenArith && symbols.Include(SymbolType(tok.val)); tok = p.peek() {
+		p.next()
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		exp = &AstExpression{Type: Operator, Symbol: SymbolType(tok.val), Expressions: AstExpressions{exp, right}}
+	}
+	return exp, nil
+}
+
+func (p *ruleParser) parsePrimary() (*AstExpression, error) {
+	tok := p.peek()
+	switch tok.typ {
+	case ruleTokenPunct:
+		if tok.val == "(" {
+			p.next()
+			exp, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return exp, nil
+		}
+		if tok.val == "[" {
+			return p.parseList()
+		}
+	case ruleTokenIdent:
+		p.next()
+		switch tok.val {
+		case string(Not), string(And), string(Or):
+			return nil, p.errorf(tok.pos, "unexpected '%s'", tok.val)
+		case "true", "false":
+			return &AstExpression{Type: Value, ValueType: Bool, Value: tok.val == "true"}, nil
+		}
+		if p.isPunct("(") {
+			return p.parseFunction(tok)
+		}
+		for _, v := range Variables {
+			if string(v) == tok.val {
+				return &AstExpression{Type: Variable, Name: tok.val}, nil
+			}
+		}
+		return nil, p.errorf(tok.pos, "unknown variable '%s'", tok.val)
+	case ruleTokenNumber:
+		p.next()
+		num, err := strconv.ParseUint(tok.val, 10, 64)
+		if err != nil {
+			return nil, p.errorf(tok.pos, "invalid number '%s'", tok.raw)
+		}
+		switch {
+		case num <= 0xff:
+			return &AstExpression{Type: Value, ValueType: Uint8, Value: uint8(num)}, nil
+		case num <= 0xffffffff:
+			return &AstExpression{Type: Value, ValueType: Uint32, Value: uint32(num)}, nil
+		default:
+			return &AstExpression{Type: Value, ValueType: Uint64, Value: num}, nil
+		}
+	case ruleTokenHex:
+		p.next()
+		if _, err := hex.DecodeString(tok.val[2:]); err != nil || len(tok.val) == 2 {
+			return nil, p.errorf(tok.pos, "invalid hex '%s'", tok.raw)
+		}
+		return &AstExpression{Type: Value, ValueType: Binary, Value: strings.ToLower(tok.val)}, nil
+	case ruleTokenString:
+		p.next()
+		return &AstExpression{Type: Value, ValueType: String, Value: tok.val}, nil
+	}
+	return nil, p.errorf(tok.pos, "unexpected %s", tok.describe())
+}
+
+func (p *ruleParser) parseList() (*AstExpression, error) {
+	start := p.next()
+	list := make([]string, 0)
+	valueType := StringArray
+	for !p.isPunct("]") {
+		if len(list) > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+		tok := p.next()
+		switch {
+		case tok.typ == ruleTokenString && valueType == StringArray:
+			list = append(list, tok.val)
+		case tok.typ == ruleTokenHex && (valueType == BinaryArray || len(list) == 0):
+			valueType = BinaryArray
+			list = append(list, strings.ToLower(tok.val))
+		default:
+			return nil, p.errorf(tok.pos, "list items must be all strings or all hex, got %s", tok.describe())
+		}
+	}
+	p.next()
+	if len(list) == 0 {
+		return nil, p.errorf(start.pos, "list can't be empty")
+	}
+	return &AstExpression{Type: Value, ValueType: valueType, Value: list}, nil
+}
+
+func (p *ruleParser) parseFunction(name ruleToken) (*AstExpression, error) {
+	args, ok := functionArguments[FunctionType(name.val)]
+	if !ok {
+		return nil, p.errorf(name.pos, "unknown function '%s'", name.val)
+	}
+	p.next()
+	exp := &AstExpression{Type: Function, Name: name.val}
+	for !p.isPunct(")") {
+		if len(exp.Arguments) > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+		tok := p.peek()
+		arg, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if arg.Type == Value {
+			if err = coerceFunctionValue(arg, args.valueType); err != nil {
+				return nil, p.errorf(tok.pos, "%s: %s", name.val, err.Error())
+			}
+			if args.valueType == BinaryArray {
+				if err = p.toAccountIds(arg); err != nil {
+					return nil, p.errorf(tok.pos, "%s: %s", name.val, err.Error())
+				}
+			}
+		}
+		exp.Arguments = append(exp.Arguments, arg)
+	}
+	p.next()
+	if len(exp.Arguments) == 0 || len(exp.Arguments) == 1 && exp.Arguments[0].Type == Value {
+		exp.Arguments = append(AstExpressions{{Type: Variable, Name: string(args.variable)}}, exp.Arguments...)
+	}
+	return exp, nil
+}
+
+// toAccountIds converts the sub-account names of the list to the account ids, as GenWitnessData expects
+func (p *ruleParser) toAccountIds(arg *AstExpression) error {
+	list := gconv.Strings(arg.Value)
+	for i, v := range list {
+		if strings.HasPrefix(v, common.HexPreFix) {
+			continue
+		}
+		if p.parentAccount == "" {
+			return fmt.Errorf("account name \"%s\" needs the parent account, use the account id instead", v)
+		}
+		list[i] = common.Bytes2Hex(common.GetAccountIdByAccount(fmt.Sprintf("%s.%s", v, p.parentAccount)))
+	}
+	arg.Value = list
+	return nil
+}
+
+// coerceFunctionValue converts the literal to the value type the function takes
+func coerceFunctionValue(arg *AstExpression, valueType ValueType) error {
+	switch valueType {
+	case StringArray, BinaryArray:
+		switch arg.ValueType {
+		case String, Binary:
+			arg.Value = []string{gconv.String(arg.Value)}
+		case StringArray, BinaryArray:
+		default:
+			return fmt.Errorf("value must be a string or a list of strings")
+		}
+		arg.ValueType = valueType
+	case Charset:
+		switch arg.ValueType {
+		case String:
+			charset, ok := charsetTypeByName(gconv.String(arg.Value))
+			if !ok {
+				return fmt.Errorf("unknown charset \"%s\"", gconv.String(arg.Value))
+			}
+			arg.Value = uint32(charset)
+		case Uint8, Uint32:
+			arg.Value = gconv.Uint32(arg.Value)
+		default:
+			return fmt.Errorf("value must be a charset name")
+		}
+		arg.ValueType = Charset
+	case Pattern:
+		if arg.ValueType != String && arg.ValueType != Pattern {
+			return fmt.Errorf("value must be a pattern string")
+		}
+		if _, err := CompileRulePattern(gconv.String(arg.Value)); err != nil {
+			return err
+		}
+		arg.ValueType = Pattern
+	case "":
+		return fmt.Errorf("function takes no value")
+	}
+	return nil
+}
+
+func charsetTypeByName(name string) (common.AccountCharType, bool) {
+	for k, v := range common.AccountCharTypeNameMap {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func charsetNameByType(charset common.AccountCharType) (string, bool) {
+	for k, v := range common.AccountCharTypeNameMap {
+		if v == charset {
+			return strings.ToLower(k), true
+		}
+	}
+	return "", false
+}
+
+const (
+	rulePrecedenceOr = iota + 1
+	rulePrecedenceAnd
+	rulePrecedenceNot
+	rulePrecedenceComparison
+	rulePrecedenceAdditive
+	rulePrecedenceMultiplicative
+	rulePrecedencePrimary
+)
+
+func (e *AstExpression) precedence() int {
+	if e.Type != Operator {
+		return rulePrecedencePrimary
+	}
+	switch e.Symbol {
+	case Or:
+		return rulePrecedenceOr
+	case And:
+		return rulePrecedenceAnd
+	case Not:
+		return rulePrecedenceNot
+	case Add, Sub:
+		return rulePrecedenceAdditive
+	case Mul, Div, Mod:
+		return rulePrecedenceMultiplicative
+	default:
+		return rulePrecedenceComparison
+	}
+}
+
+// String formats the expression in the text syntax, ParseAstExpression(e.String()) gives the same ast
+func (e *AstExpression) String() string {
+	var sb strings.Builder
+	e.format(&sb)
+	return sb.String()
+}
+
+func (e *AstExpression) format(sb *strings.Builder) {
+	switch e.Type {
+	case Operator:
+		switch e.Symbol {
+		case And, Or:
+			for i, v := range e.Expressions {
+				if i > 0 {
+					sb.WriteString(" " + string(e.Symbol) + " ")
+				}
+				v.formatOperand(sb, v.precedence() <= e.precedence())
+			}
+		case Not:
+			sb.WriteString("not ")
+			for _, v := range e.Expressions {
+				v.formatOperand(sb, v.Type == Operator && v.Symbol != Not)
+			}
+		case Add, Sub, Mul, Div, Mod:
+			for i, v := range e.Expressions {
+				if i > 0 {
+					sb.WriteString(" " + string(e.Symbol) + " ")
+				}
+				// left associative, the right operand of the same precedence needs parentheses
+				v.formatOperand(sb, v.precedence() < e.precedence() || i > 0 && v.precedence() == e.precedence())
+			}
+		default:
+			for i, v := range e.Expressions {
+				if i > 0 {
+					sb.WriteString(" " + string(e.Symbol) + " ")
+				}
+				v.formatOperand(sb, v.precedence() <= e.precedence())
+			}
+		}
+	case Function:
+		sb.WriteString(e.Name + "(")
+		args := e.Arguments
+		if v, ok := functionArguments[FunctionType(e.Name)]; ok && len(args) <= 2 && len(args) > 0 &&
+			args[0].Type == Variable && args[0].Name == string(v.variable) &&
+			(len(args) == 1 || args[1].Type == Value) {
+			args = args[1:]
+		}
+		for i, v := range args {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			v.format(sb)
+		}
+		sb.WriteString(")")
+	case Variable:
+		sb.WriteString(e.Name)
+	case Value:
+		e.formatValue(sb)
+	default:
+		sb.WriteString(fmt.Sprintf("<%s>", e.Type))
+	}
+}
+
+func (e *AstExpression) formatOperand(sb *strings.Builder, paren bool) {
+	if paren {
+		sb.WriteString("(")
+	}
+	e.format(sb)
+	if paren {
+		sb.WriteString(")")
+	}
+}
+
+func (e *AstExpression) formatValue(sb *strings.Builder) {
+	switch e.ValueType {
+	case Bool:
+		sb.WriteString(strconv.FormatBool(gconv.Bool(e.Value)))
+	case Uint8, Uint32, Uint64:
+		sb.WriteString(strconv.FormatUint(gconv.Uint64(e.Value), 10))
+	case Binary:
+		sb.WriteString(gconv.String(e.Value))
+	case String, Pattern:
+		sb.WriteString(quoteRuleString(gconv.String(e.Value)))
+	case StringArray, BinaryArray:
+		sb.WriteString("[")
+		for i, v := range gconv.Strings(e.Value) {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if e.ValueType == BinaryArray {
+				sb.WriteString(v)
+			} else {
+				sb.WriteString(quoteRuleString(v))
+			}
+		}
+		sb.WriteString("]")
+	case Charset:
+		if name, ok := charsetNameByType(common.AccountCharType(gconv.Uint32(e.Value))); ok {
+			sb.WriteString(quoteRuleString(name))
+		} else {
+			sb.WriteString(strconv.FormatUint(uint64(gconv.Uint32(e.Value)), 10))
+		}
+	default:
+		sb.WriteString(quoteRuleString(gconv.String(e.Value)))
+	}
+}
+
+// quoteRuleString keeps the emoji and other non-ascii characters readable
+func quoteRuleString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			sb.WriteString(fmt.Sprintf("\\x%02x", r))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
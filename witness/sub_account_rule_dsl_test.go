@@ -0,0 +1,180 @@
+package witness
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAstExpression(t *testing.T) {
+	exp, err := ParseAstExpression(`account_length <= 3 and only_include_charset("digit")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Operator, exp.Type)
+	assert.Equal(t, And, exp.Symbol)
+	assert.Len(t, exp.Expressions, 2)
+
+	cmp := exp.Expressions[0]
+	assert.Equal(t, Lte, cmp.Symbol)
+	assert.Equal(t, string(AccountLength), cmp.Expressions[0].Name)
+	assert.Equal(t, Uint32, cmp.Expressions[1].ValueType)
+	assert.EqualValues(t, 3, cmp.Expressions[1].Value)
+
+	fn := exp.Expressions[1]
+	assert.Equal(t, Function, fn.Type)
+	assert.Equal(t, string(AccountChars), fn.Arguments[0].Name)
+	assert.Equal(t, Charset, fn.Arguments[1].ValueType)
+	assert.EqualValues(t, common.AccountCharTypeDigit, fn.Arguments[1].Value)
+
+	assert.Equal(t, `account_length <= 3 and only_include_charset("digit")`, exp.String())
+}
+
+func TestAstExpressionStringRoundTrip(t *testing.T) {
+	list := []string{
+		`account_length <= 3 and only_include_charset("digit")`,
+		`not in_list([0x6ade4c435b8f3c4cf52336c9dd9dac71ed98520d, 0xa84c83477c8f43670e70cef260da053818d770a5]) or starts_with(["a", "b"]) and account_length == 5`,
+		`(account_length > 1 or account_length < 10) and not (account == "x")`,
+		`include_chars(["⚠️", "❌"]) and not not ends_with(["\"q\\"])`,
+		`(account_length > 1 and account_length < 10) and include_charset("zhhans")`,
+		`account_chars == ["a", "b"] or account == 0x01ab`,
+	}
+	for _, v := range list {
+		exp, err := ParseAstExpression(v)
+		if err != nil {
+			t.Fatal(v, err)
+		}
+		assert.Equal(t, v, exp.String())
+
+		data, err := json.Marshal(exp)
+		assert.NoError(t, err)
+		var fromJSON AstExpression
+		assert.NoError(t, json.Unmarshal(data, &fromJSON))
+		assert.Equal(t, v, fromJSON.String())
+	}
+
+	exp, err := ParseAstExpression(`include_charset(account_chars, 3)`)
+	assert.NoError(t, err)
+	assert.Equal(t, `include_charset("zhhans")`, exp.String())
+
+	exp, err = ParseAstExpression("starts_with(account, \"a\")\n\tor (ACCOUNT_LENGTH_X)")
+	assert.Nil(t, exp)
+	assert.EqualError(t, err, "line 2 column 6: unknown variable 'ACCOUNT_LENGTH_X'")
+}
+
+func TestParseAstExpressionError(t *testing.T) {
+	list := map[string]string{
+		`account_length <= `:                     "line 1 column 19: unexpected end of rule",
+		`account_length != 3`:                    "line 1 column 16: operator '!=' is not supported",
+		`1 < account_length < 3`:                 "line 1 column 20: comparison '<' can't be chained, use 'and'",
+		`only_include_charset("latin")`:          "line 1 column 22: only_include_charset: unknown charset \"latin\"",
+		`match("a")`:                             "line 1 column 1: unknown function 'match'",
+		`starts_with(["a", 0x01])`:               "line 1 column 19: list items must be all strings or all hex, got '0x01'",
+		`(account_length > 1`:                    "line 1 column 20: expected ')' but got end of rule",
+		`account == "abc`:                        "line 1 column 12: unterminated string",
+		`account_length > 1 account_length < 10`: "line 1 column 20: unexpected 'account_length'",
+		`account == 0x1`:                         "line 1 column 12: invalid hex '0x1'",
+		`in_list(["test"])`:                      "line 1 column 9: in_list: account name \"test\" needs the parent account, use the account id instead",
+	}
+	for text, msg := range list {
+		_, err := ParseAstExpression(text)
+		var syntaxErr *RuleSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatal(text, err)
+		}
+		assert.Equal(t, msg, err.Error(), text)
+	}
+}
+
+func TestSubAccountRuleEntity_AddRuleFromText(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit")
+	_, err := rule.AddRuleFromText("short", "", 100, `account_length <= 3`)
+	assert.NoError(t, err)
+	_, err = rule.AddRuleFromText("prefix", "", 10, `starts_with(["a", "b"]) and not ends_with("z")`)
+	assert.NoError(t, err)
+
+	// syntax is fine but include_chars takes account not account_chars
+	_, err = rule.AddRuleFromText("bad", "", 1, `include_chars(account_chars, ["a"])`)
+	assert.Error(t, err)
+	assert.Len(t, rule.Rules, 2)
+
+	hit, idx, err := rule.Hit("abc.test.bit")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, 0, idx)
+
+	hit, idx, err = rule.Hit("bcdef.test.bit")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, 1, idx)
+
+	hit, _, err = rule.Hit("bcdez.test.bit")
+	assert.NoError(t, err)
+	assert.False(t, hit)
+
+	_, err = rule.AddRuleFromText("reserved", "", 0, `in_list(["reserved"])`)
+	assert.NoError(t, err)
+	hit, idx, err = rule.Hit("reserved.test.bit")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, 2, idx)
+
+	witnessData, err := rule.GenWitnessData(common.ActionDataTypeSubAccountPriceRules)
+	assert.NoError(t, err)
+	decoded := NewSubAccountRuleEntity("test.bit")
+	assert.NoError(t, decoded.ParseFromDasActionWitnessData(witnessData))
+	assert.Equal(t, `account_length <= 3`, decoded.Rules[0].Ast.String())
+	assert.Equal(t, `starts_with(["a", "b"]) and not ends_with(["z"])`, decoded.Rules[1].Ast.String())
+
+	hit, idx, err = decoded.Hit("reserved.test.bit")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, 2, idx)
+	assert.Equal(t, rule.Rules[2].Ast.String(), decoded.Rules[2].Ast.String())
+}
+
+func TestAstExpressionStringRoundTripDecoded(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit", SubAccountRuleVersionV2)
+	for i, v := range []string{
+		`account_length <= 3 and only_include_charset("digit")`,
+		`not in_list(["test", "reverse"]) or starts_with(["a", "b"]) and account_length == 5`,
+		`in_list(account, [0x6ade4c435b8f3c4cf52336c9dd9dac71ed98520d])`,
+		`include_chars(["⚠️", "❌"]) and not ends_with("z")`,
+		`account_chars == ["a", "b"] or include_charset("zhhans")`,
+		`account_length % 2 == 0 and digit_count() >= account_length - 1 and matches_pattern("[a-c]*9")`,
+	} {
+		if _, err := rule.AddRuleFromText(fmt.Sprintf("rule%d", i), "", 1, v); err != nil {
+			t.Fatal(v, err)
+		}
+	}
+	witnessData, err := rule.GenWitnessData(common.ActionDataTypeSubAccountPriceRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewSubAccountRuleEntity("test.bit", SubAccountRuleVersionV2)
+	if err = decoded.ParseFromDasActionWitnessData(witnessData); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `not in_list([0x6ade4c435b8f3c4cf52336c9dd9dac71ed98520d, 0xa84c83477c8f43670e70cef260da053818d770a5]) `+
+		`or starts_with(["a", "b"]) and account_length == 5`, decoded.Rules[1].Ast.String())
+
+	parsed := NewSubAccountRuleEntity("test.bit", SubAccountRuleVersionV2)
+	for _, v := range decoded.Rules {
+		exp, err := ParseAstExpression(v.Ast.String())
+		if err != nil {
+			t.Fatal(v.Ast.String(), err)
+		}
+		want, _ := json.Marshal(v.Ast)
+		got, _ := json.Marshal(exp)
+		assert.JSONEq(t, string(want), string(got), v.Ast.String())
+		if _, err = parsed.AddRuleFromText(v.Name, v.Note, v.Price, v.Ast.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parsedData, err := parsed.GenWitnessData(common.ActionDataTypeSubAccountPriceRules)
+	assert.NoError(t, err)
+	assert.Equal(t, witnessData, parsedData)
+}
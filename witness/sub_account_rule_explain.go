@@ -0,0 +1,390 @@
+package witness
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/gogf/gf/v2/util/gconv"
+	"sort"
+	"strings"
+)
+
+// RuleTrace is the evaluation of one expression, Children are the operands of and, or, not
+type RuleTrace struct {
+	Expression string       `json:"expression"`
+	Hit        bool         `json:"hit"`
+	Detail     string       `json:"detail,omitempty"`
+	Children   []*RuleTrace `json:"children,omitempty"`
+}
+
+type RuleExplain struct {
+	Index   int        `json:"index"`
+	Name    string     `json:"name"`
+	Price   float64    `json:"price"`
+	Enabled bool       `json:"enabled"`
+	Hit     bool       `json:"hit"` // result of the expression, disabled rules are evaluated too
+	Trace   *RuleTrace `json:"trace"`
+}
+
+type SubAccountRuleExplain struct {
+	Account       string         `json:"account"`
+	AccountLength int            `json:"account_length"`
+	AccountChars  []string       `json:"account_chars"`
+	Hit           bool           `json:"hit"`
+	Index         int            `json:"index"` // the rule Hit returns, -1 if no rule hit
+	Rules         []*RuleExplain `json:"rules"`
+}
+
+// Explain evaluates every rule against the account and returns the trace of the evaluation
+func (s *SubAccountRuleEntity) Explain(account string) (*SubAccountRuleExplain, error) {
+	if s.ParentAccount == "" {
+		return nil, ParentAccountError
+	}
+	account = strings.Split(account, ".")[0]
+	res := &SubAccountRuleExplain{
+		Account:       account,
+		AccountLength: len([]rune(account)),
+		AccountChars:  strings.Split(account, ""),
+		Index:         -1,
+	}
+	for idx, v := range s.Rules {
+		v.Ast.subAccountRuleEntity = s
+		trace, err := v.Ast.explain(account)
+		if err != nil {
+			return nil, fmt.Errorf("rule[%d] %s", idx, err.Error())
+		}
+		res.Rules = append(res.Rules, &RuleExplain{
+			Index:   idx,
+			Name:    v.Name,
+			Price:   v.Price,
+			Enabled: v.Status != 0,
+			Hit:     trace.Hit,
+			Trace:   trace,
+		})
+		if !res.Hit && v.Status != 0 && trace.Hit {
+			res.Hit, res.Index = true, idx
+		}
+	}
+	return res, nil
+}
+
+func (e *AstExpression) explain(account string) (*RuleTrace, error) {
+	trace := &RuleTrace{Expression: e.String()}
+	if e.Type != Operator || (e.Symbol != And && e.Symbol != Or && e.Symbol != Not) {
+		hit, err := e.Check(true, account)
+		if err != nil {
+			return nil, err
+		}
+		trace.Hit = hit
+		if e.Type == Operator && len(e.Expressions) == 2 {
+			trace.Detail = fmt.Sprintf("%s %s %s", e.Expressions[0].explainValue(account), e.Symbol, e.Expressions[1].explainValue(account))
+		}
+		return trace, nil
+	}
+
+	// operands are all evaluated to show which of them matched
+	if e.Symbol == Not && len(e.Expressions) != 1 {
+		return nil, fmt.Errorf("operator not must have one expression")
+	}
+	trace.Hit = e.Symbol == And
+	for _, v := range e.Expressions {
+		v.subAccountRuleEntity = e.subAccountRuleEntity
+		child, err := v.explain(account)
+		if err != nil {
+			return nil, err
+		}
+		trace.Children = append(trace.Children, child)
+		switch e.Symbol {
+		case And:
+			trace.Hit = trace.Hit && child.Hit
+		case Or:
+			trace.Hit = trace.Hit || child.Hit
+		case Not:
+			trace.Hit = !child.Hit
+		}
+	}
+	return trace, nil
+}
+
+func (e *AstExpression) explainValue(account string) string {
+	switch e.ReturnType() {
+	case ReturnTypeNumber:
+		return gconv.String(e.GetNumberValue(account))
+	case ReturnTypeString:
+		return quoteRuleString(e.GetStringValue(account))
+	case ReturnTypeStringArray:
+		list := make([]string, 0)
+		for _, v := range e.GetStringArray(account) {
+			list = append(list, quoteRuleString(v))
+		}
+		return "[" + strings.Join(list, ", ") + "]"
+	default:
+		return e.String()
+	}
+}
+
+type RuleIssueType string
+
+const (
+	RuleIssueNeverMatch    RuleIssueType = "never_match"
+	RuleIssueUnreachable   RuleIssueType = "unreachable"
+	RuleIssuePriceConflict RuleIssueType = "price_conflict"
+	RuleIssueUnresolvedIds RuleIssueType = "unresolved_ids"
+)
+
+type RuleIssue struct {
+	Type    RuleIssueType `json:"type"`
+	Index   int           `json:"index"`
+	Other   int           `json:"other"`             // the earlier rule involved, -1 if none
+	Account string        `json:"account,omitempty"` // an account showing the issue
+	Msg     string        `json:"msg"`
+}
+
+// Analyze reports the enabled rules that can never match, rules shadowed by the rules before them,
+// and rules matching the same account with different prices.
+// The rules are checked against accounts generated from the lengths, words and char sets used by the rules,
+// so the result is reliable for rules written with these, but it is not a proof for every possible account.
+// The account ids of in_list are resolved by knownAccounts (sub-account names without the parent account),
+// a rule with ids not resolved is reported by RuleIssueUnresolvedIds and never by RuleIssueNeverMatch
+func (s *SubAccountRuleEntity) Analyze(knownAccounts ...string) ([]*RuleIssue, error) {
+	if s.ParentAccount == "" {
+		return nil, ParentAccountError
+	}
+	samples, unresolved, err := s.sampleAccounts(knownAccounts)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([][]bool, len(s.Rules))
+	for idx, v := range s.Rules {
+		if v.Status == 0 {
+			continue
+		}
+		v.Ast.subAccountRuleEntity = s
+		hits[idx] = make([]bool, len(samples))
+		for i, account := range samples {
+			if hits[idx][i], err = v.Ast.Check(true, account); err != nil {
+				return nil, fmt.Errorf("rule[%d] %s", idx, err.Error())
+			}
+		}
+	}
+
+	issues := make([]*RuleIssue, 0)
+	for j, rule := range s.Rules {
+		if hits[j] == nil {
+			continue
+		}
+		reachable, matched := -1, -1
+		shadowedBy := make([]int, 0)
+		for i := range samples {
+			if !hits[j][i] {
+				continue
+			}
+			if matched < 0 {
+				matched = i
+			}
+			first := firstHit(hits, j, i)
+			if first < 0 {
+				reachable = i
+				break
+			}
+			shadowedBy = append(shadowedBy, first)
+		}
+		if unresolved[j] > 0 {
+			issues = append(issues, &RuleIssue{
+				Type:  RuleIssueUnresolvedIds,
+				Index: j,
+				Other: -1,
+				Msg:   fmt.Sprintf("rule[%d] %s lists %d account ids not in the known accounts, they are not checked", j, rule.Name, unresolved[j]),
+			})
+		}
+		switch {
+		case matched < 0 && unresolved[j] > 0:
+			continue
+		case matched < 0:
+			issues = append(issues, &RuleIssue{
+				Type:  RuleIssueNeverMatch,
+				Index: j,
+				Other: -1,
+				Msg:   fmt.Sprintf("rule[%d] %s matches no account", j, rule.Name),
+			})
+			continue
+		case reachable < 0:
+			issue := &RuleIssue{Type: RuleIssueUnreachable, Index: j, Other: -1, Account: samples[matched]}
+			issue.Msg = fmt.Sprintf("rule[%d] %s is shadowed by the rules before it", j, rule.Name)
+			if other := sameIndex(shadowedBy); other >= 0 {
+				issue.Other = other
+				issue.Msg = fmt.Sprintf("rule[%d] %s is shadowed by rule[%d] %s", j, rule.Name, other, s.Rules[other].Name)
+			}
+			issues = append(issues, issue)
+			continue
+		}
+
+		for i := 0; i < j; i++ {
+			if hits[i] == nil || s.Rules[i].Price == rule.Price {
+				continue
+			}
+			for k, account := range samples {
+				if hits[i][k] && hits[j][k] {
+					issues = append(issues, &RuleIssue{
+						Type:    RuleIssuePriceConflict,
+						Index:   j,
+						Other:   i,
+						Account: account,
+						Msg: fmt.Sprintf("account %s matches rule[%d] %s with price %v and rule[%d] %s with price %v, rule[%d] is used",
+							account, i, s.Rules[i].Name, s.Rules[i].Price, j, rule.Name, rule.Price, i),
+					})
+					break
+				}
+			}
+		}
+	}
+	return issues, nil
+}
+
+func firstHit(hits [][]bool, before, sample int) int {
+	for i := 0; i < before; i++ {
+		if hits[i] != nil && hits[i][sample] {
+			return i
+		}
+	}
+	return -1
+}
+
+func sameIndex(list []int) int {
+	if len(list) == 0 {
+		return -1
+	}
+	for _, v := range list {
+		if v != list[0] {
+			return -1
+		}
+	}
+	return list[0]
+}
+
+// sampleAccounts generates the accounts around the boundaries of the rules,
+// the account ids of in_list are resolved by knownAccounts and the generated accounts,
+// unresolved is the number of the ids not resolved of each rule
+func (s *SubAccountRuleEntity) sampleAccounts(knownAccounts []string) (samples []string, unresolved map[int]int, err error) {
+	ruleIdx := 0
+	listIds := make(map[int][]string)
+	lengths := map[int]struct{}{}
+	for i := 1; i <= 10; i++ {
+		lengths[i] = struct{}{}
+	}
+	fillers := []string{"a", "1"}
+	pieces := make([]string, 0)
+	accounts := make([]string, 0)
+
+	var walk func(e *AstExpression) error
+	walk = func(e *AstExpression) error {
+		for _, v := range append(append(AstExpressions{}, e.Expressions...), e.Arguments...) {
+			if err := walk(v); err != nil {
+				return err
+			}
+		}
+		if e.Type == Function && FunctionType(e.Name) == FunctionEmojiCount {
+			fillers = append(fillers, "😀")
+		}
+		if e.Type != Value {
+			return nil
+		}
+		switch e.ValueType {
+		case Uint8, Uint32, Uint64:
+			if n := gconv.Int(e.Value); n < 1000 {
+				for _, l := range []int{n - 1, n, n + 1} {
+					if l > 0 {
+						lengths[l] = struct{}{}
+					}
+				}
+			}
+		case String:
+			pieces = append(pieces, gconv.String(e.Value))
+		case StringArray:
+			pieces = append(pieces, gconv.Strings(e.Value)...)
+		case BinaryArray:
+			for _, v := range gconv.Strings(e.Value) {
+				listIds[ruleIdx] = append(listIds[ruleIdx], strings.ToLower(v))
+			}
+		case Pattern:
+			if pattern, err := CompileRulePattern(gconv.String(e.Value)); err == nil {
+				pieces = append(pieces, pattern.Sample('a'))
+			}
+		case Charset:
+			charset := common.AccountCharType(gconv.Uint32(e.Value))
+			chars := make([]string, 0)
+			for k := range common.AccountCharTypeMap[charset] {
+				chars = append(chars, k)
+			}
+			if len(chars) == 0 {
+				return fmt.Errorf("char set %d is not loaded", charset)
+			}
+			sort.Strings(chars)
+			fillers = append(fillers, chars[0])
+		}
+		return nil
+	}
+	for i, v := range s.Rules {
+		if v.Status == 0 {
+			continue
+		}
+		ruleIdx = i
+		if err := walk(&v.Ast); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, v := range pieces {
+		lengths[len([]rune(v))] = struct{}{}
+	}
+
+	for l := range lengths {
+		for _, f := range fillers {
+			accounts = append(accounts, strings.Repeat(f, l))
+			for _, f2 := range fillers {
+				if f2 != f {
+					accounts = append(accounts, f+strings.Repeat(f2, l-1))
+				}
+			}
+			for _, p := range pieces {
+				if n := l - len([]rune(p)); n >= 0 {
+					accounts = append(accounts, p+strings.Repeat(f, n), strings.Repeat(f, n)+p)
+				}
+			}
+		}
+	}
+	for _, p := range pieces {
+		for _, p2 := range pieces {
+			accounts = append(accounts, p+p2, p+fillers[0]+p2)
+		}
+	}
+
+	accountIds := make(map[string]string, len(accounts)+len(knownAccounts))
+	for _, v := range append(accounts, knownAccounts...) {
+		accountIds[common.Bytes2Hex(common.GetAccountIdByAccount(v+"."+s.ParentAccount))] = v
+	}
+	unresolved = make(map[int]int)
+	for idx, ids := range listIds {
+		for _, v := range ids {
+			if account, ok := accountIds[v]; ok {
+				accounts = append(accounts, account)
+			} else {
+				unresolved[idx]++
+			}
+		}
+	}
+
+	exist := map[string]struct{}{}
+	res := make([]string, 0, len(accounts))
+	for _, v := range accounts {
+		if _, ok := exist[v]; ok || v == "" {
+			continue
+		}
+		exist[v] = struct{}{}
+		res = append(res, v)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		li, lj := len([]rune(res[i])), len([]rune(res[j]))
+		return li < lj || li == lj && res[i] < res[j]
+	})
+	return res, unresolved, nil
+}
@@ -0,0 +1,149 @@
+package witness
+
+import (
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSubAccountRuleEntity_Explain(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit")
+	for _, v := range []struct {
+		name  string
+		price float64
+		text  string
+	}{
+		{"short", 100, `account_length <= 3`},
+		{"ab", 10, `(starts_with("a") or starts_with("b")) and not ends_with("z")`},
+		{"others", 1, `account_length > 0`},
+	} {
+		if _, err := rule.AddRuleFromText(v.name, "", v.price, v.text); err != nil {
+			t.Fatal(v.name, err)
+		}
+	}
+
+	res, err := rule.Explain("bcdef.test.bit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "bcdef", res.Account)
+	assert.Equal(t, 5, res.AccountLength)
+	assert.Equal(t, []string{"b", "c", "d", "e", "f"}, res.AccountChars)
+	assert.True(t, res.Hit)
+	assert.Equal(t, 1, res.Index)
+
+	assert.False(t, res.Rules[0].Hit)
+	assert.Equal(t, "5 <= 3", res.Rules[0].Trace.Detail)
+
+	trace := res.Rules[1].Trace
+	assert.True(t, trace.Hit)
+	or := trace.Children[0]
+	assert.Equal(t, `starts_with(["a"]) or starts_with(["b"])`, or.Expression)
+	assert.False(t, or.Children[0].Hit)
+	assert.True(t, or.Children[1].Hit)
+	assert.True(t, trace.Children[1].Hit)
+	assert.True(t, res.Rules[2].Hit)
+
+	// or must not hit when none of its operands hit
+	res, err = rule.Explain("xyzxyz")
+	assert.NoError(t, err)
+	assert.False(t, res.Rules[1].Trace.Children[0].Hit)
+	assert.Equal(t, 2, res.Index)
+	hit, idx, err := rule.Hit("xyzxyz")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, 2, idx)
+
+	rule.Rules[2].Status = 0
+	res, err = rule.Explain("xyzxyz")
+	assert.NoError(t, err)
+	assert.False(t, res.Hit)
+	assert.Equal(t, -1, res.Index)
+	assert.True(t, res.Rules[2].Hit)
+}
+
+func TestSubAccountRuleEntity_Analyze(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit")
+	for _, v := range []struct {
+		name  string
+		price float64
+		text  string
+	}{
+		{"short", 100, `account_length <= 3`},
+		{"two", 50, `account_length == 2`},
+		{"a", 10, `starts_with("a")`},
+		{"impossible", 10, `account_length > 5 and account_length < 4`},
+		{"others", 1, `account_length >= 1`},
+		{"vip", 1, `in_list(["vip"]) or ends_with("vip")`},
+	} {
+		if _, err := rule.AddRuleFromText(v.name, "", v.price, v.text); err != nil {
+			t.Fatal(v.name, err)
+		}
+	}
+
+	issues, err := rule.Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range issues {
+		t.Log(v.Type, v.Msg)
+	}
+	type issueKey struct {
+		Type         RuleIssueType
+		Index, Other int
+		Account      string
+	}
+	keys := make([]issueKey, 0)
+	for _, v := range issues {
+		keys = append(keys, issueKey{v.Type, v.Index, v.Other, v.Account})
+	}
+	assert.Equal(t, []issueKey{
+		{RuleIssueUnreachable, 1, 0, "11"},
+		{RuleIssuePriceConflict, 2, 0, "a"},
+		{RuleIssuePriceConflict, 2, 1, "a1"},
+		{RuleIssueNeverMatch, 3, -1, ""},
+		{RuleIssuePriceConflict, 4, 0, "1"},
+		{RuleIssuePriceConflict, 4, 1, "11"},
+		{RuleIssuePriceConflict, 4, 2, "a"},
+		{RuleIssueUnreachable, 5, -1, "vip"},
+	}, keys)
+	assert.Equal(t, "rule[1] two is shadowed by rule[0] short", issues[0].Msg)
+	assert.Equal(t, "rule[3] impossible matches no account", issues[3].Msg)
+
+	rule.Rules[4].Status = 0
+	issues, err = rule.Analyze()
+	assert.NoError(t, err)
+	assert.Equal(t, RuleIssuePriceConflict, issues[len(issues)-1].Type)
+	assert.Equal(t, 5, issues[len(issues)-1].Index)
+}
+
+func TestSubAccountRuleEntity_AnalyzeAccountIds(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit")
+	accountId := common.Bytes2Hex(common.GetAccountIdByAccount("alice.test.bit"))
+	if _, err := rule.AddRuleFromText("white", "", 10, `in_list(["`+accountId+`"])`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rule.AddRuleFromText("others", "", 1, `account_length >= 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := rule.Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, RuleIssueUnresolvedIds, issues[0].Type)
+		assert.Equal(t, 0, issues[0].Index)
+		assert.Equal(t, "rule[0] white lists 1 account ids not in the known accounts, they are not checked", issues[0].Msg)
+	}
+
+	issues, err = rule.Analyze("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, RuleIssuePriceConflict, issues[0].Type)
+		assert.Equal(t, 1, issues[0].Index)
+		assert.Equal(t, "alice", issues[0].Account)
+	}
+}
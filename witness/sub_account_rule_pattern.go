@@ -0,0 +1,145 @@
+package witness
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the pattern of matches_pattern is a restricted glob matched against the whole account:
+//   ?      any single char
+//   *      any chars, including none
+//   [abc]  one of the chars, ranges like [a-z0-9] are allowed
+//   \x     the char x itself
+// there is no regex, and the matching takes at most len(pattern)*len(account) steps
+
+const RulePatternMaxLength = 64
+
+type rulePatternItemType int
+
+const (
+	rulePatternChar rulePatternItemType = iota
+	rulePatternAny
+	rulePatternStar
+	rulePatternClass
+)
+
+type rulePatternItem struct {
+	typ    rulePatternItemType
+	char   rune
+	ranges [][2]rune
+}
+
+type RulePattern struct {
+	pattern string
+	items   []rulePatternItem
+}
+
+func CompileRulePattern(pattern string) (*RulePattern, error) {
+	chars := []rune(pattern)
+	if len(chars) == 0 {
+		return nil, errors.New("pattern can't be empty")
+	}
+	if len(chars) > RulePatternMaxLength {
+		return nil, fmt.Errorf("pattern length can't be more than %d", RulePatternMaxLength)
+	}
+	p := &RulePattern{pattern: pattern}
+	for i := 0; i < len(chars); i++ {
+		switch chars[i] {
+		case '?':
+			p.items = append(p.items, rulePatternItem{typ: rulePatternAny})
+		case '*':
+			if len(p.items) > 0 && p.items[len(p.items)-1].typ == rulePatternStar {
+				continue
+			}
+			p.items = append(p.items, rulePatternItem{typ: rulePatternStar})
+		case '\\':
+			if i++; i >= len(chars) {
+				return nil, fmt.Errorf("pattern %s ends with '\\'", pattern)
+			}
+			p.items = append(p.items, rulePatternItem{typ: rulePatternChar, char: chars[i]})
+		case '[':
+			item := rulePatternItem{typ: rulePatternClass}
+			start := i
+			for i++; i < len(chars) && chars[i] != ']'; i++ {
+				from := chars[i]
+				if i+2 < len(chars) && chars[i+1] == '-' && chars[i+2] != ']' {
+					if chars[i+2] < from {
+						return nil, fmt.Errorf("pattern %s range %c-%c is invalid", pattern, from, chars[i+2])
+					}
+					item.ranges = append(item.ranges, [2]rune{from, chars[i+2]})
+					i += 2
+					continue
+				}
+				item.ranges = append(item.ranges, [2]rune{from, from})
+			}
+			if i >= len(chars) {
+				return nil, fmt.Errorf("pattern %s '[' at %d is not closed", pattern, start)
+			}
+			if len(item.ranges) == 0 {
+				return nil, fmt.Errorf("pattern %s '[]' at %d is empty", pattern, start)
+			}
+			p.items = append(p.items, item)
+		case ']':
+			return nil, fmt.Errorf("pattern %s ']' at %d is not opened", pattern, i)
+		default:
+			p.items = append(p.items, rulePatternItem{typ: rulePatternChar, char: chars[i]})
+		}
+	}
+	return p, nil
+}
+
+func (p *RulePattern) String() string {
+	return p.pattern
+}
+
+func (item *rulePatternItem) match(c rune) bool {
+	switch item.typ {
+	case rulePatternAny:
+		return true
+	case rulePatternChar:
+		return item.char == c
+	case rulePatternClass:
+		for _, v := range item.ranges {
+			if c >= v[0] && c <= v[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sample returns an account matching the pattern, the wildcards are filled by filler
+func (p *RulePattern) Sample(filler rune) string {
+	res := make([]rune, 0, len(p.items))
+	for _, item := range p.items {
+		switch item.typ {
+		case rulePatternChar:
+			res = append(res, item.char)
+		case rulePatternAny:
+			res = append(res, filler)
+		case rulePatternClass:
+			res = append(res, item.ranges[0][0])
+		}
+	}
+	return string(res)
+}
+
+// Match reports whether the whole account matches the pattern
+func (p *RulePattern) Match(account string) bool {
+	chars := []rune(account)
+	// matched[j]: the items so far match chars[:j]
+	matched := make([]bool, len(chars)+1)
+	matched[0] = true
+	for _, item := range p.items {
+		next := make([]bool, len(chars)+1)
+		for j := 0; j <= len(chars); j++ {
+			if item.typ == rulePatternStar {
+				next[j] = matched[j] || j > 0 && next[j-1]
+			} else if j > 0 {
+				next[j] = matched[j-1] && item.match(chars[j-1])
+			}
+		}
+		matched = next
+	}
+	return matched[len(chars)]
+}
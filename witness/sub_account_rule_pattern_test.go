@@ -0,0 +1,125 @@
+package witness
+
+import (
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRulePattern(t *testing.T) {
+	list := []struct {
+		pattern string
+		account string
+		match   bool
+	}{
+		{"a*", "abc", true},
+		{"a*", "bac", false},
+		{"*", "", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"[a-c]*9", "b0009", true},
+		{"[a-c]*9", "d0009", false},
+		{"[0-9][0-9]", "42", true},
+		{"*😀*", "a😀b", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"a**b", "ab", true},
+		{strings.Repeat("*a", 30), strings.Repeat("a", 29) + "b", false},
+	}
+	for _, v := range list {
+		p, err := CompileRulePattern(v.pattern)
+		if err != nil {
+			t.Fatal(v.pattern, err)
+		}
+		assert.Equal(t, v.match, p.Match(v.account), v.pattern, v.account)
+		if v.match && !strings.Contains(v.pattern, `\`) {
+			assert.True(t, p.Match(p.Sample('x')), v.pattern)
+		}
+	}
+
+	for _, v := range []string{"", "[a-", "[]", "a]", `a\`, "[c-a]", strings.Repeat("a", RulePatternMaxLength+1)} {
+		_, err := CompileRulePattern(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestSubAccountRuleV2Functions(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit")
+	_, err := rule.AddRuleFromText("even", "", 1, `account_length % 2 == 0`)
+	assert.EqualError(t, err, "rule even needs version 2")
+
+	rule = NewSubAccountRuleEntity("test.bit", SubAccountRuleVersionV2)
+	for _, v := range []struct {
+		name string
+		text string
+	}{
+		{"pattern", `matches_pattern("[a-c]*9")`},
+		{"palindrome", `is_palindrome() and account_length >= 3`},
+		{"repeat", `repeat_char_count() >= 4`},
+		{"digits", `digit_count() * 2 > account_length`},
+		{"emoji", `emoji_count() == account_length - 1`},
+		{"even", `account_length % 2 == 0 and account_length / 2 >= 3`},
+	} {
+		if _, err = rule.AddRuleFromText(v.name, "", 1, v.text); err != nil {
+			t.Fatal(v.name, err)
+		}
+	}
+	assert.Equal(t, `digit_count() * 2 > account_length`, rule.Rules[3].Ast.String())
+	assert.Equal(t, `emoji_count() == account_length - 1`, rule.Rules[4].Ast.String())
+
+	for account, index := range map[string]int{
+		"cat9":    0,
+		"level":   1,
+		"xaaaay":  2,
+		"x12":     3,
+		"😀😀x":     4,
+		"xyzxyz":  5,
+		"xy":      -1,
+		"xyzxyza": -1,
+	} {
+		hit, idx, err := rule.Hit(account)
+		assert.NoError(t, err)
+		if index < 0 {
+			assert.False(t, hit, account)
+			continue
+		}
+		assert.True(t, hit, account)
+		assert.Equal(t, index, idx, account)
+	}
+
+	for _, v := range []string{
+		`account_length / 0 == 1`,
+		`account_length % digit_count() == 1`,
+		`digit_count()`,
+		`digit_count(account_chars) > 1`,
+		`is_palindrome("abc")`,
+	} {
+		exp, err := ParseAstExpression(v)
+		if err != nil {
+			continue
+		}
+		bad := NewSubAccountRuleEntity("test.bit", SubAccountRuleVersionV2)
+		bad.Rules = append(bad.Rules, &SubAccountRule{Name: "bad", Ast: *exp, Status: 1})
+		assert.Error(t, bad.Check(), v)
+	}
+
+	witnessData, err := rule.GenWitnessData(common.ActionDataTypeSubAccountPriceRules)
+	assert.NoError(t, err)
+	decoded := NewSubAccountRuleEntity("test.bit")
+	assert.EqualError(t, decoded.ParseFromDasActionWitnessData(witnessData), "version aberrant")
+	decoded = NewSubAccountRuleEntity("test.bit", SubAccountRuleVersionV2)
+	assert.NoError(t, decoded.ParseFromDasActionWitnessData(witnessData))
+	for i, v := range decoded.Rules {
+		assert.Equal(t, rule.Rules[i].Ast.String(), v.Ast.String())
+	}
+	hit, idx, err := decoded.Hit("xyzxyz")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, 5, idx)
+
+	// a version 1 witness can't carry the new functions
+	rule.Version = SubAccountRuleVersionV1
+	_, err = rule.GenWitnessData(common.ActionDataTypeSubAccountPriceRules)
+	assert.Error(t, err)
+}
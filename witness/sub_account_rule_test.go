@@ -567,3 +567,87 @@ func TestSubAccountRule_EndsWith(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, hit)
 }
+
+func TestSubAccountRule_OrNotHit(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit")
+	err := rule.ParseFromJSON([]byte(`
+{
+    "version": 1,
+    "rules": [
+        {
+            "name": "or",
+            "note": "",
+            "price": 1,
+            "ast": {
+                "type": "operator",
+                "symbol": "or",
+                "expressions": [
+                    {
+                        "type": "function",
+                        "name": "starts_with",
+                        "arguments": [
+                            {"type": "variable", "name": "account"},
+                            {"type": "value", "value_type": "string[]", "value": ["a"]}
+                        ]
+                    },
+                    {
+                        "type": "function",
+                        "name": "ends_with",
+                        "arguments": [
+                            {"type": "variable", "name": "account"},
+                            {"type": "value", "value_type": "string[]", "value": ["z"]}
+                        ]
+                    }
+                ]
+            },
+			"status": 1
+        }
+    ]
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hit, _, err := rule.Hit("test")
+	assert.NoError(t, err)
+	assert.False(t, hit)
+
+	hit, _, err = rule.Hit("xyz")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+}
+
+func TestSubAccountRule_AccountCharsEqual(t *testing.T) {
+	rule := NewSubAccountRuleEntity("test.bit")
+	err := rule.ParseFromJSON([]byte(`
+{
+    "version": 1,
+    "rules": [
+        {
+            "name": "account_chars",
+            "note": "",
+            "price": 1,
+            "ast": {
+                "type": "operator",
+                "symbol": "==",
+                "expressions": [
+                    {"type": "variable", "name": "account_chars"},
+                    {"type": "value", "value_type": "string[]", "value": ["a", "你", "b"]}
+                ]
+            },
+			"status": 1
+        }
+    ]
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hit, _, err := rule.Hit("a你b")
+	assert.NoError(t, err)
+	assert.True(t, hit)
+
+	hit, _, err = rule.Hit("ab你")
+	assert.NoError(t, err)
+	assert.False(t, hit)
+}
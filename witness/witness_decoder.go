@@ -0,0 +1,264 @@
+package witness
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"sync"
+)
+
+var (
+	ErrWitnessDecoderNotExist = errors.New("witness decoder not exist")
+	ErrWitnessTypeMismatch    = errors.New("witness type mismatch")
+)
+
+// WitnessEntity is what a decoder receives.
+// For the witnesses wrapped by molecule.Data, Bys is the raw data of DataEntity.Entity,
+// otherwise Bys is the witness without the das header and Version, Index are 0
+type WitnessEntity struct {
+	DataType      common.DataType
+	Version       uint32
+	Index         uint32
+	DataEntityOpt *molecule.DataEntityOpt
+	Bys           []byte
+}
+
+type WitnessDecodeFunc func(entity *WitnessEntity) (interface{}, error)
+type WitnessViewFunc func(obj interface{}) interface{}
+
+type WitnessDecoder struct {
+	Name   string
+	Decode WitnessDecodeFunc
+	View   WitnessViewFunc // optional, the decoded obj is used as the json view when it is nil
+}
+
+// WitnessDecoderKey Version 0 matches all versions which have no decoder registered
+type WitnessDecoderKey struct {
+	ActionDataType common.ActionDataType
+	DataType       common.DataType
+	Version        uint32
+}
+
+type witnessDecoderRegistry struct {
+	rw       sync.RWMutex
+	decoders map[WitnessDecoderKey]*WitnessDecoder
+	wrapped  map[common.ActionDataType]bool // wrapped by molecule.Data
+}
+
+var decoderRegistry = witnessDecoderRegistry{
+	decoders: make(map[WitnessDecoderKey]*WitnessDecoder),
+	wrapped:  make(map[common.ActionDataType]bool),
+}
+
+// RegisterWitnessDecoder registers or replaces the decoder of key,
+// all keys of one ActionDataType should be either common.DataTypeNone or not
+func RegisterWitnessDecoder(key WitnessDecoderKey, decoder WitnessDecoder) {
+	decoderRegistry.rw.Lock()
+	defer decoderRegistry.rw.Unlock()
+	decoderRegistry.decoders[key] = &decoder
+	decoderRegistry.wrapped[key.ActionDataType] = key.DataType != common.DataTypeNone
+}
+
+// RegisterDataEntityDecoder registers the decoder for new, old and dep data entity of actionDataType
+func RegisterDataEntityDecoder(actionDataType common.ActionDataType, version uint32, decoder WitnessDecoder) {
+	for _, dataType := range []common.DataType{common.DataTypeNew, common.DataTypeOld, common.DataTypeDep} {
+		RegisterWitnessDecoder(WitnessDecoderKey{ActionDataType: actionDataType, DataType: dataType, Version: version}, decoder)
+	}
+}
+
+func getWitnessDecoder(key WitnessDecoderKey) (*WitnessDecoder, bool) {
+	decoderRegistry.rw.RLock()
+	defer decoderRegistry.rw.RUnlock()
+	if decoder, ok := decoderRegistry.decoders[key]; ok {
+		return decoder, true
+	}
+	key.Version = 0
+	decoder, ok := decoderRegistry.decoders[key]
+	return decoder, ok
+}
+
+func isWrappedWitness(actionDataType common.ActionDataType) (wrapped, ok bool) {
+	decoderRegistry.rw.RLock()
+	defer decoderRegistry.rw.RUnlock()
+	wrapped, ok = decoderRegistry.wrapped[actionDataType]
+	return
+}
+
+type DecodedWitness struct {
+	WitnessIndex   int                   `json:"witness_index"`
+	ActionDataType common.ActionDataType `json:"action_data_type"`
+	DataType       common.DataType       `json:"data_type"`
+	Name           string                `json:"name"`
+	Version        uint32                `json:"version"`
+	Index          uint32                `json:"index"`
+	Obj            interface{}           `json:"-"`
+	view           WitnessViewFunc
+}
+
+// View returns the typed json view of Obj
+func (d *DecodedWitness) View() interface{} {
+	if d.view == nil {
+		return d.Obj
+	}
+	return d.view(d.Obj)
+}
+
+func (d *DecodedWitness) ToWitnessView() WitnessView {
+	return WitnessView{
+		Name:     d.Name,
+		DataType: dataTypeName(d.DataType),
+		Version:  d.Version,
+		Index:    d.Index,
+		Data:     d.View(),
+	}
+}
+
+type WitnessView struct {
+	Name     string      `json:"name"`
+	DataType string      `json:"data_type,omitempty"`
+	Version  uint32      `json:"version"`
+	Index    uint32      `json:"index"`
+	Data     interface{} `json:"data"`
+}
+
+func dataTypeName(dataType common.DataType) string {
+	switch dataType {
+	case common.DataTypeNew:
+		return "new"
+	case common.DataTypeOld:
+		return "old"
+	case common.DataTypeDep:
+		return "dep"
+	}
+	return ""
+}
+
+// DecodeWitness decodes a das witness, one DecodedWitness for each of new, old and dep when it is wrapped by molecule.Data
+func DecodeWitness(witnessByte []byte) ([]*DecodedWitness, error) {
+	actionDataType := ParserWitnessAction(witnessByte)
+	if actionDataType == "" {
+		return nil, fmt.Errorf("not a das witness")
+	}
+	return decodeWitnessData(actionDataType, witnessByte[common.WitnessDasTableTypeEndIndex:], -1)
+}
+
+func decodeWitnessData(actionDataType common.ActionDataType, dataBys []byte, witnessIndex int) ([]*DecodedWitness, error) {
+	wrapped, ok := isWrappedWitness(actionDataType)
+	if !ok {
+		return nil, ErrWitnessDecoderNotExist
+	}
+	if !wrapped {
+		decoder, ok := getWitnessDecoder(WitnessDecoderKey{ActionDataType: actionDataType, DataType: common.DataTypeNone})
+		if !ok {
+			return nil, ErrWitnessDecoderNotExist
+		}
+		obj, err := decoder.Decode(&WitnessEntity{DataType: common.DataTypeNone, Bys: dataBys})
+		if err != nil {
+			return nil, fmt.Errorf("decode %s err: %s", decoder.Name, err.Error())
+		}
+		return []*DecodedWitness{{
+			WitnessIndex:   witnessIndex,
+			ActionDataType: actionDataType,
+			DataType:       common.DataTypeNone,
+			Name:           decoder.Name,
+			Obj:            obj,
+			view:           decoder.View,
+		}}, nil
+	}
+
+	var list []*DecodedWitness
+	for _, dataType := range []common.DataType{common.DataTypeNew, common.DataTypeOld, common.DataTypeDep} {
+		dataEntityOpt, dataEntity, err := getDataEntityOpt(dataBys, dataType)
+		if err != nil {
+			if err == ErrDataEntityOptIsNil {
+				continue
+			}
+			return nil, fmt.Errorf("getDataEntityOpt err: %s", err.Error())
+		}
+		version, err := molecule.Bytes2GoU32(dataEntity.Version().RawData())
+		if err != nil {
+			return nil, fmt.Errorf("get version err: %s", err.Error())
+		}
+		index, err := molecule.Bytes2GoU32(dataEntity.Index().RawData())
+		if err != nil {
+			return nil, fmt.Errorf("get index err: %s", err.Error())
+		}
+		decoder, ok := getWitnessDecoder(WitnessDecoderKey{ActionDataType: actionDataType, DataType: dataType, Version: version})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s-%d-%d", ErrWitnessDecoderNotExist, actionDataType, dataType, version)
+		}
+		obj, err := decoder.Decode(&WitnessEntity{
+			DataType:      dataType,
+			Version:       version,
+			Index:         index,
+			DataEntityOpt: dataEntityOpt,
+			Bys:           dataEntity.Entity().RawData(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("decode %s err: %s", decoder.Name, err.Error())
+		}
+		list = append(list, &DecodedWitness{
+			WitnessIndex:   witnessIndex,
+			ActionDataType: actionDataType,
+			DataType:       dataType,
+			Name:           decoder.Name,
+			Version:        version,
+			Index:          index,
+			Obj:            obj,
+			view:           decoder.View,
+		})
+	}
+	return list, nil
+}
+
+// DecodeWitnessFromTx decodes all das witnesses which have decoders registered
+func DecodeWitnessFromTx(tx *types.Transaction) ([]*DecodedWitness, error) {
+	var list []*DecodedWitness
+	err := GetWitnessDataFromTx(tx, func(actionDataType common.ActionDataType, dataBys []byte, index int) (bool, error) {
+		res, err := decodeWitnessData(actionDataType, dataBys, index)
+		if err != nil {
+			if err == ErrWitnessDecoderNotExist {
+				return true, nil
+			}
+			return false, fmt.Errorf("decodeWitnessData err: %s", err.Error())
+		}
+		list = append(list, res...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetWitnessDataFromTx err: %s", err.Error())
+	}
+	return list, nil
+}
+
+// DecodeWitnessFromTxAs returns the decoded objs of actionDataType and dataType in tx as T,
+// e.g. DecodeWitnessFromTxAs[*AccountCellDataBuilder](tx, common.ActionDataTypeAccountCell, common.DataTypeNew)
+func DecodeWitnessFromTxAs[T any](tx *types.Transaction, actionDataType common.ActionDataType, dataType common.DataType) ([]T, error) {
+	var list []T
+	err := GetWitnessDataFromTx(tx, func(adt common.ActionDataType, dataBys []byte, index int) (bool, error) {
+		if adt != actionDataType {
+			return true, nil
+		}
+		res, err := decodeWitnessData(adt, dataBys, index)
+		if err != nil {
+			return false, fmt.Errorf("decodeWitnessData err: %w", err)
+		}
+		for _, v := range res {
+			if v.DataType != dataType {
+				continue
+			}
+			obj, ok := v.Obj.(T)
+			if !ok {
+				return false, fmt.Errorf("%w: %T", ErrWitnessTypeMismatch, v.Obj)
+			}
+			list = append(list, obj)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetWitnessDataFromTx err: %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,392 @@
+package witness
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func init() {
+	RegisterWitnessDecoder(WitnessDecoderKey{ActionDataType: common.ActionDataTypeActionData, DataType: common.DataTypeNone}, WitnessDecoder{
+		Name:   "ActionData",
+		Decode: decodeActionData,
+		View:   viewActionData,
+	})
+
+	accountCellDecoder := WitnessDecoder{Name: "AccountCell", Decode: decodeAccountCell, View: viewAccountCell}
+	for _, version := range []uint32{0, common.GoDataEntityVersion1, common.GoDataEntityVersion2, common.GoDataEntityVersion3} {
+		RegisterDataEntityDecoder(common.ActionDataTypeAccountCell, version, accountCellDecoder)
+	}
+	accountSaleCellDecoder := WitnessDecoder{Name: "AccountSaleCell", Decode: decodeAccountSaleCell, View: viewAccountSaleCell}
+	for _, version := range []uint32{0, common.GoDataEntityVersion1} {
+		RegisterDataEntityDecoder(common.ActionDataTypeAccountSaleCell, version, accountSaleCellDecoder)
+	}
+	RegisterDataEntityDecoder(common.ActionDataTypeIncomeCell, 0, WitnessDecoder{
+		Name:   "IncomeCell",
+		Decode: decodeIncomeCell,
+		View:   viewIncomeCell,
+	})
+	RegisterDataEntityDecoder(common.ActionDataTypeKeyListCfgCell, 0, WitnessDecoder{
+		Name:   "KeyListCfgCell",
+		Decode: decodeKeyListCfgCell,
+		View:   viewKeyListCfgCell,
+	})
+
+	RegisterWitnessDecoder(WitnessDecoderKey{ActionDataType: common.ActionDataTypeSubAccount, DataType: common.DataTypeNone}, WitnessDecoder{
+		Name:   "SubAccount",
+		Decode: decodeSubAccount,
+		View:   viewSubAccount,
+	})
+	for actionDataType, name := range map[common.ActionDataType]string{
+		common.ActionDataTypeSubAccountMintSign:            "SubAccountMintSign",
+		common.ActionDataTypeSubAccountRenewSign:           "SubAccountRenewSign",
+		common.ActionDataTypeSubAccountCreateApprovalSign:  "SubAccountCreateApprovalSign",
+		common.ActionDataTypeSubAccountDelayApprovalSign:   "SubAccountDelayApprovalSign",
+		common.ActionDataTypeSubAccountRevokeApprovalSign:  "SubAccountRevokeApprovalSign",
+		common.ActionDataTypeSubAccountFulfillApprovalSign: "SubAccountFulfillApprovalSign",
+	} {
+		RegisterWitnessDecoder(WitnessDecoderKey{ActionDataType: actionDataType, DataType: common.DataTypeNone}, WitnessDecoder{
+			Name:   name,
+			Decode: decodeSubAccountMintSign,
+			View:   viewSubAccountMintSign,
+		})
+	}
+	RegisterWitnessDecoder(WitnessDecoderKey{ActionDataType: common.ActionDataTypeReverseSmt, DataType: common.DataTypeNone}, WitnessDecoder{
+		Name:   "ReverseSmt",
+		Decode: decodeReverseSmt,
+		View:   viewReverseSmt,
+	})
+	RegisterWitnessDecoder(WitnessDecoderKey{ActionDataType: common.ActionDataTypeDPOrderInfo, DataType: common.DataTypeNone}, WitnessDecoder{
+		Name:   "DPOrderInfo",
+		Decode: decodeDPOrderInfo,
+	})
+}
+
+// === ActionData ===
+
+type ActionDataView struct {
+	Action     common.DasAction `json:"action"`
+	ActionHash string           `json:"action_hash"`
+	Params     string           `json:"params"`
+}
+
+func decodeActionData(entity *WitnessEntity) (interface{}, error) {
+	var builder ActionDataBuilder
+	if err := builder.ConvertToActionData(entity.Bys); err != nil {
+		return nil, err
+	}
+	return &builder, nil
+}
+
+func viewActionData(obj interface{}) interface{} {
+	builder := obj.(*ActionDataBuilder)
+	return ActionDataView{
+		Action:     builder.Action,
+		ActionHash: common.Bytes2Hex(builder.ActionData.Action().RawData()),
+		Params:     builder.ParamsStr,
+	}
+}
+
+// === AccountCell ===
+
+type AccountCellView struct {
+	WitnessHash           string   `json:"witness_hash"`
+	AccountId             string   `json:"account_id"`
+	Account               string   `json:"account"`
+	Status                uint8    `json:"status"`
+	RegisteredAt          uint64   `json:"registered_at"`
+	UpdatedAt             uint64   `json:"updated_at,omitempty"`
+	LastTransferAccountAt uint64   `json:"last_transfer_account_at"`
+	LastEditManagerAt     uint64   `json:"last_edit_manager_at"`
+	LastEditRecordsAt     uint64   `json:"last_edit_records_at"`
+	EnableSubAccount      uint8    `json:"enable_sub_account"`
+	RenewSubAccountPrice  uint64   `json:"renew_sub_account_price"`
+	Records               []Record `json:"records"`
+}
+
+func decodeAccountCell(entity *WitnessEntity) (interface{}, error) {
+	builder := AccountCellDataBuilder{
+		Index:         entity.Index,
+		Version:       entity.Version,
+		DataEntityOpt: entity.DataEntityOpt,
+	}
+	var err error
+	switch entity.Version {
+	case common.GoDataEntityVersion1:
+		err = builder.ConvertToAccountCellDataV1(entity.Bys)
+	case common.GoDataEntityVersion2:
+		err = builder.ConvertToAccountCellDataV2(entity.Bys)
+	case common.GoDataEntityVersion3:
+		err = builder.ConvertToAccountCellDataV3(entity.Bys)
+	default:
+		err = builder.ConvertToAccountCellData(entity.Bys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &builder, nil
+}
+
+func viewAccountCell(obj interface{}) interface{} {
+	builder := obj.(*AccountCellDataBuilder)
+	var slice []byte
+	switch {
+	case builder.AccountCellDataV1 != nil:
+		slice = builder.AccountCellDataV1.AsSlice()
+	case builder.AccountCellDataV2 != nil:
+		slice = builder.AccountCellDataV2.AsSlice()
+	case builder.AccountCellDataV3 != nil:
+		slice = builder.AccountCellDataV3.AsSlice()
+	case builder.AccountCellData != nil:
+		slice = builder.AccountCellData.AsSlice()
+	}
+	return AccountCellView{
+		WitnessHash:           common.Bytes2Hex(common.Blake2b(slice)),
+		AccountId:             builder.AccountId,
+		Account:               builder.Account,
+		Status:                builder.Status,
+		RegisteredAt:          builder.RegisteredAt,
+		UpdatedAt:             builder.UpdatedAt,
+		LastTransferAccountAt: builder.LastTransferAccountAt,
+		LastEditManagerAt:     builder.LastEditManagerAt,
+		LastEditRecordsAt:     builder.LastEditRecordsAt,
+		EnableSubAccount:      builder.EnableSubAccount,
+		RenewSubAccountPrice:  builder.RenewSubAccountPrice,
+		Records:               builder.Records,
+	}
+}
+
+// === AccountSaleCell ===
+
+type AccountSaleCellView struct {
+	AccountId              string `json:"account_id"`
+	Account                string `json:"account"`
+	Description            string `json:"description"`
+	Price                  uint64 `json:"price"`
+	StartedAt              uint64 `json:"started_at"`
+	BuyerInviterProfitRate uint32 `json:"buyer_inviter_profit_rate"`
+}
+
+func decodeAccountSaleCell(entity *WitnessEntity) (interface{}, error) {
+	builder := AccountSaleCellDataBuilder{
+		Index:         entity.Index,
+		Version:       entity.Version,
+		DataEntityOpt: entity.DataEntityOpt,
+	}
+	var err error
+	if entity.Version == common.GoDataEntityVersion1 {
+		err = builder.ConvertToAccountSaleCellDataV1(entity.Bys)
+	} else {
+		err = builder.ConvertToAccountSaleCellData(entity.Bys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &builder, nil
+}
+
+func viewAccountSaleCell(obj interface{}) interface{} {
+	builder := obj.(*AccountSaleCellDataBuilder)
+	return AccountSaleCellView{
+		AccountId:              builder.AccountId,
+		Account:                builder.Account,
+		Description:            builder.Description,
+		Price:                  builder.Price,
+		StartedAt:              builder.StartedAt,
+		BuyerInviterProfitRate: builder.BuyerInviterProfitRate,
+	}
+}
+
+// === IncomeCell ===
+
+type IncomeCellView struct {
+	Creator *types.Script          `json:"creator"`
+	Records []IncomeCellRecordView `json:"records"`
+}
+
+type IncomeCellRecordView struct {
+	BelongTo *types.Script `json:"belong_to"`
+	Capacity uint64        `json:"capacity"`
+}
+
+func decodeIncomeCell(entity *WitnessEntity) (interface{}, error) {
+	incomeCellData, err := molecule.IncomeCellDataFromSlice(entity.Bys, true)
+	if err != nil {
+		return nil, fmt.Errorf("IncomeCellDataFromSlice err: %s", err.Error())
+	}
+	return &IncomeCellDataBuilder{
+		Index:          entity.Index,
+		Version:        entity.Version,
+		IncomeCellData: incomeCellData,
+		DataEntityOpt:  entity.DataEntityOpt,
+	}, nil
+}
+
+func viewIncomeCell(obj interface{}) interface{} {
+	builder := obj.(*IncomeCellDataBuilder)
+	var view IncomeCellView
+	if creator := builder.Creator(); creator != nil {
+		view.Creator = molecule.MoleculeScript2CkbScript(creator)
+	}
+	for _, v := range builder.Records() {
+		view.Records = append(view.Records, IncomeCellRecordView{
+			BelongTo: molecule.MoleculeScript2CkbScript(v.BelongTo),
+			Capacity: v.Capacity,
+		})
+	}
+	return view
+}
+
+// === KeyListCfgCell ===
+
+type KeyListCfgView struct {
+	Keys       []WebauthnKey `json:"keys"`
+	RefundLock *types.Script `json:"refund_lock"`
+}
+
+func decodeKeyListCfgCell(entity *WitnessEntity) (interface{}, error) {
+	deviceKeyListCellData, err := molecule.DeviceKeyListCellDataFromSlice(entity.Bys, true)
+	if err != nil {
+		return nil, fmt.Errorf("DeviceKeyListCellDataFromSlice err: %s", err.Error())
+	}
+	return &WebAuthnKeyListDataBuilder{
+		WebauthnKeyList:       ConvertToWebauthnKeyList(deviceKeyListCellData.Keys()),
+		Index:                 entity.Index,
+		Version:               entity.Version,
+		DeviceKeyListCellData: deviceKeyListCellData,
+		DataEntityOpt:         entity.DataEntityOpt,
+	}, nil
+}
+
+func viewKeyListCfgCell(obj interface{}) interface{} {
+	builder := obj.(*WebAuthnKeyListDataBuilder)
+	return KeyListCfgView{
+		Keys:       builder.WebauthnKeyList,
+		RefundLock: molecule.MoleculeScript2CkbScript(builder.DeviceKeyListCellData.RefundLock()),
+	}
+}
+
+// === SubAccount ===
+
+type SubAccountView struct {
+	Action        string          `json:"action"`
+	Version       uint32          `json:"version"`
+	Account       string          `json:"account"`
+	Signature     string          `json:"signature"`
+	SignRole      string          `json:"sign_role"`
+	SignExpiredAt uint64          `json:"sign_expired_at"`
+	PrevRoot      string          `json:"prev_root,omitempty"`
+	CurrentRoot   string          `json:"current_root,omitempty"`
+	NewRoot       string          `json:"new_root"`
+	Proof         string          `json:"proof"`
+	EditKey       common.EditKey  `json:"edit_key"`
+	EditValue     interface{}     `json:"edit_value"`
+	SubAccount    *SubAccountData `json:"sub_account"`
+}
+
+func decodeSubAccount(entity *WitnessEntity) (interface{}, error) {
+	var builder SubAccountNewBuilder
+	return builder.ConvertSubAccountNewFromBytes(entity.Bys)
+}
+
+func viewSubAccount(obj interface{}) interface{} {
+	subAccount := obj.(*SubAccountNew)
+	view := SubAccountView{
+		Action:        subAccount.Action,
+		Version:       subAccount.Version,
+		Account:       subAccount.Account,
+		Signature:     common.Bytes2Hex(subAccount.Signature),
+		SignRole:      common.Bytes2Hex(subAccount.SignRole),
+		SignExpiredAt: subAccount.SignExpiredAt,
+		NewRoot:       common.Bytes2Hex(subAccount.NewRoot),
+		Proof:         common.Bytes2Hex(subAccount.Proof),
+		EditKey:       subAccount.EditKey,
+		SubAccount:    subAccount.SubAccountData,
+	}
+	if len(subAccount.PrevRoot) > 0 {
+		view.PrevRoot = common.Bytes2Hex(subAccount.PrevRoot)
+		view.CurrentRoot = common.Bytes2Hex(subAccount.CurrentRoot)
+	}
+	if subAccount.EditKey == common.EditKeyRecords {
+		view.EditValue = subAccount.EditRecords
+	} else if len(subAccount.EditValue) > 0 {
+		view.EditValue = common.Bytes2Hex(subAccount.EditValue)
+	}
+	return view
+}
+
+// === SubAccountMintSign ===
+
+type SubAccountMintSignView struct {
+	Version            uint32 `json:"version"`
+	Signature          string `json:"signature"`
+	SignRole           string `json:"sign_role"`
+	ExpiredAt          uint64 `json:"expired_at"`
+	AccountListSmtRoot string `json:"account_list_smt_root"`
+}
+
+func decodeSubAccountMintSign(entity *WitnessEntity) (interface{}, error) {
+	var builder SubAccountNewBuilder
+	return builder.ConvertSubAccountMintSignFromBytes(entity.Bys)
+}
+
+func viewSubAccountMintSign(obj interface{}) interface{} {
+	mintSign := obj.(*SubAccountMintSign)
+	return SubAccountMintSignView{
+		Version:            mintSign.Version,
+		Signature:          common.Bytes2Hex(mintSign.Signature),
+		SignRole:           common.Bytes2Hex(mintSign.SignRole),
+		ExpiredAt:          mintSign.ExpiredAt,
+		AccountListSmtRoot: common.Bytes2Hex(mintSign.AccountListSmtRoot),
+	}
+}
+
+// === ReverseSmt ===
+
+type ReverseSmtView struct {
+	Version     ReverseSmtRecordVersion `json:"version"`
+	Action      ReverseSmtRecordAction  `json:"action"`
+	Signature   string                  `json:"signature"`
+	SignType    uint8                   `json:"sign_type"`
+	Address     string                  `json:"address"`
+	Proof       string                  `json:"proof"`
+	PrevNonce   uint32                  `json:"prev_nonce"`
+	PrevAccount string                  `json:"prev_account"`
+	NextRoot    string                  `json:"next_root"`
+	NextAccount string                  `json:"next_account"`
+}
+
+func decodeReverseSmt(entity *WitnessEntity) (interface{}, error) {
+	var record ReverseSmtRecord
+	if err := ParseFromBytes(entity.Bys, &record); err != nil {
+		return nil, fmt.Errorf("ParseFromBytes err: %s", err.Error())
+	}
+	return &record, nil
+}
+
+func viewReverseSmt(obj interface{}) interface{} {
+	record := obj.(*ReverseSmtRecord)
+	return ReverseSmtView{
+		Version:     record.Version,
+		Action:      record.Action,
+		Signature:   common.Bytes2Hex(record.Signature),
+		SignType:    record.SignType,
+		Address:     common.Bytes2Hex(record.Address),
+		Proof:       common.Bytes2Hex(record.Proof),
+		PrevNonce:   record.PrevNonce,
+		PrevAccount: record.PrevAccount,
+		NextRoot:    common.Bytes2Hex(record.NextRoot),
+		NextAccount: record.NextAccount,
+	}
+}
+
+// === DPOrderInfo ===
+
+func decodeDPOrderInfo(entity *WitnessEntity) (interface{}, error) {
+	info, err := ConvertDPOrderInfoWitness(entity.Bys)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,84 @@
+package witness
+
+import (
+	"errors"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/molecule"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"testing"
+)
+
+func TestDecodeWitnessFromTx(t *testing.T) {
+	actionWitness, err := GenActionDataWitness(common.DasActionEditRecords, common.Hex2Bytes(common.ParamOwner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dpWitness, _, err := GenDPOrderInfoWitness(DPOrderInfo{OrderId: "order-1", Action: DPActionMint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &types.Transaction{Witnesses: [][]byte{actionWitness, dpWitness, {0x01}}}
+
+	list, err := DecodeWitnessFromTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "ActionData" || list[1].Name != "DPOrderInfo" {
+		t.Fatal("unexpected decoded witnesses", len(list))
+	}
+	if view, ok := list[0].View().(ActionDataView); !ok || view.Action != common.DasActionEditRecords {
+		t.Fatal("unexpected action data view", list[0].View())
+	}
+
+	infoList, err := DecodeWitnessFromTxAs[*DPOrderInfo](tx, common.ActionDataTypeDPOrderInfo, common.DataTypeNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infoList) != 1 || infoList[0].OrderId != "order-1" {
+		t.Fatal("unexpected dp order info", infoList)
+	}
+	if _, err = DecodeWitnessFromTxAs[*AccountCellDataBuilder](tx, common.ActionDataTypeDPOrderInfo, common.DataTypeNone); !errors.Is(err, ErrWitnessTypeMismatch) {
+		t.Fatal("want ErrWitnessTypeMismatch, got", err)
+	}
+}
+
+func TestRegisterWitnessDecoder(t *testing.T) {
+	actionDataType := common.ActionDataType("0xffff0000")
+	entity := molecule.NewDataEntityBuilder().
+		Entity(molecule.GoBytes2MoleculeBytes([]byte("v2"))).
+		Version(molecule.GoU32ToMoleculeU32(2)).
+		Index(molecule.GoU32ToMoleculeU32(3)).
+		Build()
+	entityOpt := molecule.NewDataEntityOptBuilder().Set(entity).Build()
+	data := molecule.NewDataBuilder().New(entityOpt).Build()
+	witness := GenDasDataWitness(actionDataType, &data)
+
+	if _, err := DecodeWitness(witness); err != ErrWitnessDecoderNotExist {
+		t.Fatal("want ErrWitnessDecoderNotExist, got", err)
+	}
+
+	RegisterDataEntityDecoder(actionDataType, 0, WitnessDecoder{
+		Name: "Test",
+		Decode: func(e *WitnessEntity) (interface{}, error) {
+			return "any:" + string(e.Bys), nil
+		},
+	})
+	RegisterDataEntityDecoder(actionDataType, 2, WitnessDecoder{
+		Name: "TestV2",
+		Decode: func(e *WitnessEntity) (interface{}, error) {
+			return "v2:" + string(e.Bys), nil
+		},
+	})
+	list, err := DecodeWitness(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "TestV2" || list[0].Index != 3 || list[0].DataType != common.DataTypeNew || list[0].Obj != "v2:v2" {
+		t.Fatal("unexpected decoded witness", list)
+	}
+
+	res := ParserWitnessData(witness).(map[string]interface{})
+	if res["name"] != "TestV2" {
+		t.Fatal("unexpected parser result", res)
+	}
+}
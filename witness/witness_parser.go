@@ -154,8 +154,25 @@ func ParserWitnessData(witnessByte []byte) interface{} {
 		return ParserConfigCellTypeArgsCharSetVn(witnessByte)
 
 	default:
+		return parserRegisteredWitness(witnessByte)
+	}
+}
+
+// parserRegisteredWitness parses the witnesses plugged in by RegisterWitnessDecoder
+func parserRegisteredWitness(witnessByte []byte) interface{} {
+	list, err := DecodeWitness(witnessByte)
+	if err != nil || len(list) == 0 {
 		return parserDefaultWitness(witnessByte)
 	}
+	data := make([]WitnessView, 0, len(list))
+	for _, v := range list {
+		data = append(data, v.ToWitnessView())
+	}
+	return map[string]interface{}{
+		"witness": common.Bytes2Hex(witnessByte),
+		"name":    list[0].Name,
+		"data":    data,
+	}
 }
 
 func parserDefaultWitness(witnessByte []byte) interface{} {
@@ -1517,8 +1534,7 @@ func ParseFromBytesV2(data []byte, obj interface{}) error {
 }
 
 func ParseFromBytes(data []byte, obj interface{}) error {
-	index, indexLen, dataLen := uint32(0), uint32(4), uint32(0)
-	if int(indexLen) > len(data) {
+	if int(molecule.HeaderSizeUint) > len(data) {
 		return fmt.Errorf("data length error: %d", len(data))
 	}
 	if obj == nil {
@@ -1534,24 +1550,21 @@ func ParseFromBytes(data []byte, obj interface{}) error {
 	}
 	v = v.Elem()
 
-	var err error
+	r := NewLVReader(data)
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Field(i)
 		if !f.CanInterface() {
 			return fmt.Errorf("field: %s can't Interface()", f)
 		}
 
-		dataLen, err = molecule.Bytes2GoU32(data[index : index+indexLen])
+		dataBs, err := r.Next(v.Type().Field(i).Name)
 		if err != nil {
 			return err
 		}
-		if dataLen == 0 {
-			index = index + indexLen
+		if len(dataBs) == 0 {
 			continue
 		}
 
-		dataBs := data[index+indexLen : index+indexLen+dataLen]
-
 		if f.Type().Implements(TypeOfDasWitness) {
 			if f.IsNil() {
 				return fmt.Errorf("field: %s receive type not specified", f)
@@ -1561,7 +1574,6 @@ func ParseFromBytes(data []byte, obj interface{}) error {
 				return err
 			}
 			f.Set(reflect.ValueOf(value))
-			index = index + indexLen + dataLen
 			continue
 		}
 
@@ -1598,7 +1610,6 @@ func ParseFromBytes(data []byte, obj interface{}) error {
 		case reflect.String:
 			f.Set(reflect.ValueOf(string(dataBs)).Convert(f.Type()))
 		}
-		index = index + indexLen + dataLen
 	}
 	return nil
 }